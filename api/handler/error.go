@@ -10,20 +10,47 @@ var (
 	LoginFailedError           = EmontirError{Code: "AUTH-400-02", Message: "incorrect password" + "/" + "email"}
 	ActivationEmailFailedError = EmontirError{Code: "AUTH-400-03", Message: "activation email failed"}
 	ActivationLinkExpired      = EmontirError{Code: "AUTH-400-04", Message: "email activation link expired"}
+	PasswordResetTokenInvalid  = EmontirError{Code: "AUTH-400-05", Message: "password reset link is invalid or expired"}
 	UnauthorizedError          = EmontirError{Code: "AUTH-401-01", Message: "token invalid"}
+	InvalidPaymentSignature    = EmontirError{Code: "AUTH-401-02", Message: "invalid payment notification signature"}
+	RefreshTokenInvalid        = EmontirError{Code: "AUTH-401-03", Message: "refresh token invalid"}
+	ForbiddenError             = EmontirError{Code: "AUTH-403-01", Message: "access denied"}
 	EmailNotActivatedError     = EmontirError{Code: "AUTH-422-01", Message: "email not verified"}
 	ParsePayloadError          = EmontirError{Code: "SERVER-400-01", Message: "failed to parse payload"}
 	// nolint(gosec) // false positive
-	CartAppointmentAvailable    = EmontirError{Code: "SERVER-400-02", Message: "appointment is exists, remove appointment before change appointment date or time"}
-	NoEmployeeError             = EmontirError{Code: "SERVER-400-03", Message: "no employee available"}
-	OrderHasBeenPaid            = EmontirError{Code: "SERVER-400-04", Message: "order has been paid"}
-	ServiceIsReviewed           = EmontirError{Code: "SERVER-400-05", Message: "service has been reviewed"}
-	ServiceIsAlreadyFav         = EmontirError{Code: "SERVER-400-06", Message: "service is already in the favorite list"}
-	ServiceNotExists            = EmontirError{Code: "SERVER-404-01", Message: "service not exists"}
-	CartAppointmentNotAvailable = EmontirError{Code: "SERVER-404-02", Message: "appointment not exists"}
-	OrderNotExists              = EmontirError{Code: "SERVER-404-03", Message: "cannot make payment to not exist order"}
-	FavServiceNotExists         = EmontirError{Code: "SERVER-404-04", Message: "favorite service not exists"}
-	InternalServerError         = EmontirError{Code: "SERVER-500-01", Message: "server error"}
+	CartAppointmentAvailable     = EmontirError{Code: "SERVER-400-02", Message: "appointment is exists, remove appointment before change appointment date or time"}
+	NoEmployeeError              = EmontirError{Code: "SERVER-400-03", Message: "no employee available"}
+	OrderHasBeenPaid             = EmontirError{Code: "SERVER-400-04", Message: "order has been paid"}
+	ServiceIsReviewed            = EmontirError{Code: "SERVER-400-05", Message: "service has been reviewed"}
+	ServiceIsAlreadyFav          = EmontirError{Code: "SERVER-400-06", Message: "service is already in the favorite list"}
+	InvalidOrderStatusTransition = EmontirError{Code: "SERVER-400-07", Message: "order status transition is not allowed"}
+	OrderCannotBeCancelled       = EmontirError{Code: "SERVER-400-08", Message: "order cannot be cancelled once mechanic is on the way"}
+	OrderPaymentExpired          = EmontirError{Code: "SERVER-400-09", Message: "payment deadline of the order has passed"}
+	PaymentAmountMismatch        = EmontirError{Code: "SERVER-400-10", Message: "paid amount does not match the order total price"}
+	OrderCannotBeRefunded        = EmontirError{Code: "SERVER-400-11", Message: "only paid orders which are cancelled or done can be refunded"}
+	RefundAlreadyRequested       = EmontirError{Code: "SERVER-400-12", Message: "refund of the order has been requested"}
+	JobNotAccepted               = EmontirError{Code: "SERVER-400-13", Message: "job has to be accepted first"}
+	JobCannotBeAccepted          = EmontirError{Code: "SERVER-400-14", Message: "job has been accepted or no longer waits for a mechanic"}
+	MechanicAccountExists        = EmontirError{Code: "SERVER-400-15", Message: "mechanic already has an account"}
+	OrderNotTrackable            = EmontirError{Code: "SERVER-400-16", Message: "order can only be tracked while it is being processed"}
+	ChatClosed                   = EmontirError{Code: "SERVER-400-17", Message: "chat is only open while a mechanic handles the order"}
+	MechanicIsReviewed           = EmontirError{Code: "SERVER-400-18", Message: "mechanic of the order has been reviewed"}
+	OrderNotCompleted            = EmontirError{Code: "SERVER-400-19", Message: "order has to be done before it can be reviewed"}
+	ReviewEditWindowClosed       = EmontirError{Code: "SERVER-400-20", Message: "review can no longer be changed"}
+	ReviewModerationConflict     = EmontirError{Code: "SERVER-400-21", Message: "review is already in the requested state"}
+	AttachmentLimitReached       = EmontirError{Code: "SERVER-400-22", Message: "no more photos can be attached"}
+	OrderAttachmentClosed        = EmontirError{Code: "SERVER-400-23", Message: "photos can only be attached once the mechanic has arrived"}
+	ServiceNotExists             = EmontirError{Code: "SERVER-404-01", Message: "service not exists"}
+	CartAppointmentNotAvailable  = EmontirError{Code: "SERVER-404-02", Message: "appointment not exists"}
+	OrderNotExists               = EmontirError{Code: "SERVER-404-03", Message: "cannot make payment to not exist order"}
+	FavServiceNotExists          = EmontirError{Code: "SERVER-404-04", Message: "favorite service not exists"}
+	OrderNotFound                = EmontirError{Code: "SERVER-404-05", Message: "order not exists"}
+	MechanicNotFound             = EmontirError{Code: "SERVER-404-06", Message: "mechanic not exists"}
+	MediaNotFound                = EmontirError{Code: "SERVER-404-07", Message: "file not exists"}
+	ReviewNotFound               = EmontirError{Code: "SERVER-404-08", Message: "review not exists"}
+	DeviceNotFound               = EmontirError{Code: "SERVER-404-09", Message: "device not exists"}
+	NotificationNotFound         = EmontirError{Code: "SERVER-404-10", Message: "notification not exists"}
+	InternalServerError          = EmontirError{Code: "SERVER-500-01", Message: "server error"}
 )
 
 const (
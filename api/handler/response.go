@@ -62,7 +62,17 @@ func ResponseError(w http.ResponseWriter, err error) {
 			GenerateResponse(w, http.StatusInternalServerError, res)
 			return
 		}
-		if code == ServiceNotExists.Code || code == CartAppointmentNotAvailable.Code || code == OrderNotExists.Code {
+		if code == InvalidPaymentSignature.Code || code == RefreshTokenInvalid.Code {
+			GenerateResponse(w, http.StatusUnauthorized, res)
+			return
+		}
+		if code == ForbiddenError.Code {
+			GenerateResponse(w, http.StatusForbidden, res)
+			return
+		}
+		if code == ServiceNotExists.Code || code == CartAppointmentNotAvailable.Code || code == OrderNotExists.Code ||
+			code == OrderNotFound.Code || code == MechanicNotFound.Code || code == MediaNotFound.Code ||
+			code == ReviewNotFound.Code || code == DeviceNotFound.Code || code == NotificationNotFound.Code {
 			GenerateResponse(w, http.StatusNotFound, res)
 			return
 		}
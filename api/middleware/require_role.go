@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"e-montir/api/handler"
+	"net/http"
+)
+
+// RequireRole only lets the request through when the role claim of the token is one of roles,
+// it has to be placed after ValidateToken.
+func RequireRole(roles ...string) func(next http.Handler) http.Handler {
+	allowed := make(map[string]bool, len(roles))
+	for _, role := range roles {
+		allowed[role] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claim := handler.GetTokenClaim(r.Context())
+			if !allowed[claim.Role] {
+				handler.GenerateResponse(w, http.StatusForbidden, handler.ForbiddenError)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"context"
+	"e-montir/pkg/jwt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireRole(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	requireRole := RequireRole("admin", "mechanic")(next)
+
+	tt := []struct {
+		Name       string
+		Role       string
+		StatusCode int
+	}{
+		{
+			Name:       "Admin allowed",
+			Role:       "admin",
+			StatusCode: http.StatusOK,
+		},
+		{
+			Name:       "Mechanic allowed",
+			Role:       "mechanic",
+			StatusCode: http.StatusOK,
+		},
+		{
+			Name:       "Customer forbidden",
+			Role:       "customer",
+			StatusCode: http.StatusForbidden,
+		},
+		{
+			Name:       "Token without role forbidden",
+			StatusCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "localhost:8080/api/v1/order/status", nil)
+			r = r.WithContext(context.WithValue(r.Context(), tokenKey, &jwt.Claim{ID: "user-1", Role: tc.Role}))
+			w := httptest.NewRecorder()
+
+			requireRole.ServeHTTP(w, r)
+			assert.Equal(t, tc.StatusCode, w.Code)
+		})
+	}
+}
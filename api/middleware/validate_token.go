@@ -13,7 +13,14 @@ const (
 	tokenKey = handler.ContextKey("token")
 )
 
-func ValidateToken() func(next http.Handler) http.Handler {
+// TokenRevocationChecker reports whether an access token has been revoked by logging out
+type TokenRevocationChecker interface {
+	IsTokenRevoked(ctx context.Context, tokenID string) (bool, error)
+}
+
+// ValidateToken rejects requests without a valid access token. The checker may cache what it knows about a token,
+// the auth controller does so per process, so a logout on another instance takes up to 30 seconds to be seen here.
+func ValidateToken(checker TokenRevocationChecker) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			authorizationHeader := r.Header.Get("Authorization")
@@ -23,17 +30,11 @@ func ValidateToken() func(next http.Handler) http.Handler {
 			}
 
 			bearerToken := strings.Split(authorizationHeader, " ")
-			token := bearerToken[1]
-
-			if len(bearerToken) > 2 {
-				handler.GenerateResponse(w, http.StatusUnauthorized, handler.UnauthorizedError)
-				return
-			}
-
-			if len(bearerToken) != 2 && bearerToken[0] != "Bearer" {
+			if len(bearerToken) != 2 || bearerToken[0] != "Bearer" {
 				handler.GenerateResponse(w, http.StatusUnauthorized, handler.UnauthorizedError)
 				return
 			}
+			token := bearerToken[1]
 
 			claim, err := jwt.ParseTokenClaim(token, os.Getenv("ACCESS_KEY"))
 			if err != nil {
@@ -41,6 +42,20 @@ func ValidateToken() func(next http.Handler) http.Handler {
 				return
 			}
 
+			// tokens without jti were issued before sessions existed, they cannot be revoked
+			// and are accepted until they expire so nobody is logged out by the upgrade
+			if claim.Id != "" {
+				revoked, err := checker.IsTokenRevoked(r.Context(), claim.Id)
+				if err != nil {
+					handler.ResponseError(w, &handler.InternalServerError)
+					return
+				}
+				if revoked {
+					handler.GenerateResponse(w, http.StatusUnauthorized, handler.UnauthorizedError)
+					return
+				}
+			}
+
 			ctx := context.WithValue(r.Context(), tokenKey, claim)
 			r = r.WithContext(ctx)
 			next.ServeHTTP(w, r)
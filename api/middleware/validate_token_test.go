@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"e-montir/controller"
+	"e-montir/pkg/jwt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestValidateToken(t *testing.T) {
+	t.Setenv("ACCESS_KEY", "secret")
+
+	activeToken, _, err := jwt.GenerateToken("user-1", "customer", "token-active", "secret", 10)
+	if err != nil {
+		t.Fatalf("failed %v", err)
+	}
+	revokedToken, _, err := jwt.GenerateToken("user-1", "customer", "token-revoked", "secret", 10)
+	if err != nil {
+		t.Fatalf("failed %v", err)
+	}
+	legacyToken, _, err := jwt.GenerateToken("user-1", "customer", "", "secret", 10)
+	if err != nil {
+		t.Fatalf("failed %v", err)
+	}
+
+	checker := new(controller.MockAuthController)
+	checker.On("IsTokenRevoked", mock.Anything, "token-active").Return(false, nil)
+	checker.On("IsTokenRevoked", mock.Anything, "token-revoked").Return(true, nil)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	validate := ValidateToken(checker)(next)
+
+	tt := []struct {
+		Name          string
+		Authorization string
+		StatusCode    int
+	}{
+		{
+			Name:          "Status ok",
+			Authorization: "Bearer " + activeToken,
+			StatusCode:    http.StatusOK,
+		},
+		{
+			Name:          "Revoked token",
+			Authorization: "Bearer " + revokedToken,
+			StatusCode:    http.StatusUnauthorized,
+		},
+		{
+			Name:          "Token without jti",
+			Authorization: "Bearer " + legacyToken,
+			StatusCode:    http.StatusOK,
+		},
+		{
+			Name:          "Missing bearer",
+			Authorization: activeToken,
+			StatusCode:    http.StatusUnauthorized,
+		},
+		{
+			Name:       "Missing header",
+			StatusCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "localhost:8080/api/v1/orders", nil)
+			if tc.Authorization != "" {
+				r.Header.Add("Authorization", tc.Authorization)
+			}
+			w := httptest.NewRecorder()
+
+			validate.ServeHTTP(w, r)
+			assert.Equal(t, tc.StatusCode, w.Code)
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package v1
+
+import (
+	"e-montir/api/handler"
+	"e-montir/controller"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/rs/zerolog/log"
+)
+
+type AttachmentHandler struct {
+	attachmentController controller.Attachment
+}
+
+func NewAttachmentHandler(attachmentController controller.Attachment) AttachmentHandler {
+	return AttachmentHandler{
+		attachmentController: attachmentController,
+	}
+}
+
+// endpoint for the mechanic to upload a before or after photo of the job as a multipart form
+// with the "image" and "kind" fields
+func (c *AttachmentHandler) UploadOrderAttachment(w http.ResponseWriter, r *http.Request) {
+	if err := parseImageForm(w, r); err != nil {
+		handler.ResponseError(w, &handler.ParsePayloadError)
+		return
+	}
+	image, err := formImage(r, "image")
+	if err != nil {
+		handler.ResponseError(w, &handler.ParsePayloadError)
+		return
+	}
+	request := &controller.OrderAttachmentRequest{
+		OrderID: chi.URLParam(r, "order_id"),
+		Kind:    r.FormValue("kind"),
+		Image:   image,
+	}
+
+	fieldsErr, err := request.ValidateOrderAttachmentRequest()
+	if err != nil {
+		res := handler.DefaultUnprocessableEntityError(err.Error(), fieldsErr)
+		handler.GenerateResponse(w, http.StatusUnprocessableEntity, res)
+		return
+	}
+
+	userID := handler.GetTokenClaim(r.Context()).ID
+	res, err := c.attachmentController.UploadOrderAttachment(r.Context(), userID, request)
+	if err != nil {
+		handler.ResponseError(w, err)
+		return
+	}
+	handler.GenerateResponse(w, http.StatusOK, res)
+}
+
+func (c *AttachmentHandler) ListOfOrderAttachments(w http.ResponseWriter, r *http.Request) {
+	userID := handler.GetTokenClaim(r.Context()).ID
+	orderID := chi.URLParam(r, "order_id")
+
+	res, err := c.attachmentController.ListOfOrderAttachments(r.Context(), userID, orderID)
+	if err != nil {
+		handler.ResponseError(w, err)
+		return
+	}
+	handler.GenerateResponse(w, http.StatusOK, res)
+}
+
+// endpoint for the customer to add a photo to their review as a multipart form with the "image" field
+func (c *AttachmentHandler) UploadReviewAttachment(w http.ResponseWriter, r *http.Request) {
+	if err := parseImageForm(w, r); err != nil {
+		handler.ResponseError(w, &handler.ParsePayloadError)
+		return
+	}
+	image, err := formImage(r, "image")
+	if err != nil {
+		handler.ResponseError(w, &handler.ParsePayloadError)
+		return
+	}
+	request := &controller.ReviewAttachmentRequest{
+		ReviewIDString: chi.URLParam(r, "review_id"),
+		Image:          image,
+	}
+
+	fieldsErr, err := request.ValidateReviewAttachmentRequest()
+	if err != nil {
+		res := handler.DefaultUnprocessableEntityError(err.Error(), fieldsErr)
+		handler.GenerateResponse(w, http.StatusUnprocessableEntity, res)
+		return
+	}
+
+	userID := handler.GetTokenClaim(r.Context()).ID
+	res, err := c.attachmentController.UploadReviewAttachment(r.Context(), userID, request)
+	if err != nil {
+		handler.ResponseError(w, err)
+		return
+	}
+	handler.GenerateResponse(w, http.StatusOK, res)
+}
+
+// endpoint serving the uploaded files, the URLs of attachments and chat images point here
+func (c *AttachmentHandler) ServeMedia(w http.ResponseWriter, r *http.Request) {
+	userID := handler.GetTokenClaim(r.Context()).ID
+	res, err := c.attachmentController.GetMedia(r.Context(), userID, chi.URLParam(r, "*"))
+	if err != nil {
+		handler.ResponseError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", res.ContentType)
+	w.Header().Set("Cache-Control", "private")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	if _, err = w.Write(res.Content); err != nil {
+		log.Error().Err(fmt.Errorf("error when writing media: %w", err)).Send()
+	}
+}
@@ -4,19 +4,22 @@ import (
 	"e-montir/api/handler"
 	"e-montir/controller"
 	"e-montir/pkg/mailer"
+	"fmt"
 	"net/http"
 	"strings"
+
+	"github.com/rs/zerolog/log"
 )
 
 type AuthHandler struct {
-	authController   controller.Auth
-	mailerController mailer.Mailer
+	authController controller.Auth
+	mailer         *mailer.Mailer
 }
 
-func NewAuthHandler(authController controller.Auth, mailedCfg *mailer.Config) AuthHandler {
+func NewAuthHandler(authController controller.Auth, mailer *mailer.Mailer) AuthHandler {
 	return AuthHandler{
-		authController:   authController,
-		mailerController: *mailer.NewMailtrap(mailedCfg),
+		authController: authController,
+		mailer:         mailer,
 	}
 }
 
@@ -36,27 +39,24 @@ func (c *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	}
 
 	request.Email = strings.ToLower(request.Email)
-	uid, err := c.authController.Register(r.Context(), request)
+	activationToken, err := c.authController.Register(r.Context(), request)
 
 	if err != nil {
 		handler.ResponseError(w, err)
 		return
 	}
 
-	// case when user enter + in the email
-	if strings.Contains(request.Email, "+") {
-		request.Email = strings.ReplaceAll(request.Email, "+", "%2B")
+	err = c.mailer.SendActivationLink(request.Email, activationToken)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when SendActivationLink: %w", err)).Send()
 	}
-
-	go c.mailerController.SendActivationLink(r.Context(), request.Email, uid)
 	handler.GenerateResponse(w, http.StatusOK, handler.DefaultSuccess{Success: true})
 }
 
 func (c *AuthHandler) ActivateEmail(w http.ResponseWriter, r *http.Request) {
 	request := new(controller.ActivateEmailRequest)
 
-	request.ID = r.URL.Query().Get("id")
-	request.Email = strings.ToLower(r.URL.Query().Get("email"))
+	request.Token = r.URL.Query().Get("token")
 
 	fieldsErr, err := request.ValidateActivateEmailRequest()
 	if err != nil {
@@ -65,7 +65,7 @@ func (c *AuthHandler) ActivateEmail(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = c.authController.ActivateEmail(r.Context(), request.Email, request.ID)
+	err = c.authController.ActivateEmail(r.Context(), request.Token)
 	if err != nil {
 		handler.ResponseError(w, err)
 		return
@@ -96,3 +96,157 @@ func (c *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 
 	handler.GenerateResponse(w, http.StatusOK, token)
 }
+
+// endpoint for mechanics to log in to the mechanic app, only mechanic accounts are accepted
+func (c *AuthHandler) MechanicLogin(w http.ResponseWriter, r *http.Request) {
+	request := new(controller.LoginRequest)
+	if err := handler.DecodeJSON(r, request); err != nil {
+		handler.ResponseError(w, &handler.ParsePayloadError)
+		return
+	}
+
+	if fieldsErr, err := request.ValidateLoginRequest(); err != nil {
+		res := handler.DefaultUnprocessableEntityError(err.Error(), fieldsErr)
+		handler.GenerateResponse(w, http.StatusUnprocessableEntity, res)
+		return
+	}
+
+	request.Email = strings.ToLower(request.Email)
+	token, err := c.authController.MechanicLogin(r.Context(), request)
+	if err != nil {
+		handler.ResponseError(w, err)
+		return
+	}
+
+	handler.GenerateResponse(w, http.StatusOK, token)
+}
+
+func (c *AuthHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	request := new(controller.RefreshTokenRequest)
+	if err := handler.DecodeJSON(r, request); err != nil {
+		handler.ResponseError(w, &handler.ParsePayloadError)
+		return
+	}
+
+	if fieldsErr, err := request.ValidateRefreshTokenRequest(); err != nil {
+		res := handler.DefaultUnprocessableEntityError(err.Error(), fieldsErr)
+		handler.GenerateResponse(w, http.StatusUnprocessableEntity, res)
+		return
+	}
+
+	token, err := c.authController.RefreshToken(r.Context(), request)
+	if err != nil {
+		handler.ResponseError(w, err)
+		return
+	}
+
+	handler.GenerateResponse(w, http.StatusOK, token)
+}
+
+// endpoint to revoke the session of the token used for the request
+func (c *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	tokenID := handler.GetTokenClaim(r.Context()).Id
+
+	err := c.authController.Logout(r.Context(), tokenID)
+	if err != nil {
+		handler.ResponseError(w, err)
+		return
+	}
+
+	handler.GenerateResponse(w, http.StatusOK, handler.DefaultSuccess{Success: true})
+}
+
+// endpoint to revoke every session of the user, including the one used for the request
+func (c *AuthHandler) LogoutAllDevices(w http.ResponseWriter, r *http.Request) {
+	userID := handler.GetTokenClaim(r.Context()).ID
+
+	err := c.authController.LogoutAllDevices(r.Context(), userID)
+	if err != nil {
+		handler.ResponseError(w, err)
+		return
+	}
+
+	handler.GenerateResponse(w, http.StatusOK, handler.DefaultSuccess{Success: true})
+}
+
+// endpoint to email a password reset link, it answers the same way whether the email is registered or not
+func (c *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	request := new(controller.ForgotPasswordRequest)
+	if err := handler.DecodeJSON(r, request); err != nil {
+		handler.ResponseError(w, &handler.ParsePayloadError)
+		return
+	}
+
+	request.Email = strings.ToLower(request.Email)
+	if fieldsErr, err := request.ValidateForgotPasswordRequest(); err != nil {
+		res := handler.DefaultUnprocessableEntityError(err.Error(), fieldsErr)
+		handler.GenerateResponse(w, http.StatusUnprocessableEntity, res)
+		return
+	}
+
+	link, err := c.authController.ForgotPassword(r.Context(), request)
+	if err != nil {
+		handler.ResponseError(w, err)
+		return
+	}
+
+	if link != nil {
+		err = c.mailer.SendPasswordResetLink(request.Email, link.Token, link.Duration)
+		if err != nil {
+			log.Error().Err(fmt.Errorf("error when SendPasswordResetLink: %w", err)).Send()
+		}
+	}
+	handler.GenerateResponse(w, http.StatusOK, handler.DefaultSuccess{Success: true})
+}
+
+func (c *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	request := new(controller.ResetPasswordRequest)
+	if err := handler.DecodeJSON(r, request); err != nil {
+		handler.ResponseError(w, &handler.ParsePayloadError)
+		return
+	}
+
+	if fieldsErr, err := request.ValidateResetPasswordRequest(); err != nil {
+		res := handler.DefaultUnprocessableEntityError(err.Error(), fieldsErr)
+		handler.GenerateResponse(w, http.StatusUnprocessableEntity, res)
+		return
+	}
+
+	err := c.authController.ResetPassword(r.Context(), request)
+	if err != nil {
+		handler.ResponseError(w, err)
+		return
+	}
+
+	handler.GenerateResponse(w, http.StatusOK, handler.DefaultSuccess{Success: true})
+}
+
+// endpoint to send a new activation link, it answers the same way whether the email is registered or not
+func (c *AuthHandler) ResendActivationLink(w http.ResponseWriter, r *http.Request) {
+	request := new(controller.ResendActivationRequest)
+	if err := handler.DecodeJSON(r, request); err != nil {
+		handler.ResponseError(w, &handler.ParsePayloadError)
+		return
+	}
+
+	request.Email = strings.ToLower(request.Email)
+	if fieldsErr, err := request.ValidateResendActivationRequest(); err != nil {
+		res := handler.DefaultUnprocessableEntityError(err.Error(), fieldsErr)
+		handler.GenerateResponse(w, http.StatusUnprocessableEntity, res)
+		return
+	}
+
+	activationToken, err := c.authController.ResendActivationLink(r.Context(), request)
+	if err != nil {
+		handler.ResponseError(w, err)
+		return
+	}
+
+	if activationToken != "" {
+		err = c.mailer.SendActivationLink(request.Email, activationToken)
+		if err != nil {
+			log.Error().Err(fmt.Errorf("error when SendActivationLink: %w", err)).Send()
+		}
+	}
+	handler.GenerateResponse(w, http.StatusOK, handler.DefaultSuccess{Success: true})
+}
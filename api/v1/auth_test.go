@@ -2,18 +2,23 @@ package v1
 
 import (
 	"bytes"
+	"context"
+	"database/sql"
 	"e-montir/controller"
+	"e-montir/model"
 	"e-montir/pkg/mailer"
+	"e-montir/pkg/token"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
 var MockManagerController = new(controller.MockManagerController)
-var route = GetHandler(MockManagerController, &mailer.Config{})
+var route = GetHandler(MockManagerController, mailer.NewMailer(mailer.NewQueue(mailer.NewMemory(), 10), "", ""))
 
 func TestRegister(t *testing.T) {
 	tt := []struct {
@@ -83,7 +88,7 @@ func TestActivateEmail(t *testing.T) {
 	}{
 		{
 			Name:       "Status found",
-			Input:      "email=hello@gmail.com&id={user_id}",
+			Input:      "token=activation-token",
 			StatusCode: http.StatusFound,
 		},
 		{
@@ -93,7 +98,7 @@ func TestActivateEmail(t *testing.T) {
 		},
 		{
 			Name:       "Unprocessable entity",
-			Input:      "email=hello@gmailcom",
+			Input:      "email=hello@gmail.com&id={user_id}",
 			StatusCode: http.StatusUnprocessableEntity,
 		},
 	}
@@ -165,3 +170,156 @@ func TestLogin(t *testing.T) {
 		})
 	}
 }
+
+// memoryUserModel keeps the password reset tokens of the users, the methods the reset does not need
+// are left to the embedded nil interface.
+type memoryUserModel struct {
+	model.User
+	resetTokens []model.PasswordResetToken
+	passwords   map[string]string
+}
+
+func (s *memoryUserModel) GetPasswordResetToken(ctx context.Context, tokenHash string) (*model.PasswordResetToken, error) {
+	for _, v := range s.resetTokens {
+		if v.TokenHash == tokenHash {
+			res := v
+			return &res, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (s *memoryUserModel) ResetPassword(ctx context.Context, tokenID int, userID, passwordHash string) (bool, error) {
+	if s.resetTokens[tokenID-1].UsedAt.Valid {
+		return false, nil
+	}
+	s.resetTokens[tokenID-1].UsedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	s.passwords[userID] = passwordHash
+	return true, nil
+}
+
+type memorySessionModel struct {
+	model.Session
+	sessions []model.SessionBaseModel
+}
+
+func (s *memorySessionModel) RevokeUserSessions(ctx context.Context, userID string) error {
+	for i := range s.sessions {
+		if s.sessions[i].UserID == userID {
+			s.sessions[i].RevokedAt = sql.NullTime{Time: time.Now(), Valid: true}
+		}
+	}
+	return nil
+}
+
+func (s *memorySessionModel) IsTokenRevoked(ctx context.Context, tokenID string) (bool, error) {
+	for _, v := range s.sessions {
+		if v.AccessTokenID == tokenID {
+			return v.RevokedAt.Valid, nil
+		}
+	}
+	return true, nil
+}
+
+func TestResetPassword(t *testing.T) {
+	tt := []struct {
+		Name       string
+		Token      string
+		ExpiresAt  time.Time
+		UsedAt     sql.NullTime
+		StatusCode int
+	}{
+		{
+			Name:       "Status ok",
+			Token:      "reset-token",
+			ExpiresAt:  time.Now().Add(30 * time.Minute),
+			StatusCode: http.StatusOK,
+		},
+		{
+			Name:       "Expired token",
+			Token:      "reset-token",
+			ExpiresAt:  time.Now().Add(-time.Minute),
+			StatusCode: http.StatusBadRequest,
+		},
+		{
+			Name:       "Used token",
+			Token:      "reset-token",
+			ExpiresAt:  time.Now().Add(30 * time.Minute),
+			UsedAt:     sql.NullTime{Time: time.Now().Add(-time.Minute), Valid: true},
+			StatusCode: http.StatusBadRequest,
+		},
+		{
+			Name:       "Unknown token",
+			Token:      "guessed-token",
+			ExpiresAt:  time.Now().Add(30 * time.Minute),
+			StatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			users := &memoryUserModel{
+				resetTokens: []model.PasswordResetToken{{
+					ID:        1,
+					UserID:    "user-1",
+					TokenHash: token.Hash("reset-token"),
+					ExpiresAt: tc.ExpiresAt,
+					UsedAt:    tc.UsedAt,
+				}},
+				passwords: map[string]string{},
+			}
+			sessions := &memorySessionModel{sessions: []model.SessionBaseModel{
+				{ID: "session-1", UserID: "user-1", AccessTokenID: "phone"},
+				{ID: "session-2", UserID: "user-1", AccessTokenID: "laptop"},
+				{ID: "session-3", UserID: "user-2", AccessTokenID: "other-user"},
+			}}
+			authController := controller.NewAuth(users, sessions, nil)
+			authHandler := NewAuthHandler(authController, mailer.NewMailer(mailer.NewQueue(mailer.NewMemory(), 10), "", ""))
+			resetPassword := func() int {
+				rBody, err := json.Marshal(map[string]string{
+					"token":    tc.Token,
+					"password": "abc123Dc1.",
+				})
+				if err != nil {
+					t.Errorf("failed %v", err)
+				}
+
+				r := httptest.NewRequest("POST", "localhost:8080/api/v1/auth/reset-password", bytes.NewBuffer(rBody))
+				r.Header.Set("content-type", "application/json")
+				w := httptest.NewRecorder()
+
+				authHandler.ResetPassword(w, r)
+				return w.Code
+			}
+
+			// the access tokens are checked before the reset so the revocation cache holds them as valid
+			for _, v := range sessions.sessions {
+				revoked, err := authController.IsTokenRevoked(context.Background(), v.AccessTokenID)
+				assert.NoError(t, err)
+				assert.False(t, revoked)
+			}
+
+			assert.Equal(t, tc.StatusCode, resetPassword())
+			if tc.StatusCode != http.StatusOK {
+				assert.Empty(t, users.passwords)
+				for _, v := range sessions.sessions {
+					assert.False(t, v.RevokedAt.Valid)
+				}
+				return
+			}
+
+			assert.NotEmpty(t, users.passwords["user-1"])
+			for _, tokenID := range []string{"phone", "laptop"} {
+				revoked, err := authController.IsTokenRevoked(context.Background(), tokenID)
+				assert.NoError(t, err)
+				assert.True(t, revoked, "session of %s is still valid", tokenID)
+			}
+			revoked, err := authController.IsTokenRevoked(context.Background(), "other-user")
+			assert.NoError(t, err)
+			assert.False(t, revoked)
+
+			// the link cannot be used a second time
+			assert.Equal(t, http.StatusBadRequest, resetPassword())
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package v1
+
+import (
+	"e-montir/api/handler"
+	"e-montir/controller"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/rs/zerolog/log"
+)
+
+const chatKeepAlive = 15 * time.Second
+
+type ChatHandler struct {
+	chatController controller.Chat
+}
+
+func NewChatHandler(chatController controller.Chat) ChatHandler {
+	return ChatHandler{
+		chatController: chatController,
+	}
+}
+
+func (c *ChatHandler) ListOfMessages(w http.ResponseWriter, r *http.Request) {
+	request := &controller.MessageListRequest{
+		OrderID:     chi.URLParam(r, "order_id"),
+		PageString:  r.URL.Query().Get("page"),
+		LimitString: r.URL.Query().Get("limit"),
+	}
+
+	fieldsErr, err := request.ValidateMessageListRequest()
+	if err != nil {
+		res := handler.DefaultUnprocessableEntityError(err.Error(), fieldsErr)
+		handler.GenerateResponse(w, http.StatusUnprocessableEntity, res)
+		return
+	}
+
+	userID := handler.GetTokenClaim(r.Context()).ID
+	res, err := c.chatController.ListOfMessages(r.Context(), userID, request)
+	if err != nil {
+		handler.ResponseError(w, err)
+		return
+	}
+	handler.GenerateResponse(w, http.StatusOK, res)
+}
+
+// endpoint for the customer and the mechanic to send a message, a JSON body carries text
+// only while a multipart form may attach an image in the "image" field.
+func (c *ChatHandler) SendMessage(w http.ResponseWriter, r *http.Request) {
+	request := &controller.SendMessageRequest{
+		OrderID: chi.URLParam(r, "order_id"),
+	}
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := parseImageForm(w, r); err != nil {
+			handler.ResponseError(w, &handler.ParsePayloadError)
+			return
+		}
+		request.Body = r.FormValue("body")
+
+		image, err := formImage(r, "image")
+		if err != nil {
+			handler.ResponseError(w, &handler.ParsePayloadError)
+			return
+		}
+		request.Image = image
+	} else {
+		payload := struct {
+			Body string `json:"body"`
+		}{}
+		if err := handler.DecodeJSON(r, &payload); err != nil {
+			handler.ResponseError(w, &handler.ParsePayloadError)
+			return
+		}
+		request.Body = payload.Body
+	}
+
+	fieldsErr, err := request.ValidateSendMessageRequest()
+	if err != nil {
+		res := handler.DefaultUnprocessableEntityError(err.Error(), fieldsErr)
+		handler.GenerateResponse(w, http.StatusUnprocessableEntity, res)
+		return
+	}
+
+	userID := handler.GetTokenClaim(r.Context()).ID
+	res, err := c.chatController.SendMessage(r.Context(), userID, request)
+	if err != nil {
+		handler.ResponseError(w, err)
+		return
+	}
+	handler.GenerateResponse(w, http.StatusOK, res)
+}
+
+func (c *ChatHandler) MarkMessagesAsRead(w http.ResponseWriter, r *http.Request) {
+	userID := handler.GetTokenClaim(r.Context()).ID
+	orderID := chi.URLParam(r, "order_id")
+
+	err := c.chatController.MarkMessagesAsRead(r.Context(), userID, orderID)
+	if err != nil {
+		handler.ResponseError(w, err)
+		return
+	}
+	handler.GenerateResponse(w, http.StatusOK, handler.DefaultSuccess{Success: true})
+}
+
+// endpoint for the participants to receive new messages and read receipts through Server-Sent Events
+func (c *ChatHandler) Subscribe(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		handler.ResponseError(w, &handler.InternalServerError)
+		return
+	}
+
+	userID := handler.GetTokenClaim(r.Context()).ID
+	orderID := chi.URLParam(r, "order_id")
+
+	events, unsubscribe, err := c.chatController.Subscribe(r.Context(), userID, orderID)
+	if err != nil {
+		handler.ResponseError(w, err)
+		return
+	}
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	_, _ = fmt.Fprint(w, "retry: 5000\n\n")
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(chatKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(event.Data)
+			if err != nil {
+				log.Error().Err(fmt.Errorf("error when marshalling chat event: %w", err)).Send()
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Name, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
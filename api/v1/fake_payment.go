@@ -0,0 +1,46 @@
+package v1
+
+import (
+	"e-montir/api/handler"
+	"e-montir/pkg/payment"
+	"e-montir/pkg/validator"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi"
+)
+
+// FakePaymentHandler lets developers decide the outcome of transactions created on the fake payment gateway.
+type FakePaymentHandler struct {
+	gateway *payment.FakeGateway
+}
+
+func NewFakePaymentHandler(gateway *payment.FakeGateway) FakePaymentHandler {
+	return FakePaymentHandler{
+		gateway: gateway,
+	}
+}
+
+func (c *FakePaymentHandler) Simulate(w http.ResponseWriter, r *http.Request) {
+	orderID := chi.URLParam(r, "order_id")
+	status := strings.ToUpper(chi.URLParam(r, "status"))
+
+	err := validator.ValidateOrderID(orderID)
+	if err != nil {
+		var fieldError []handler.Fields
+		fieldError = append(fieldError, handler.Fields{
+			Name:    "order_id",
+			Message: err.Error(),
+		})
+		res := handler.DefaultUnprocessableEntityError(handler.ValidationFailed, fieldError)
+		handler.GenerateResponse(w, http.StatusUnprocessableEntity, res)
+		return
+	}
+
+	err = c.gateway.Simulate(r.Context(), orderID, status)
+	if err != nil {
+		handler.GenerateResponse(w, http.StatusBadRequest, handler.DefaultError{Message: err.Error()})
+		return
+	}
+	handler.GenerateResponse(w, http.StatusOK, handler.DefaultSuccess{Success: true})
+}
@@ -0,0 +1,107 @@
+package v1
+
+import (
+	"context"
+	"e-montir/api/handler"
+	"e-montir/controller"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi"
+)
+
+type MechanicHandler struct {
+	mechanicJobController controller.MechanicJob
+}
+
+func NewMechanicHandler(mechanicJobController controller.MechanicJob) MechanicHandler {
+	return MechanicHandler{
+		mechanicJobController: mechanicJobController,
+	}
+}
+
+// endpoint for admin to give a mechanic the credentials of the mechanic app
+func (c *MechanicHandler) CreateMechanicAccount(w http.ResponseWriter, r *http.Request) {
+	request := new(controller.MechanicAccountRequest)
+	if err := handler.DecodeJSON(r, request); err != nil {
+		handler.ResponseError(w, &handler.ParsePayloadError)
+		return
+	}
+	request.MechanicIDString = chi.URLParam(r, "mechanic_id")
+
+	fieldsErr, err := request.ValidateMechanicAccountRequest()
+	if err != nil {
+		res := handler.DefaultUnprocessableEntityError(err.Error(), fieldsErr)
+		handler.GenerateResponse(w, http.StatusUnprocessableEntity, res)
+		return
+	}
+
+	request.Email = strings.ToLower(request.Email)
+	err = c.mechanicJobController.CreateMechanicAccount(r.Context(), request)
+	if err != nil {
+		handler.ResponseError(w, err)
+		return
+	}
+	handler.GenerateResponse(w, http.StatusOK, handler.DefaultSuccess{Success: true})
+}
+
+func (c *MechanicHandler) MechanicProfile(w http.ResponseWriter, r *http.Request) {
+	request := &controller.MechanicProfileRequest{
+		MechanicIDString: chi.URLParam(r, "mechanic_id"),
+	}
+
+	fieldsErr, err := request.ValidateMechanicProfileRequest()
+	if err != nil {
+		res := handler.DefaultUnprocessableEntityError(err.Error(), fieldsErr)
+		handler.GenerateResponse(w, http.StatusUnprocessableEntity, res)
+		return
+	}
+
+	res, err := c.mechanicJobController.MechanicProfile(r.Context(), request)
+	if err != nil {
+		handler.ResponseError(w, err)
+		return
+	}
+	handler.GenerateResponse(w, http.StatusOK, res)
+}
+
+func (c *MechanicHandler) ListOfJobs(w http.ResponseWriter, r *http.Request) {
+	userID := handler.GetTokenClaim(r.Context()).ID
+
+	res, err := c.mechanicJobController.ListOfJobs(r.Context(), userID)
+	if err != nil {
+		handler.ResponseError(w, err)
+		return
+	}
+	handler.GenerateResponse(w, http.StatusOK, res)
+}
+
+func (c *MechanicHandler) AcceptJob(w http.ResponseWriter, r *http.Request) {
+	c.updateJob(w, r, c.mechanicJobController.AcceptJob)
+}
+
+func (c *MechanicHandler) StartTravel(w http.ResponseWriter, r *http.Request) {
+	c.updateJob(w, r, c.mechanicJobController.StartTravel)
+}
+
+func (c *MechanicHandler) MarkArrived(w http.ResponseWriter, r *http.Request) {
+	c.updateJob(w, r, c.mechanicJobController.MarkArrived)
+}
+
+func (c *MechanicHandler) CompleteJob(w http.ResponseWriter, r *http.Request) {
+	c.updateJob(w, r, c.mechanicJobController.CompleteJob)
+}
+
+type jobAction func(ctx context.Context, userID, orderID string) (*controller.JobStatusResponse, error)
+
+func (c *MechanicHandler) updateJob(w http.ResponseWriter, r *http.Request, action jobAction) {
+	userID := handler.GetTokenClaim(r.Context()).ID
+	orderID := chi.URLParam(r, "order_id")
+
+	res, err := action(r.Context(), userID, orderID)
+	if err != nil {
+		handler.ResponseError(w, err)
+		return
+	}
+	handler.GenerateResponse(w, http.StatusOK, res)
+}
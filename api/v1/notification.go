@@ -0,0 +1,130 @@
+package v1
+
+import (
+	"e-montir/api/handler"
+	"e-montir/controller"
+	"net/http"
+
+	"github.com/go-chi/chi"
+)
+
+type NotificationHandler struct {
+	notificationController controller.Notification
+}
+
+func NewNotificationHandler(notificationController controller.Notification) NotificationHandler {
+	return NotificationHandler{
+		notificationController: notificationController,
+	}
+}
+
+// endpoint for the user to page through the inbox, the next page starts after pagination.next_cursor
+func (c *NotificationHandler) ListOfNotifications(w http.ResponseWriter, r *http.Request) {
+	request := &controller.NotificationListRequest{
+		CursorString: r.URL.Query().Get("cursor"),
+		LimitString:  r.URL.Query().Get("limit"),
+	}
+
+	fieldsErr, err := request.ValidateNotificationListRequest()
+	if err != nil {
+		res := handler.DefaultUnprocessableEntityError(err.Error(), fieldsErr)
+		handler.GenerateResponse(w, http.StatusUnprocessableEntity, res)
+		return
+	}
+
+	userID := handler.GetTokenClaim(r.Context()).ID
+	res, err := c.notificationController.ListOfNotifications(r.Context(), userID, request)
+	if err != nil {
+		handler.ResponseError(w, err)
+		return
+	}
+	handler.GenerateResponse(w, http.StatusOK, res)
+}
+
+func (c *NotificationHandler) MarkNotificationAsRead(w http.ResponseWriter, r *http.Request) {
+	request := &controller.NotificationReadRequest{
+		NotificationIDString: chi.URLParam(r, "notification_id"),
+	}
+
+	fieldsErr, err := request.ValidateNotificationReadRequest()
+	if err != nil {
+		res := handler.DefaultUnprocessableEntityError(err.Error(), fieldsErr)
+		handler.GenerateResponse(w, http.StatusUnprocessableEntity, res)
+		return
+	}
+
+	userID := handler.GetTokenClaim(r.Context()).ID
+	err = c.notificationController.MarkNotificationAsRead(r.Context(), userID, request.NotificationID)
+	if err != nil {
+		handler.ResponseError(w, err)
+		return
+	}
+	handler.GenerateResponse(w, http.StatusOK, handler.DefaultSuccess{Success: true})
+}
+
+func (c *NotificationHandler) MarkAllNotificationsAsRead(w http.ResponseWriter, r *http.Request) {
+	userID := handler.GetTokenClaim(r.Context()).ID
+	err := c.notificationController.MarkAllNotificationsAsRead(r.Context(), userID)
+	if err != nil {
+		handler.ResponseError(w, err)
+		return
+	}
+	handler.GenerateResponse(w, http.StatusOK, handler.DefaultSuccess{Success: true})
+}
+
+// endpoint for the admin to send a promotion to every customer
+func (c *NotificationHandler) SendPromotion(w http.ResponseWriter, r *http.Request) {
+	request := new(controller.SendPromotionRequest)
+	if err := handler.DecodeJSON(r, request); err != nil {
+		handler.ResponseError(w, &handler.ParsePayloadError)
+		return
+	}
+
+	fieldsErr, err := request.ValidateSendPromotionRequest()
+	if err != nil {
+		res := handler.DefaultUnprocessableEntityError(err.Error(), fieldsErr)
+		handler.GenerateResponse(w, http.StatusUnprocessableEntity, res)
+		return
+	}
+
+	res, err := c.notificationController.SendPromotion(r.Context(), request)
+	if err != nil {
+		handler.ResponseError(w, err)
+		return
+	}
+	handler.GenerateResponse(w, http.StatusOK, res)
+}
+
+func (c *NotificationHandler) GetNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	userID := handler.GetTokenClaim(r.Context()).ID
+	res, err := c.notificationController.GetNotificationPreferences(r.Context(), userID)
+	if err != nil {
+		handler.ResponseError(w, err)
+		return
+	}
+	handler.GenerateResponse(w, http.StatusOK, res)
+}
+
+// endpoint for the user to replace the notification preferences, every field has to be sent
+func (c *NotificationHandler) UpdateNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	request := new(controller.NotificationPreferences)
+	if err := handler.DecodeJSON(r, request); err != nil {
+		handler.ResponseError(w, &handler.ParsePayloadError)
+		return
+	}
+
+	fieldsErr, err := request.ValidateNotificationPreferences()
+	if err != nil {
+		res := handler.DefaultUnprocessableEntityError(err.Error(), fieldsErr)
+		handler.GenerateResponse(w, http.StatusUnprocessableEntity, res)
+		return
+	}
+
+	userID := handler.GetTokenClaim(r.Context()).ID
+	res, err := c.notificationController.UpdateNotificationPreferences(r.Context(), userID, request)
+	if err != nil {
+		handler.ResponseError(w, err)
+		return
+	}
+	handler.GenerateResponse(w, http.StatusOK, res)
+}
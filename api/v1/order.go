@@ -69,7 +69,15 @@ func (c *OrderHandler) UpdateOrderStatus(w http.ResponseWriter, r *http.Request)
 	}
 
 	request.Status = strings.ToLower(request.Status)
-	err := c.orderController.UpdateOrderStatus(r.Context(), request)
+	fieldsErr, err := request.ValidateUpdateOrderRequest()
+	if err != nil {
+		res := handler.DefaultUnprocessableEntityError(err.Error(), fieldsErr)
+		handler.GenerateResponse(w, http.StatusUnprocessableEntity, res)
+		return
+	}
+
+	actorID := handler.GetTokenClaim(r.Context()).ID
+	err = c.orderController.UpdateOrderStatus(r.Context(), actorID, request)
 	if err != nil {
 		handler.ResponseError(w, err)
 		return
@@ -79,6 +87,7 @@ func (c *OrderHandler) UpdateOrderStatus(w http.ResponseWriter, r *http.Request)
 
 func (c *OrderHandler) OrderDetail(w http.ResponseWriter, r *http.Request) {
 	orderID := chi.URLParam(r, "order_id")
+	userID := handler.GetTokenClaim(r.Context()).ID
 
 	err := validator.ValidateOrderID(orderID)
 	var fieldError []handler.Fields
@@ -93,7 +102,31 @@ func (c *OrderHandler) OrderDetail(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	res, err := c.orderController.OrderDetail(r.Context(), orderID)
+	res, err := c.orderController.OrderDetail(r.Context(), userID, orderID)
+	if err != nil {
+		handler.ResponseError(w, err)
+		return
+	}
+	handler.GenerateResponse(w, http.StatusOK, res)
+}
+
+func (c *OrderHandler) CancelOrder(w http.ResponseWriter, r *http.Request) {
+	orderID := chi.URLParam(r, "order_id")
+	userID := handler.GetTokenClaim(r.Context()).ID
+
+	err := validator.ValidateOrderID(orderID)
+	if err != nil {
+		var fieldError []handler.Fields
+		fieldError = append(fieldError, handler.Fields{
+			Name:    "order_id",
+			Message: err.Error(),
+		})
+		res := handler.DefaultUnprocessableEntityError(handler.ValidationFailed, fieldError)
+		handler.GenerateResponse(w, http.StatusUnprocessableEntity, res)
+		return
+	}
+
+	res, err := c.orderController.CancelOrder(r.Context(), userID, orderID)
 	if err != nil {
 		handler.ResponseError(w, err)
 		return
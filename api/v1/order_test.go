@@ -0,0 +1,180 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"e-montir/api/handler"
+	"e-montir/controller"
+	"e-montir/model"
+	"e-montir/pkg/jwt"
+	"e-montir/pkg/payment"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+)
+
+// newOrderRequest builds a request the way the router hands it to the handler,
+// with the token claim of userID and the URL params of the route.
+func newOrderRequest(method, url, userID string, body []byte, params map[string]string) *http.Request {
+	r := httptest.NewRequest(method, url, bytes.NewBuffer(body))
+	r.Header.Set("content-type", "application/json")
+
+	rctx := chi.NewRouteContext()
+	for k, v := range params {
+		rctx.URLParams.Add(k, v)
+	}
+	ctx := context.WithValue(r.Context(), chi.RouteCtxKey, rctx)
+	ctx = context.WithValue(ctx, handler.ContextKey("token"), &jwt.Claim{ID: userID, Role: "customer"})
+	return r.WithContext(ctx)
+}
+
+// memoryReviewModel keeps the service reviews of the ownership test, the methods it does not need are left
+// to the embedded nil interface.
+type memoryReviewModel struct {
+	model.Review
+	reviews []model.ReviewBaseModel
+}
+
+func (s *memoryReviewModel) AddServiceReview(ctx context.Context, param *model.ReviewBaseModel) error {
+	s.reviews = append(s.reviews, *param)
+	return nil
+}
+
+func (s *memoryReviewModel) IsServiceReviewed(ctx context.Context, orderID string, serviceID int) (bool, error) {
+	for _, v := range s.reviews {
+		if v.OrderID == orderID && v.ServiceID == serviceID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *memoryReviewModel) GetReviewByOrderID(ctx context.Context, orderID string) (*model.ReviewBaseModel, error) {
+	for _, v := range s.reviews {
+		if v.OrderID == orderID {
+			res := v
+			return &res, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (s *memoryReviewModel) IsMechanicReviewed(ctx context.Context, orderID string) (bool, error) {
+	return false, nil
+}
+
+type memoryCartModel struct {
+	model.Cart
+}
+
+func (s *memoryCartModel) IsServiceAvailable(ctx context.Context, serviceID int) (bool, error) {
+	return true, nil
+}
+
+// every order scoped handler goes through the real controllers, so only the owner of the order
+// gets past the ownership check and the order is left untouched for anybody else
+func TestOrderOwnership(t *testing.T) {
+	tt := []struct {
+		Name       string
+		UserID     string
+		StatusCode int
+	}{
+		{
+			Name:       "Owner",
+			UserID:     "user-1",
+			StatusCode: http.StatusOK,
+		},
+		{
+			Name:       "Other user",
+			UserID:     "user-2",
+			StatusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range tt {
+		orders := &memoryOrderModel{orders: map[string]*model.OrderBaseModel{
+			"order-1": {
+				ID:            "order-1",
+				UserID:        "user-1",
+				UserAddressID: "address-1",
+				Date:          "2026-10-20T00:00:00Z",
+				TimeSlot:      "10:00",
+				TotalPrice:    265000,
+				OrderStatus:   sql.NullString{String: model.OrderStatus[1], Valid: true},
+			},
+		}}
+		reviews := &memoryReviewModel{}
+		refunds := &memoryRefundModel{}
+		gateway := payment.NewFakeGateway(&payment.FakeConfig{ServerKey: "secret"})
+		orderController := controller.NewOrder(orders, nil, nil, reviews, refunds, gateway, &memoryAssignment{}, nil)
+		orderHandler := NewOrderHandler(orderController)
+		paymentHandler := NewPaymentHandler(controller.NewPayment(orders, nil, &memoryPaymentModel{}, gateway), orderController, nil)
+		reviewHandler := NewReviewHandler(controller.NewReview(reviews, &memoryCartModel{}, orders, nil, nil))
+
+		t.Run("OrderDetail "+tc.Name, func(t *testing.T) {
+			r := newOrderRequest("GET", "localhost:8080/api/v1/orders/order-1", tc.UserID, nil, map[string]string{
+				"order_id": "order-1",
+			})
+			w := httptest.NewRecorder()
+
+			orderHandler.OrderDetail(w, r)
+			assert.Equal(t, tc.StatusCode, w.Code)
+		})
+
+		t.Run("PaymentStatus "+tc.Name, func(t *testing.T) {
+			r := newOrderRequest("GET", "localhost:8080/api/v1/orders/order-1/payment", tc.UserID, nil, map[string]string{
+				"order_id": "order-1",
+			})
+			w := httptest.NewRecorder()
+
+			paymentHandler.PaymentStatus(w, r)
+			assert.Equal(t, tc.StatusCode, w.Code)
+		})
+
+		t.Run("MakePayment "+tc.Name, func(t *testing.T) {
+			r := newOrderRequest("POST", "localhost:8080/api/v1/pay/order-1", tc.UserID, nil, map[string]string{
+				"order_id": "order-1",
+			})
+			w := httptest.NewRecorder()
+
+			paymentHandler.MakePayment(w, r)
+			assert.Equal(t, tc.StatusCode, w.Code)
+
+			_, err := gateway.GetStatus(context.Background(), "order-1")
+			assert.Equal(t, tc.StatusCode == http.StatusOK, err == nil)
+		})
+
+		t.Run("AddServiceReview "+tc.Name, func(t *testing.T) {
+			body := []byte(`{"rating": "4.5", "feedback": "mechanic was on time"}`)
+			r := newOrderRequest("POST", "localhost:8080/api/v1/services/order-1/1/review", tc.UserID, body, map[string]string{
+				"order_id":   "order-1",
+				"service_id": "1",
+			})
+			w := httptest.NewRecorder()
+
+			reviewHandler.AddServiceReview(w, r)
+			assert.Equal(t, tc.StatusCode, w.Code)
+			assert.Equal(t, tc.StatusCode == http.StatusOK, len(reviews.reviews) == 1)
+		})
+
+		t.Run("CancelOrder "+tc.Name, func(t *testing.T) {
+			r := newOrderRequest("POST", "localhost:8080/api/v1/orders/order-1/cancel", tc.UserID, nil, map[string]string{
+				"order_id": "order-1",
+			})
+			w := httptest.NewRecorder()
+
+			orderHandler.CancelOrder(w, r)
+			assert.Equal(t, tc.StatusCode, w.Code)
+
+			expected := model.OrderStatus[1]
+			if tc.StatusCode == http.StatusOK {
+				expected = model.OrderStatus[6]
+			}
+			assert.Equal(t, expected, orders.orders["order-1"].OrderStatus.String)
+		})
+	}
+}
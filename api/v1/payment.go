@@ -1,8 +1,11 @@
 package v1
 
 import (
+	"bytes"
 	"e-montir/api/handler"
 	"e-montir/controller"
+	"e-montir/pkg/payment"
+	"io/ioutil"
 	"net/http"
 
 	"github.com/go-chi/chi"
@@ -11,12 +14,14 @@ import (
 type PaymentHandler struct {
 	paymentController controller.Payment
 	orderController   controller.Order
+	refundController  controller.Refund
 }
 
-func NewPaymentHandler(paymentController controller.Payment, orderController controller.Order) PaymentHandler {
+func NewPaymentHandler(paymentController controller.Payment, orderController controller.Order, refundController controller.Refund) PaymentHandler {
 	return PaymentHandler{
 		paymentController: paymentController,
 		orderController:   orderController,
+		refundController:  refundController,
 	}
 }
 
@@ -24,6 +29,7 @@ func NewPaymentHandler(paymentController controller.Payment, orderController con
 func (c *PaymentHandler) MakePayment(w http.ResponseWriter, r *http.Request) {
 	request := new(controller.PaymentRequest)
 	request.OrderID = chi.URLParam(r, "order_id")
+	userID := handler.GetTokenClaim(r.Context()).ID
 
 	fieldsErr, err := request.ValidatePaymentRequest()
 	if err != nil {
@@ -32,7 +38,7 @@ func (c *PaymentHandler) MakePayment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	res, err := c.paymentController.Pay(r.Context(), request.OrderID)
+	res, err := c.paymentController.Pay(r.Context(), userID, request.OrderID)
 	if err != nil {
 		handler.ResponseError(w, err)
 		return
@@ -42,14 +48,53 @@ func (c *PaymentHandler) MakePayment(w http.ResponseWriter, r *http.Request) {
 }
 
 // endpoint to check the payment status.
-// it paid then order status will be updated and mechanic will be assigned to the order
+// it paid then order status will be updated and mechanic will be assigned to the order,
+// any other status is recorded and acknowledged so the gateway stops sending it.
+// notifications are verified against the gateway signature and processed only once,
+// repeated notifications are acknowledged without touching the order again.
+// refund outcomes are reported on the same endpoint and settle the refund of the order
 func (c *PaymentHandler) PaymentNotification(w http.ResponseWriter, r *http.Request) {
 	request := new(controller.PaymentDetail)
-	if err := handler.DecodeJSON(r, request); err != nil {
+	if r.Body == nil {
 		handler.ResponseError(w, &handler.ParsePayloadError)
 		return
 	}
-	err := c.orderController.PaymentReceived(r.Context(), request.OrderID, request.TransactionStatus)
+
+	payload, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		handler.ResponseError(w, &handler.ParsePayloadError)
+		return
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(payload))
+
+	if err = handler.DecodeJSON(r, request); err != nil {
+		handler.ResponseError(w, &handler.ParsePayloadError)
+		return
+	}
+
+	notification, err := c.paymentController.VerifyNotification(r.Context(), request, payload)
+	if err != nil {
+		handler.ResponseError(w, err)
+		return
+	}
+
+	if notification.IsDuplicate {
+		handler.GenerateResponse(w, http.StatusOK, handler.DefaultSuccess{Success: true})
+		return
+	}
+
+	switch request.TransactionStatus {
+	case payment.StatusRefunded, payment.StatusRefundFailed:
+		err = c.refundController.RefundReceived(r.Context(), request.OrderID, request.TransactionStatus)
+	default:
+		err = c.orderController.PaymentReceived(r.Context(), request.OrderID, request.TransactionStatus)
+	}
+	if err != nil {
+		handler.ResponseError(w, err)
+		return
+	}
+
+	err = c.paymentController.MarkNotificationProcessed(r.Context(), notification.ID)
 	if err != nil {
 		handler.ResponseError(w, err)
 		return
@@ -57,3 +102,23 @@ func (c *PaymentHandler) PaymentNotification(w http.ResponseWriter, r *http.Requ
 
 	handler.GenerateResponse(w, http.StatusOK, handler.DefaultSuccess{Success: true})
 }
+
+func (c *PaymentHandler) PaymentStatus(w http.ResponseWriter, r *http.Request) {
+	request := new(controller.PaymentRequest)
+	request.OrderID = chi.URLParam(r, "order_id")
+	userID := handler.GetTokenClaim(r.Context()).ID
+
+	fieldsErr, err := request.ValidatePaymentRequest()
+	if err != nil {
+		res := handler.DefaultUnprocessableEntityError(err.Error(), fieldsErr)
+		handler.GenerateResponse(w, http.StatusUnprocessableEntity, res)
+		return
+	}
+
+	res, err := c.paymentController.PaymentStatus(r.Context(), userID, request.OrderID)
+	if err != nil {
+		handler.ResponseError(w, err)
+		return
+	}
+	handler.GenerateResponse(w, http.StatusOK, res)
+}
@@ -0,0 +1,284 @@
+package v1
+
+import (
+	"context"
+	"database/sql"
+	"e-montir/api/handler"
+	"e-montir/controller"
+	"e-montir/model"
+	"e-montir/pkg/payment"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryOrderModel serves the orders the payment notifications and the order scoped handlers touch, the methods
+// they do not need are left to the embedded nil interface.
+type memoryOrderModel struct {
+	model.Order
+	orders map[string]*model.OrderBaseModel
+}
+
+func (s *memoryOrderModel) GetOrderByOrderID(ctx context.Context, orderID string) (*model.OrderBaseModel, error) {
+	order, ok := s.orders[orderID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	res := *order
+	return &res, nil
+}
+
+func (s *memoryOrderModel) UpdateOrderStatus(ctx context.Context, orderID, status, invoiceID, actor string) error {
+	order := s.orders[orderID]
+	if !model.CanTransitOrderStatus(order.OrderStatus.String, status) {
+		return &handler.InvalidOrderStatusTransition
+	}
+	order.OrderStatus.String = status
+	return nil
+}
+
+func (s *memoryOrderModel) CancelOrder(ctx context.Context, orderID, actor, reason string) (*model.CancelledOrder, error) {
+	order := s.orders[orderID]
+	previous := order.OrderStatus.String
+	if err := s.UpdateOrderStatus(ctx, orderID, model.OrderStatus[6], "", actor); err != nil {
+		return nil, err
+	}
+	return &model.CancelledOrder{ID: orderID, PreviousStatus: previous, TotalPrice: order.TotalPrice}, nil
+}
+
+func (s *memoryOrderModel) ListOfOrderStatusHistory(ctx context.Context, orderID string) ([]model.OrderStatusHistory, error) {
+	return nil, nil
+}
+
+func (s *memoryOrderModel) CheckOrder(ctx context.Context, orderID string) (*model.OrderBaseModel, error) {
+	return s.GetOrderByOrderID(ctx, orderID)
+}
+
+func (s *memoryOrderModel) ListOfOrderItems(ctx context.Context, orderID string) ([]model.OrderItem, error) {
+	return []model.OrderItem{{ServiceID: 1, Title: "Engine tune up", Price: 265000}}, nil
+}
+
+func (s *memoryOrderModel) OrderLocation(ctx context.Context, userAddressID string) (*model.OrderLocation, error) {
+	return &model.OrderLocation{ID: userAddressID, Label: "Home"}, nil
+}
+
+func (s *memoryOrderModel) GetOrderMechanic(ctx context.Context, mechanicID int) (*model.OrderMechanic, error) {
+	return nil, sql.ErrNoRows
+}
+
+type memoryPaymentModel struct {
+	notifications []model.PaymentNotification
+}
+
+func (s *memoryPaymentModel) StoreNotification(ctx context.Context, param *model.PaymentNotification) (int, error) {
+	s.notifications = append(s.notifications, *param)
+	return len(s.notifications), nil
+}
+
+func (s *memoryPaymentModel) IsNotificationProcessed(ctx context.Context, param *model.PaymentNotification) (bool, error) {
+	for _, v := range s.notifications {
+		if v.OrderID == param.OrderID && v.TransactionID == param.TransactionID && v.RefundKey == param.RefundKey &&
+			v.TransactionStatus == param.TransactionStatus && v.ProcessedAt.Valid {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *memoryPaymentModel) MarkNotificationProcessed(ctx context.Context, notificationID int) error {
+	s.notifications[notificationID-1].ProcessedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	return nil
+}
+
+func (s *memoryPaymentModel) SetPayment(ctx context.Context, param *model.PaymentTransaction) error {
+	return nil
+}
+
+func (s *memoryPaymentModel) UpdateLatestPayment(ctx context.Context, param *model.PaymentTransaction) error {
+	return nil
+}
+
+func (s *memoryPaymentModel) ListOfPayments(ctx context.Context, orderID string) ([]model.PaymentTransaction, error) {
+	return nil, nil
+}
+
+type memoryRefundModel struct {
+	refunds []model.RefundBaseModel
+}
+
+func (s *memoryRefundModel) SetRefund(ctx context.Context, param *model.RefundBaseModel) (int, error) {
+	s.refunds = append(s.refunds, *param)
+	return len(s.refunds), nil
+}
+
+func (s *memoryRefundModel) UpdateRefundStatus(ctx context.Context, refundID int, status, failureReason string) error {
+	s.refunds[refundID-1].Status = status
+	return nil
+}
+
+func (s *memoryRefundModel) GetLatestRefund(ctx context.Context, orderID string) (*model.RefundBaseModel, error) {
+	if len(s.refunds) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	res := s.refunds[len(s.refunds)-1]
+	res.ID = len(s.refunds)
+	return &res, nil
+}
+
+type memoryAssignment struct{}
+
+func (s *memoryAssignment) AssignMechanic(ctx context.Context, orderID string) error {
+	return nil
+}
+
+func (s *memoryAssignment) QueueAssignment(ctx context.Context, orderID, reason string) error {
+	return nil
+}
+
+func (s *memoryAssignment) AssignQueuedOrders(ctx context.Context) error {
+	return nil
+}
+
+// every outcome the fake gateway can report goes through PaymentNotification and is acknowledged,
+// anything else makes the gateway send it again
+func TestPaymentNotification(t *testing.T) {
+	tt := []struct {
+		Name      string
+		Status    string
+		Simulated []string
+		Expected  string
+		Refund    string
+	}{
+		{Name: "Paid", Status: model.OrderStatus[1], Simulated: []string{payment.StatusPaid}, Expected: model.OrderStatus[2]},
+		{Name: "Pending", Status: model.OrderStatus[1], Simulated: []string{payment.StatusPending}, Expected: model.OrderStatus[1]},
+		{Name: "Failed", Status: model.OrderStatus[1], Simulated: []string{payment.StatusFailed}, Expected: model.OrderStatus[1]},
+		{Name: "Expired", Status: model.OrderStatus[1], Simulated: []string{payment.StatusExpired}, Expected: model.OrderStatus[6]},
+		{
+			Name:      "Paid after it was cancelled",
+			Status:    model.OrderStatus[6],
+			Simulated: []string{payment.StatusPaid},
+			Expected:  model.OrderStatus[6],
+			Refund:    model.RefundStatusProcessing,
+		},
+		{
+			Name:      "Refunded",
+			Status:    model.OrderStatus[6],
+			Simulated: []string{payment.StatusPaid, payment.StatusRefunded},
+			Expected:  model.OrderStatus[7],
+			Refund:    model.RefundStatusSucceeded,
+		},
+		{
+			Name:      "Refund failed",
+			Status:    model.OrderStatus[6],
+			Simulated: []string{payment.StatusPaid, payment.StatusRefundFailed},
+			Expected:  model.OrderStatus[6],
+			Refund:    model.RefundStatusFailed,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			orders := &memoryOrderModel{orders: map[string]*model.OrderBaseModel{
+				"order-1": {
+					ID:          "order-1",
+					UserID:      "user-1",
+					TotalPrice:  265000,
+					OrderStatus: sql.NullString{String: tc.Status, Valid: true},
+				},
+			}}
+			payments := &memoryPaymentModel{}
+			refunds := &memoryRefundModel{}
+			gateway := payment.NewFakeGateway(&payment.FakeConfig{ServerKey: "secret"})
+			paymentHandler := NewPaymentHandler(
+				controller.NewPayment(orders, nil, payments, gateway),
+				controller.NewOrder(orders, nil, nil, nil, refunds, gateway, &memoryAssignment{}, nil),
+				controller.NewRefund(orders, refunds, gateway),
+			)
+
+			var codes []int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				rec := httptest.NewRecorder()
+				paymentHandler.PaymentNotification(rec, r)
+				codes = append(codes, rec.Code)
+				w.WriteHeader(rec.Code)
+			}))
+			defer server.Close()
+
+			_, err := gateway.CreateTransaction(context.Background(), &payment.TransactionRequest{
+				OrderID:         "order-1",
+				GrossAmount:     265000,
+				NotificationURL: server.URL,
+			})
+			assert.NoError(t, err)
+
+			for _, status := range tc.Simulated {
+				assert.NoError(t, gateway.Simulate(context.Background(), "order-1", status))
+				latest := payments.notifications[len(payments.notifications)-1]
+				assert.True(t, latest.ProcessedAt.Valid, "%s notification is not processed", status)
+			}
+			assert.Len(t, codes, len(tc.Simulated))
+			for _, code := range codes {
+				assert.Equal(t, http.StatusOK, code)
+			}
+			assert.Equal(t, tc.Expected, orders.orders["order-1"].OrderStatus.String)
+
+			refund, err := refunds.GetLatestRefund(context.Background(), "order-1")
+			if tc.Refund == "" {
+				assert.Equal(t, sql.ErrNoRows, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.Refund, refund.Status)
+		})
+	}
+}
+
+// a refund requested again after the gateway rejected one is settled by its own notifications,
+// they are not taken for the ones of the rejected refund
+func TestPaymentNotificationRefundRequestedAgain(t *testing.T) {
+	orders := &memoryOrderModel{orders: map[string]*model.OrderBaseModel{
+		"order-1": {
+			ID:          "order-1",
+			UserID:      "user-1",
+			TotalPrice:  265000,
+			OrderStatus: sql.NullString{String: model.OrderStatus[6], Valid: true},
+		},
+	}}
+	payments := &memoryPaymentModel{}
+	refunds := &memoryRefundModel{}
+	gateway := payment.NewFakeGateway(&payment.FakeConfig{ServerKey: "secret"})
+	refundController := controller.NewRefund(orders, refunds, gateway)
+	paymentHandler := NewPaymentHandler(
+		controller.NewPayment(orders, nil, payments, gateway),
+		controller.NewOrder(orders, nil, nil, nil, refunds, gateway, &memoryAssignment{}, nil),
+		refundController,
+	)
+	server := httptest.NewServer(http.HandlerFunc(paymentHandler.PaymentNotification))
+	defer server.Close()
+
+	_, err := gateway.CreateTransaction(context.Background(), &payment.TransactionRequest{
+		OrderID:         "order-1",
+		GrossAmount:     265000,
+		NotificationURL: server.URL,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, gateway.Simulate(context.Background(), "order-1", payment.StatusPaid))
+
+	request := &controller.RefundRequest{OrderID: "order-1", Reason: "mechanic never came"}
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, gateway.Simulate(context.Background(), "order-1", payment.StatusRefundFailed))
+		assert.Equal(t, model.RefundStatusFailed, refunds.refunds[len(refunds.refunds)-1].Status)
+
+		res, err := refundController.RequestRefund(context.Background(), "admin-1", request)
+		assert.NoError(t, err)
+		assert.Equal(t, model.RefundStatusProcessing, res.Refund.Status)
+	}
+
+	assert.NoError(t, gateway.Simulate(context.Background(), "order-1", payment.StatusRefunded))
+	assert.Len(t, refunds.refunds, 3)
+	assert.Equal(t, model.RefundStatusSucceeded, refunds.refunds[2].Status)
+	assert.Equal(t, model.OrderStatus[7], orders.orders["order-1"].OrderStatus.String)
+}
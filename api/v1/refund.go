@@ -0,0 +1,44 @@
+package v1
+
+import (
+	"e-montir/api/handler"
+	"e-montir/controller"
+	"net/http"
+
+	"github.com/go-chi/chi"
+)
+
+type RefundHandler struct {
+	refundController controller.Refund
+}
+
+func NewRefundHandler(refundController controller.Refund) RefundHandler {
+	return RefundHandler{
+		refundController: refundController,
+	}
+}
+
+// endpoint for admin to refund a paid order which has been cancelled or disputed after it was done
+func (c *RefundHandler) RequestRefund(w http.ResponseWriter, r *http.Request) {
+	request := new(controller.RefundRequest)
+	if err := handler.DecodeJSON(r, request); err != nil {
+		handler.ResponseError(w, &handler.ParsePayloadError)
+		return
+	}
+	request.OrderID = chi.URLParam(r, "order_id")
+	actorID := handler.GetTokenClaim(r.Context()).ID
+
+	fieldsErr, err := request.ValidateRefundRequest()
+	if err != nil {
+		res := handler.DefaultUnprocessableEntityError(err.Error(), fieldsErr)
+		handler.GenerateResponse(w, http.StatusUnprocessableEntity, res)
+		return
+	}
+
+	res, err := c.refundController.RequestRefund(r.Context(), actorID, request)
+	if err != nil {
+		handler.ResponseError(w, err)
+		return
+	}
+	handler.GenerateResponse(w, http.StatusOK, res)
+}
@@ -3,6 +3,7 @@ package v1
 import (
 	"e-montir/api/handler"
 	"e-montir/controller"
+	"e-montir/model"
 	"net/http"
 
 	"github.com/go-chi/chi"
@@ -44,3 +45,183 @@ func (c *ReviewHandler) AddServiceReview(w http.ResponseWriter, r *http.Request)
 
 	handler.GenerateResponse(w, http.StatusOK, handler.DefaultSuccess{Success: true})
 }
+
+func (c *ReviewHandler) AddMechanicReview(w http.ResponseWriter, r *http.Request) {
+	request := new(controller.MechanicReviewRequest)
+	if err := handler.DecodeJSON(r, request); err != nil {
+		handler.ResponseError(w, &handler.ParsePayloadError)
+		return
+	}
+	request.OrderID = chi.URLParam(r, "order_id")
+	userID := handler.GetTokenClaim(r.Context()).ID
+
+	fieldsErr, err := request.ValidateMechanicReviewRequest()
+	if err != nil {
+		res := handler.DefaultUnprocessableEntityError(err.Error(), fieldsErr)
+		handler.GenerateResponse(w, http.StatusUnprocessableEntity, res)
+		return
+	}
+
+	err = c.reviewController.AddMechanicReview(r.Context(), userID, request)
+	if err != nil {
+		handler.ResponseError(w, err)
+		return
+	}
+
+	handler.GenerateResponse(w, http.StatusOK, handler.DefaultSuccess{Success: true})
+}
+
+func (c *ReviewHandler) ListOfServiceReviews(w http.ResponseWriter, r *http.Request) {
+	request := &controller.ServiceReviewListRequest{
+		ServiceIDString: chi.URLParam(r, "service_id"),
+		PageString:      r.URL.Query().Get("page"),
+		LimitString:     r.URL.Query().Get("limit"),
+		Sort:            r.URL.Query().Get("sort"),
+	}
+
+	fieldsErr, err := request.ValidateServiceReviewListRequest()
+	if err != nil {
+		res := handler.DefaultUnprocessableEntityError(err.Error(), fieldsErr)
+		handler.GenerateResponse(w, http.StatusUnprocessableEntity, res)
+		return
+	}
+
+	res, err := c.reviewController.ListOfServiceReviews(r.Context(), request)
+	if err != nil {
+		handler.ResponseError(w, err)
+		return
+	}
+	handler.GenerateResponse(w, http.StatusOK, res)
+}
+
+// endpoint for customer to change their review while the edit window is open
+func (c *ReviewHandler) UpdateServiceReview(w http.ResponseWriter, r *http.Request) {
+	request := new(controller.UpdateServiceReviewRequest)
+	if err := handler.DecodeJSON(r, request); err != nil {
+		handler.ResponseError(w, &handler.ParsePayloadError)
+		return
+	}
+	request.ReviewIDString = chi.URLParam(r, "review_id")
+	userID := handler.GetTokenClaim(r.Context()).ID
+
+	fieldsErr, err := request.ValidateUpdateServiceReviewRequest()
+	if err != nil {
+		res := handler.DefaultUnprocessableEntityError(err.Error(), fieldsErr)
+		handler.GenerateResponse(w, http.StatusUnprocessableEntity, res)
+		return
+	}
+
+	err = c.reviewController.UpdateServiceReview(r.Context(), userID, request)
+	if err != nil {
+		handler.ResponseError(w, err)
+		return
+	}
+	handler.GenerateResponse(w, http.StatusOK, handler.DefaultSuccess{Success: true})
+}
+
+func (c *ReviewHandler) DeleteServiceReview(w http.ResponseWriter, r *http.Request) {
+	request := &controller.ServiceReviewIDRequest{
+		ReviewIDString: chi.URLParam(r, "review_id"),
+	}
+	userID := handler.GetTokenClaim(r.Context()).ID
+
+	fieldsErr, err := request.ValidateServiceReviewIDRequest()
+	if err != nil {
+		res := handler.DefaultUnprocessableEntityError(err.Error(), fieldsErr)
+		handler.GenerateResponse(w, http.StatusUnprocessableEntity, res)
+		return
+	}
+
+	err = c.reviewController.DeleteServiceReview(r.Context(), userID, request)
+	if err != nil {
+		handler.ResponseError(w, err)
+		return
+	}
+	handler.GenerateResponse(w, http.StatusOK, handler.DefaultSuccess{Success: true})
+}
+
+// endpoint for admin to go through the reviews waiting for moderation or the hidden ones
+func (c *ReviewHandler) ListOfModerationQueue(w http.ResponseWriter, r *http.Request) {
+	request := &controller.ModerationQueueRequest{
+		Status:      r.URL.Query().Get("status"),
+		PageString:  r.URL.Query().Get("page"),
+		LimitString: r.URL.Query().Get("limit"),
+	}
+
+	fieldsErr, err := request.ValidateModerationQueueRequest()
+	if err != nil {
+		res := handler.DefaultUnprocessableEntityError(err.Error(), fieldsErr)
+		handler.GenerateResponse(w, http.StatusUnprocessableEntity, res)
+		return
+	}
+
+	res, err := c.reviewController.ListOfModerationQueue(r.Context(), request)
+	if err != nil {
+		handler.ResponseError(w, err)
+		return
+	}
+	handler.GenerateResponse(w, http.StatusOK, res)
+}
+
+func (c *ReviewHandler) HideServiceReview(w http.ResponseWriter, r *http.Request) {
+	c.moderateServiceReview(w, r, model.ModerationActionHide)
+}
+
+func (c *ReviewHandler) RestoreServiceReview(w http.ResponseWriter, r *http.Request) {
+	c.moderateServiceReview(w, r, model.ModerationActionRestore)
+}
+
+func (c *ReviewHandler) ApproveServiceReview(w http.ResponseWriter, r *http.Request) {
+	c.moderateServiceReview(w, r, model.ModerationActionApprove)
+}
+
+func (c *ReviewHandler) moderateServiceReview(w http.ResponseWriter, r *http.Request, action string) {
+	request := new(controller.ReviewModerationRequest)
+	if r.ContentLength != 0 {
+		if err := handler.DecodeJSON(r, request); err != nil {
+			handler.ResponseError(w, &handler.ParsePayloadError)
+			return
+		}
+	}
+	request.ReviewIDString = chi.URLParam(r, "review_id")
+	request.Action = action
+	moderatorID := handler.GetTokenClaim(r.Context()).ID
+
+	fieldsErr, err := request.ValidateReviewModerationRequest()
+	if err != nil {
+		res := handler.DefaultUnprocessableEntityError(err.Error(), fieldsErr)
+		handler.GenerateResponse(w, http.StatusUnprocessableEntity, res)
+		return
+	}
+
+	err = c.reviewController.ModerateServiceReview(r.Context(), moderatorID, request)
+	if err != nil {
+		handler.ResponseError(w, err)
+		return
+	}
+	handler.GenerateResponse(w, http.StatusOK, handler.DefaultSuccess{Success: true})
+}
+
+// endpoint for the workshop to answer a review publicly
+func (c *ReviewHandler) ReplyServiceReview(w http.ResponseWriter, r *http.Request) {
+	request := new(controller.ReviewReplyRequest)
+	if err := handler.DecodeJSON(r, request); err != nil {
+		handler.ResponseError(w, &handler.ParsePayloadError)
+		return
+	}
+	request.ReviewIDString = chi.URLParam(r, "review_id")
+
+	fieldsErr, err := request.ValidateReviewReplyRequest()
+	if err != nil {
+		res := handler.DefaultUnprocessableEntityError(err.Error(), fieldsErr)
+		handler.GenerateResponse(w, http.StatusUnprocessableEntity, res)
+		return
+	}
+
+	err = c.reviewController.ReplyServiceReview(r.Context(), request)
+	if err != nil {
+		handler.ResponseError(w, err)
+		return
+	}
+	handler.GenerateResponse(w, http.StatusOK, handler.DefaultSuccess{Success: true})
+}
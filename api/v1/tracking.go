@@ -0,0 +1,142 @@
+package v1
+
+import (
+	"e-montir/api/handler"
+	"e-montir/controller"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/rs/zerolog/log"
+)
+
+const trackingKeepAlive = 15 * time.Second
+
+type TrackingHandler struct {
+	trackingController controller.Tracking
+}
+
+func NewTrackingHandler(trackingController controller.Tracking) TrackingHandler {
+	return TrackingHandler{
+		trackingController: trackingController,
+	}
+}
+
+// endpoint for the mechanic app to push the current GPS position
+func (c *TrackingHandler) UpdateLocation(w http.ResponseWriter, r *http.Request) {
+	request := new(controller.LocationRequest)
+	if err := handler.DecodeJSON(r, request); err != nil {
+		handler.ResponseError(w, &handler.ParsePayloadError)
+		return
+	}
+
+	fieldsErr, err := request.ValidateLocationRequest()
+	if err != nil {
+		res := handler.DefaultUnprocessableEntityError(err.Error(), fieldsErr)
+		handler.GenerateResponse(w, http.StatusUnprocessableEntity, res)
+		return
+	}
+
+	userID := handler.GetTokenClaim(r.Context()).ID
+	err = c.trackingController.UpdateLocation(r.Context(), userID, request)
+	if err != nil {
+		handler.ResponseError(w, err)
+		return
+	}
+	handler.GenerateResponse(w, http.StatusOK, handler.DefaultSuccess{Success: true})
+}
+
+// endpoint for customer to follow the mechanic through Server-Sent Events. The stream
+// ends once the mechanic arrives, clients reconnect when the server cuts it short and
+// receive the last known position first. The WriteTimeout of the server does not apply
+// to the stream, it would cut every stream after the same time otherwise.
+func (c *TrackingHandler) TrackOrder(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		handler.ResponseError(w, &handler.InternalServerError)
+		return
+	}
+
+	userID := handler.GetTokenClaim(r.Context()).ID
+	orderID := chi.URLParam(r, "order_id")
+
+	sub, err := c.trackingController.TrackOrder(r.Context(), userID, orderID)
+	if err != nil {
+		handler.ResponseError(w, err)
+		return
+	}
+	defer sub.Close()
+
+	if !clearWriteDeadline(w) {
+		log.Warn().Msg("write deadline cannot be cleared, tracking stream ends at the server WriteTimeout")
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	_, _ = fmt.Fprint(w, "retry: 5000\n\n")
+
+	if !writeTrackingEvent(w, flusher, sub.Initial) || sub.Initial.Finished() {
+		return
+	}
+
+	keepAlive := time.NewTicker(trackingKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case update, ok := <-sub.Updates:
+			if !ok {
+				return
+			}
+			event := sub.Event(update)
+			if !writeTrackingEvent(w, flusher, event) || event.Finished() {
+				return
+			}
+		}
+	}
+}
+
+func writeTrackingEvent(w http.ResponseWriter, flusher http.Flusher, event controller.TrackingEvent) bool {
+	name := "status"
+	if event.Position != nil {
+		name = "location"
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when marshalling tracking event: %w", err)).Send()
+		return false
+	}
+
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, data); err != nil {
+		return false
+	}
+	flusher.Flush()
+	return true
+}
+
+// clearWriteDeadline lifts the write deadline of the connection behind w, a writer wrapped by a
+// middleware is unwrapped first. http.ResponseController is not used as it needs go 1.20.
+func clearWriteDeadline(w http.ResponseWriter) bool {
+	for {
+		switch rw := w.(type) {
+		case interface{ SetWriteDeadline(time.Time) error }:
+			return rw.SetWriteDeadline(time.Time{}) == nil
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = rw.Unwrap()
+		default:
+			return false
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package v1
+
+import (
+	"e-montir/pkg/media"
+	"io"
+	"net/http"
+)
+
+// parseImageForm reads a multipart form holding at most one image, the body is capped
+// a little above the image limit so oversized uploads are cut off early.
+func parseImageForm(w http.ResponseWriter, r *http.Request) error {
+	r.Body = http.MaxBytesReader(w, r.Body, media.MaxImageSize+(1<<20))
+	return r.ParseMultipartForm(media.MaxImageSize)
+}
+
+// formImage returns the content of the image field, nil when it was not sent, one byte over
+// the limit is read so the size validation can tell an oversized image apart.
+func formImage(r *http.Request, field string) ([]byte, error) {
+	file, _, err := r.FormFile(field)
+	if err != nil {
+		if err == http.ErrMissingFile {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(io.LimitReader(file, media.MaxImageSize+1))
+}
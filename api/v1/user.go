@@ -49,3 +49,49 @@ func (c *UserHandler) ListOfUserLocation(w http.ResponseWriter, r *http.Request)
 	}
 	handler.GenerateResponse(w, http.StatusOK, res)
 }
+
+func (c *UserHandler) RegisterDevice(w http.ResponseWriter, r *http.Request) {
+	request := new(controller.RegisterDeviceRequest)
+	if err := handler.DecodeJSON(r, request); err != nil {
+		handler.ResponseError(w, &handler.ParsePayloadError)
+		return
+	}
+
+	fieldsErr, err := request.ValidateRegisterDevice()
+	if err != nil {
+		res := handler.DefaultUnprocessableEntityError(err.Error(), fieldsErr)
+		handler.GenerateResponse(w, http.StatusUnprocessableEntity, res)
+		return
+	}
+
+	userID := handler.GetTokenClaim(r.Context()).ID
+	err = c.userController.RegisterDevice(r.Context(), userID, request)
+	if err != nil {
+		handler.ResponseError(w, err)
+		return
+	}
+	handler.GenerateResponse(w, http.StatusOK, handler.DefaultSuccess{Success: true})
+}
+
+func (c *UserHandler) RemoveDevice(w http.ResponseWriter, r *http.Request) {
+	request := new(controller.RemoveDeviceRequest)
+	if err := handler.DecodeJSON(r, request); err != nil {
+		handler.ResponseError(w, &handler.ParsePayloadError)
+		return
+	}
+
+	fieldsErr, err := request.ValidateRemoveDevice()
+	if err != nil {
+		res := handler.DefaultUnprocessableEntityError(err.Error(), fieldsErr)
+		handler.GenerateResponse(w, http.StatusUnprocessableEntity, res)
+		return
+	}
+
+	userID := handler.GetTokenClaim(r.Context()).ID
+	err = c.userController.RemoveDevice(r.Context(), userID, request)
+	if err != nil {
+		handler.ResponseError(w, err)
+		return
+	}
+	handler.GenerateResponse(w, http.StatusOK, handler.DefaultSuccess{Success: true})
+}
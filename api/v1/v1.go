@@ -6,25 +6,37 @@ import (
 )
 
 type Handler struct {
-	Auth     AuthHandler
-	Service  ServiceHandler
-	Timeslot TimeslotHandler
-	Cart     CartHandler
-	User     UserHandler
-	Order    OrderHandler
-	Payment  PaymentHandler
-	Review   ReviewHandler
+	Auth         AuthHandler
+	Service      ServiceHandler
+	Timeslot     TimeslotHandler
+	Cart         CartHandler
+	User         UserHandler
+	Order        OrderHandler
+	Payment      PaymentHandler
+	Review       ReviewHandler
+	Refund       RefundHandler
+	Mechanic     MechanicHandler
+	Tracking     TrackingHandler
+	Chat         ChatHandler
+	Attachment   AttachmentHandler
+	Notification NotificationHandler
 }
 
-func GetHandler(c controller.Manager, mailerCfg *mailer.Config) Handler {
+func GetHandler(c controller.Manager, mailer *mailer.Mailer) Handler {
 	return Handler{
-		Auth:     NewAuthHandler(c.Auth(), mailerCfg),
-		Service:  NewServiceHandler(c.Service()),
-		Timeslot: NewTimeslotHandler(c.Timeslot()),
-		Cart:     NewCartHandler(c.Cart()),
-		User:     NewUserHandler(c.User()),
-		Order:    NewOrderHandler(c.Order()),
-		Payment:  NewPaymentHandler(c.Payment(), c.Order()),
-		Review:   NewReviewHandler(c.Review()),
+		Auth:         NewAuthHandler(c.Auth(), mailer),
+		Service:      NewServiceHandler(c.Service()),
+		Timeslot:     NewTimeslotHandler(c.Timeslot()),
+		Cart:         NewCartHandler(c.Cart()),
+		User:         NewUserHandler(c.User()),
+		Order:        NewOrderHandler(c.Order()),
+		Payment:      NewPaymentHandler(c.Payment(), c.Order(), c.Refund()),
+		Review:       NewReviewHandler(c.Review()),
+		Refund:       NewRefundHandler(c.Refund()),
+		Mechanic:     NewMechanicHandler(c.MechanicJob()),
+		Tracking:     NewTrackingHandler(c.Tracking()),
+		Chat:         NewChatHandler(c.Chat()),
+		Attachment:   NewAttachmentHandler(c.Attachment()),
+		Notification: NewNotificationHandler(c.Notification()),
 	}
 }
@@ -0,0 +1,24 @@
+// Command recompute_ratings rebuilds the stored service and mechanic ratings from every review,
+// run it once after deploying the rating aggregates or whenever the stored values drift.
+package main
+
+import (
+	"context"
+	"e-montir/model"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+func main() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	reviewModel := model.NewReview(model.NewSQLDB())
+	err := reviewModel.RecomputeRatings(ctx)
+	if err != nil {
+		log.Fatal().Err(fmt.Errorf("error when RecomputeRatings : %w", err)).Send()
+	}
+	log.Info().Msg("ratings recomputed")
+}
@@ -0,0 +1,180 @@
+package controller
+
+import (
+	"context"
+	"e-montir/model"
+	"e-montir/pkg/assignment"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const assignmentQueueBatch = 50
+
+type assignmentCtx struct {
+	assignmentModel model.Assignment
+	orderModel      model.Order
+	strategy        assignment.Strategy
+}
+
+type Assignment interface {
+	AssignMechanic(ctx context.Context, orderID string) error
+	QueueAssignment(ctx context.Context, orderID, reason string) error
+	AssignQueuedOrders(ctx context.Context) error
+}
+
+func NewAssignment(assignmentModel model.Assignment, orderModel model.Order, strategy assignment.Strategy) Assignment {
+	return &assignmentCtx{
+		assignmentModel: assignmentModel,
+		orderModel:      orderModel,
+		strategy:        strategy,
+	}
+}
+
+// AssignMechanic gives a paid order to the mechanic picked by the strategy,
+// the order waits in the queue when nobody qualifies yet.
+func (c *assignmentCtx) AssignMechanic(ctx context.Context, orderID string) error {
+	order, err := c.orderModel.GetOrderByOrderID(ctx, orderID)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when GetOrderByOrderID : %w", err)).Send()
+		return err
+	}
+
+	// the order has been cancelled or taken care of in the meantime
+	if order.MechanicID.Valid || order.OrderStatus.String != model.OrderStatus[2] {
+		return c.assignmentModel.RemoveQueuedAssignment(ctx, orderID)
+	}
+
+	job, err := c.assignmentJob(ctx, order)
+	if err != nil {
+		return err
+	}
+
+	appointmentDate, err := time.Parse(time.RFC3339, order.Date)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when parsingDate: %w", err)).Send()
+		return err
+	}
+
+	rows, err := c.assignmentModel.ListOfAssignmentCandidates(ctx, appointmentDate.Format("2006-01-02"), order.TimeSlot)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when ListOfAssignmentCandidates : %w", err)).Send()
+		return err
+	}
+
+	mechanicID, ok := c.strategy.Pick(*job, assignmentCandidates(rows))
+	if !ok {
+		log.Warn().Msg(fmt.Sprintf("no mechanic qualifies for order %s, queueing it", orderID))
+		err = c.assignmentModel.QueueAssignment(ctx, orderID, "no mechanic qualifies")
+		if err != nil {
+			log.Error().Err(fmt.Errorf("error when QueueAssignment : %w", err)).Send()
+		}
+		return err
+	}
+
+	assigned, err := c.assignmentModel.AssignMechanic(ctx, orderID, mechanicID)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when AssignMechanic : %w", err)).Send()
+		return err
+	}
+	if !assigned {
+		return c.assignmentModel.RemoveQueuedAssignment(ctx, orderID)
+	}
+
+	log.Info().Msg(fmt.Sprintf("order %s assigned to mechanic %d", orderID, mechanicID))
+	return nil
+}
+
+// QueueAssignment leaves the order to AssignQueuedOrders, e.g. when assigning it right away failed
+func (c *assignmentCtx) QueueAssignment(ctx context.Context, orderID, reason string) error {
+	err := c.assignmentModel.QueueAssignment(ctx, orderID, reason)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when QueueAssignment : %w", err)).Send()
+	}
+	return err
+}
+
+// AssignQueuedOrders retries the orders nobody qualified for, the one tried least recently first.
+// An order which fails again is queued again, which moves it behind the others.
+func (c *assignmentCtx) AssignQueuedOrders(ctx context.Context) error {
+	queued, err := c.assignmentModel.ListOfQueuedAssignments(ctx, assignmentQueueBatch)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when ListOfQueuedAssignments : %w", err)).Send()
+		return err
+	}
+
+	for _, v := range queued {
+		err = c.AssignMechanic(ctx, v.OrderID)
+		if err != nil {
+			log.Error().Err(fmt.Errorf("error when assigning queued order %s : %w", v.OrderID, err)).Send()
+			_ = c.QueueAssignment(ctx, v.OrderID, "assignment failed")
+		}
+	}
+	return nil
+}
+
+func (c *assignmentCtx) assignmentJob(ctx context.Context, order *model.OrderBaseModel) (*assignment.Job, error) {
+	job := &assignment.Job{
+		OrderID: order.ID,
+	}
+
+	location, err := c.orderModel.OrderLocation(ctx, order.UserAddressID)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when getOrderLocation : %w", err)).Send()
+		return nil, err
+	}
+
+	// addresses saved without coordinates are assigned regardless of distance
+	latitude, latErr := strconv.ParseFloat(location.Latitude, 64)
+	longitude, longErr := strconv.ParseFloat(location.Longitude, 64)
+	if latErr == nil && longErr == nil {
+		job.Latitude = latitude
+		job.Longitude = longitude
+		job.HasLocation = true
+	}
+
+	job.Categories, err = c.assignmentModel.ListOfOrderCategories(ctx, order.ID)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when ListOfOrderCategories : %w", err)).Send()
+		return nil, err
+	}
+	return job, nil
+}
+
+func assignmentCandidates(rows []model.AssignmentCandidate) []assignment.Candidate {
+	candidates := make([]assignment.Candidate, 0, len(rows))
+	for _, v := range rows {
+		candidate := assignment.Candidate{
+			MechanicID:  v.MechanicID,
+			Latitude:    v.Latitude.Float64,
+			Longitude:   v.Longitude.Float64,
+			HasLocation: v.Latitude.Valid && v.Longitude.Valid,
+			JobsOnDate:  v.JobsOnDate,
+			JobsOnSlot:  v.JobsOnSlot,
+			Rating:      v.Rating,
+			ReviewCount: v.ReviewCount,
+		}
+		if v.Skills.Valid && v.Skills.String != "" {
+			candidate.Skills = strings.Split(v.Skills.String, ",")
+		}
+		candidates = append(candidates, candidate)
+	}
+	return candidates
+}
+
+// newAssignmentStrategy builds the default scorer, ASSIGNMENT_MAX_DISTANCE is in km
+func newAssignmentStrategy() assignment.Strategy {
+	maxDistance, err := strconv.ParseFloat(os.Getenv("ASSIGNMENT_MAX_DISTANCE"), 64)
+	if err != nil || maxDistance < 0 {
+		maxDistance = 20
+	}
+	maxJobsPerDay, err := strconv.Atoi(os.Getenv("ASSIGNMENT_MAX_JOBS_PER_DAY"))
+	if err != nil || maxJobsPerDay < 0 {
+		maxJobsPerDay = 4
+	}
+	return assignment.NewScorer(maxDistance, maxJobsPerDay)
+}
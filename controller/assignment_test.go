@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"context"
+	"e-montir/model"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// stubAssignmentModel keeps the queue the way mechanic_assignment_queue does, the methods
+// the queue sweep does not need are left to the embedded nil interface.
+type stubAssignmentModel struct {
+	model.Assignment
+	queue map[string]*model.QueuedAssignment
+}
+
+func (s *stubAssignmentModel) QueueAssignment(ctx context.Context, orderID, reason string) error {
+	now := time.Now()
+	queued, ok := s.queue[orderID]
+	if !ok {
+		s.queue[orderID] = &model.QueuedAssignment{OrderID: orderID, Reason: reason, Attempts: 1, CreatedAt: now, UpdatedAt: now}
+		return nil
+	}
+	queued.Reason = reason
+	queued.Attempts++
+	queued.UpdatedAt = now
+	return nil
+}
+
+func (s *stubAssignmentModel) ListOfQueuedAssignments(ctx context.Context, limit int) ([]model.QueuedAssignment, error) {
+	result := make([]model.QueuedAssignment, 0, len(s.queue))
+	for _, v := range s.queue {
+		result = append(result, *v)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].UpdatedAt.Before(result[j].UpdatedAt) })
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+func (s *stubAssignmentModel) RemoveQueuedAssignment(ctx context.Context, orderID string) error {
+	delete(s.queue, orderID)
+	return nil
+}
+
+func TestAssignQueuedOrders(t *testing.T) {
+	queuedAt := time.Now().Add(-time.Hour)
+	assignments := &stubAssignmentModel{queue: map[string]*model.QueuedAssignment{
+		"order-1": {OrderID: "order-1", Reason: "no mechanic qualifies", Attempts: 1, CreatedAt: queuedAt, UpdatedAt: queuedAt},
+		"order-2": {OrderID: "order-2", Reason: "no mechanic qualifies", Attempts: 1, CreatedAt: queuedAt, UpdatedAt: queuedAt.Add(time.Minute)},
+	}}
+	// order-1 cannot be loaded, order-2 was cancelled while it waited
+	orders := newStubOrderModel(newOrderBaseModel("order-2", model.OrderStatus[6], 265000))
+	c := NewAssignment(assignments, orders, nil)
+
+	assert.NoError(t, c.AssignQueuedOrders(context.Background()))
+	assert.Len(t, assignments.queue, 1)
+
+	// the failed order is queued again, so it goes behind the orders which waited since it was tried
+	failed := assignments.queue["order-1"]
+	assert.Equal(t, 2, failed.Attempts)
+	assert.Equal(t, "assignment failed", failed.Reason)
+	assert.True(t, failed.UpdatedAt.After(queuedAt.Add(time.Minute)))
+}
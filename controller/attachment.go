@@ -0,0 +1,361 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"e-montir/api/handler"
+	"e-montir/model"
+	"e-montir/pkg/media"
+	"e-montir/pkg/uuid"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+type attachmentCtx struct {
+	attachmentModel model.Attachment
+	orderModel      model.Order
+	mechanicModel   model.Mechanic
+	reviewModel     model.Review
+	mediaStore      media.Storage
+	editWindow      time.Duration
+}
+
+type Attachment interface {
+	UploadOrderAttachment(ctx context.Context, userID string, form *OrderAttachmentRequest) (*AttachmentResponse, error)
+	ListOfOrderAttachments(ctx context.Context, userID, orderID string) (*AttachmentListResponse, error)
+	UploadReviewAttachment(ctx context.Context, userID string, form *ReviewAttachmentRequest) (*AttachmentResponse, error)
+	GetMedia(ctx context.Context, userID, key string) (*MediaFile, error)
+}
+
+func NewAttachment(attachmentModel model.Attachment, orderModel model.Order, mechanicModel model.Mechanic, reviewModel model.Review, mediaStore media.Storage) Attachment {
+	return &attachmentCtx{
+		attachmentModel: attachmentModel,
+		orderModel:      orderModel,
+		mechanicModel:   mechanicModel,
+		reviewModel:     reviewModel,
+		mediaStore:      mediaStore,
+		editWindow:      reviewEditWindow(),
+	}
+}
+
+type (
+	OrderAttachmentRequest struct {
+		OrderID string
+		Kind    string
+		Image   []byte
+	}
+
+	ReviewAttachmentRequest struct {
+		ReviewIDString string
+		ReviewID       int
+		Image          []byte
+	}
+
+	AttachmentData struct {
+		ID           string `json:"id"`
+		Kind         string `json:"kind"`
+		URL          string `json:"url"`
+		ThumbnailURL string `json:"thumbnail_url"`
+		ContentType  string `json:"content_type"`
+		Size         int    `json:"size"`
+		CreatedAt    string `json:"created_at"`
+	}
+
+	AttachmentResponse struct {
+		Data AttachmentData `json:"data"`
+	}
+
+	AttachmentListResponse struct {
+		Data []AttachmentData `json:"data"`
+	}
+
+	MediaFile struct {
+		ContentType string
+		Content     []byte
+	}
+)
+
+func validateImage(content []byte) *handler.Fields {
+	if len(content) == 0 {
+		return &handler.Fields{
+			Name:    "image",
+			Message: "image cannot be empty",
+		}
+	}
+	if len(content) > media.MaxImageSize {
+		return &handler.Fields{
+			Name:    "image",
+			Message: "image cannot exceed 5 MB",
+		}
+	}
+	if _, err := media.ImageExtension(content); err != nil {
+		return &handler.Fields{
+			Name:    "image",
+			Message: err.Error(),
+		}
+	}
+	return nil
+}
+
+func (req *OrderAttachmentRequest) ValidateOrderAttachmentRequest() ([]handler.Fields, error) {
+	var count int
+	var fields []handler.Fields
+	if req.Kind != model.AttachmentKindBefore && req.Kind != model.AttachmentKindAfter {
+		count++
+		fields = append(fields, handler.Fields{
+			Name:    "kind",
+			Message: "kind must be before or after",
+		})
+	}
+
+	if fieldErr := validateImage(req.Image); fieldErr != nil {
+		count++
+		fields = append(fields, *fieldErr)
+	}
+
+	if count == 0 {
+		return nil, nil
+	}
+	return fields, errors.New(handler.ValidationFailed)
+}
+
+func (req *ReviewAttachmentRequest) ValidateReviewAttachmentRequest() ([]handler.Fields, error) {
+	var count int
+	var fields []handler.Fields
+	reviewID, err := strconv.Atoi(req.ReviewIDString)
+	if err != nil || reviewID < 1 {
+		count++
+		fields = append(fields, handler.Fields{
+			Name:    "review_id",
+			Message: "review_id must be more than 0",
+		})
+	}
+	req.ReviewID = reviewID
+
+	if fieldErr := validateImage(req.Image); fieldErr != nil {
+		count++
+		fields = append(fields, *fieldErr)
+	}
+
+	if count == 0 {
+		return nil, nil
+	}
+	return fields, errors.New(handler.ValidationFailed)
+}
+
+// isOrderAttachmentOpen tells whether the mechanic may take photos of the job, from arrival until it is done
+func isOrderAttachmentOpen(order *model.OrderBaseModel) bool {
+	switch order.OrderStatus.String {
+	case model.OrderStatus[4], model.OrderStatus[5]:
+		return true
+	}
+	return false
+}
+
+// UploadOrderAttachment stores a before or after photo taken by the mechanic handling the order
+func (c *attachmentCtx) UploadOrderAttachment(ctx context.Context, userID string, form *OrderAttachmentRequest) (*AttachmentResponse, error) {
+	order, role, err := authorizeParticipant(ctx, c.orderModel, c.mechanicModel, userID, form.OrderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if role != model.MessageSenderMechanic {
+		return nil, &handler.ForbiddenError
+	}
+
+	if !isOrderAttachmentOpen(order) {
+		return nil, &handler.OrderAttachmentClosed
+	}
+
+	attachment := &model.AttachmentBaseModel{
+		OrderID:    sql.NullString{String: form.OrderID, Valid: true},
+		UploaderID: userID,
+		Kind:       form.Kind,
+	}
+	err = c.saveAttachment(ctx, "orders/"+form.OrderID, attachment, form.Image)
+	if err != nil {
+		return nil, err
+	}
+	return &AttachmentResponse{Data: attachmentData(c.mediaStore, attachment)}, nil
+}
+
+func (c *attachmentCtx) ListOfOrderAttachments(ctx context.Context, userID, orderID string) (*AttachmentListResponse, error) {
+	_, _, err := authorizeParticipant(ctx, c.orderModel, c.mechanicModel, userID, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.attachmentModel.ListOfOrderAttachments(ctx, orderID)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when ListOfOrderAttachments : %w", err)).Send()
+		return nil, &handler.InternalServerError
+	}
+
+	response := &AttachmentListResponse{
+		Data: make([]AttachmentData, 0, len(res)),
+	}
+	for i := range res {
+		response.Data = append(response.Data, attachmentData(c.mediaStore, &res[i]))
+	}
+	return response, nil
+}
+
+// UploadReviewAttachment adds a photo to the review, like editing it is only open to its author within the edit window
+func (c *attachmentCtx) UploadReviewAttachment(ctx context.Context, userID string, form *ReviewAttachmentRequest) (*AttachmentResponse, error) {
+	review, err := c.reviewModel.GetServiceReviewByID(ctx, form.ReviewID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, &handler.ReviewNotFound
+		}
+		log.Error().Err(fmt.Errorf("error when GetServiceReviewByID : %w", err)).Send()
+		return nil, &handler.InternalServerError
+	}
+
+	err = authorizeReviewChange(review, userID, time.Now(), c.editWindow)
+	if err != nil {
+		return nil, err
+	}
+
+	attachment := &model.AttachmentBaseModel{
+		FeedbackID: sql.NullInt64{Int64: int64(form.ReviewID), Valid: true},
+		UploaderID: userID,
+		Kind:       model.AttachmentKindReview,
+	}
+	err = c.saveAttachment(ctx, fmt.Sprintf("reviews/%d", form.ReviewID), attachment, form.Image)
+	if err != nil {
+		return nil, err
+	}
+	return &AttachmentResponse{Data: attachmentData(c.mediaStore, attachment)}, nil
+}
+
+// GetMedia loads an uploaded file for the user. Photos of reviews are shown to every user, the photos of an order
+// and the images of its chat only to its customer and its assigned mechanic, anyone else gets not found.
+func (c *attachmentCtx) GetMedia(ctx context.Context, userID, key string) (*MediaFile, error) {
+	segments := strings.Split(key, "/")
+	if !media.ValidKey(key) || len(segments) != 3 {
+		return nil, &handler.MediaNotFound
+	}
+
+	switch segments[0] {
+	case "reviews":
+	case "orders", "chat":
+		_, _, err := authorizeParticipant(ctx, c.orderModel, c.mechanicModel, userID, segments[1])
+		if err != nil {
+			if errors.Is(err, &handler.OrderNotFound) {
+				return nil, &handler.MediaNotFound
+			}
+			return nil, err
+		}
+	default:
+		return nil, &handler.MediaNotFound
+	}
+
+	content, err := c.mediaStore.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, media.ErrNotExist) {
+			return nil, &handler.MediaNotFound
+		}
+		log.Error().Err(fmt.Errorf("error when getting media %s: %w", key, err)).Send()
+		return nil, &handler.InternalServerError
+	}
+
+	contentType, _, err := media.ImageType(content)
+	if err != nil {
+		contentType = "application/octet-stream"
+	}
+	return &MediaFile{
+		ContentType: contentType,
+		Content:     content,
+	}, nil
+}
+
+// saveAttachment stores the image with its thumbnail under the prefix and records the attachment,
+// the stored files are removed again when the owner has no room left.
+func (c *attachmentCtx) saveAttachment(ctx context.Context, prefix string, attachment *model.AttachmentBaseModel, image []byte) error {
+	attachmentID, err := uuid.GenerateUUID()
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when generateUUID: %w", err)).Send()
+		return &handler.InternalServerError
+	}
+
+	contentType, ext, err := media.ImageType(image)
+	if err != nil {
+		return &handler.ParsePayloadError
+	}
+
+	attachment.ID = attachmentID
+	attachment.StorageKey = fmt.Sprintf("%s/%s%s", prefix, attachmentID, ext)
+	attachment.ContentType = contentType
+	attachment.Size = len(image)
+	attachment.CreatedAt = time.Now()
+
+	err = c.mediaStore.Save(ctx, attachment.StorageKey, contentType, image)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when saving attachment: %w", err)).Send()
+		return &handler.InternalServerError
+	}
+
+	thumbnail, ok, err := media.Thumbnail(image, media.ThumbnailSize)
+	if err != nil {
+		// a broken image still gets stored, it is shown without a thumbnail
+		log.Error().Err(fmt.Errorf("error when generating thumbnail: %w", err)).Send()
+	}
+	if ok {
+		thumbnailKey := fmt.Sprintf("%s/%s_thumb.jpg", prefix, attachmentID)
+		err = c.mediaStore.Save(ctx, thumbnailKey, "image/jpeg", thumbnail)
+		if err != nil {
+			log.Error().Err(fmt.Errorf("error when saving thumbnail: %w", err)).Send()
+		} else {
+			attachment.ThumbnailKey = sql.NullString{String: thumbnailKey, Valid: true}
+		}
+	}
+
+	added, err := c.attachmentModel.SetAttachment(ctx, attachment)
+	if err != nil || !added {
+		deleteAttachmentFiles(ctx, c.mediaStore, []model.AttachmentBaseModel{*attachment})
+	}
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when SetAttachment : %w", err)).Send()
+		return &handler.InternalServerError
+	}
+	if !added {
+		return &handler.AttachmentLimitReached
+	}
+	return nil
+}
+
+func deleteAttachmentFiles(ctx context.Context, mediaStore media.Storage, attachments []model.AttachmentBaseModel) {
+	for _, v := range attachments {
+		keys := []string{v.StorageKey}
+		if v.ThumbnailKey.Valid {
+			keys = append(keys, v.ThumbnailKey.String)
+		}
+		for _, key := range keys {
+			if err := mediaStore.Delete(ctx, key); err != nil {
+				log.Error().Err(fmt.Errorf("error when deleting attachment %s: %w", key, err)).Send()
+			}
+		}
+	}
+}
+
+// attachmentData falls back to the original image when there is no thumbnail
+func attachmentData(mediaStore media.Storage, v *model.AttachmentBaseModel) AttachmentData {
+	data := AttachmentData{
+		ID:           v.ID,
+		Kind:         v.Kind,
+		URL:          mediaStore.URL(v.StorageKey),
+		ThumbnailURL: mediaStore.URL(v.StorageKey),
+		ContentType:  v.ContentType,
+		Size:         v.Size,
+		CreatedAt:    v.CreatedAt.Format(time.RFC3339),
+	}
+	if v.ThumbnailKey.Valid {
+		data.ThumbnailURL = mediaStore.URL(v.ThumbnailKey.String)
+	}
+	return data
+}
@@ -0,0 +1,143 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"e-montir/api/handler"
+	"e-montir/model"
+	"e-montir/pkg/media"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateOrderAttachmentRequest(t *testing.T) {
+	jpeg := []byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00")
+
+	t.Run("valid", func(t *testing.T) {
+		req := &OrderAttachmentRequest{Kind: model.AttachmentKindBefore, Image: jpeg}
+		_, err := req.ValidateOrderAttachmentRequest()
+		assert.NoError(t, err)
+	})
+
+	t.Run("unknown kind and not an image", func(t *testing.T) {
+		req := &OrderAttachmentRequest{Kind: "during", Image: []byte("plain text")}
+		fields, err := req.ValidateOrderAttachmentRequest()
+		assert.Error(t, err)
+		assert.Len(t, fields, 2)
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		req := &OrderAttachmentRequest{Kind: model.AttachmentKindAfter, Image: append(jpeg, make([]byte, media.MaxImageSize)...)}
+		fields, err := req.ValidateOrderAttachmentRequest()
+		assert.Error(t, err)
+		assert.Equal(t, "image cannot exceed 5 MB", fields[0].Message)
+	})
+}
+
+func TestIsOrderAttachmentOpen(t *testing.T) {
+	for status, open := range map[string]bool{
+		model.OrderStatus[2]: false,
+		model.OrderStatus[3]: false,
+		model.OrderStatus[4]: true,
+		model.OrderStatus[5]: true,
+		model.OrderStatus[6]: false,
+	} {
+		order := &model.OrderBaseModel{OrderStatus: sql.NullString{String: status, Valid: true}}
+		assert.Equal(t, open, isOrderAttachmentOpen(order), status)
+	}
+}
+
+func TestAttachmentData(t *testing.T) {
+	store := media.NewLocal("uploads", "/media")
+	attachment := &model.AttachmentBaseModel{
+		ID:          "attachment-1",
+		Kind:        model.AttachmentKindReview,
+		StorageKey:  "reviews/1/attachment-1.webp",
+		ContentType: "image/webp",
+		Size:        10,
+		CreatedAt:   time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+
+	data := attachmentData(store, attachment)
+	assert.Equal(t, "/media/reviews/1/attachment-1.webp", data.URL)
+	assert.Equal(t, data.URL, data.ThumbnailURL)
+
+	attachment.ThumbnailKey = sql.NullString{String: "reviews/1/attachment-1_thumb.jpg", Valid: true}
+	data = attachmentData(store, attachment)
+	assert.Equal(t, "/media/reviews/1/attachment-1_thumb.jpg", data.ThumbnailURL)
+}
+
+type stubOrderLookup struct {
+	model.Order
+	orders stubOrderFinder
+}
+
+func (s *stubOrderLookup) GetOrderByOrderID(ctx context.Context, orderID string) (*model.OrderBaseModel, error) {
+	return s.orders.GetOrderByOrderID(ctx, orderID)
+}
+
+type stubMechanicModel struct {
+	model.Mechanic
+	mechanics stubMechanicFinder
+}
+
+func (s *stubMechanicModel) GetMechanicByUserID(ctx context.Context, userID string) (*model.MechanicBaseModel, error) {
+	return s.mechanics.GetMechanicByUserID(ctx, userID)
+}
+
+func TestGetMedia(t *testing.T) {
+	dir, err := ioutil.TempDir("", "media")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	store := media.NewLocal(dir, "/media")
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR")
+	for _, key := range []string{"orders/order-1/a.png", "chat/order-1/b.png", "reviews/1/c.png"} {
+		assert.NoError(t, store.Save(context.Background(), key, "image/png", png))
+	}
+
+	c := &attachmentCtx{
+		orderModel: &stubOrderLookup{orders: stubOrderFinder{
+			"order-1": {ID: "order-1", UserID: "user-1", MechanicID: sql.NullInt64{Int64: 7, Valid: true}},
+		}},
+		mechanicModel: &stubMechanicModel{mechanics: stubMechanicFinder{
+			"mechanic-user-7": {ID: 7},
+			"mechanic-user-8": {ID: 8},
+		}},
+		mediaStore: store,
+	}
+
+	tt := []struct {
+		Name   string
+		UserID string
+		Key    string
+		Err    error
+	}{
+		{Name: "Customer sees order photo", UserID: "user-1", Key: "orders/order-1/a.png"},
+		{Name: "Assigned mechanic sees order photo", UserID: "mechanic-user-7", Key: "orders/order-1/a.png"},
+		{Name: "Customer sees chat image", UserID: "user-1", Key: "chat/order-1/b.png"},
+		{Name: "Other user sees review photo", UserID: "user-2", Key: "reviews/1/c.png"},
+		{Name: "Other user", UserID: "user-2", Key: "orders/order-1/a.png", Err: &handler.MediaNotFound},
+		{Name: "Other mechanic", UserID: "mechanic-user-8", Key: "chat/order-1/b.png", Err: &handler.MediaNotFound},
+		{Name: "Missing file", UserID: "user-1", Key: "orders/order-1/missing.png", Err: &handler.MediaNotFound},
+		{Name: "Unknown order", UserID: "user-1", Key: "orders/order-2/a.png", Err: &handler.MediaNotFound},
+		{Name: "Escaping the order", UserID: "user-1", Key: "orders/order-1/../../reviews/1/c.png", Err: &handler.MediaNotFound},
+		{Name: "Unknown prefix", UserID: "user-1", Key: "avatars/user-1/a.png", Err: &handler.MediaNotFound},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			res, err := c.GetMedia(context.Background(), tc.UserID, tc.Key)
+			assert.Equal(t, tc.Err, err)
+			if tc.Err != nil {
+				return
+			}
+			assert.Equal(t, "image/png", res.ContentType)
+			assert.Equal(t, png, res.Content)
+		})
+	}
+}
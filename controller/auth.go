@@ -5,31 +5,54 @@ import (
 	"database/sql"
 	"e-montir/api/handler"
 	"e-montir/model"
+	"e-montir/pkg/cache"
 	"e-montir/pkg/jwt"
 	"e-montir/pkg/password"
+	"e-montir/pkg/token"
 	"e-montir/pkg/uuid"
 	"e-montir/pkg/validator"
 	"errors"
 	"fmt"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/rs/zerolog/log"
 )
 
 type authCtx struct {
-	userModel model.User
+	userModel     model.User
+	sessionModel  model.Session
+	deviceModel   model.Device
+	revokedTokens *cache.TTLCache
 }
 
 type Auth interface {
 	Register(ctx context.Context, form *RegisterRequest) (string, error)
-	ActivateEmail(ctx context.Context, email string, id string) error
+	ActivateEmail(ctx context.Context, activationToken string) error
+	ResendActivationLink(ctx context.Context, form *ResendActivationRequest) (string, error)
 	Login(ctx context.Context, form *LoginRequest) (*LoginResponse, error)
+	MechanicLogin(ctx context.Context, form *LoginRequest) (*LoginResponse, error)
+	RefreshToken(ctx context.Context, form *RefreshTokenRequest) (*LoginResponse, error)
+	Logout(ctx context.Context, tokenID string) error
+	LogoutAllDevices(ctx context.Context, userID string) error
+	IsTokenRevoked(ctx context.Context, tokenID string) (bool, error)
+	ForgotPassword(ctx context.Context, form *ForgotPasswordRequest) (*PasswordResetLink, error)
+	ResetPassword(ctx context.Context, form *ResetPasswordRequest) error
 }
 
-func NewAuth(userModel model.User) Auth {
+// the cache is kept per process, a logout is seen by the other instances at most revokedTokenCacheTTL later
+const (
+	revokedTokenCacheTTL  = 30 * time.Second
+	revokedTokenCacheSize = 10000
+)
+
+func NewAuth(userModel model.User, sessionModel model.Session, deviceModel model.Device) Auth {
 	return &authCtx{
-		userModel: userModel,
+		userModel:     userModel,
+		sessionModel:  sessionModel,
+		deviceModel:   deviceModel,
+		revokedTokens: cache.NewTTLCache(revokedTokenCacheTTL, revokedTokenCacheSize),
 	}
 }
 
@@ -40,17 +63,37 @@ type (
 		Password string `json:"password"`
 	}
 	ActivateEmailRequest struct {
-		ID    string
-		Email string
+		Token string
+	}
+	ResendActivationRequest struct {
+		Email string `json:"email"`
 	}
 	LoginRequest struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
-		FMCToken string `json:"fcm_key"`
+		Email      string `json:"email"`
+		Password   string `json:"password"`
+		FMCToken   string `json:"fcm_key"`
+		Platform   string `json:"platform"`
+		AppVersion string `json:"app_version"`
 	}
 	LoginResponse struct {
-		Token     string `json:"token"`
-		ExpiredAt string `json:"expired_at"`
+		Token                 string `json:"token"`
+		ExpiredAt             string `json:"expired_at"`
+		RefreshToken          string `json:"refresh_token"`
+		RefreshTokenExpiredAt string `json:"refresh_token_expired_at"`
+	}
+	RefreshTokenRequest struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	ForgotPasswordRequest struct {
+		Email string `json:"email"`
+	}
+	PasswordResetLink struct {
+		Token    string
+		Duration int
+	}
+	ResetPasswordRequest struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
 	}
 )
 
@@ -91,15 +134,25 @@ func (rr *RegisterRequest) ValidateRegisterRequest() ([]handler.Fields, error) {
 func (v *ActivateEmailRequest) ValidateActivateEmailRequest() ([]handler.Fields, error) {
 	var count int
 	var fields []handler.Fields
-	err := validator.ValidateID(v.ID)
+	err := validator.ValidateActivationToken(v.Token)
 	if err != nil {
 		count++
 		fields = append(fields, handler.Fields{
-			Name:    "id",
+			Name:    "token",
 			Message: err.Error(),
 		})
 	}
-	err = validator.ValidateEmail(v.Email)
+
+	if count == 0 {
+		return nil, nil
+	}
+	return fields, errors.New(handler.ValidationFailed)
+}
+
+func (v *ResendActivationRequest) ValidateResendActivationRequest() ([]handler.Fields, error) {
+	var count int
+	var fields []handler.Fields
+	err := validator.ValidateEmail(v.Email)
 	if err != nil {
 		count++
 		fields = append(fields, handler.Fields{
@@ -135,6 +188,100 @@ func (lr *LoginRequest) ValidateLoginRequest() ([]handler.Fields, error) {
 		})
 	}
 
+	if lr.FMCToken != "" {
+		err = validator.ValidateDeviceToken(lr.FMCToken)
+		if err != nil {
+			count++
+			fields = append(fields, handler.Fields{
+				Name:    "fcm_key",
+				Message: err.Error(),
+			})
+		}
+	}
+
+	if lr.Platform != "" {
+		err = validator.ValidateDevicePlatform(lr.Platform)
+		if err != nil {
+			count++
+			fields = append(fields, handler.Fields{
+				Name:    "platform",
+				Message: err.Error(),
+			})
+		}
+	}
+
+	err = validator.ValidateAppVersion(lr.AppVersion)
+	if err != nil {
+		count++
+		fields = append(fields, handler.Fields{
+			Name:    "app_version",
+			Message: err.Error(),
+		})
+	}
+
+	if count == 0 {
+		return nil, nil
+	}
+	return fields, errors.New(handler.ValidationFailed)
+}
+
+func (rr *RefreshTokenRequest) ValidateRefreshTokenRequest() ([]handler.Fields, error) {
+	var count int
+	var fields []handler.Fields
+	err := validator.ValidateRefreshToken(rr.RefreshToken)
+	if err != nil {
+		count++
+		fields = append(fields, handler.Fields{
+			Name:    "refresh_token",
+			Message: err.Error(),
+		})
+	}
+
+	if count == 0 {
+		return nil, nil
+	}
+	return fields, errors.New(handler.ValidationFailed)
+}
+
+func (fr *ForgotPasswordRequest) ValidateForgotPasswordRequest() ([]handler.Fields, error) {
+	var count int
+	var fields []handler.Fields
+	err := validator.ValidateEmail(fr.Email)
+	if err != nil {
+		count++
+		fields = append(fields, handler.Fields{
+			Name:    "email",
+			Message: err.Error(),
+		})
+	}
+
+	if count == 0 {
+		return nil, nil
+	}
+	return fields, errors.New(handler.ValidationFailed)
+}
+
+func (rr *ResetPasswordRequest) ValidateResetPasswordRequest() ([]handler.Fields, error) {
+	var count int
+	var fields []handler.Fields
+	err := validator.ValidatePasswordResetToken(rr.Token)
+	if err != nil {
+		count++
+		fields = append(fields, handler.Fields{
+			Name:    "token",
+			Message: err.Error(),
+		})
+	}
+
+	err = validator.ValidatePassword(rr.Password)
+	if err != nil {
+		count++
+		fields = append(fields, handler.Fields{
+			Name:    "password",
+			Message: err.Error(),
+		})
+	}
+
 	if count == 0 {
 		return nil, nil
 	}
@@ -176,48 +323,96 @@ func (c *authCtx) Register(ctx context.Context, form *RegisterRequest) (string,
 		return "", &handler.InternalServerError
 	}
 
-	// err = c.userModel.SetEmailTimeLimit(req.Email, uid)
-	// if err != nil {
-	// 	log.Error().Err(fmt.Errorf("error when SetEmailActivationTimeLimit: %w", err)).Send()
-	// 	return "", err
-	// }
-
-	return uid, nil
+	return c.issueActivationToken(ctx, uid)
 }
 
-func (c *authCtx) ActivateEmail(ctx context.Context, email, id string) error {
-	res, err := c.userModel.GetUserByEmail(ctx, email)
+func (c *authCtx) ActivateEmail(ctx context.Context, activationToken string) error {
+	res, err := c.userModel.GetActivationToken(ctx, token.Hash(activationToken))
 	if err != nil {
-		log.Error().Err(fmt.Errorf("error when GetUserByEmail: %w", err)).Send()
+		log.Error().Err(fmt.Errorf("error when GetActivationToken: %w", err)).Send()
 		if err == sql.ErrNoRows {
 			return &handler.ActivationEmailFailedError
 		}
 		return &handler.InternalServerError
 	}
 
-	if res.ID != id {
-		log.Error().Msg("incorrect id")
+	if res.UsedAt.Valid {
+		log.Error().Msg("activation token has been used")
 		return &handler.ActivationEmailFailedError
 	}
 
-	// err = c.userModel.IsEmailStillValid(email)
-	// if err != nil {
-	// 	log.Error().Err(fmt.Errorf("error when checking IsEmailActivationLinkStillValid: %w", err)).Send()
-	// 	if err == redis.Nil {
-	// 		return &handler.ActivationLinkExpired
-	// 	}
-	// 	return err
-	// }
+	if time.Now().After(res.ExpiresAt) {
+		return &handler.ActivationLinkExpired
+	}
 
-	err = c.userModel.ActivateEmail(ctx, email)
+	activated, err := c.userModel.ActivateEmail(ctx, res.ID, res.UserID)
 	if err != nil {
 		log.Error().Err(fmt.Errorf("error when ActivateEmail: %w", err)).Send()
 		return &handler.InternalServerError
 	}
+	if !activated {
+		return &handler.ActivationEmailFailedError
+	}
 	return nil
 }
 
+// ResendActivationLink issues a fresh activation token which replaces the previous ones.
+// No token is returned when the email is unknown or already activated so the caller can
+// answer the same way in every case.
+func (c *authCtx) ResendActivationLink(ctx context.Context, form *ResendActivationRequest) (string, error) {
+	res, err := c.userModel.GetUserByEmail(ctx, form.Email)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		log.Error().Err(fmt.Errorf("error when GetUserByEmail: %w", err)).Send()
+		return "", &handler.InternalServerError
+	}
+
+	if res.IsActive {
+		return "", nil
+	}
+	return c.issueActivationToken(ctx, res.ID)
+}
+
+func (c *authCtx) issueActivationToken(ctx context.Context, userID string) (string, error) {
+	duration, err := strconv.Atoi(os.Getenv("ACTIVATION_TOKEN_DURATION"))
+	if err != nil || duration <= 0 {
+		duration = 24 * 60
+	}
+
+	activationToken, activationTokenHash, err := token.Generate()
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when generateActivationToken: %w", err)).Send()
+		return "", &handler.InternalServerError
+	}
+
+	now := time.Now()
+	err = c.userModel.SetActivationToken(ctx, &model.ActivationToken{
+		UserID:    userID,
+		TokenHash: activationTokenHash,
+		ExpiresAt: now.Add(time.Minute * time.Duration(duration)),
+		CreatedAt: now,
+	})
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when SetActivationToken: %w", err)).Send()
+		return "", &handler.InternalServerError
+	}
+	return activationToken, nil
+}
+
 func (c *authCtx) Login(ctx context.Context, form *LoginRequest) (*LoginResponse, error) {
+	return c.login(ctx, form, "")
+}
+
+// MechanicLogin only lets accounts with the mechanic role in, so the mechanic app
+// never holds a customer token.
+func (c *authCtx) MechanicLogin(ctx context.Context, form *LoginRequest) (*LoginResponse, error) {
+	return c.login(ctx, form, model.UserRoleMechanic)
+}
+
+// login checks the credentials and starts a new session, an empty role accepts any account
+func (c *authCtx) login(ctx context.Context, form *LoginRequest, role string) (*LoginResponse, error) {
 	req := &model.LoginUser{
 		Email:    form.Email,
 		Password: form.Password,
@@ -242,25 +437,262 @@ func (c *authCtx) Login(ctx context.Context, form *LoginRequest) (*LoginResponse
 		return nil, &handler.LoginFailedError
 	}
 
-	keyDuration, err := strconv.Atoi(os.Getenv("ACCESS_TOKEN_DURATION"))
+	if role != "" && res.Role != role {
+		log.Error().Msg("account does not have the required role")
+		return nil, &handler.LoginFailedError
+	}
+
+	sessionID, err := uuid.GenerateUUID()
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when generateUUID: %w", err)).Send()
+		return nil, &handler.InternalServerError
+	}
+
+	session := &model.SessionBaseModel{
+		ID:     sessionID,
+		UserID: res.ID,
+	}
+	refreshToken, tokens, err := c.generateTokens(session, res.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	session.CreatedAt = session.UpdatedAt
+	err = c.sessionModel.SetSession(ctx, session)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when SetSession: %w", err)).Send()
+		return nil, &handler.InternalServerError
+	}
+
+	// the app registers its push token along with the login, logging in without one leaves the other devices alone
+	if form.FMCToken != "" {
+		platform := form.Platform
+		if platform == "" {
+			platform = model.DevicePlatformUnknown
+		}
+		err = c.deviceModel.SetDevice(ctx, &model.DeviceBaseModel{
+			UserID:     res.ID,
+			Token:      form.FMCToken,
+			Platform:   platform,
+			AppVersion: form.AppVersion,
+			LastSeenAt: time.Now(),
+		})
+		if err != nil {
+			log.Error().Err(fmt.Errorf("error when SetDevice: %w", err)).Send()
+			return nil, &handler.InternalServerError
+		}
+	}
+
+	tokens.RefreshToken = refreshToken
+	return tokens, nil
+}
+
+// RefreshToken rotates the refresh token of the session and issues a new access token.
+// Presenting a refresh token which has already been rotated revokes the whole session
+// since the token has most likely been stolen.
+func (c *authCtx) RefreshToken(ctx context.Context, form *RefreshTokenRequest) (*LoginResponse, error) {
+	tokenHash := token.Hash(form.RefreshToken)
+	session, err := c.sessionModel.GetSessionByRefreshToken(ctx, tokenHash)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, &handler.RefreshTokenInvalid
+		}
+		log.Error().Err(fmt.Errorf("error when GetSessionByRefreshToken: %w", err)).Send()
+		return nil, &handler.InternalServerError
+	}
+
+	if session.RevokedAt.Valid || time.Now().After(session.ExpiresAt) {
+		return nil, &handler.RefreshTokenInvalid
+	}
+
+	if session.RefreshTokenHash != tokenHash {
+		log.Warn().Msg(fmt.Sprintf("rotated refresh token of session %s is reused, revoking session", session.ID))
+		err = c.sessionModel.RevokeSession(ctx, session.ID)
+		if err != nil {
+			log.Error().Err(fmt.Errorf("error when RevokeSession: %w", err)).Send()
+			return nil, &handler.InternalServerError
+		}
+		c.revokedTokens.Delete(session.AccessTokenID)
+		return nil, &handler.RefreshTokenInvalid
+	}
+
+	// the role is read again so a changed role applies from the next refresh
+	role, err := c.userModel.GetUserRole(ctx, session.UserID)
 	if err != nil {
+		log.Error().Err(fmt.Errorf("error when GetUserRole: %w", err)).Send()
 		return nil, &handler.InternalServerError
 	}
 
-	token, expiredAt, err := jwt.GenerateToken(res.ID, os.Getenv("ACCESS_KEY"), keyDuration)
+	oldAccessTokenID := session.AccessTokenID
+	refreshToken, tokens, err := c.generateTokens(session, role)
+	if err != nil {
+		return nil, err
+	}
+
+	rotated, err := c.sessionModel.RotateSession(ctx, session, tokenHash)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when RotateSession: %w", err)).Send()
+		return nil, &handler.InternalServerError
+	}
+	if !rotated {
+		return nil, &handler.RefreshTokenInvalid
+	}
+	c.revokedTokens.Delete(oldAccessTokenID)
+
+	tokens.RefreshToken = refreshToken
+	return tokens, nil
+}
+
+func (c *authCtx) Logout(ctx context.Context, tokenID string) error {
+	err := c.sessionModel.RevokeSessionByTokenID(ctx, tokenID)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when RevokeSessionByTokenID: %w", err)).Send()
+		return &handler.InternalServerError
+	}
+	c.revokedTokens.Delete(tokenID)
+	return nil
+}
+
+func (c *authCtx) LogoutAllDevices(ctx context.Context, userID string) error {
+	err := c.sessionModel.RevokeUserSessions(ctx, userID)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when RevokeUserSessions: %w", err)).Send()
+		return &handler.InternalServerError
+	}
+	// the cache is keyed by token so the tokens of the user cannot be picked out of it
+	c.revokedTokens.Clear()
+	return nil
+}
+
+func (c *authCtx) IsTokenRevoked(ctx context.Context, tokenID string) (bool, error) {
+	if revoked, ok := c.revokedTokens.Get(tokenID); ok {
+		return revoked.(bool), nil
+	}
+
+	revoked, err := c.sessionModel.IsTokenRevoked(ctx, tokenID)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when IsTokenRevoked: %w", err)).Send()
+		return false, err
+	}
+	c.revokedTokens.Set(tokenID, revoked)
+	return revoked, nil
+}
+
+// generateTokens fills the session with a fresh refresh token and access token id,
+// the plain refresh token is returned separately as only its hash is stored.
+func (c *authCtx) generateTokens(session *model.SessionBaseModel, role string) (string, *LoginResponse, error) {
+	keyDuration, err := strconv.Atoi(os.Getenv("ACCESS_TOKEN_DURATION"))
+	if err != nil {
+		return "", nil, &handler.InternalServerError
+	}
+
+	refreshDuration, err := strconv.Atoi(os.Getenv("REFRESH_TOKEN_DURATION"))
+	if err != nil {
+		refreshDuration = 30 * 24 * 60
+	}
+
+	accessTokenID, err := uuid.GenerateUUID()
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when generateUUID: %w", err)).Send()
+		return "", nil, &handler.InternalServerError
+	}
+
+	accessToken, expiredAt, err := jwt.GenerateToken(session.UserID, role, accessTokenID, os.Getenv("ACCESS_KEY"), keyDuration)
 	if err != nil {
 		log.Error().Err(fmt.Errorf("error when generateAccessToken: %w", err)).Send()
+		return "", nil, &handler.InternalServerError
+	}
+
+	refreshToken, refreshTokenHash, err := token.Generate()
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when generateRefreshToken: %w", err)).Send()
+		return "", nil, &handler.InternalServerError
+	}
+
+	now := time.Now()
+	session.AccessTokenID = accessTokenID
+	session.RefreshTokenHash = refreshTokenHash
+	session.ExpiresAt = now.Add(time.Minute * time.Duration(refreshDuration))
+	session.UpdatedAt = now
+
+	return refreshToken, &LoginResponse{
+		Token:                 accessToken,
+		ExpiredAt:             expiredAt,
+		RefreshTokenExpiredAt: session.ExpiresAt.UTC().Format(time.RFC3339),
+	}, nil
+}
+
+// ForgotPassword issues a single-use reset token for the account of the email.
+// No link is returned for an unknown email so the caller can answer the same way
+// whether the account exists or not.
+func (c *authCtx) ForgotPassword(ctx context.Context, form *ForgotPasswordRequest) (*PasswordResetLink, error) {
+	res, err := c.userModel.GetUserByEmail(ctx, form.Email)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Error().Err(fmt.Errorf("error when GetUserByEmail: %w", err)).Send()
+		return nil, &handler.InternalServerError
+	}
+
+	duration, err := strconv.Atoi(os.Getenv("PASSWORD_RESET_TOKEN_DURATION"))
+	if err != nil || duration <= 0 {
+		duration = 30
+	}
+
+	resetToken, resetTokenHash, err := token.Generate()
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when generateResetToken: %w", err)).Send()
 		return nil, &handler.InternalServerError
 	}
 
-	err = c.userModel.StoreFCMKey(ctx, res.ID, form.FMCToken)
+	now := time.Now()
+	err = c.userModel.SetPasswordResetToken(ctx, &model.PasswordResetToken{
+		UserID:    res.ID,
+		TokenHash: resetTokenHash,
+		ExpiresAt: now.Add(time.Minute * time.Duration(duration)),
+		CreatedAt: now,
+	})
 	if err != nil {
-		log.Error().Err(fmt.Errorf("error when storingFCMKey: %w", err)).Send()
+		log.Error().Err(fmt.Errorf("error when SetPasswordResetToken: %w", err)).Send()
 		return nil, &handler.InternalServerError
 	}
 
-	return &LoginResponse{
-		Token:     token,
-		ExpiredAt: expiredAt,
+	return &PasswordResetLink{
+		Token:    resetToken,
+		Duration: duration,
 	}, nil
 }
+
+// ResetPassword stores the new password and logs the user out of every device.
+func (c *authCtx) ResetPassword(ctx context.Context, form *ResetPasswordRequest) error {
+	resetToken, err := c.userModel.GetPasswordResetToken(ctx, token.Hash(form.Token))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return &handler.PasswordResetTokenInvalid
+		}
+		log.Error().Err(fmt.Errorf("error when GetPasswordResetToken: %w", err)).Send()
+		return &handler.InternalServerError
+	}
+
+	if resetToken.UsedAt.Valid || time.Now().After(resetToken.ExpiresAt) {
+		return &handler.PasswordResetTokenInvalid
+	}
+
+	hashPassword, err := password.HashPassword(form.Password)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when hashPassword: %w", err)).Send()
+		return &handler.InternalServerError
+	}
+
+	reset, err := c.userModel.ResetPassword(ctx, resetToken.ID, resetToken.UserID, hashPassword)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when ResetPassword: %w", err)).Send()
+		return &handler.InternalServerError
+	}
+	if !reset {
+		return &handler.PasswordResetTokenInvalid
+	}
+
+	return c.LogoutAllDevices(ctx, resetToken.UserID)
+}
@@ -15,13 +15,55 @@ func (m *MockAuthController) Register(ctx context.Context, form *RegisterRequest
 	return args.String(0), args.Error(1)
 }
 
-func (m *MockAuthController) ActivateEmail(ctx context.Context, email, id string) error {
-	args := m.Called(ctx, email)
+func (m *MockAuthController) ActivateEmail(ctx context.Context, activationToken string) error {
+	args := m.Called(ctx, activationToken)
 	return args.Error(0)
 }
 
+func (m *MockAuthController) ResendActivationLink(ctx context.Context, form *ResendActivationRequest) (string, error) {
+	args := m.Called(ctx, form)
+	return args.String(0), args.Error(1)
+}
+
 func (m *MockAuthController) Login(ctx context.Context, form *LoginRequest) (*LoginResponse, error) {
 	args := m.Called(ctx, form)
 
 	return &LoginResponse{}, args.Error(1)
 }
+
+func (m *MockAuthController) MechanicLogin(ctx context.Context, form *LoginRequest) (*LoginResponse, error) {
+	args := m.Called(ctx, form)
+
+	return &LoginResponse{}, args.Error(1)
+}
+
+func (m *MockAuthController) RefreshToken(ctx context.Context, form *RefreshTokenRequest) (*LoginResponse, error) {
+	args := m.Called(ctx, form)
+
+	return &LoginResponse{}, args.Error(1)
+}
+
+func (m *MockAuthController) Logout(ctx context.Context, tokenID string) error {
+	args := m.Called(ctx, tokenID)
+	return args.Error(0)
+}
+
+func (m *MockAuthController) LogoutAllDevices(ctx context.Context, userID string) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *MockAuthController) IsTokenRevoked(ctx context.Context, tokenID string) (bool, error) {
+	args := m.Called(ctx, tokenID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockAuthController) ForgotPassword(ctx context.Context, form *ForgotPasswordRequest) (*PasswordResetLink, error) {
+	args := m.Called(ctx, form)
+	return nil, args.Error(1)
+}
+
+func (m *MockAuthController) ResetPassword(ctx context.Context, form *ResetPasswordRequest) error {
+	args := m.Called(ctx, form)
+	return args.Error(0)
+}
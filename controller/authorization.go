@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"e-montir/api/handler"
+	"e-montir/model"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+)
+
+// orderFinder is the part of model.Order the ownership check depends on
+type orderFinder interface {
+	GetOrderByOrderID(ctx context.Context, orderID string) (*model.OrderBaseModel, error)
+}
+
+// authorizeOrder loads the order on behalf of the user, every order scoped operation of a customer
+// goes through it. An order of another user is reported as not found so its existence is not disclosed.
+func authorizeOrder(ctx context.Context, orders orderFinder, userID, orderID string) (*model.OrderBaseModel, error) {
+	order, err := orders.GetOrderByOrderID(ctx, orderID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, &handler.OrderNotFound
+		}
+		log.Error().Err(fmt.Errorf("error when getOrderByOrderID : %w", err)).Send()
+		return nil, &handler.InternalServerError
+	}
+
+	if order.UserID != userID {
+		return nil, &handler.OrderNotFound
+	}
+	return order, nil
+}
+
+// authorizeJob loads the order on behalf of the mechanic it is assigned to,
+// an order assigned to someone else is reported as not found.
+func authorizeJob(ctx context.Context, orders orderFinder, mechanicID int, orderID string) (*model.OrderBaseModel, error) {
+	order, err := orders.GetOrderByOrderID(ctx, orderID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, &handler.OrderNotFound
+		}
+		log.Error().Err(fmt.Errorf("error when getOrderByOrderID : %w", err)).Send()
+		return nil, &handler.InternalServerError
+	}
+
+	if !order.MechanicID.Valid || int(order.MechanicID.Int64) != mechanicID {
+		return nil, &handler.OrderNotFound
+	}
+	return order, nil
+}
+
+// mechanicFinder is the part of model.Mechanic the participant check depends on
+type mechanicFinder interface {
+	GetMechanicByUserID(ctx context.Context, userID string) (*model.MechanicBaseModel, error)
+}
+
+// authorizeParticipant loads the order on behalf of its customer or its assigned mechanic
+// and tells which of them the user is, anyone else gets not found.
+func authorizeParticipant(ctx context.Context, orders orderFinder, mechanics mechanicFinder, userID, orderID string) (*model.OrderBaseModel, string, error) {
+	order, err := orders.GetOrderByOrderID(ctx, orderID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, "", &handler.OrderNotFound
+		}
+		log.Error().Err(fmt.Errorf("error when getOrderByOrderID : %w", err)).Send()
+		return nil, "", &handler.InternalServerError
+	}
+
+	if order.UserID == userID {
+		return order, model.MessageSenderCustomer, nil
+	}
+
+	mechanic, err := mechanics.GetMechanicByUserID(ctx, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, "", &handler.OrderNotFound
+		}
+		log.Error().Err(fmt.Errorf("error when GetMechanicByUserID : %w", err)).Send()
+		return nil, "", &handler.InternalServerError
+	}
+
+	if !order.MechanicID.Valid || int(order.MechanicID.Int64) != mechanic.ID {
+		return nil, "", &handler.OrderNotFound
+	}
+	return order, model.MessageSenderMechanic, nil
+}
@@ -0,0 +1,193 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"e-montir/api/handler"
+	"e-montir/model"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type stubOrderFinder map[string]*model.OrderBaseModel
+
+func (s stubOrderFinder) GetOrderByOrderID(ctx context.Context, orderID string) (*model.OrderBaseModel, error) {
+	if orderID == "broken-order" {
+		return nil, errors.New("connection refused")
+	}
+	order, ok := s[orderID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return order, nil
+}
+
+func TestAuthorizeOrder(t *testing.T) {
+	orders := stubOrderFinder{
+		"order-1": {ID: "order-1", UserID: "user-1"},
+	}
+
+	tt := []struct {
+		Name    string
+		UserID  string
+		OrderID string
+		Err     error
+	}{
+		{
+			Name:    "Owner",
+			UserID:  "user-1",
+			OrderID: "order-1",
+		},
+		{
+			Name:    "Other user",
+			UserID:  "user-2",
+			OrderID: "order-1",
+			Err:     &handler.OrderNotFound,
+		},
+		{
+			Name:    "Order not exists",
+			UserID:  "user-1",
+			OrderID: "order-2",
+			Err:     &handler.OrderNotFound,
+		},
+		{
+			Name:    "Database error",
+			UserID:  "user-1",
+			OrderID: "broken-order",
+			Err:     &handler.InternalServerError,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			order, err := authorizeOrder(context.Background(), orders, tc.UserID, tc.OrderID)
+			assert.Equal(t, tc.Err, err)
+			if tc.Err == nil {
+				assert.Equal(t, tc.OrderID, order.ID)
+			}
+		})
+	}
+}
+
+func TestAuthorizeJob(t *testing.T) {
+	orders := stubOrderFinder{
+		"order-1": {ID: "order-1", MechanicID: sql.NullInt64{Int64: 1, Valid: true}},
+		"order-2": {ID: "order-2"},
+	}
+
+	tt := []struct {
+		Name       string
+		MechanicID int
+		OrderID    string
+		Err        error
+	}{
+		{
+			Name:       "Assigned mechanic",
+			MechanicID: 1,
+			OrderID:    "order-1",
+		},
+		{
+			Name:       "Other mechanic",
+			MechanicID: 2,
+			OrderID:    "order-1",
+			Err:        &handler.OrderNotFound,
+		},
+		{
+			Name:       "No mechanic assigned",
+			MechanicID: 1,
+			OrderID:    "order-2",
+			Err:        &handler.OrderNotFound,
+		},
+		{
+			Name:       "Database error",
+			MechanicID: 1,
+			OrderID:    "broken-order",
+			Err:        &handler.InternalServerError,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			order, err := authorizeJob(context.Background(), orders, tc.MechanicID, tc.OrderID)
+			assert.Equal(t, tc.Err, err)
+			if tc.Err == nil {
+				assert.Equal(t, tc.OrderID, order.ID)
+			}
+		})
+	}
+}
+
+type stubMechanicFinder map[string]*model.MechanicBaseModel
+
+func (s stubMechanicFinder) GetMechanicByUserID(ctx context.Context, userID string) (*model.MechanicBaseModel, error) {
+	if userID == "broken-user" {
+		return nil, errors.New("connection refused")
+	}
+	mechanic, ok := s[userID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return mechanic, nil
+}
+
+func TestAuthorizeParticipant(t *testing.T) {
+	orders := stubOrderFinder{
+		"order-1": {ID: "order-1", UserID: "user-1", MechanicID: sql.NullInt64{Int64: 1, Valid: true}},
+	}
+	mechanics := stubMechanicFinder{
+		"mechanic-user-1": {ID: 1},
+		"mechanic-user-2": {ID: 2},
+	}
+
+	tt := []struct {
+		Name    string
+		UserID  string
+		OrderID string
+		Role    string
+		Err     error
+	}{
+		{
+			Name:    "Customer",
+			UserID:  "user-1",
+			OrderID: "order-1",
+			Role:    model.MessageSenderCustomer,
+		},
+		{
+			Name:    "Assigned mechanic",
+			UserID:  "mechanic-user-1",
+			OrderID: "order-1",
+			Role:    model.MessageSenderMechanic,
+		},
+		{
+			Name:    "Other mechanic",
+			UserID:  "mechanic-user-2",
+			OrderID: "order-1",
+			Err:     &handler.OrderNotFound,
+		},
+		{
+			Name:    "Other customer",
+			UserID:  "user-2",
+			OrderID: "order-1",
+			Err:     &handler.OrderNotFound,
+		},
+		{
+			Name:    "Database error",
+			UserID:  "broken-user",
+			OrderID: "order-1",
+			Err:     &handler.InternalServerError,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			order, role, err := authorizeParticipant(context.Background(), orders, mechanics, tc.UserID, tc.OrderID)
+			assert.Equal(t, tc.Err, err)
+			assert.Equal(t, tc.Role, role)
+			if tc.Err == nil {
+				assert.Equal(t, tc.OrderID, order.ID)
+			}
+		})
+	}
+}
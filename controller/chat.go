@@ -0,0 +1,318 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"e-montir/api/handler"
+	"e-montir/model"
+	"e-montir/pkg/chat"
+	"e-montir/pkg/media"
+	"e-montir/pkg/uuid"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const maxMessageLength = 1000
+
+type chatCtx struct {
+	messageModel  model.Message
+	orderModel    model.Order
+	mechanicModel model.Mechanic
+	hub           *chat.Hub
+	mediaStore    media.Storage
+}
+
+type Chat interface {
+	ListOfMessages(ctx context.Context, userID string, form *MessageListRequest) (*MessageListResponse, error)
+	SendMessage(ctx context.Context, userID string, form *SendMessageRequest) (*OrderMessage, error)
+	MarkMessagesAsRead(ctx context.Context, userID, orderID string) error
+	Subscribe(ctx context.Context, userID, orderID string) (<-chan chat.Event, func(), error)
+}
+
+func NewChat(messageModel model.Message, orderModel model.Order, mechanicModel model.Mechanic, hub *chat.Hub, mediaStore media.Storage) Chat {
+	return &chatCtx{
+		messageModel:  messageModel,
+		orderModel:    orderModel,
+		mechanicModel: mechanicModel,
+		hub:           hub,
+		mediaStore:    mediaStore,
+	}
+}
+
+type (
+	MessageListRequest struct {
+		OrderID     string
+		Page        int
+		Limit       int
+		PageString  string
+		LimitString string
+	}
+
+	SendMessageRequest struct {
+		OrderID string
+		Body    string
+		Image   []byte
+	}
+
+	OrderMessage struct {
+		ID            string `json:"id"`
+		OrderID       string `json:"order_id"`
+		SenderID      string `json:"sender_id"`
+		SenderRole    string `json:"sender_role"`
+		Body          string `json:"body"`
+		AttachmentURL string `json:"attachment_url,omitempty"`
+		ReadAt        string `json:"read_at,omitempty"`
+		CreatedAt     string `json:"created_at"`
+	}
+
+	MessageListResponse struct {
+		Data        []OrderMessage `json:"data"`
+		UnreadCount int            `json:"unread_count"`
+		Pagination  Pagination     `json:"pagination"`
+	}
+
+	MessagesRead struct {
+		OrderID  string `json:"order_id"`
+		ReaderID string `json:"reader_id"`
+		ReadAt   string `json:"read_at"`
+	}
+)
+
+func (req *MessageListRequest) ValidateMessageListRequest() ([]handler.Fields, error) {
+	var count int
+	var fields []handler.Fields
+	req.Page = 1
+	if strings.TrimSpace(req.PageString) != "" {
+		page, err := strconv.Atoi(req.PageString)
+		if err != nil || page < 1 {
+			count++
+			fields = append(fields, handler.Fields{
+				Name:    "page",
+				Message: "page must be more than 0",
+			})
+		}
+		req.Page = page
+	}
+
+	req.Limit = 20
+	if strings.TrimSpace(req.LimitString) != "" {
+		limit, err := strconv.Atoi(req.LimitString)
+		if err != nil || limit < 1 || limit > 100 {
+			count++
+			fields = append(fields, handler.Fields{
+				Name:    "limit",
+				Message: "limit must be between 1 and 100",
+			})
+		}
+		req.Limit = limit
+	}
+
+	if count == 0 {
+		return nil, nil
+	}
+	return fields, errors.New(handler.ValidationFailed)
+}
+
+func (req *SendMessageRequest) ValidateSendMessageRequest() ([]handler.Fields, error) {
+	var count int
+	var fields []handler.Fields
+	req.Body = strings.TrimSpace(req.Body)
+	if req.Body == "" && len(req.Image) == 0 {
+		count++
+		fields = append(fields, handler.Fields{
+			Name:    "body",
+			Message: "body cannot be empty without an image",
+		})
+	}
+
+	if len(req.Body) > maxMessageLength {
+		count++
+		fields = append(fields, handler.Fields{
+			Name:    "body",
+			Message: fmt.Sprintf("body cannot exceed %d characters", maxMessageLength),
+		})
+	}
+
+	if len(req.Image) > 0 {
+		if len(req.Image) > media.MaxImageSize {
+			count++
+			fields = append(fields, handler.Fields{
+				Name:    "image",
+				Message: "image cannot exceed 5 MB",
+			})
+		} else if _, err := media.ImageExtension(req.Image); err != nil {
+			count++
+			fields = append(fields, handler.Fields{
+				Name:    "image",
+				Message: err.Error(),
+			})
+		}
+	}
+
+	if count == 0 {
+		return nil, nil
+	}
+	return fields, errors.New(handler.ValidationFailed)
+}
+
+func (c *chatCtx) ListOfMessages(ctx context.Context, userID string, form *MessageListRequest) (*MessageListResponse, error) {
+	_, _, err := authorizeParticipant(ctx, c.orderModel, c.mechanicModel, userID, form.OrderID)
+	if err != nil {
+		return nil, err
+	}
+
+	// one more row than asked tells whether there is a next page
+	offset := (form.Page - 1) * form.Limit
+	res, err := c.messageModel.ListOfMessages(ctx, form.OrderID, form.Limit+1, offset)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when ListOfMessages : %w", err)).Send()
+		return nil, &handler.InternalServerError
+	}
+
+	unread, err := c.messageModel.CountUnreadMessages(ctx, form.OrderID, userID)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when CountUnreadMessages : %w", err)).Send()
+		return nil, &handler.InternalServerError
+	}
+
+	response := &MessageListResponse{
+		Data:        make([]OrderMessage, 0),
+		UnreadCount: unread,
+	}
+	if len(res) > form.Limit {
+		res = res[:form.Limit]
+		response.Pagination.NextPage = form.Page + 1
+	}
+	for i := range res {
+		response.Data = append(response.Data, c.orderMessage(&res[i]))
+	}
+	return response, nil
+}
+
+// SendMessage stores the message with its image and pushes it to the other participant
+func (c *chatCtx) SendMessage(ctx context.Context, userID string, form *SendMessageRequest) (*OrderMessage, error) {
+	order, role, err := authorizeParticipant(ctx, c.orderModel, c.mechanicModel, userID, form.OrderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isChatOpen(order) {
+		return nil, &handler.ChatClosed
+	}
+
+	messageID, err := uuid.GenerateUUID()
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when generateUUID: %w", err)).Send()
+		return nil, &handler.InternalServerError
+	}
+
+	message := &model.MessageBaseModel{
+		ID:         messageID,
+		OrderID:    form.OrderID,
+		SenderID:   userID,
+		SenderRole: role,
+		Body:       sql.NullString{String: form.Body, Valid: form.Body != ""},
+		CreatedAt:  time.Now(),
+	}
+
+	if len(form.Image) > 0 {
+		contentType, ext, err := media.ImageType(form.Image)
+		if err != nil {
+			return nil, &handler.ParsePayloadError
+		}
+		key := fmt.Sprintf("chat/%s/%s%s", form.OrderID, messageID, ext)
+		err = c.mediaStore.Save(ctx, key, contentType, form.Image)
+		if err != nil {
+			log.Error().Err(fmt.Errorf("error when saving chat image: %w", err)).Send()
+			return nil, &handler.InternalServerError
+		}
+		message.Attachment = sql.NullString{String: key, Valid: true}
+	}
+
+	err = c.messageModel.SetMessage(ctx, message)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when SetMessage : %w", err)).Send()
+		return nil, &handler.InternalServerError
+	}
+
+	res := c.orderMessage(message)
+	c.hub.Publish(form.OrderID, chat.Event{
+		Name: chat.EventMessage,
+		Data: res,
+	})
+	return &res, nil
+}
+
+// MarkMessagesAsRead acknowledges every message the other participant sent so far
+func (c *chatCtx) MarkMessagesAsRead(ctx context.Context, userID, orderID string) error {
+	_, _, err := authorizeParticipant(ctx, c.orderModel, c.mechanicModel, userID, orderID)
+	if err != nil {
+		return err
+	}
+
+	readAt := time.Now()
+	marked, err := c.messageModel.MarkMessagesAsRead(ctx, orderID, userID, readAt)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when MarkMessagesAsRead : %w", err)).Send()
+		return &handler.InternalServerError
+	}
+
+	if marked > 0 {
+		c.hub.Publish(orderID, chat.Event{
+			Name: chat.EventRead,
+			Data: MessagesRead{
+				OrderID:  orderID,
+				ReaderID: userID,
+				ReadAt:   readAt.UTC().Format(time.RFC3339),
+			},
+		})
+	}
+	return nil
+}
+
+// Subscribe joins the realtime channel of the conversation, the returned function leaves it
+func (c *chatCtx) Subscribe(ctx context.Context, userID, orderID string) (<-chan chat.Event, func(), error) {
+	_, _, err := authorizeParticipant(ctx, c.orderModel, c.mechanicModel, userID, orderID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	events, unsubscribe := c.hub.Subscribe(orderID)
+	return events, unsubscribe, nil
+}
+
+func (c *chatCtx) orderMessage(message *model.MessageBaseModel) OrderMessage {
+	res := OrderMessage{
+		ID:         message.ID,
+		OrderID:    message.OrderID,
+		SenderID:   message.SenderID,
+		SenderRole: message.SenderRole,
+		Body:       message.Body.String,
+		CreatedAt:  message.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	if message.Attachment.Valid {
+		res.AttachmentURL = c.mediaStore.URL(message.Attachment.String)
+	}
+	if message.ReadAt.Valid {
+		res.ReadAt = message.ReadAt.Time.UTC().Format(time.RFC3339)
+	}
+	return res
+}
+
+// isChatOpen reports whether the customer and the mechanic can still talk, the chat
+// opens once a mechanic is assigned and closes when the order is done or cancelled.
+func isChatOpen(order *model.OrderBaseModel) bool {
+	if !order.MechanicID.Valid {
+		return false
+	}
+	switch order.OrderStatus.String {
+	case model.OrderStatus[2], model.OrderStatus[3], model.OrderStatus[4]:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"database/sql"
+	"e-montir/model"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateSendMessageRequest(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+	tt := []struct {
+		Name    string
+		Request SendMessageRequest
+		Fields  []string
+	}{
+		{
+			Name:    "Text",
+			Request: SendMessageRequest{Body: "I am at the gate"},
+		},
+		{
+			Name:    "Image without text",
+			Request: SendMessageRequest{Image: png},
+		},
+		{
+			Name:    "Empty",
+			Request: SendMessageRequest{Body: "   "},
+			Fields:  []string{"body"},
+		},
+		{
+			Name:    "Too long",
+			Request: SendMessageRequest{Body: strings.Repeat("a", maxMessageLength+1)},
+			Fields:  []string{"body"},
+		},
+		{
+			Name:    "Not an image",
+			Request: SendMessageRequest{Body: "see attachment", Image: []byte("%PDF-1.4")},
+			Fields:  []string{"image"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			fields, err := tc.Request.ValidateSendMessageRequest()
+			if tc.Fields == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Error(t, err)
+			names := make([]string, 0)
+			for _, v := range fields {
+				names = append(names, v.Name)
+			}
+			assert.Equal(t, tc.Fields, names)
+		})
+	}
+}
+
+func TestIsChatOpen(t *testing.T) {
+	mechanic := sql.NullInt64{Int64: 1, Valid: true}
+
+	assert.False(t, isChatOpen(&model.OrderBaseModel{OrderStatus: sql.NullString{String: model.OrderStatus[2], Valid: true}}))
+	assert.True(t, isChatOpen(&model.OrderBaseModel{MechanicID: mechanic, OrderStatus: sql.NullString{String: model.OrderStatus[3], Valid: true}}))
+	assert.False(t, isChatOpen(&model.OrderBaseModel{MechanicID: mechanic, OrderStatus: sql.NullString{String: model.OrderStatus[5], Valid: true}}))
+}
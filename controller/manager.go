@@ -2,6 +2,12 @@ package controller
 
 import (
 	"e-montir/model"
+	"e-montir/pkg/chat"
+	"e-montir/pkg/mailer"
+	"e-montir/pkg/media"
+	"e-montir/pkg/notification"
+	"e-montir/pkg/payment"
+	"e-montir/pkg/tracking"
 	"sync"
 )
 
@@ -14,15 +20,34 @@ type Manager interface {
 	Order() Order
 	Payment() Payment
 	Review() Review
+	Refund() Refund
+	MechanicJob() MechanicJob
+	Assignment() Assignment
+	Tracking() Tracking
+	Chat() Chat
+	Attachment() Attachment
+	Notification() Notification
 }
 
 type manager struct {
-	modelManager model.Manager
+	modelManager   model.Manager
+	paymentGateway payment.PaymentGateway
+	trackingBroker *tracking.Broker
+	chatHub        *chat.Hub
+	mediaStore     media.Storage
+	notifier       notification.Notifier
+	mailTransport  mailer.Transport
 }
 
-func NewManager(modelManager model.Manager) Manager {
+func NewManager(modelManager model.Manager, paymentGateway payment.PaymentGateway, mediaStore media.Storage, notifier notification.Notifier, mailTransport mailer.Transport) Manager {
 	sm := &manager{
-		modelManager: modelManager,
+		modelManager:   modelManager,
+		paymentGateway: paymentGateway,
+		trackingBroker: tracking.NewBroker(),
+		chatHub:        chat.NewHub(),
+		mediaStore:     mediaStore,
+		notifier:       notifier,
+		mailTransport:  mailTransport,
 	}
 	return sm
 }
@@ -34,7 +59,7 @@ var (
 
 func (c *manager) Auth() Auth {
 	authControllerOnce.Do(func() {
-		authController = NewAuth(c.modelManager.User())
+		authController = NewAuth(c.modelManager.User(), c.modelManager.Session(), c.modelManager.Device())
 	})
 	return authController
 }
@@ -46,7 +71,7 @@ var (
 
 func (c *manager) User() User {
 	userControllerOnce.Do(func() {
-		userController = NewUser(c.modelManager.User())
+		userController = NewUser(c.modelManager.User(), c.modelManager.Device())
 	})
 	return userController
 }
@@ -94,7 +119,7 @@ var (
 
 func (c *manager) Order() Order {
 	orderControllerOnce.Do(func() {
-		orderController = NewOrder(c.modelManager.Order(), c.modelManager.Cart(), c.modelManager.User(), c.modelManager.Review())
+		orderController = NewOrder(c.modelManager.Order(), c.modelManager.Cart(), c.modelManager.User(), c.modelManager.Review(), c.modelManager.Refund(), c.paymentGateway, c.Assignment(), c.trackingBroker)
 	})
 	return orderController
 }
@@ -106,7 +131,7 @@ var (
 
 func (c *manager) Payment() Payment {
 	paymentControllerOnce.Do(func() {
-		paymentController = NewPayment(c.modelManager.Order(), c.modelManager.Cart())
+		paymentController = NewPayment(c.modelManager.Order(), c.modelManager.Cart(), c.modelManager.Payment(), c.paymentGateway)
 	})
 	return paymentController
 }
@@ -118,7 +143,92 @@ var (
 
 func (c *manager) Review() Review {
 	reviewControllerOnce.Do(func() {
-		reviewController = NewReview(c.modelManager.Review(), c.modelManager.Cart())
+		reviewController = NewReview(c.modelManager.Review(), c.modelManager.Cart(), c.modelManager.Order(), c.modelManager.Attachment(), c.mediaStore)
 	})
 	return reviewController
 }
+
+var (
+	refundControllerOnce sync.Once
+	refundController     Refund
+)
+
+func (c *manager) Refund() Refund {
+	refundControllerOnce.Do(func() {
+		refundController = NewRefund(c.modelManager.Order(), c.modelManager.Refund(), c.paymentGateway)
+	})
+	return refundController
+}
+
+var (
+	mechanicJobControllerOnce sync.Once
+	mechanicJobController     MechanicJob
+)
+
+func (c *manager) MechanicJob() MechanicJob {
+	mechanicJobControllerOnce.Do(func() {
+		mechanicJobController = NewMechanicJob(c.modelManager.Mechanic(), c.modelManager.Order(), c.modelManager.User(), c.modelManager.Review(), c.trackingBroker)
+	})
+	return mechanicJobController
+}
+
+var (
+	assignmentControllerOnce sync.Once
+	assignmentController     Assignment
+)
+
+func (c *manager) Assignment() Assignment {
+	assignmentControllerOnce.Do(func() {
+		assignmentController = NewAssignment(c.modelManager.Assignment(), c.modelManager.Order(), newAssignmentStrategy())
+	})
+	return assignmentController
+}
+
+var (
+	trackingControllerOnce sync.Once
+	trackingController     Tracking
+)
+
+func (c *manager) Tracking() Tracking {
+	trackingControllerOnce.Do(func() {
+		trackingController = NewTracking(c.modelManager.Tracking(), c.modelManager.Mechanic(), c.modelManager.Order(), c.trackingBroker)
+	})
+	return trackingController
+}
+
+var (
+	chatControllerOnce sync.Once
+	chatController     Chat
+)
+
+func (c *manager) Chat() Chat {
+	chatControllerOnce.Do(func() {
+		chatController = NewChat(c.modelManager.Message(), c.modelManager.Order(), c.modelManager.Mechanic(), c.chatHub, c.mediaStore)
+	})
+	return chatController
+}
+
+var (
+	attachmentControllerOnce sync.Once
+	attachmentController     Attachment
+)
+
+func (c *manager) Attachment() Attachment {
+	attachmentControllerOnce.Do(func() {
+		attachmentController = NewAttachment(c.modelManager.Attachment(), c.modelManager.Order(), c.modelManager.Mechanic(), c.modelManager.Review(), c.mediaStore)
+	})
+	return attachmentController
+}
+
+var (
+	notificationControllerOnce sync.Once
+	notificationController     Notification
+)
+
+func (c *manager) Notification() Notification {
+	notificationControllerOnce.Do(func() {
+		notificationController = NewNotification(c.modelManager.Notification(), c.modelManager.Device(), c.modelManager.User(), c.modelManager.NotificationPreference(),
+			c.notifier, c.mailTransport)
+	})
+	return notificationController
+}
@@ -19,7 +19,7 @@ func (m *MockManagerController) Auth() Auth {
 		Password: "abc123Dc1.",
 	}).Return("", nil)
 
-	MockAuthController.On("ActivateEmail", context.Background(), "hello@gmail.com").Return(nil)
+	MockAuthController.On("ActivateEmail", context.Background(), "activation-token").Return(nil)
 
 	MockAuthController.On("Login", context.Background(), &LoginRequest{
 		Email:    "hello@gmail.com",
@@ -56,3 +56,31 @@ func (m *MockManagerController) Payment() Payment {
 func (m *MockManagerController) Review() Review {
 	return nil
 }
+
+func (m *MockManagerController) Refund() Refund {
+	return nil
+}
+
+func (m *MockManagerController) MechanicJob() MechanicJob {
+	return nil
+}
+
+func (m *MockManagerController) Assignment() Assignment {
+	return nil
+}
+
+func (m *MockManagerController) Tracking() Tracking {
+	return nil
+}
+
+func (m *MockManagerController) Chat() Chat {
+	return nil
+}
+
+func (m *MockManagerController) Attachment() Attachment {
+	return nil
+}
+
+func (m *MockManagerController) Notification() Notification {
+	return nil
+}
@@ -0,0 +1,407 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"e-montir/api/handler"
+	"e-montir/model"
+	"e-montir/pkg/password"
+	"e-montir/pkg/tracking"
+	"e-montir/pkg/uuid"
+	"e-montir/pkg/validator"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+type mechanicJobCtx struct {
+	mechanicModel model.Mechanic
+	orderModel    model.Order
+	userModel     model.User
+	reviewModel   model.Review
+	broker        *tracking.Broker
+}
+
+type MechanicJob interface {
+	CreateMechanicAccount(ctx context.Context, form *MechanicAccountRequest) error
+	MechanicProfile(ctx context.Context, form *MechanicProfileRequest) (*MechanicProfileResponse, error)
+	ListOfJobs(ctx context.Context, userID string) (*JobListResponse, error)
+	AcceptJob(ctx context.Context, userID, orderID string) (*JobStatusResponse, error)
+	StartTravel(ctx context.Context, userID, orderID string) (*JobStatusResponse, error)
+	MarkArrived(ctx context.Context, userID, orderID string) (*JobStatusResponse, error)
+	CompleteJob(ctx context.Context, userID, orderID string) (*JobStatusResponse, error)
+}
+
+func NewMechanicJob(mechanicModel model.Mechanic, orderModel model.Order, userModel model.User, reviewModel model.Review, broker *tracking.Broker) MechanicJob {
+	return &mechanicJobCtx{
+		mechanicModel: mechanicModel,
+		orderModel:    orderModel,
+		userModel:     userModel,
+		reviewModel:   reviewModel,
+		broker:        broker,
+	}
+}
+
+type (
+	MechanicAccountRequest struct {
+		MechanicIDString string `json:"-"`
+		MechanicID       int    `json:"-"`
+		Email            string `json:"email"`
+		Password         string `json:"password"`
+	}
+
+	Job struct {
+		OrderID         string           `json:"order_id"`
+		InvoiceID       string           `json:"invoice_id"`
+		Description     string           `json:"description"`
+		MotorCycleBrand string           `json:"motor_cycle_brand"`
+		Appointment     OrderAppointment `json:"appointment"`
+		Location        OrderLocation    `json:"location"`
+		Items           []OrderItem      `json:"items"`
+		TotalPrice      float64          `json:"total_price"`
+		StatusOrder     string           `json:"status_order"`
+		StatusDetail    string           `json:"status_detail"`
+		AcceptedAt      string           `json:"accepted_at,omitempty"`
+	}
+
+	JobListResponse struct {
+		Data []Job `json:"data"`
+	}
+
+	JobStatusResponse struct {
+		OrderID     string `json:"order_id"`
+		StatusOrder string `json:"status_order"`
+	}
+
+	MechanicProfileRequest struct {
+		MechanicIDString string
+		MechanicID       int
+	}
+
+	MechanicReview struct {
+		Reviewer  string  `json:"reviewer"`
+		Rating    float64 `json:"rating"`
+		Feedback  string  `json:"feedback"`
+		CreatedAt string  `json:"created_at"`
+	}
+
+	MechanicProfile struct {
+		ID               int              `json:"id"`
+		Name             string           `json:"name"`
+		Picture          string           `json:"picture"`
+		CompletedService int              `json:"completed_service"`
+		Rating           float64          `json:"rating"`
+		ReviewCount      int              `json:"review_count"`
+		Reviews          []MechanicReview `json:"reviews"`
+	}
+
+	MechanicProfileResponse struct {
+		Data MechanicProfile `json:"data"`
+	}
+)
+
+const mechanicProfileReviews = 5
+
+func (req *MechanicAccountRequest) ValidateMechanicAccountRequest() ([]handler.Fields, error) {
+	var count int
+	var fields []handler.Fields
+	mechanicID, err := strconv.Atoi(req.MechanicIDString)
+	if err != nil || mechanicID < 1 {
+		count++
+		fields = append(fields, handler.Fields{
+			Name:    "mechanic_id",
+			Message: "mechanic_id must be more than 0",
+		})
+	}
+	req.MechanicID = mechanicID
+
+	err = validator.ValidateEmail(req.Email)
+	if err != nil {
+		count++
+		fields = append(fields, handler.Fields{
+			Name:    "email",
+			Message: err.Error(),
+		})
+	}
+
+	err = validator.ValidatePassword(req.Password)
+	if err != nil {
+		count++
+		fields = append(fields, handler.Fields{
+			Name:    "password",
+			Message: err.Error(),
+		})
+	}
+
+	if count == 0 {
+		return nil, nil
+	}
+	return fields, errors.New(handler.ValidationFailed)
+}
+
+// CreateMechanicAccount gives a seeded mechanic credentials to log in with the mechanic role
+func (c *mechanicJobCtx) CreateMechanicAccount(ctx context.Context, form *MechanicAccountRequest) error {
+	mechanic, err := c.orderModel.GetOrderMechanic(ctx, form.MechanicID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return &handler.MechanicNotFound
+		}
+		log.Error().Err(fmt.Errorf("error when GetOrderMechanic: %w", err)).Send()
+		return &handler.InternalServerError
+	}
+
+	emailUsed, err := c.userModel.IsEmailUsed(ctx, form.Email)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when IsEmailUsed: %w", err)).Send()
+		return &handler.InternalServerError
+	}
+	if emailUsed {
+		return &handler.DuplicatedEmailError
+	}
+
+	uid, err := uuid.GenerateUUID()
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when generateUUID: %w", err)).Send()
+		return &handler.InternalServerError
+	}
+
+	hashPassword, err := password.HashPassword(form.Password)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when hashPassword: %w", err)).Send()
+		return &handler.InternalServerError
+	}
+
+	created, err := c.mechanicModel.SetMechanicAccount(ctx, form.MechanicID, &model.RegisterUser{
+		ID:       uid,
+		Name:     mechanic.Name,
+		Email:    form.Email,
+		Password: hashPassword,
+	})
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when SetMechanicAccount: %w", err)).Send()
+		return &handler.InternalServerError
+	}
+	if !created {
+		return &handler.MechanicAccountExists
+	}
+	return nil
+}
+
+func (req *MechanicProfileRequest) ValidateMechanicProfileRequest() ([]handler.Fields, error) {
+	var count int
+	var fields []handler.Fields
+	mechanicID, err := strconv.Atoi(req.MechanicIDString)
+	if err != nil || mechanicID < 1 {
+		count++
+		fields = append(fields, handler.Fields{
+			Name:    "mechanic_id",
+			Message: "mechanic_id must be more than 0",
+		})
+	}
+	req.MechanicID = mechanicID
+
+	if count == 0 {
+		return nil, nil
+	}
+	return fields, errors.New(handler.ValidationFailed)
+}
+
+// MechanicProfile shows customers who is coming together with the latest reviews of the mechanic
+func (c *mechanicJobCtx) MechanicProfile(ctx context.Context, form *MechanicProfileRequest) (*MechanicProfileResponse, error) {
+	mechanic, err := c.mechanicModel.GetMechanicByID(ctx, form.MechanicID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, &handler.MechanicNotFound
+		}
+		log.Error().Err(fmt.Errorf("error when GetMechanicByID : %w", err)).Send()
+		return nil, &handler.InternalServerError
+	}
+
+	reviews, err := c.reviewModel.ListOfMechanicReviews(ctx, mechanic.ID, mechanicProfileReviews)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when ListOfMechanicReviews : %w", err)).Send()
+		return nil, &handler.InternalServerError
+	}
+
+	profile := MechanicProfile{
+		ID:               mechanic.ID,
+		Name:             mechanic.Name,
+		Picture:          mechanic.Picture.String,
+		CompletedService: mechanic.CompletedService,
+		Rating:           mechanic.Rating,
+		ReviewCount:      mechanic.ReviewCount,
+		Reviews:          make([]MechanicReview, 0),
+	}
+	for _, v := range reviews {
+		profile.Reviews = append(profile.Reviews, MechanicReview{
+			Reviewer:  v.UserName,
+			Rating:    v.Rating,
+			Feedback:  v.Feedback.String,
+			CreatedAt: v.CreatedAt.Format(time.RFC3339),
+		})
+	}
+
+	return &MechanicProfileResponse{
+		Data: profile,
+	}, nil
+}
+
+func (c *mechanicJobCtx) ListOfJobs(ctx context.Context, userID string) (*JobListResponse, error) {
+	mechanic, err := mechanicOfUser(ctx, c.mechanicModel, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	orders, err := c.mechanicModel.ListOfMechanicJobs(ctx, mechanic.ID)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when ListOfMechanicJobs : %w", err)).Send()
+		return nil, &handler.InternalServerError
+	}
+
+	jobs := make([]Job, 0)
+	for _, order := range orders {
+		orderItems := make([]OrderItem, 0)
+		items, err := c.orderModel.ListOfOrderItems(ctx, order.ID)
+		if err != nil {
+			log.Error().Err(fmt.Errorf("error when getListOfOrderItems : %w", err)).Send()
+			return nil, err
+		}
+
+		for _, v := range items {
+			orderItems = append(orderItems, OrderItem{
+				ServiceID: v.ServiceID,
+				Title:     v.Title,
+				Price:     v.Price,
+				Picture:   v.Picture,
+			})
+		}
+
+		userLoc, err := c.orderModel.OrderLocation(ctx, order.UserAddressID)
+		if err != nil {
+			log.Error().Err(fmt.Errorf("error when getOrderLocation : %w", err)).Send()
+			return nil, err
+		}
+
+		appointmentDate, err := time.Parse(time.RFC3339, order.Date)
+		if err != nil {
+			log.Error().Err(fmt.Errorf("error when parsingDate: %w", err)).Send()
+			return nil, &handler.InternalServerError
+		}
+
+		job := Job{
+			OrderID:         order.ID,
+			InvoiceID:       order.InvoiceID,
+			Description:     order.Description.String,
+			MotorCycleBrand: order.MotorCycleBrand,
+			Appointment: OrderAppointment{
+				Date: appointmentDate.Local().Format("2006-01-02"),
+				Time: order.TimeSlot,
+			},
+			Location: OrderLocation{
+				AddressID: userLoc.ID,
+				Address:   userLoc.Address,
+				Label:     userLoc.Label,
+				Recipient: userLoc.RecipientName,
+				PhoneNum:  userLoc.PhoneNumber,
+			},
+			Items:        orderItems,
+			TotalPrice:   order.TotalPrice,
+			StatusOrder:  order.OrderStatus.String,
+			StatusDetail: order.OrderDetail.String,
+		}
+		if order.AcceptedAt.Valid {
+			job.AcceptedAt = order.AcceptedAt.Time.Format(time.RFC3339)
+		}
+		jobs = append(jobs, job)
+	}
+
+	return &JobListResponse{
+		Data: jobs,
+	}, nil
+}
+
+func (c *mechanicJobCtx) AcceptJob(ctx context.Context, userID, orderID string) (*JobStatusResponse, error) {
+	mechanic, err := mechanicOfUser(ctx, c.mechanicModel, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := authorizeJob(ctx, c.orderModel, mechanic.ID, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	accepted, err := c.mechanicModel.AcceptJob(ctx, mechanic.ID, orderID)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when AcceptJob : %w", err)).Send()
+		return nil, &handler.InternalServerError
+	}
+	if !accepted {
+		return nil, &handler.JobCannotBeAccepted
+	}
+
+	return &JobStatusResponse{
+		OrderID:     orderID,
+		StatusOrder: order.OrderStatus.String,
+	}, nil
+}
+
+func (c *mechanicJobCtx) StartTravel(ctx context.Context, userID, orderID string) (*JobStatusResponse, error) {
+	return c.progressJob(ctx, userID, orderID, model.OrderStatus[3])
+}
+
+func (c *mechanicJobCtx) MarkArrived(ctx context.Context, userID, orderID string) (*JobStatusResponse, error) {
+	return c.progressJob(ctx, userID, orderID, model.OrderStatus[4])
+}
+
+func (c *mechanicJobCtx) CompleteJob(ctx context.Context, userID, orderID string) (*JobStatusResponse, error) {
+	return c.progressJob(ctx, userID, orderID, model.OrderStatus[5])
+}
+
+// progressJob moves an accepted job to status through the same state machine the operators use
+func (c *mechanicJobCtx) progressJob(ctx context.Context, userID, orderID, status string) (*JobStatusResponse, error) {
+	mechanic, err := mechanicOfUser(ctx, c.mechanicModel, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := authorizeJob(ctx, c.orderModel, mechanic.ID, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !order.AcceptedAt.Valid {
+		return nil, &handler.JobNotAccepted
+	}
+
+	err = progressOrder(ctx, c.orderModel, order.InvoiceID, status, userID)
+	if err != nil {
+		return nil, err
+	}
+	c.broker.Publish(orderID, tracking.Update{
+		Status:     status,
+		RecordedAt: time.Now(),
+	})
+
+	return &JobStatusResponse{
+		OrderID:     orderID,
+		StatusOrder: status,
+	}, nil
+}
+
+// mechanicOfUser resolves the mechanic linked to the account, a mechanic role
+// without a linked mechanic has no jobs to act on.
+func mechanicOfUser(ctx context.Context, mechanicModel model.Mechanic, userID string) (*model.MechanicBaseModel, error) {
+	mechanic, err := mechanicModel.GetMechanicByUserID(ctx, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, &handler.ForbiddenError
+		}
+		log.Error().Err(fmt.Errorf("error when GetMechanicByUserID : %w", err)).Send()
+		return nil, &handler.InternalServerError
+	}
+	return mechanic, nil
+}
@@ -0,0 +1,628 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"e-montir/api/handler"
+	"e-montir/model"
+	"e-montir/pkg/mailer"
+	"e-montir/pkg/notification"
+	"e-montir/pkg/validator"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	notificationDispatchBatch = 50
+
+	// a notification claimed by a dispatcher which died is sent again once the lease is over
+	notificationLease = 5 * time.Minute
+)
+
+// deviceFinder is the part of model.Device the dispatcher depends on
+type deviceFinder interface {
+	ListOfActiveDevices(ctx context.Context, userID string, since time.Time) ([]model.DeviceBaseModel, error)
+	RemoveDeviceByToken(ctx context.Context, token string) error
+}
+
+// recipientFinder is the part of model.User the dispatcher depends on to mail the user
+type recipientFinder interface {
+	GetUserByID(ctx context.Context, userID string) (*model.UserBaseModel, error)
+}
+
+type notificationCtx struct {
+	notificationModel model.Notification
+	deviceModel       deviceFinder
+	userModel         recipientFinder
+	preferenceModel   model.NotificationPreference
+	notifier          notification.Notifier
+	mailTransport     mailer.Transport
+}
+
+type Notification interface {
+	DispatchNotifications(ctx context.Context) error
+	ListOfNotifications(ctx context.Context, userID string, form *NotificationListRequest) (*NotificationListResponse, error)
+	MarkNotificationAsRead(ctx context.Context, userID string, notificationID int) error
+	MarkAllNotificationsAsRead(ctx context.Context, userID string) error
+	SendPromotion(ctx context.Context, form *SendPromotionRequest) (*SendPromotionResponse, error)
+	GetNotificationPreferences(ctx context.Context, userID string) (*NotificationPreferences, error)
+	UpdateNotificationPreferences(ctx context.Context, userID string, form *NotificationPreferences) (*NotificationPreferences, error)
+}
+
+func NewNotification(notificationModel model.Notification, deviceModel deviceFinder, userModel recipientFinder, preferenceModel model.NotificationPreference,
+	notifier notification.Notifier, mailTransport mailer.Transport) Notification {
+	return &notificationCtx{
+		notificationModel: notificationModel,
+		deviceModel:       deviceModel,
+		userModel:         userModel,
+		preferenceModel:   preferenceModel,
+		notifier:          notifier,
+		mailTransport:     mailTransport,
+	}
+}
+
+type (
+	NotificationListRequest struct {
+		Cursor       int
+		Limit        int
+		CursorString string
+		LimitString  string
+	}
+
+	NotificationReadRequest struct {
+		NotificationID       int
+		NotificationIDString string
+	}
+
+	InboxNotification struct {
+		ID        int               `json:"id"`
+		Category  string            `json:"category"`
+		Title     string            `json:"title"`
+		Body      string            `json:"body"`
+		Redirect  string            `json:"redirect,omitempty"`
+		Data      map[string]string `json:"data"`
+		ReadAt    string            `json:"read_at,omitempty"`
+		CreatedAt string            `json:"created_at"`
+	}
+
+	CursorPagination struct {
+		NextCursor int `json:"next_cursor"`
+	}
+
+	NotificationListResponse struct {
+		Data        []InboxNotification `json:"data"`
+		UnreadCount int                 `json:"unread_count"`
+		Pagination  CursorPagination    `json:"pagination"`
+	}
+
+	SendPromotionRequest struct {
+		Title    string `json:"title"`
+		Body     string `json:"body"`
+		Redirect string `json:"redirect"`
+	}
+
+	SendPromotionResponse struct {
+		Recipients int64 `json:"recipients"`
+	}
+
+	// NotificationPreferences is read and written as a whole, a field left out of an update turns it off
+	NotificationPreferences struct {
+		Channels   NotificationChannelPreferences  `json:"channels"`
+		Categories NotificationCategoryPreferences `json:"categories"`
+		QuietHours QuietHours                      `json:"quiet_hours"`
+	}
+
+	NotificationChannelPreferences struct {
+		Push  bool `json:"push"`
+		Email bool `json:"email"`
+		SMS   bool `json:"sms"`
+	}
+
+	NotificationCategoryPreferences struct {
+		OrderUpdates bool `json:"order_updates"`
+		Reminders    bool `json:"reminders"`
+		Promotions   bool `json:"promotions"`
+	}
+
+	// QuietHours holds back pushes and sms from start until end, in the timezone of the user. It may run past midnight.
+	QuietHours struct {
+		Enabled  bool   `json:"enabled"`
+		Start    string `json:"start,omitempty"`
+		End      string `json:"end,omitempty"`
+		Timezone string `json:"timezone"`
+	}
+)
+
+func (req *NotificationListRequest) ValidateNotificationListRequest() ([]handler.Fields, error) {
+	var count int
+	var fields []handler.Fields
+	if strings.TrimSpace(req.CursorString) != "" {
+		cursor, err := strconv.Atoi(req.CursorString)
+		if err != nil || cursor < 1 {
+			count++
+			fields = append(fields, handler.Fields{
+				Name:    "cursor",
+				Message: "cursor must be more than 0",
+			})
+		}
+		req.Cursor = cursor
+	}
+
+	req.Limit = 20
+	if strings.TrimSpace(req.LimitString) != "" {
+		limit, err := strconv.Atoi(req.LimitString)
+		if err != nil || limit < 1 || limit > 100 {
+			count++
+			fields = append(fields, handler.Fields{
+				Name:    "limit",
+				Message: "limit must be between 1 and 100",
+			})
+		}
+		req.Limit = limit
+	}
+
+	if count == 0 {
+		return nil, nil
+	}
+	return fields, errors.New(handler.ValidationFailed)
+}
+
+func (req *NotificationReadRequest) ValidateNotificationReadRequest() ([]handler.Fields, error) {
+	notificationID, err := strconv.Atoi(req.NotificationIDString)
+	if err != nil || notificationID < 1 {
+		return []handler.Fields{{Name: "notification_id", Message: "notification_id must be more than 0"}}, errors.New(handler.ValidationFailed)
+	}
+	req.NotificationID = notificationID
+	return nil, nil
+}
+
+func (req *SendPromotionRequest) ValidateSendPromotionRequest() ([]handler.Fields, error) {
+	var count int
+	var fields []handler.Fields
+	err := validator.ValidateNotificationTitle(req.Title)
+	if err != nil {
+		count++
+		fields = append(fields, handler.Fields{
+			Name:    "title",
+			Message: err.Error(),
+		})
+	}
+
+	err = validator.ValidateNotificationBody(req.Body)
+	if err != nil {
+		count++
+		fields = append(fields, handler.Fields{
+			Name:    "body",
+			Message: err.Error(),
+		})
+	}
+
+	err = validator.ValidateRedirect(req.Redirect)
+	if err != nil {
+		count++
+		fields = append(fields, handler.Fields{
+			Name:    "redirect",
+			Message: err.Error(),
+		})
+	}
+
+	if count == 0 {
+		return nil, nil
+	}
+	return fields, errors.New(handler.ValidationFailed)
+}
+
+func (req *NotificationPreferences) ValidateNotificationPreferences() ([]handler.Fields, error) {
+	var count int
+	var fields []handler.Fields
+	if req.QuietHours.Enabled {
+		err := validator.ValidateQuietHour(req.QuietHours.Start)
+		if err != nil {
+			count++
+			fields = append(fields, handler.Fields{
+				Name:    "quiet_hours.start",
+				Message: err.Error(),
+			})
+		}
+
+		err = validator.ValidateQuietHour(req.QuietHours.End)
+		if err != nil {
+			count++
+			fields = append(fields, handler.Fields{
+				Name:    "quiet_hours.end",
+				Message: err.Error(),
+			})
+		}
+
+		if err == nil && req.QuietHours.Start == req.QuietHours.End {
+			count++
+			fields = append(fields, handler.Fields{
+				Name:    "quiet_hours.end",
+				Message: "quiet hours cannot end when they start",
+			})
+		}
+	} else {
+		req.QuietHours.Start = ""
+		req.QuietHours.End = ""
+	}
+
+	if req.QuietHours.Timezone == "" {
+		req.QuietHours.Timezone = model.DefaultNotificationTimezone
+	}
+	err := validator.ValidateTimezone(req.QuietHours.Timezone)
+	if err != nil {
+		count++
+		fields = append(fields, handler.Fields{
+			Name:    "quiet_hours.timezone",
+			Message: err.Error(),
+		})
+	}
+
+	if count == 0 {
+		return nil, nil
+	}
+	return fields, errors.New(handler.ValidationFailed)
+}
+
+// DispatchNotifications sends the due notifications of the outbox through their channel, a failed one
+// is retried with a growing backoff until it runs out of attempts.
+func (c *notificationCtx) DispatchNotifications(ctx context.Context) error {
+	now := time.Now()
+	notifications, err := c.notificationModel.ClaimNotifications(ctx, now, notificationLease, notificationDispatchBatch)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when ClaimNotifications : %w", err)).Send()
+		return err
+	}
+
+	for i := range notifications {
+		err = c.dispatch(ctx, &notifications[i], now)
+		if err != nil {
+			log.Error().Err(fmt.Errorf("error when dispatching notification %d : %w", notifications[i].ID, err)).Send()
+		}
+	}
+	return nil
+}
+
+// dispatch sends the notification unless the user opted out of its channel or category. A push goes
+// to every active device of the user, it counts as sent once any device got it. Devices the provider
+// rejects for good are forgotten.
+func (c *notificationCtx) dispatch(ctx context.Context, param *model.NotificationBaseModel, now time.Time) error {
+	preference, err := c.notificationPreference(ctx, param.UserID)
+	if err != nil {
+		return c.retry(ctx, param, now, err)
+	}
+	if !channelAllowed(preference, param.Channel) || !categoryAllowed(preference, param.Category) {
+		return c.notificationModel.FailNotification(ctx, param.ID, now, "user opted out of "+param.Category+" notifications by "+param.Channel)
+	}
+
+	// the preference is kept, but nothing sends sms until there is a provider for it
+	if param.Channel == model.NotificationChannelSMS {
+		return c.notificationModel.FailNotification(ctx, param.ID, now, "no sms provider")
+	}
+
+	// an email waits in the mailbox, only what interrupts the user is held back until the quiet hours are over
+	if param.Channel != model.NotificationChannelEmail {
+		if until, quiet := quietHoursEnd(preference, now); quiet {
+			return c.notificationModel.DeferNotification(ctx, param.ID, until)
+		}
+	}
+
+	if param.Channel == model.NotificationChannelEmail {
+		return c.dispatchEmail(ctx, param, now)
+	}
+
+	devices, err := c.deviceModel.ListOfActiveDevices(ctx, param.UserID, now.Add(-model.DeviceActivePeriod))
+	if err != nil {
+		return c.retry(ctx, param, now, err)
+	}
+
+	// nobody to push to, the user is not logged in on any device
+	if len(devices) == 0 {
+		return c.notificationModel.FailNotification(ctx, param.ID, now, "user has no active device")
+	}
+
+	var delivered bool
+	var sendErr error
+	for _, v := range devices {
+		err = c.notifier.Send(ctx, &notification.Message{
+			To:    v.Token,
+			Title: param.Title,
+			Body:  param.Body,
+			Data:  param.Data,
+		})
+		if err == nil {
+			delivered = true
+			continue
+		}
+
+		if errors.Is(err, notification.ErrInvalidToken) {
+			removeErr := c.deviceModel.RemoveDeviceByToken(ctx, v.Token)
+			if removeErr != nil {
+				log.Error().Err(fmt.Errorf("error when RemoveDeviceByToken : %w", removeErr)).Send()
+			}
+			if sendErr == nil {
+				sendErr = err
+			}
+			continue
+		}
+		sendErr = err
+	}
+
+	if delivered {
+		return c.notificationModel.MarkNotificationSent(ctx, param.ID, now)
+	}
+	if errors.Is(sendErr, notification.ErrInvalidToken) {
+		return c.notificationModel.FailNotification(ctx, param.ID, now, sendErr.Error())
+	}
+	return c.retry(ctx, param, now, sendErr)
+}
+
+// dispatchEmail mails the notification with its template to the address of the user. It is marked sent only once
+// the mail server accepted it, a failed send is retried from the outbox like a push.
+func (c *notificationCtx) dispatchEmail(ctx context.Context, param *model.NotificationBaseModel, now time.Time) error {
+	user, err := c.userModel.GetUserByID(ctx, param.UserID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return c.notificationModel.FailNotification(ctx, param.ID, now, "user not exists")
+		}
+		return c.retry(ctx, param, now, err)
+	}
+
+	msg, err := mailer.Render(param.EmailTemplate.String, user.Email, &mailer.TemplateData{
+		Name:  user.Name,
+		Title: param.Title,
+		Body:  param.Body,
+		Link:  appLink(param.Data["redirect"]),
+		Data:  param.Data,
+	})
+	if err != nil {
+		// rendering again gives the same result, the template has to be fixed first
+		return c.notificationModel.FailNotification(ctx, param.ID, now, err.Error())
+	}
+
+	err = c.mailTransport.Send(ctx, msg)
+	if err != nil {
+		return c.retry(ctx, param, now, err)
+	}
+	return c.notificationModel.MarkNotificationSent(ctx, param.ID, now)
+}
+
+// notificationPreference gives the defaults to a user who never changed the preferences
+func (c *notificationCtx) notificationPreference(ctx context.Context, userID string) (*model.NotificationPreferenceBaseModel, error) {
+	preference, err := c.preferenceModel.GetNotificationPreference(ctx, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return model.DefaultNotificationPreference(userID), nil
+		}
+		return nil, err
+	}
+	return preference, nil
+}
+
+func channelAllowed(preference *model.NotificationPreferenceBaseModel, channel string) bool {
+	switch channel {
+	case model.NotificationChannelPush:
+		return preference.PushEnabled
+	case model.NotificationChannelEmail:
+		return preference.EmailEnabled
+	case model.NotificationChannelSMS:
+		return preference.SMSEnabled
+	}
+	return true
+}
+
+func categoryAllowed(preference *model.NotificationPreferenceBaseModel, category string) bool {
+	switch category {
+	case model.NotificationCategoryPayment, model.NotificationCategoryOrderStatus:
+		return preference.OrderUpdatesEnabled
+	case model.NotificationCategoryReviewReminder:
+		return preference.RemindersEnabled
+	case model.NotificationCategoryPromotion:
+		return preference.PromotionsEnabled
+	}
+	return true
+}
+
+// quietHoursEnd tells whether now falls in the quiet hours of the user and when they are over
+func quietHoursEnd(preference *model.NotificationPreferenceBaseModel, now time.Time) (time.Time, bool) {
+	if !preference.QuietHoursStart.Valid || !preference.QuietHoursEnd.Valid {
+		return time.Time{}, false
+	}
+	start, err := time.Parse("15:04", preference.QuietHoursStart.String)
+	if err != nil {
+		return time.Time{}, false
+	}
+	end, err := time.Parse("15:04", preference.QuietHoursEnd.String)
+	if err != nil {
+		return time.Time{}, false
+	}
+	loc, err := time.LoadLocation(preference.Timezone)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when LoadLocation %q : %w", preference.Timezone, err)).Send()
+		return time.Time{}, false
+	}
+
+	local := now.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	startMinute := start.Hour()*60 + start.Minute()
+	endMinute := end.Hour()*60 + end.Minute()
+	endAt := time.Date(local.Year(), local.Month(), local.Day(), end.Hour(), end.Minute(), 0, 0, loc)
+
+	if startMinute < endMinute {
+		return endAt, minute >= startMinute && minute < endMinute
+	}
+	// the quiet hours run past midnight, before midnight they end tomorrow
+	if minute >= startMinute {
+		return endAt.AddDate(0, 0, 1), true
+	}
+	return endAt, minute < endMinute
+}
+
+// appLink opens redirect in the app, APP_URL is the deep link prefix of the app
+func appLink(redirect string) string {
+	appURL := os.Getenv("APP_URL")
+	if appURL == "" {
+		appURL = "emontir://app"
+	}
+	return appURL + redirect
+}
+
+func (c *notificationCtx) retry(ctx context.Context, param *model.NotificationBaseModel, now time.Time, cause error) error {
+	if param.Attempts >= notification.MaxAttempts {
+		return c.notificationModel.FailNotification(ctx, param.ID, now, cause.Error())
+	}
+	return c.notificationModel.RetryNotification(ctx, param.ID, now.Add(notification.Backoff(param.Attempts)), cause.Error())
+}
+
+// ListOfNotifications lists the inbox of the user, newest first
+func (c *notificationCtx) ListOfNotifications(ctx context.Context, userID string, form *NotificationListRequest) (*NotificationListResponse, error) {
+	now := time.Now()
+
+	// one more row than asked tells whether there is a next page
+	res, err := c.notificationModel.ListOfInboxNotifications(ctx, userID, form.Cursor, form.Limit+1, now)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when ListOfInboxNotifications : %w", err)).Send()
+		return nil, &handler.InternalServerError
+	}
+
+	unread, err := c.notificationModel.CountUnreadNotifications(ctx, userID, now)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when CountUnreadNotifications : %w", err)).Send()
+		return nil, &handler.InternalServerError
+	}
+
+	response := &NotificationListResponse{
+		Data:        make([]InboxNotification, 0),
+		UnreadCount: unread,
+	}
+	if len(res) > form.Limit {
+		res = res[:form.Limit]
+		response.Pagination.NextCursor = res[len(res)-1].ID
+	}
+	for i := range res {
+		response.Data = append(response.Data, inboxNotification(&res[i]))
+	}
+	return response, nil
+}
+
+func (c *notificationCtx) MarkNotificationAsRead(ctx context.Context, userID string, notificationID int) error {
+	marked, err := c.notificationModel.MarkNotificationAsRead(ctx, userID, notificationID, time.Now())
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when MarkNotificationAsRead : %w", err)).Send()
+		return &handler.InternalServerError
+	}
+	if !marked {
+		return &handler.NotificationNotFound
+	}
+	return nil
+}
+
+func (c *notificationCtx) MarkAllNotificationsAsRead(ctx context.Context, userID string) error {
+	_, err := c.notificationModel.MarkAllNotificationsAsRead(ctx, userID, time.Now())
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when MarkAllNotificationsAsRead : %w", err)).Send()
+		return &handler.InternalServerError
+	}
+	return nil
+}
+
+// SendPromotion puts the promotion in the inbox of every active customer and queues its pushes
+func (c *notificationCtx) SendPromotion(ctx context.Context, form *SendPromotionRequest) (*SendPromotionResponse, error) {
+	data := model.NotificationData{}
+	if form.Redirect != "" {
+		data["redirect"] = form.Redirect
+	}
+
+	recipients, err := c.notificationModel.EnqueuePromotion(ctx, model.UserRoleCustomer, &model.NotificationBaseModel{
+		Category:  model.NotificationCategoryPromotion,
+		Title:     form.Title,
+		Body:      form.Body,
+		Data:      data,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when EnqueuePromotion : %w", err)).Send()
+		return nil, &handler.InternalServerError
+	}
+	return &SendPromotionResponse{Recipients: recipients}, nil
+}
+
+func (c *notificationCtx) GetNotificationPreferences(ctx context.Context, userID string) (*NotificationPreferences, error) {
+	preference, err := c.notificationPreference(ctx, userID)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when GetNotificationPreference : %w", err)).Send()
+		return nil, &handler.InternalServerError
+	}
+	return notificationPreferences(preference), nil
+}
+
+func (c *notificationCtx) UpdateNotificationPreferences(ctx context.Context, userID string, form *NotificationPreferences) (*NotificationPreferences, error) {
+	preference := &model.NotificationPreferenceBaseModel{
+		UserID:              userID,
+		PushEnabled:         form.Channels.Push,
+		EmailEnabled:        form.Channels.Email,
+		SMSEnabled:          form.Channels.SMS,
+		OrderUpdatesEnabled: form.Categories.OrderUpdates,
+		RemindersEnabled:    form.Categories.Reminders,
+		PromotionsEnabled:   form.Categories.Promotions,
+		Timezone:            form.QuietHours.Timezone,
+		UpdatedAt:           time.Now(),
+	}
+	if form.QuietHours.Enabled {
+		preference.QuietHoursStart = sql.NullString{String: form.QuietHours.Start, Valid: true}
+		preference.QuietHoursEnd = sql.NullString{String: form.QuietHours.End, Valid: true}
+	}
+
+	err := c.preferenceModel.SetNotificationPreference(ctx, preference)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when SetNotificationPreference : %w", err)).Send()
+		return nil, &handler.InternalServerError
+	}
+	return notificationPreferences(preference), nil
+}
+
+func notificationPreferences(param *model.NotificationPreferenceBaseModel) *NotificationPreferences {
+	return &NotificationPreferences{
+		Channels: NotificationChannelPreferences{
+			Push:  param.PushEnabled,
+			Email: param.EmailEnabled,
+			SMS:   param.SMSEnabled,
+		},
+		Categories: NotificationCategoryPreferences{
+			OrderUpdates: param.OrderUpdatesEnabled,
+			Reminders:    param.RemindersEnabled,
+			Promotions:   param.PromotionsEnabled,
+		},
+		QuietHours: QuietHours{
+			Enabled:  param.QuietHoursStart.Valid && param.QuietHoursEnd.Valid,
+			Start:    param.QuietHoursStart.String,
+			End:      param.QuietHoursEnd.String,
+			Timezone: param.Timezone,
+		},
+	}
+}
+
+func inboxNotification(param *model.InboxNotificationBaseModel) InboxNotification {
+	res := InboxNotification{
+		ID:        param.ID,
+		Category:  param.Category,
+		Title:     param.Title,
+		Body:      param.Body,
+		Redirect:  param.Data["redirect"],
+		Data:      param.Data,
+		CreatedAt: param.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	if res.Data == nil {
+		res.Data = map[string]string{}
+	}
+	if param.ReadAt.Valid {
+		res.ReadAt = param.ReadAt.Time.UTC().Format(time.RFC3339)
+	}
+	return res
+}
@@ -0,0 +1,439 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"e-montir/api/handler"
+	"e-montir/model"
+	"e-montir/pkg/mailer"
+	"e-montir/pkg/notification"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type stubNotificationModel struct {
+	inbox    []model.InboxNotificationBaseModel
+	pending  []model.NotificationBaseModel
+	sent     []int
+	retried  map[int]time.Time
+	failed   map[int]string
+	deferred map[int]time.Time
+}
+
+func newStubNotificationModel(pending ...model.NotificationBaseModel) *stubNotificationModel {
+	return &stubNotificationModel{
+		pending:  pending,
+		retried:  map[int]time.Time{},
+		failed:   map[int]string{},
+		deferred: map[int]time.Time{},
+	}
+}
+
+func (s *stubNotificationModel) EnqueueNotification(ctx context.Context, param *model.NotificationBaseModel) error {
+	s.pending = append(s.pending, *param)
+	return nil
+}
+
+func (s *stubNotificationModel) ClaimNotifications(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]model.NotificationBaseModel, error) {
+	claimed := s.pending
+	s.pending = nil
+	for i := range claimed {
+		claimed[i].Attempts++
+	}
+	return claimed, nil
+}
+
+func (s *stubNotificationModel) MarkNotificationSent(ctx context.Context, notificationID int, sentAt time.Time) error {
+	s.sent = append(s.sent, notificationID)
+	return nil
+}
+
+func (s *stubNotificationModel) RetryNotification(ctx context.Context, notificationID int, nextAttemptAt time.Time, lastError string) error {
+	s.retried[notificationID] = nextAttemptAt
+	return nil
+}
+
+func (s *stubNotificationModel) FailNotification(ctx context.Context, notificationID int, failedAt time.Time, lastError string) error {
+	s.failed[notificationID] = lastError
+	return nil
+}
+
+func (s *stubNotificationModel) DeferNotification(ctx context.Context, notificationID int, nextAttemptAt time.Time) error {
+	s.deferred[notificationID] = nextAttemptAt
+	return nil
+}
+
+func (s *stubNotificationModel) EnqueuePromotion(ctx context.Context, role string, param *model.NotificationBaseModel) (int64, error) {
+	return 0, nil
+}
+
+// ListOfInboxNotifications expects the inbox newest first, the cursor is the id of the last notification seen
+func (s *stubNotificationModel) ListOfInboxNotifications(ctx context.Context, userID string, cursor, limit int, now time.Time) ([]model.InboxNotificationBaseModel, error) {
+	var result []model.InboxNotificationBaseModel
+	passed := cursor == 0
+	for _, v := range s.inbox {
+		if !passed {
+			passed = v.ID == cursor
+			continue
+		}
+		if v.UserID == userID && len(result) < limit {
+			result = append(result, v)
+		}
+	}
+	return result, nil
+}
+
+func (s *stubNotificationModel) CountUnreadNotifications(ctx context.Context, userID string, now time.Time) (int, error) {
+	var count int
+	for _, v := range s.inbox {
+		if v.UserID == userID && !v.ReadAt.Valid {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *stubNotificationModel) MarkNotificationAsRead(ctx context.Context, userID string, notificationID int, readAt time.Time) (bool, error) {
+	for i, v := range s.inbox {
+		if v.ID == notificationID && v.UserID == userID {
+			s.inbox[i].ReadAt = sql.NullTime{Time: readAt, Valid: true}
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *stubNotificationModel) MarkAllNotificationsAsRead(ctx context.Context, userID string, readAt time.Time) (int64, error) {
+	return 0, nil
+}
+
+// stubDeviceFinder maps the users to the tokens of their devices
+type stubDeviceFinder map[string][]string
+
+func (s stubDeviceFinder) ListOfActiveDevices(ctx context.Context, userID string, since time.Time) ([]model.DeviceBaseModel, error) {
+	if userID == "broken-user" {
+		return nil, errors.New("connection refused")
+	}
+	var devices []model.DeviceBaseModel
+	for _, v := range s[userID] {
+		devices = append(devices, model.DeviceBaseModel{UserID: userID, Token: v})
+	}
+	return devices, nil
+}
+
+func (s stubDeviceFinder) RemoveDeviceByToken(ctx context.Context, token string) error {
+	for userID, tokens := range s {
+		var kept []string
+		for _, v := range tokens {
+			if v != token {
+				kept = append(kept, v)
+			}
+		}
+		s[userID] = kept
+	}
+	return nil
+}
+
+type stubRecipientFinder map[string]*model.UserBaseModel
+
+func (s stubRecipientFinder) GetUserByID(ctx context.Context, userID string) (*model.UserBaseModel, error) {
+	user, ok := s[userID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return user, nil
+}
+
+// stubPreferenceModel holds the preferences users changed, the others get the defaults
+type stubPreferenceModel map[string]*model.NotificationPreferenceBaseModel
+
+func (s stubPreferenceModel) GetNotificationPreference(ctx context.Context, userID string) (*model.NotificationPreferenceBaseModel, error) {
+	preference, ok := s[userID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return preference, nil
+}
+
+func (s stubPreferenceModel) SetNotificationPreference(ctx context.Context, param *model.NotificationPreferenceBaseModel) error {
+	s[param.UserID] = param
+	return nil
+}
+
+func TestDispatchNotifications(t *testing.T) {
+	notifications := newStubNotificationModel(
+		model.NotificationBaseModel{ID: 1, UserID: "user-1", Title: "payment success", Data: model.NotificationData{"order_id": "order-1"}},
+		model.NotificationBaseModel{ID: 2, UserID: "user-2", Title: "service done"},
+		model.NotificationBaseModel{ID: 3, UserID: "broken-user", Title: "service done"},
+		model.NotificationBaseModel{ID: 4, UserID: "broken-user", Title: "service done", Attempts: notification.MaxAttempts - 1},
+	)
+	notifier := notification.NewMemory()
+	c := NewNotification(notifications, stubDeviceFinder{"user-1": {"phone-1", "tablet-1"}}, stubRecipientFinder{}, stubPreferenceModel{}, notifier, mailer.NewMemory())
+
+	before := time.Now()
+	assert.NoError(t, c.DispatchNotifications(context.Background()))
+
+	assert.Equal(t, []int{1}, notifications.sent)
+	messages := notifier.Messages()
+	assert.Len(t, messages, 2)
+	assert.Equal(t, "phone-1", messages[0].To)
+	assert.Equal(t, "tablet-1", messages[1].To)
+	assert.Equal(t, "order-1", messages[0].Data["order_id"])
+
+	assert.Contains(t, notifications.failed, 2)
+	assert.Contains(t, notifications.failed, 4)
+	assert.WithinDuration(t, before.Add(notification.Backoff(1)), notifications.retried[3], time.Second)
+}
+
+func TestDispatchNotificationsSendError(t *testing.T) {
+	notifications := newStubNotificationModel(
+		model.NotificationBaseModel{ID: 1, UserID: "user-1", Title: "payment success"},
+	)
+	notifier := notification.NewMemory()
+	c := NewNotification(notifications, stubDeviceFinder{"user-1": {"phone-1"}}, stubRecipientFinder{}, stubPreferenceModel{}, notifier, mailer.NewMemory())
+
+	notifier.Err = errors.New("unavailable")
+	assert.NoError(t, c.DispatchNotifications(context.Background()))
+	assert.Contains(t, notifications.retried, 1)
+	assert.Empty(t, notifications.failed)
+}
+
+func TestDispatchNotificationsInvalidToken(t *testing.T) {
+	invalid := fmt.Errorf("%w: not registered", notification.ErrInvalidToken)
+
+	t.Run("other device still gets it", func(t *testing.T) {
+		notifications := newStubNotificationModel(
+			model.NotificationBaseModel{ID: 1, UserID: "user-1", Title: "payment success"},
+		)
+		notifier := notification.NewMemory()
+		notifier.TokenErrs = map[string]error{"old-phone": invalid}
+		devices := stubDeviceFinder{"user-1": {"old-phone", "phone-1"}}
+		c := NewNotification(notifications, devices, stubRecipientFinder{}, stubPreferenceModel{}, notifier, mailer.NewMemory())
+
+		assert.NoError(t, c.DispatchNotifications(context.Background()))
+		assert.Equal(t, []int{1}, notifications.sent)
+		assert.Equal(t, []string{"phone-1"}, devices["user-1"])
+	})
+
+	t.Run("no device left", func(t *testing.T) {
+		notifications := newStubNotificationModel(
+			model.NotificationBaseModel{ID: 1, UserID: "user-1", Title: "payment success"},
+		)
+		notifier := notification.NewMemory()
+		notifier.TokenErrs = map[string]error{"old-phone": invalid}
+		devices := stubDeviceFinder{"user-1": {"old-phone"}}
+		c := NewNotification(notifications, devices, stubRecipientFinder{}, stubPreferenceModel{}, notifier, mailer.NewMemory())
+
+		assert.NoError(t, c.DispatchNotifications(context.Background()))
+		assert.Empty(t, notifications.retried)
+		assert.Contains(t, notifications.failed, 1)
+		assert.Empty(t, devices["user-1"])
+	})
+}
+
+func TestDispatchEmailNotifications(t *testing.T) {
+	statusTemplate := sql.NullString{String: mailer.TemplateOrderStatus, Valid: true}
+	notifications := newStubNotificationModel(
+		model.NotificationBaseModel{
+			ID: 1, UserID: "user-1", Channel: model.NotificationChannelEmail, EmailTemplate: statusTemplate,
+			Title: "service done", Body: "service done, looking forward to your next order",
+			Data: model.NotificationData{"invoice_id": "INV-1", "redirect": "/orders/order-1"},
+		},
+		model.NotificationBaseModel{ID: 2, UserID: "user-2", Channel: model.NotificationChannelEmail, EmailTemplate: statusTemplate, Title: "service done"},
+		model.NotificationBaseModel{ID: 3, UserID: "user-1", Channel: model.NotificationChannelEmail, EmailTemplate: sql.NullString{String: "unknown", Valid: true}},
+	)
+	notifier := notification.NewMemory()
+	transport := mailer.NewMemory()
+	users := stubRecipientFinder{"user-1": {ID: "user-1", Name: "Budi", Email: "budi@gmail.com"}}
+	c := NewNotification(notifications, stubDeviceFinder{"user-1": {"phone-1"}}, users, stubPreferenceModel{}, notifier, transport)
+
+	assert.NoError(t, c.DispatchNotifications(context.Background()))
+	assert.Equal(t, []int{1}, notifications.sent)
+	assert.Contains(t, notifications.failed, 2)
+	assert.Contains(t, notifications.failed, 3)
+	assert.Empty(t, notifier.Messages())
+
+	messages := transport.Messages()
+	assert.Len(t, messages, 1)
+	assert.Equal(t, "budi@gmail.com", messages[0].To)
+	assert.Equal(t, "service done", messages[0].Subject)
+	assert.Contains(t, messages[0].Text, "Invoice: INV-1")
+	assert.Contains(t, messages[0].Text, "emontir://app/orders/order-1")
+
+	transport.Err = errors.New("connection refused")
+	notifications.pending = []model.NotificationBaseModel{
+		{ID: 4, UserID: "user-1", Channel: model.NotificationChannelEmail, EmailTemplate: statusTemplate, Title: "service done"},
+	}
+	assert.NoError(t, c.DispatchNotifications(context.Background()))
+	assert.Contains(t, notifications.retried, 4)
+}
+
+func TestDispatchNotificationsPreferences(t *testing.T) {
+	now := time.Now().UTC()
+	noPromotions := model.DefaultNotificationPreference("user-1")
+	noPromotions.PromotionsEnabled = false
+	noEmail := model.DefaultNotificationPreference("user-2")
+	noEmail.EmailEnabled = false
+	noEmail.SMSEnabled = true
+	quiet := model.DefaultNotificationPreference("user-3")
+	quiet.QuietHoursStart = sql.NullString{String: now.Add(-time.Hour).Format("15:04"), Valid: true}
+	quiet.QuietHoursEnd = sql.NullString{String: now.Add(time.Hour).Format("15:04"), Valid: true}
+	quiet.Timezone = "UTC"
+	preferences := stubPreferenceModel{"user-1": noPromotions, "user-2": noEmail, "user-3": quiet}
+
+	statusTemplate := sql.NullString{String: mailer.TemplateOrderStatus, Valid: true}
+	notifications := newStubNotificationModel(
+		model.NotificationBaseModel{ID: 1, UserID: "user-1", Channel: model.NotificationChannelPush, Category: model.NotificationCategoryPromotion, Title: "promo"},
+		model.NotificationBaseModel{ID: 2, UserID: "user-1", Channel: model.NotificationChannelPush, Category: model.NotificationCategoryOrderStatus, Title: "on the way"},
+		model.NotificationBaseModel{ID: 3, UserID: "user-2", Channel: model.NotificationChannelEmail, Category: model.NotificationCategoryOrderStatus, EmailTemplate: statusTemplate, Title: "on the way"},
+		model.NotificationBaseModel{ID: 4, UserID: "user-3", Channel: model.NotificationChannelPush, Category: model.NotificationCategoryOrderStatus, Title: "on the way"},
+		model.NotificationBaseModel{ID: 5, UserID: "user-3", Channel: model.NotificationChannelEmail, Category: model.NotificationCategoryOrderStatus, EmailTemplate: statusTemplate, Title: "on the way"},
+		model.NotificationBaseModel{ID: 6, UserID: "user-2", Channel: model.NotificationChannelSMS, Category: model.NotificationCategoryOrderStatus, Title: "on the way"},
+	)
+	notifier := notification.NewMemory()
+	transport := mailer.NewMemory()
+	devices := stubDeviceFinder{"user-1": {"phone-1"}, "user-2": {"phone-2"}, "user-3": {"phone-3"}}
+	users := stubRecipientFinder{
+		"user-2": {ID: "user-2", Name: "Sari", Email: "sari@gmail.com"},
+		"user-3": {ID: "user-3", Name: "Andi", Email: "andi@gmail.com"},
+	}
+	c := NewNotification(notifications, devices, users, preferences, notifier, transport)
+
+	assert.NoError(t, c.DispatchNotifications(context.Background()))
+	assert.Equal(t, []int{2, 5}, notifications.sent)
+	assert.Contains(t, notifications.failed, 1)
+	assert.Contains(t, notifications.failed, 3)
+	assert.Equal(t, "no sms provider", notifications.failed[6])
+	assert.Equal(t, now.Add(time.Hour).Truncate(time.Minute), notifications.deferred[4].UTC())
+
+	messages := notifier.Messages()
+	assert.Len(t, messages, 1)
+	assert.Equal(t, "phone-1", messages[0].To)
+	assert.Len(t, transport.Messages(), 1)
+	assert.Equal(t, "andi@gmail.com", transport.Messages()[0].To)
+}
+
+func TestQuietHoursEnd(t *testing.T) {
+	jakarta, err := time.LoadLocation("Asia/Jakarta")
+	assert.NoError(t, err)
+	preference := func(start, end string) *model.NotificationPreferenceBaseModel {
+		res := model.DefaultNotificationPreference("user-1")
+		res.QuietHoursStart = sql.NullString{String: start, Valid: true}
+		res.QuietHoursEnd = sql.NullString{String: end, Valid: true}
+		return res
+	}
+
+	tests := []struct {
+		name       string
+		preference *model.NotificationPreferenceBaseModel
+		now        time.Time
+		quiet      bool
+		until      time.Time
+	}{
+		{"no quiet hours", model.DefaultNotificationPreference("user-1"), time.Date(2022, 5, 1, 23, 0, 0, 0, jakarta), false, time.Time{}},
+		{"before midnight", preference("22:00", "07:00"), time.Date(2022, 5, 1, 23, 30, 0, 0, jakarta), true, time.Date(2022, 5, 2, 7, 0, 0, 0, jakarta)},
+		{"after midnight", preference("22:00", "07:00"), time.Date(2022, 5, 2, 6, 59, 0, 0, jakarta), true, time.Date(2022, 5, 2, 7, 0, 0, 0, jakarta)},
+		{"over", preference("22:00", "07:00"), time.Date(2022, 5, 2, 7, 0, 0, 0, jakarta), false, time.Time{}},
+		{"read in the timezone of the user", preference("22:00", "07:00"), time.Date(2022, 5, 1, 16, 0, 0, 0, time.UTC), true, time.Date(2022, 5, 2, 7, 0, 0, 0, jakarta)},
+		{"within the day", preference("13:00", "15:00"), time.Date(2022, 5, 1, 14, 0, 0, 0, jakarta), true, time.Date(2022, 5, 1, 15, 0, 0, 0, jakarta)},
+		{"outside the day", preference("13:00", "15:00"), time.Date(2022, 5, 1, 12, 0, 0, 0, jakarta), false, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			until, quiet := quietHoursEnd(tt.preference, tt.now)
+			assert.Equal(t, tt.quiet, quiet)
+			if tt.quiet {
+				assert.True(t, tt.until.Equal(until), "until %s", until)
+			}
+		})
+	}
+}
+
+func TestNotificationPreferences(t *testing.T) {
+	preferences := stubPreferenceModel{}
+	c := NewNotification(newStubNotificationModel(), stubDeviceFinder{}, stubRecipientFinder{}, preferences, notification.NewMemory(), mailer.NewMemory())
+
+	t.Run("defaults", func(t *testing.T) {
+		res, err := c.GetNotificationPreferences(context.Background(), "user-1")
+		assert.NoError(t, err)
+		assert.True(t, res.Channels.Push)
+		assert.False(t, res.Channels.SMS)
+		assert.True(t, res.Categories.Promotions)
+		assert.False(t, res.QuietHours.Enabled)
+		assert.Equal(t, model.DefaultNotificationTimezone, res.QuietHours.Timezone)
+	})
+
+	t.Run("invalid quiet hours", func(t *testing.T) {
+		form := &NotificationPreferences{QuietHours: QuietHours{Enabled: true, Start: "25:00", End: "7:00", Timezone: "Mars/Olympus"}}
+		fields, err := form.ValidateNotificationPreferences()
+		assert.Error(t, err)
+		assert.Len(t, fields, 3)
+
+		form = &NotificationPreferences{QuietHours: QuietHours{Enabled: true, Start: "22:00", End: "22:00"}}
+		fields, err = form.ValidateNotificationPreferences()
+		assert.Error(t, err)
+		assert.Equal(t, "quiet_hours.end", fields[0].Name)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		form := &NotificationPreferences{
+			Channels:   NotificationChannelPreferences{Push: true},
+			Categories: NotificationCategoryPreferences{OrderUpdates: true},
+			QuietHours: QuietHours{Enabled: true, Start: "22:00", End: "07:00"},
+		}
+		_, err := form.ValidateNotificationPreferences()
+		assert.NoError(t, err)
+
+		_, err = c.UpdateNotificationPreferences(context.Background(), "user-1", form)
+		assert.NoError(t, err)
+		res, err := c.GetNotificationPreferences(context.Background(), "user-1")
+		assert.NoError(t, err)
+		assert.Equal(t, form, res)
+		assert.False(t, preferences["user-1"].EmailEnabled)
+		assert.Equal(t, "Asia/Jakarta", preferences["user-1"].Timezone)
+	})
+}
+
+func TestListOfNotifications(t *testing.T) {
+	createdAt := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+	notifications := newStubNotificationModel()
+	notifications.inbox = []model.InboxNotificationBaseModel{
+		{ID: 3, UserID: "user-1", Category: model.NotificationCategoryPayment, Title: "payment success", Data: model.NotificationData{"redirect": "/orders/order-1"}, CreatedAt: createdAt},
+		{ID: 2, UserID: "user-2", Title: "service done", CreatedAt: createdAt},
+		{ID: 1, UserID: "user-1", Title: "order cancelled", ReadAt: sql.NullTime{Time: createdAt, Valid: true}, CreatedAt: createdAt},
+	}
+	c := NewNotification(notifications, stubDeviceFinder{}, stubRecipientFinder{}, stubPreferenceModel{}, notification.NewMemory(), mailer.NewMemory())
+
+	res, err := c.ListOfNotifications(context.Background(), "user-1", &NotificationListRequest{Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, res.UnreadCount)
+	assert.Equal(t, 3, res.Pagination.NextCursor)
+	assert.Len(t, res.Data, 1)
+	assert.Equal(t, "/orders/order-1", res.Data[0].Redirect)
+	assert.Equal(t, "2022-06-01T10:00:00Z", res.Data[0].CreatedAt)
+	assert.Empty(t, res.Data[0].ReadAt)
+
+	res, err = c.ListOfNotifications(context.Background(), "user-1", &NotificationListRequest{Cursor: 3, Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, res.Pagination.NextCursor)
+	assert.Len(t, res.Data, 1)
+	assert.Equal(t, 1, res.Data[0].ID)
+	assert.NotEmpty(t, res.Data[0].ReadAt)
+	assert.NotNil(t, res.Data[0].Data)
+}
+
+func TestMarkNotificationAsRead(t *testing.T) {
+	notifications := newStubNotificationModel()
+	notifications.inbox = []model.InboxNotificationBaseModel{
+		{ID: 1, UserID: "user-1", Title: "payment success"},
+	}
+	c := NewNotification(notifications, stubDeviceFinder{}, stubRecipientFinder{}, stubPreferenceModel{}, notification.NewMemory(), mailer.NewMemory())
+
+	assert.Equal(t, &handler.NotificationNotFound, c.MarkNotificationAsRead(context.Background(), "user-2", 1))
+	assert.NoError(t, c.MarkNotificationAsRead(context.Background(), "user-1", 1))
+	assert.True(t, notifications.inbox[0].ReadAt.Valid)
+}
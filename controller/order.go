@@ -5,33 +5,49 @@ import (
 	"database/sql"
 	"e-montir/api/handler"
 	"e-montir/model"
+	"e-montir/pkg/payment"
+	"e-montir/pkg/tracking"
+	"e-montir/pkg/validator"
+	"errors"
 	"fmt"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/rs/zerolog/log"
 )
 
 type orderCtx struct {
-	orderModel  model.Order
-	cartModel   model.Cart
-	userModel   model.User
-	reviewModel model.Review
+	orderModel           model.Order
+	cartModel            model.Cart
+	userModel            model.User
+	reviewModel          model.Review
+	refundModel          model.Refund
+	paymentGateway       payment.PaymentGateway
+	assignmentController Assignment
+	broker               *tracking.Broker
 }
 
 type Order interface {
 	PlaceOrder(ctx context.Context, userID, orderID, invoiceID string) (*PlcaeOrderResponse, error)
 	PaymentReceived(ctx context.Context, orderID, transactionStatus string) error
 	ListOfOrders(ctx context.Context, userID string) (*OrderListResponse, error)
-	UpdateOrderStatus(ctx context.Context, form *UpdateOrderRequest) error
-	OrderDetail(ctx context.Context, orderID string) (*OrderDetailResponse, error)
+	ExpireUnpaidOrders(ctx context.Context) error
+	UpdateOrderStatus(ctx context.Context, actorID string, form *UpdateOrderRequest) error
+	OrderDetail(ctx context.Context, userID, orderID string) (*OrderDetailResponse, error)
+	CancelOrder(ctx context.Context, userID, orderID string) (*CancelOrderResponse, error)
 }
 
-func NewOrder(orderModel model.Order, cartModel model.Cart, userModel model.User, reviewModel model.Review) Order {
+func NewOrder(orderModel model.Order, cartModel model.Cart, userModel model.User, reviewModel model.Review, refundModel model.Refund, paymentGateway payment.PaymentGateway, assignmentController Assignment, broker *tracking.Broker) Order {
 	return &orderCtx{
-		orderModel:  orderModel,
-		cartModel:   cartModel,
-		userModel:   userModel,
-		reviewModel: reviewModel,
+		orderModel:           orderModel,
+		cartModel:            cartModel,
+		userModel:            userModel,
+		reviewModel:          reviewModel,
+		refundModel:          refundModel,
+		paymentGateway:       paymentGateway,
+		assignmentController: assignmentController,
+		broker:               broker,
 	}
 }
 
@@ -57,27 +73,42 @@ type (
 	}
 
 	Mechanic struct {
-		Name             string `json:"name"`
-		PhoneNumber      string `json:"phone_number"`
-		CompletedService int    `json:"completed_service"`
-		Picture          string `json:"picture"`
+		ID               int     `json:"id,omitempty"`
+		Name             string  `json:"name"`
+		PhoneNumber      string  `json:"phone_number"`
+		CompletedService int     `json:"completed_service"`
+		Picture          string  `json:"picture"`
+		Rating           float64 `json:"rating"`
+		ReviewCount      int     `json:"review_count"`
 	}
 
 	OrderListData struct {
-		ID              string           `json:"id"`
-		UserID          string           `json:"user_id"`
-		Description     string           `json:"description"`
-		MotorCycleBrand string           `json:"motor_cycle_brand"`
-		CreatedAt       string           `json:"created_at"`
-		Appointment     OrderAppointment `json:"appointment"`
-		Location        OrderLocation    `json:"location"`
-		Items           []OrderItem      `json:"items"`
-		Mechanic        Mechanic         `json:"mechanic"`
-		TotalPrice      float64          `json:"total_price"`
-		StatusOrder     string           `json:"status_order"`
-		StatusDetail    string           `json:"status_detail"`
-		InvoiceID       string           `json:"invoice_id"`
-		IsReviewed      bool             `json:"is_reviewed"`
+		ID                 string           `json:"id"`
+		UserID             string           `json:"user_id"`
+		Description        string           `json:"description"`
+		MotorCycleBrand    string           `json:"motor_cycle_brand"`
+		CreatedAt          string           `json:"created_at"`
+		Appointment        OrderAppointment `json:"appointment"`
+		Location           OrderLocation    `json:"location"`
+		Items              []OrderItem      `json:"items"`
+		Mechanic           Mechanic         `json:"mechanic"`
+		TotalPrice         float64          `json:"total_price"`
+		StatusOrder        string           `json:"status_order"`
+		StatusDetail       string           `json:"status_detail"`
+		InvoiceID          string           `json:"invoice_id"`
+		IsReviewed         bool             `json:"is_reviewed"`
+		IsMechanicReviewed bool             `json:"is_mechanic_reviewed"`
+		ExpiresAt          string           `json:"expires_at,omitempty"`
+		Timeline           []OrderTimeline  `json:"timeline,omitempty"`
+		Refund             *OrderRefund     `json:"refund,omitempty"`
+	}
+
+	OrderTimeline struct {
+		Status         string `json:"status"`
+		PreviousStatus string `json:"previous_status"`
+		Actor          string `json:"actor"`
+		Reason         string `json:"reason,omitempty"`
+		CreatedAt      string `json:"created_at"`
 	}
 
 	PlcaeOrderResponse struct {
@@ -92,12 +123,45 @@ type (
 		Data OrderListData `json:"data"`
 	}
 
+	CancelOrderResponse struct {
+		OrderID         string `json:"order_id"`
+		StatusOrder     string `json:"status_order"`
+		RefundRequested bool   `json:"refund_requested"`
+	}
+
 	UpdateOrderRequest struct {
 		ID     string `json:"invoice_id"`
 		Status string `json:"status"`
 	}
 )
 
+func (req *UpdateOrderRequest) ValidateUpdateOrderRequest() ([]handler.Fields, error) {
+	var count int
+	var fields []handler.Fields
+	err := validator.ValidateInvoiceID(req.ID)
+	if err != nil {
+		count++
+		fields = append(fields, handler.Fields{
+			Name:    "invoice_id",
+			Message: err.Error(),
+		})
+	}
+
+	err = validator.ValidateOrderStatus(req.Status)
+	if err != nil {
+		count++
+		fields = append(fields, handler.Fields{
+			Name:    "status",
+			Message: err.Error(),
+		})
+	}
+
+	if count == 0 {
+		return nil, nil
+	}
+	return fields, errors.New(handler.ValidationFailed)
+}
+
 func (c *orderCtx) PlaceOrder(ctx context.Context, userID, orderID, invoiceID string) (*PlcaeOrderResponse, error) {
 	isCartAvailable, _, err := c.cartModel.IsCartAvailable(ctx, userID)
 	if err != nil {
@@ -130,6 +194,7 @@ func (c *orderCtx) PlaceOrder(ctx context.Context, userID, orderID, invoiceID st
 		totalPrice = totalPrice + 15000
 	}
 
+	createdAt := time.Now()
 	err = c.orderModel.SetOrder(ctx, userID, &model.OrderBaseModel{
 		ID:              orderID,
 		UserID:          userID,
@@ -138,8 +203,9 @@ func (c *orderCtx) PlaceOrder(ctx context.Context, userID, orderID, invoiceID st
 		Date:            res.Appointment.Date,
 		MotorCycleBrand: res.Appointment.BrandName,
 		TotalPrice:      float64(totalPrice),
-		CreatedAt:       time.Now(),
+		CreatedAt:       createdAt,
 		InvoiceID:       invoiceID,
+		ExpiresAt:       sql.NullTime{Time: createdAt.Add(paymentDeadline()), Valid: true},
 	})
 
 	if err != nil {
@@ -152,48 +218,97 @@ func (c *orderCtx) PlaceOrder(ctx context.Context, userID, orderID, invoiceID st
 	}, nil
 }
 
+// PaymentReceived moves the order on with the outcome of its payment, the customer is notified along with the
+// status change. A pending or failed payment leaves the order waiting, the customer may pay again until the
+// payment deadline. An expired payment cancels the order so its slot becomes available again.
 func (c *orderCtx) PaymentReceived(ctx context.Context, orderID, transactionStatus string) error {
-	// var notif fcm.NotifFcm
-	// userID, err := c.userModel.GetUserIDByOrderID(ctx, orderID)
-	// if err != nil {
-	// 	log.Error().Err(fmt.Errorf("error when GetUserIDByOrderID : %w", err)).Send()
-	// 	return err
-	// }
-
-	// fcmKey, err := c.userModel.GetFCMKey(ctx, userID)
-	// if err != nil {
-	// 	log.Error().Err(fmt.Errorf("error when GetFCMKey : %w", err)).Send()
-	// 	return err
-	// }
-
-	if transactionStatus != "PAID" {
-		// notif.To = fcmKey
-		// notif.Title = "payment failed"
-		// notif.Body = "payment failed, please try again"
-		// notif.Redirect = fmt.Sprintf("%s/orders/{%s}", os.Getenv("BASE_URL"), orderID)
-		// fcm.SendNotification(ctx, notif)
-		// return fmt.Errorf("payment failed")
-		return fmt.Errorf("payment not received")
-	}
-
-	err := c.orderModel.UpdateOrderStatus(ctx, orderID, "On process", "")
+	switch transactionStatus {
+	case payment.StatusPaid:
+		return c.paymentPaid(ctx, orderID)
+	case payment.StatusExpired:
+		return c.paymentExpired(ctx, orderID)
+	}
+
+	log.Info().Msg(fmt.Sprintf("payment of order %s is %s", orderID, strings.ToLower(transactionStatus)))
+	return nil
+}
+
+func (c *orderCtx) paymentPaid(ctx context.Context, orderID string) error {
+	err := c.orderModel.UpdateOrderStatus(ctx, orderID, model.OrderStatus[2], "", model.OrderActorPayment)
 	if err != nil {
+		if errors.Is(err, &handler.InvalidOrderStatusTransition) {
+			return c.latePayment(ctx, orderID)
+		}
 		log.Error().Err(fmt.Errorf("error when UpdateOrderStatus : %w", err)).Send()
 		return err
 	}
 
-	// notif.To = fcmKey
-	// notif.Title = "payment success"
-	// notif.Body = "preparing your order"
-	// notif.Redirect = fmt.Sprintf("%s/orders/{%s}", os.Getenv("BASE_URL"), orderID)
-	// fcm.SendNotification(ctx, notif)
-
-	err = c.orderModel.AssignMechanic(ctx, orderID)
+	// the order is paid by now, a notification sent again would only find it on process,
+	// so an order which cannot be assigned right away waits in the queue instead
+	err = c.assignmentController.AssignMechanic(ctx, orderID)
 	if err != nil {
 		log.Error().Err(fmt.Errorf("error when assignMechanic : %w", err)).Send()
+		return c.assignmentController.QueueAssignment(ctx, orderID, "assignment failed after payment")
+	}
+
+	return nil
+}
+
+// latePayment handles a payment for an order which is not waiting for it anymore. An order paid before is
+// left as it is, the money of an order cancelled or expired in the meantime goes back to the customer.
+func (c *orderCtx) latePayment(ctx context.Context, orderID string) error {
+	order, err := c.orderModel.GetOrderByOrderID(ctx, orderID)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when getOrderByOrderID : %w", err)).Send()
 		return err
 	}
+	if order.OrderStatus.String != model.OrderStatus[6] {
+		return nil
+	}
 
+	latest, err := c.refundModel.GetLatestRefund(ctx, orderID)
+	if err != nil && err != sql.ErrNoRows {
+		log.Error().Err(fmt.Errorf("error when GetLatestRefund : %w", err)).Send()
+		return err
+	}
+	if latest != nil && latest.Status != model.RefundStatusFailed {
+		return nil
+	}
+
+	log.Error().Msg(fmt.Sprintf("payment received for cancelled order %s, refunding it", orderID))
+	refund, err := requestRefund(ctx, c.refundModel, c.paymentGateway, orderID, model.OrderActorSystem, "payment received after the order was cancelled", order.TotalPrice)
+	if err != nil {
+		return err
+	}
+	if refund.Status == model.RefundStatusFailed {
+		// the failed refund is kept on the order, it has to be requested again once the gateway accepts it
+		log.Error().Msg(fmt.Sprintf("refund of the late payment of order %s failed, it needs to be reconciled", orderID))
+	}
+	return nil
+}
+
+func (c *orderCtx) paymentExpired(ctx context.Context, orderID string) error {
+	order, err := c.orderModel.GetOrderByOrderID(ctx, orderID)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when getOrderByOrderID : %w", err)).Send()
+		return err
+	}
+	// a paid order may still be cancelled, but not by an earlier transaction of it running out. A payment
+	// landing right after this check finds the order cancelled and is refunded.
+	if order.OrderStatus.String != model.OrderStatus[1] {
+		return nil
+	}
+
+	_, err = c.orderModel.CancelOrder(ctx, orderID, model.OrderActorPayment, "payment expired")
+	if err != nil {
+		// the order might have been paid or cancelled in the meantime
+		if errors.Is(err, &handler.InvalidOrderStatusTransition) {
+			return nil
+		}
+		log.Error().Err(fmt.Errorf("error when CancelOrder : %w", err)).Send()
+		return err
+	}
+	log.Info().Msg(fmt.Sprintf("order %s expired", orderID))
 	return nil
 }
 
@@ -281,6 +396,7 @@ func (c *orderCtx) ListOfOrders(ctx context.Context, userID string) (*OrderListR
 				StatusDetail: orderlist.OrderDetail.String,
 				InvoiceID:    orderlist.InvoiceID,
 				IsReviewed:   isReviewed,
+				ExpiresAt:    formatExpiresAt(orderlist.ExpiresAt),
 			})
 		} else {
 			orderListData = append(orderListData, OrderListData{
@@ -297,10 +413,13 @@ func (c *orderCtx) ListOfOrders(ctx context.Context, userID string) (*OrderListR
 					PhoneNum:  userLoc.PhoneNumber,
 				},
 				Mechanic: Mechanic{
+					ID:               mechanic.ID,
 					Name:             mechanic.Name,
 					PhoneNumber:      mechanic.PhoneNumber,
 					CompletedService: mechanic.CompletedService,
 					Picture:          mechanic.Picture.String,
+					Rating:           mechanic.Rating,
+					ReviewCount:      mechanic.ReviewCount,
 				},
 				CreatedAt:    orderlist.CreatedAt.Format(time.RFC3339),
 				Items:        orderItems,
@@ -309,6 +428,7 @@ func (c *orderCtx) ListOfOrders(ctx context.Context, userID string) (*OrderListR
 				StatusDetail: orderlist.OrderDetail.String,
 				InvoiceID:    orderlist.InvoiceID,
 				IsReviewed:   isReviewed,
+				ExpiresAt:    formatExpiresAt(orderlist.ExpiresAt),
 			})
 		}
 	}
@@ -318,60 +438,54 @@ func (c *orderCtx) ListOfOrders(ctx context.Context, userID string) (*OrderListR
 	}, nil
 }
 
-func (c *orderCtx) UpdateOrderStatus(ctx context.Context, form *UpdateOrderRequest) error {
-	// userID, orderID, err := c.userModel.GetUserIDNOrderIDByInvoiceID(ctx, form.ID)
-	// if err != nil {
-	// 	log.Error().Err(fmt.Errorf("error when GetUserIDByOrderID : %w", err)).Send()
-	// 	return err
-	// }
-
-	// fcmKey, err := c.userModel.GetFCMKey(ctx, userID)
-	// if err != nil {
-	// 	log.Error().Err(fmt.Errorf("error when GetFCMKey : %w", err)).Send()
-	// 	return err
-	// }
-
-	err := c.orderModel.UpdateOrderStatus(ctx, "", form.Status, form.ID)
+// UpdateOrderStatus lets an admin move the order of the invoice on, the customer is notified along with the status change.
+// Mechanics move only the orders assigned to them, through their job endpoints.
+func (c *orderCtx) UpdateOrderStatus(ctx context.Context, actorID string, form *UpdateOrderRequest) error {
+	err := progressOrder(ctx, c.orderModel, form.ID, form.Status, actorID)
 	if err != nil {
-		log.Error().Err(fmt.Errorf("error when UpdateOrderStatus : %w", err)).Send()
 		return err
 	}
 
-	if form.Status == "on the way" {
-		// fcm.SendNotification(ctx, fcm.NotifFcm{
-		// 	To:       fcmKey,
-		// 	Redirect: fmt.Sprintf("%s/orders/{%s}", os.Getenv("BASE_URL"), orderID),
-		// 	Title:    "mechanic is on the way",
-		// 	Body:     "mechanic is on the way to your place. please wait",
-		// })
+	// the customers tracking the order see the change as they do when the mechanic makes it,
+	// the status has changed already when the order cannot be loaded, only they miss it then
+	order, err := c.orderModel.GetOrderByInvoiceID(ctx, form.ID)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when GetOrderByInvoiceID : %w", err)).Send()
 		return nil
 	}
+	c.broker.Publish(order.ID, tracking.Update{
+		Status:     order.OrderStatus.String,
+		RecordedAt: time.Now(),
+	})
+	return nil
+}
 
-	if form.Status == "done" {
-		err = c.orderModel.OrderCompleted(ctx, form.ID)
+// progressOrder moves the order of the invoice to status, a done order
+// gives its mechanic and time slot back.
+func progressOrder(ctx context.Context, orderModel model.Order, invoiceID, status, actor string) error {
+	err := orderModel.UpdateOrderStatus(ctx, "", status, invoiceID, actor)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when UpdateOrderStatus : %w", err)).Send()
+		return err
+	}
+
+	if strings.EqualFold(status, model.OrderStatus[5]) {
+		err = orderModel.OrderCompleted(ctx, invoiceID)
 		if err != nil {
 			log.Error().Err(fmt.Errorf("error when execute OrderCompleted : %w", err)).Send()
 			return err
 		}
-		// fcm.SendNotification(ctx, fcm.NotifFcm{
-		// 	To:       fcmKey,
-		// 	Redirect: fmt.Sprintf("%s/orders/{%s}", os.Getenv("BASE_URL"), orderID),
-		// 	Title:    "service done",
-		// 	Body:     "service done, looking forward to your next order",
-		// })
-		return nil
 	}
 	return nil
 }
 
-func (c *orderCtx) OrderDetail(ctx context.Context, orderID string) (*OrderDetailResponse, error) {
+func (c *orderCtx) OrderDetail(ctx context.Context, userID, orderID string) (*OrderDetailResponse, error) {
 	var orderDetailResponse OrderDetailResponse
 	var orderItems []OrderItem
 	isReviewed := false
 
-	orderDetail, err := c.orderModel.GetOrderByOrderID(ctx, orderID)
+	orderDetail, err := authorizeOrder(ctx, c.orderModel, userID, orderID)
 	if err != nil {
-		log.Error().Err(fmt.Errorf("error when getOrderByOrderID : %w", err)).Send()
 		return nil, err
 	}
 
@@ -420,6 +534,23 @@ func (c *orderCtx) OrderDetail(ctx context.Context, orderID string) (*OrderDetai
 		isReviewed = true
 	}
 
+	isMechanicReviewed, err := c.reviewModel.IsMechanicReviewed(ctx, orderID)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when IsMechanicReviewed: %w", err)).Send()
+		return nil, &handler.InternalServerError
+	}
+
+	timeline, err := c.orderTimeline(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	refund, err := c.refundModel.GetLatestRefund(ctx, orderID)
+	if err != nil && err != sql.ErrNoRows {
+		log.Error().Err(fmt.Errorf("error when GetLatestRefund : %w", err)).Send()
+		return nil, err
+	}
+
 	mechanic, err := c.orderModel.GetOrderMechanic(ctx, int(orderDetail.MechanicID.Int64))
 	if err != nil {
 		if err != sql.ErrNoRows {
@@ -447,6 +578,8 @@ func (c *orderCtx) OrderDetail(ctx context.Context, orderID string) (*OrderDetai
 		orderDetailResponse.Data.StatusDetail = orderDetail.OrderDetail.String
 		orderDetailResponse.Data.InvoiceID = orderDetail.InvoiceID
 		orderDetailResponse.Data.IsReviewed = isReviewed
+		orderDetailResponse.Data.IsMechanicReviewed = isMechanicReviewed
+		orderDetailResponse.Data.ExpiresAt = formatExpiresAt(orderDetail.ExpiresAt)
 	} else {
 
 		orderDetailResponse.Data.ID = orderID
@@ -463,10 +596,13 @@ func (c *orderCtx) OrderDetail(ctx context.Context, orderID string) (*OrderDetai
 		}
 		orderDetailResponse.Data.CreatedAt = orderDetail.CreatedAt.Format(time.RFC3339)
 		orderDetailResponse.Data.Mechanic = Mechanic{
+			ID:               mechanic.ID,
 			Name:             mechanic.Name,
 			PhoneNumber:      mechanic.PhoneNumber,
 			CompletedService: mechanic.CompletedService,
 			Picture:          mechanic.Picture.String,
+			Rating:           mechanic.Rating,
+			ReviewCount:      mechanic.ReviewCount,
 		}
 		orderDetailResponse.Data.Items = orderItems
 		orderDetailResponse.Data.TotalPrice = orderDetail.TotalPrice
@@ -474,7 +610,118 @@ func (c *orderCtx) OrderDetail(ctx context.Context, orderID string) (*OrderDetai
 		orderDetailResponse.Data.StatusDetail = orderDetail.OrderDetail.String
 		orderDetailResponse.Data.InvoiceID = orderDetail.InvoiceID
 		orderDetailResponse.Data.IsReviewed = isReviewed
+		orderDetailResponse.Data.IsMechanicReviewed = isMechanicReviewed
+		orderDetailResponse.Data.ExpiresAt = formatExpiresAt(orderDetail.ExpiresAt)
+	}
+
+	orderDetailResponse.Data.Timeline = timeline
+	if refund != nil {
+		refundData := orderRefund(refund)
+		orderDetailResponse.Data.Refund = &refundData
 	}
 
 	return &orderDetailResponse, nil
 }
+
+func (c *orderCtx) orderTimeline(ctx context.Context, orderID string) ([]OrderTimeline, error) {
+	timeline := make([]OrderTimeline, 0)
+	history, err := c.orderModel.ListOfOrderStatusHistory(ctx, orderID)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when ListOfOrderStatusHistory: %w", err)).Send()
+		return nil, &handler.InternalServerError
+	}
+
+	for _, v := range history {
+		timeline = append(timeline, OrderTimeline{
+			Status:         v.ToStatus,
+			PreviousStatus: v.FromStatus.String,
+			Actor:          v.Actor,
+			Reason:         v.Reason.String,
+			CreatedAt:      v.CreatedAt.Format(time.RFC3339),
+		})
+	}
+	return timeline, nil
+}
+
+func (c *orderCtx) CancelOrder(ctx context.Context, userID, orderID string) (*CancelOrderResponse, error) {
+	_, err := authorizeOrder(ctx, c.orderModel, userID, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	cancelled, err := c.orderModel.CancelOrder(ctx, orderID, userID, "cancelled by customer")
+	if err != nil {
+		if errors.Is(err, &handler.InvalidOrderStatusTransition) {
+			return nil, &handler.OrderCannotBeCancelled
+		}
+		log.Error().Err(fmt.Errorf("error when CancelOrder : %w", err)).Send()
+		return nil, err
+	}
+
+	res := &CancelOrderResponse{
+		OrderID:     orderID,
+		StatusOrder: model.OrderStatus[6],
+	}
+
+	// an order which left "Waiting for payment" has been paid, so the money goes back to the customer
+	if cancelled.PreviousStatus != model.OrderStatus[1] {
+		refund, err := requestRefund(ctx, c.refundModel, c.paymentGateway, orderID, model.OrderActorSystem, "order cancelled by customer", cancelled.TotalPrice)
+		if err != nil {
+			return res, nil
+		}
+		res.RefundRequested = refund.Status == model.RefundStatusProcessing
+	}
+
+	return res, nil
+}
+
+// ExpireUnpaidOrders cancels every order which is still waiting for payment after its
+// payment deadline so the reserved time slot becomes available for other customers.
+func (c *orderCtx) ExpireUnpaidOrders(ctx context.Context) error {
+	orderIDs, err := c.orderModel.ListOfExpiredOrders(ctx, time.Now())
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when ListOfExpiredOrders : %w", err)).Send()
+		return err
+	}
+
+	for _, orderID := range orderIDs {
+		// the customer might have paid while the notification has not arrived yet, the order
+		// is taken as paid then or it would be listed as expired again on every run
+		status, statusErr := c.paymentGateway.GetStatus(ctx, orderID)
+		if statusErr == nil && status.TransactionStatus == payment.StatusPaid {
+			log.Warn().Msg(fmt.Sprintf("order %s is paid but not yet notified, taking the payment", orderID))
+			err = c.paymentPaid(ctx, orderID)
+			if err != nil {
+				log.Error().Err(fmt.Errorf("error when taking the payment of order %s : %w", orderID, err)).Send()
+			}
+			continue
+		}
+
+		_, err = c.orderModel.CancelOrder(ctx, orderID, model.OrderActorSystem, "payment deadline passed")
+		if err != nil {
+			// the order might have been paid or cancelled in the meantime
+			if errors.Is(err, &handler.InvalidOrderStatusTransition) {
+				continue
+			}
+			log.Error().Err(fmt.Errorf("error when expiring order %s : %w", orderID, err)).Send()
+			continue
+		}
+		log.Info().Msg(fmt.Sprintf("order %s expired", orderID))
+	}
+	return nil
+}
+
+func paymentDeadline() time.Duration {
+	deadline, err := time.ParseDuration(os.Getenv("PAYMENT_DEADLINE"))
+	if err != nil || deadline <= 0 {
+		return 60 * time.Minute
+	}
+	return deadline
+}
+
+func formatExpiresAt(expiresAt sql.NullTime) string {
+	if !expiresAt.Valid {
+		return ""
+	}
+	return expiresAt.Time.Format(time.RFC3339)
+}
@@ -0,0 +1,50 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockOrderController struct {
+	mock.Mock
+}
+
+func (m *MockOrderController) PlaceOrder(ctx context.Context, userID, orderID, invoiceID string) (*PlcaeOrderResponse, error) {
+	args := m.Called(ctx, userID, orderID, invoiceID)
+	res, _ := args.Get(0).(*PlcaeOrderResponse)
+	return res, args.Error(1)
+}
+
+func (m *MockOrderController) PaymentReceived(ctx context.Context, orderID, transactionStatus string) error {
+	args := m.Called(ctx, orderID, transactionStatus)
+	return args.Error(0)
+}
+
+func (m *MockOrderController) ListOfOrders(ctx context.Context, userID string) (*OrderListResponse, error) {
+	args := m.Called(ctx, userID)
+	res, _ := args.Get(0).(*OrderListResponse)
+	return res, args.Error(1)
+}
+
+func (m *MockOrderController) ExpireUnpaidOrders(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func (m *MockOrderController) UpdateOrderStatus(ctx context.Context, actorID string, form *UpdateOrderRequest) error {
+	args := m.Called(ctx, actorID, form)
+	return args.Error(0)
+}
+
+func (m *MockOrderController) OrderDetail(ctx context.Context, userID, orderID string) (*OrderDetailResponse, error) {
+	args := m.Called(ctx, userID, orderID)
+	res, _ := args.Get(0).(*OrderDetailResponse)
+	return res, args.Error(1)
+}
+
+func (m *MockOrderController) CancelOrder(ctx context.Context, userID, orderID string) (*CancelOrderResponse, error) {
+	args := m.Called(ctx, userID, orderID)
+	res, _ := args.Get(0).(*CancelOrderResponse)
+	return res, args.Error(1)
+}
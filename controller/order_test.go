@@ -0,0 +1,318 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"e-montir/api/handler"
+	"e-montir/model"
+	"e-montir/pkg/payment"
+	"e-montir/pkg/tracking"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// stubOrderModel keeps the orders in memory and moves them through the same state machine as model.Order,
+// the methods the tests do not need are left to the embedded nil interface.
+type stubOrderModel struct {
+	model.Order
+	orders  map[string]*model.OrderBaseModel
+	history map[string][]model.OrderStatusHistory
+	expired []string
+}
+
+func newStubOrderModel(orders ...model.OrderBaseModel) *stubOrderModel {
+	s := &stubOrderModel{
+		orders:  map[string]*model.OrderBaseModel{},
+		history: map[string][]model.OrderStatusHistory{},
+	}
+	for i := range orders {
+		s.orders[orders[i].ID] = &orders[i]
+	}
+	return s
+}
+
+func (s *stubOrderModel) status(orderID string) string {
+	return s.orders[orderID].OrderStatus.String
+}
+
+func (s *stubOrderModel) transit(orderID, status, actor string) (string, error) {
+	order, ok := s.orders[orderID]
+	if !ok {
+		return "", &handler.OrderNotFound
+	}
+	previous := order.OrderStatus.String
+	if !model.CanTransitOrderStatus(previous, status) {
+		return previous, &handler.InvalidOrderStatusTransition
+	}
+	order.OrderStatus = sql.NullString{String: status, Valid: true}
+	s.history[orderID] = append(s.history[orderID], model.OrderStatusHistory{
+		OrderID:    orderID,
+		FromStatus: sql.NullString{String: previous, Valid: true},
+		ToStatus:   status,
+		Actor:      actor,
+		CreatedAt:  time.Now(),
+	})
+	return previous, nil
+}
+
+func (s *stubOrderModel) GetOrderByOrderID(ctx context.Context, orderID string) (*model.OrderBaseModel, error) {
+	order, ok := s.orders[orderID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	res := *order
+	return &res, nil
+}
+
+func (s *stubOrderModel) GetOrderByInvoiceID(ctx context.Context, invoiceID string) (*model.OrderBaseModel, error) {
+	for _, v := range s.orders {
+		if v.InvoiceID == invoiceID {
+			res := *v
+			return &res, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (s *stubOrderModel) CheckOrder(ctx context.Context, orderID string) (*model.OrderBaseModel, error) {
+	order, ok := s.orders[orderID]
+	if !ok {
+		return nil, &handler.OrderNotExists
+	}
+	if order.OrderStatus.String != model.OrderStatus[1] {
+		return nil, &handler.OrderHasBeenPaid
+	}
+	res := *order
+	return &res, nil
+}
+
+func (s *stubOrderModel) UpdateOrderStatus(ctx context.Context, orderID, status, invoiceID, actor string) error {
+	if orderID == "" {
+		for _, v := range s.orders {
+			if v.InvoiceID == invoiceID {
+				orderID = v.ID
+			}
+		}
+	}
+	_, err := s.transit(orderID, strings.ToUpper(status[:1])+status[1:], actor)
+	return err
+}
+
+func (s *stubOrderModel) CancelOrder(ctx context.Context, orderID, actor, reason string) (*model.CancelledOrder, error) {
+	previous, err := s.transit(orderID, model.OrderStatus[6], actor)
+	if err != nil {
+		return nil, err
+	}
+	return &model.CancelledOrder{ID: orderID, PreviousStatus: previous, TotalPrice: s.orders[orderID].TotalPrice}, nil
+}
+
+func (s *stubOrderModel) ListOfOrderStatusHistory(ctx context.Context, orderID string) ([]model.OrderStatusHistory, error) {
+	return s.history[orderID], nil
+}
+
+func (s *stubOrderModel) ListOfExpiredOrders(ctx context.Context, now time.Time) ([]string, error) {
+	return s.expired, nil
+}
+
+func newOrderBaseModel(orderID, status string, totalPrice float64) model.OrderBaseModel {
+	return model.OrderBaseModel{
+		ID:          orderID,
+		UserID:      "user-1",
+		InvoiceID:   "INV-" + orderID,
+		TotalPrice:  totalPrice,
+		OrderStatus: sql.NullString{String: status, Valid: true},
+	}
+}
+
+// newFakeGateway gives a fake gateway whose notifications, sent to PAYMENT_NOTIFICATION_URL, are accepted and dropped
+func newFakeGateway(t *testing.T) *payment.FakeGateway {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("PAYMENT_NOTIFICATION_URL", server.URL)
+	return payment.NewFakeGateway(&payment.FakeConfig{ServerKey: "secret"})
+}
+
+// payThroughGateway opens a transaction for the order at the gateway and moves it to status
+func payThroughGateway(t *testing.T, gateway *payment.FakeGateway, orderID string, amount int, status string) {
+	_, err := gateway.CreateTransaction(context.Background(), &payment.TransactionRequest{
+		OrderID:         orderID,
+		GrossAmount:     amount,
+		NotificationURL: os.Getenv("PAYMENT_NOTIFICATION_URL"),
+	})
+	assert.NoError(t, err)
+	if status != payment.StatusPending {
+		assert.NoError(t, gateway.Simulate(context.Background(), orderID, status))
+	}
+}
+
+func TestPaymentDeadline(t *testing.T) {
+	tt := []struct {
+		Name     string
+		Env      string
+		Deadline time.Duration
+	}{
+		{Name: "Unset", Env: "", Deadline: 60 * time.Minute},
+		{Name: "Invalid", Env: "an hour", Deadline: 60 * time.Minute},
+		{Name: "Not positive", Env: "-15m", Deadline: 60 * time.Minute},
+		{Name: "Valid", Env: "15m", Deadline: 15 * time.Minute},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Setenv("PAYMENT_DEADLINE", tc.Env)
+			assert.Equal(t, tc.Deadline, paymentDeadline())
+		})
+	}
+}
+
+func TestExpireUnpaidOrders(t *testing.T) {
+	gateway := newFakeGateway(t)
+	orders := newStubOrderModel(
+		newOrderBaseModel("order-unpaid", model.OrderStatus[1], 265000),
+		newOrderBaseModel("order-paid", model.OrderStatus[1], 265000),
+		newOrderBaseModel("order-pending", model.OrderStatus[1], 265000),
+		newOrderBaseModel("order-cancelled", model.OrderStatus[6], 265000),
+	)
+	orders.expired = []string{"order-unpaid", "order-paid", "order-pending", "order-cancelled"}
+	payThroughGateway(t, gateway, "order-paid", 265000, payment.StatusPaid)
+	payThroughGateway(t, gateway, "order-pending", 265000, payment.StatusPending)
+
+	assignments := &stubAssignment{}
+	c := NewOrder(orders, nil, nil, nil, nil, gateway, assignments, nil)
+	assert.NoError(t, c.ExpireUnpaidOrders(context.Background()))
+
+	// the notification of the paid order is on its way, the order is taken as paid meanwhile
+	assert.Equal(t, model.OrderStatus[2], orders.status("order-paid"))
+	assert.Equal(t, []string{"order-paid"}, assignments.assigned)
+	assert.Equal(t, model.OrderStatus[6], orders.status("order-unpaid"))
+	assert.Equal(t, model.OrderStatus[6], orders.status("order-pending"))
+	assert.Equal(t, model.OrderStatus[6], orders.status("order-cancelled"))
+	assert.Len(t, orders.history["order-cancelled"], 0)
+}
+
+// a status set by an admin reaches the customers tracking the order like the ones set by the mechanic
+func TestUpdateOrderStatus(t *testing.T) {
+	broker := tracking.NewBroker()
+	updates, unsubscribe := broker.Subscribe("order-1")
+	defer unsubscribe()
+
+	orders := newStubOrderModel(newOrderBaseModel("order-1", model.OrderStatus[2], 265000))
+	c := NewOrder(orders, nil, nil, nil, nil, nil, nil, broker)
+
+	err := c.UpdateOrderStatus(context.Background(), "admin-1", &UpdateOrderRequest{ID: "INV-order-1", Status: "on the way"})
+	assert.NoError(t, err)
+	assert.Equal(t, model.OrderStatus[3], orders.status("order-1"))
+
+	select {
+	case update := <-updates:
+		assert.Equal(t, model.OrderStatus[3], update.Status)
+		assert.False(t, update.HasPosition)
+	default:
+		t.Fatal("status change is not published")
+	}
+}
+
+type stubRefundModel struct {
+	refunds []model.RefundBaseModel
+}
+
+func (s *stubRefundModel) SetRefund(ctx context.Context, param *model.RefundBaseModel) (int, error) {
+	param.ID = len(s.refunds) + 1
+	s.refunds = append(s.refunds, *param)
+	return param.ID, nil
+}
+
+func (s *stubRefundModel) UpdateRefundStatus(ctx context.Context, refundID int, status, failureReason string) error {
+	s.refunds[refundID-1].Status = status
+	s.refunds[refundID-1].FailureReason = sql.NullString{String: failureReason, Valid: failureReason != ""}
+	return nil
+}
+
+func (s *stubRefundModel) GetLatestRefund(ctx context.Context, orderID string) (*model.RefundBaseModel, error) {
+	for i := len(s.refunds) - 1; i >= 0; i-- {
+		if s.refunds[i].OrderID == orderID {
+			res := s.refunds[i]
+			return &res, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+type stubAssignment struct {
+	assigned []string
+	queued   []string
+	err      error
+}
+
+func (s *stubAssignment) AssignMechanic(ctx context.Context, orderID string) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.assigned = append(s.assigned, orderID)
+	return nil
+}
+
+func (s *stubAssignment) QueueAssignment(ctx context.Context, orderID, reason string) error {
+	s.queued = append(s.queued, orderID)
+	return nil
+}
+
+func (s *stubAssignment) AssignQueuedOrders(ctx context.Context) error {
+	return nil
+}
+
+func TestPaymentReceived(t *testing.T) {
+	tt := []struct {
+		Name      string
+		Status    string
+		Order     string
+		Payment   string
+		Expected  string
+		Assigned  bool
+		AssignErr error
+		Refund    string
+	}{
+		{Name: "Paid", Status: model.OrderStatus[1], Payment: payment.StatusPaid, Expected: model.OrderStatus[2], Assigned: true},
+		{Name: "Paid but assignment failed", Status: model.OrderStatus[1], Payment: payment.StatusPaid, Expected: model.OrderStatus[2], AssignErr: errors.New("connection refused")},
+		{Name: "Pending", Status: model.OrderStatus[1], Payment: payment.StatusPending, Expected: model.OrderStatus[1]},
+		{Name: "Failed payment can be paid again", Status: model.OrderStatus[1], Payment: payment.StatusFailed, Expected: model.OrderStatus[1]},
+		{Name: "Expired", Status: model.OrderStatus[1], Payment: payment.StatusExpired, Expected: model.OrderStatus[6]},
+		{Name: "Expired after it was paid", Status: model.OrderStatus[2], Payment: payment.StatusExpired, Expected: model.OrderStatus[2]},
+		{Name: "Paid twice", Status: model.OrderStatus[2], Payment: payment.StatusPaid, Expected: model.OrderStatus[2]},
+		{Name: "Paid after it was cancelled", Status: model.OrderStatus[6], Payment: payment.StatusPaid, Expected: model.OrderStatus[6], Refund: model.RefundStatusProcessing},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			gateway := newFakeGateway(t)
+			orders := newStubOrderModel(newOrderBaseModel("order-1", tc.Status, 265000))
+			refunds := &stubRefundModel{}
+			assignments := &stubAssignment{err: tc.AssignErr}
+			payThroughGateway(t, gateway, "order-1", 265000, tc.Payment)
+
+			c := NewOrder(orders, nil, nil, nil, refunds, gateway, assignments, nil)
+			assert.NoError(t, c.PaymentReceived(context.Background(), "order-1", tc.Payment))
+			assert.Equal(t, tc.Expected, orders.status("order-1"))
+			assert.Equal(t, tc.Assigned, len(assignments.assigned) == 1)
+			assert.Equal(t, tc.AssignErr != nil, len(assignments.queued) == 1)
+
+			refund, err := refunds.GetLatestRefund(context.Background(), "order-1")
+			if tc.Refund == "" {
+				assert.Equal(t, sql.ErrNoRows, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.Refund, refund.Status)
+			assert.Equal(t, float64(265000), refund.Amount)
+		})
+	}
+}
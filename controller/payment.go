@@ -2,33 +2,43 @@ package controller
 
 import (
 	"context"
+	"database/sql"
 	"e-montir/api/handler"
 	"e-montir/model"
+	"e-montir/pkg/payment"
 	"e-montir/pkg/validator"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
+	"math"
 	"net/http"
 	"os"
-	"strings"
+	"strconv"
+	"time"
 
 	"github.com/rs/zerolog/log"
 )
 
 type paymentCtx struct {
-	orderModel model.Order
-	cartModel  model.Cart
+	orderModel     model.Order
+	cartModel      model.Cart
+	paymentModel   model.Payment
+	paymentGateway payment.PaymentGateway
 }
 
 type Payment interface {
-	Pay(ctx context.Context, orderID string) (*TransactionResponse, error)
+	Pay(ctx context.Context, userID, orderID string) (*TransactionResponse, error)
+	VerifyNotification(ctx context.Context, detail *PaymentDetail, payload []byte) (*VerifiedNotification, error)
+	MarkNotificationProcessed(ctx context.Context, notificationID int) error
+	PaymentStatus(ctx context.Context, userID, orderID string) (*PaymentStatusResponse, error)
 }
 
-func NewPayment(orderModel model.Order, cartModel model.Cart) Payment {
+func NewPayment(orderModel model.Order, cartModel model.Cart, paymentModel model.Payment, paymentGateway payment.PaymentGateway) Payment {
 	return &paymentCtx{
-		orderModel: orderModel,
-		cartModel:  cartModel,
+		orderModel:     orderModel,
+		cartModel:      cartModel,
+		paymentModel:   paymentModel,
+		paymentGateway: paymentGateway,
 	}
 }
 
@@ -37,6 +47,7 @@ type (
 		TransactionTime        string          `json:"transaction_time"`
 		TransactionStatus      string          `json:"transaction_status"`
 		TransactionID          string          `json:"transaction_id"`
+		RefundKey              string          `json:"refund_key"`
 		StatusMessage          string          `json:"status_message"`
 		StatusCode             string          `json:"status_code"`
 		SignatureKey           string          `json:"signature_key"`
@@ -107,6 +118,35 @@ type (
 	HttpRequest struct {
 		Client *http.Client
 	}
+
+	PaymentAttempt struct {
+		ID            int        `json:"id"`
+		TransactionID string     `json:"transaction_id"`
+		Token         string     `json:"token"`
+		RedirectURL   string     `json:"redirect_url"`
+		Amount        float64    `json:"amount"`
+		Currency      string     `json:"currency"`
+		PaymentType   string     `json:"payment_type"`
+		VANumbers     []VANumber `json:"va_numbers"`
+		Status        string     `json:"status"`
+		CreatedAt     string     `json:"created_at"`
+		UpdatedAt     string     `json:"updated_at"`
+	}
+
+	PaymentStatusData struct {
+		OrderID string           `json:"order_id"`
+		Latest  *PaymentAttempt  `json:"latest"`
+		History []PaymentAttempt `json:"history"`
+	}
+
+	PaymentStatusResponse struct {
+		Data PaymentStatusData `json:"data"`
+	}
+
+	VerifiedNotification struct {
+		ID          int
+		IsDuplicate bool
+	}
 )
 
 func (req *PaymentRequest) ValidatePaymentRequest() ([]handler.Fields, error) {
@@ -127,58 +167,221 @@ func (req *PaymentRequest) ValidatePaymentRequest() ([]handler.Fields, error) {
 	return nil, nil
 }
 
-func (c *paymentCtx) Pay(ctx context.Context, orderID string) (*TransactionResponse, error) {
+func (c *paymentCtx) Pay(ctx context.Context, userID, orderID string) (*TransactionResponse, error) {
+	_, err := authorizeOrder(ctx, c.orderModel, userID, orderID)
+	if err != nil {
+		return nil, err
+	}
+
 	order, err := c.orderModel.CheckOrder(ctx, orderID)
 	if err != nil {
 		log.Error().Err(fmt.Errorf("error when checkingOrder: %w", err)).Send()
 		return nil, err
 	}
 
-	totalPrice := int(order.TotalPrice)
-	transactionRes := TransactionResponse{}
-	payload := strings.NewReader(fmt.Sprintf(`{
-	    "transaction_detail":{
-	        "order_id": "%s",
-	        "gross_amount": %d
-	    },
-	    "redirect":{
-	        "finish_url": "%s",
-			"payment_notification_url": "%s"
-	    }
-	}`, orderID, totalPrice, os.Getenv("PAYMENT_REDIRECT_BASE_URL"), os.Getenv("PAYMENT_NOTIFICATION_URL")))
-
-	client := &http.Client{}
-	req, err := http.NewRequestWithContext(
-		ctx,
-		"POST",
-		fmt.Sprintf("%s/transactions", os.Getenv("PAYMENT_BASE_URL")),
-		payload,
-	)
+	if order.ExpiresAt.Valid && time.Now().After(order.ExpiresAt.Time) {
+		return nil, &handler.OrderPaymentExpired
+	}
+
+	// paying again while the last attempt is still open continues it, the gateway takes an order only once
+	payments, err := c.paymentModel.ListOfPayments(ctx, orderID)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when ListOfPayments: %w", err)).Send()
+		return nil, &handler.InternalServerError
+	}
+	if len(payments) > 0 && payments[0].Status == model.PaymentStatusPending && payments[0].GatewayToken.Valid {
+		return &TransactionResponse{
+			Code:    "200",
+			Message: "success",
+			Data: RedirectData{
+				Token:       payments[0].GatewayToken.String,
+				RedirectURL: payments[0].RedirectURL.String,
+				FinishURL:   os.Getenv("PAYMENT_REDIRECT_BASE_URL"),
+			},
+		}, nil
+	}
+
+	transaction, err := c.paymentGateway.CreateTransaction(ctx, &payment.TransactionRequest{
+		OrderID:         orderID,
+		GrossAmount:     int(order.TotalPrice),
+		FinishURL:       os.Getenv("PAYMENT_REDIRECT_BASE_URL"),
+		NotificationURL: os.Getenv("PAYMENT_NOTIFICATION_URL"),
+	})
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when CreateTransaction: %w", err)).Send()
+		return nil, &handler.InternalServerError
+	}
+
+	transactionRes := TransactionResponse{
+		Code:    transaction.Code,
+		Message: transaction.Message,
+		Data: RedirectData{
+			Token:       transaction.Token,
+			RedirectURL: transaction.RedirectURL,
+		},
+	}
 
+	now := time.Now()
+	err = c.paymentModel.SetPayment(ctx, &model.PaymentTransaction{
+		OrderID:      orderID,
+		GatewayToken: sql.NullString{String: transactionRes.Data.Token, Valid: transactionRes.Data.Token != ""},
+		RedirectURL:  sql.NullString{String: transactionRes.Data.RedirectURL, Valid: transactionRes.Data.RedirectURL != ""},
+		Amount:       order.TotalPrice,
+		Currency:     model.PaymentCurrency,
+		Status:       model.PaymentStatusPending,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	})
 	if err != nil {
+		log.Error().Err(fmt.Errorf("error when SetPayment: %w", err)).Send()
 		return nil, &handler.InternalServerError
 	}
 
-	req.Header.Add("Content-Type", "application/json; charset=UTF-8")
-	req.Header.Add("token", os.Getenv("PAYMENT_TOKEN"))
+	transactionRes.Data.FinishURL = os.Getenv("PAYMENT_REDIRECT_BASE_URL")
+	return &transactionRes, nil
+}
+
+// VerifyNotification checks that the notification was signed by the payment gateway and
+// that the paid amount matches the order, then stores the raw payload. Notifications whose
+// order and status have already been processed are reported as duplicate.
+func (c *paymentCtx) VerifyNotification(ctx context.Context, detail *PaymentDetail, payload []byte) (*VerifiedNotification, error) {
+	err := c.paymentGateway.VerifyNotification(&payment.Notification{
+		OrderID:      detail.OrderID,
+		StatusCode:   detail.StatusCode,
+		GrossAmount:  detail.GrossAmount,
+		SignatureKey: detail.SignatureKey,
+	})
+	if err != nil {
+		log.Error().Msg(fmt.Sprintf("invalid signature for payment notification of order %s", detail.OrderID))
+		return nil, &handler.InvalidPaymentSignature
+	}
 
-	res, err := client.Do(req)
+	order, err := c.orderModel.GetOrderByOrderID(ctx, detail.OrderID)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, &handler.OrderNotFound
+		}
+		log.Error().Err(fmt.Errorf("error when getOrderByOrderID: %w", err)).Send()
 		return nil, &handler.InternalServerError
 	}
 
-	defer res.Body.Close()
+	grossAmount, err := strconv.ParseFloat(detail.GrossAmount, 64)
+	if err != nil || math.Abs(grossAmount-order.TotalPrice) > 0.01 {
+		log.Error().Msg(fmt.Sprintf("gross amount %s does not match order %s", detail.GrossAmount, detail.OrderID))
+		return nil, &handler.PaymentAmountMismatch
+	}
 
-	resData, err := ioutil.ReadAll(res.Body)
+	notification := &model.PaymentNotification{
+		OrderID:           detail.OrderID,
+		TransactionID:     sql.NullString{String: detail.TransactionID, Valid: detail.TransactionID != ""},
+		RefundKey:         sql.NullString{String: detail.RefundKey, Valid: detail.RefundKey != ""},
+		TransactionStatus: detail.TransactionStatus,
+		StatusCode:        sql.NullString{String: detail.StatusCode, Valid: detail.StatusCode != ""},
+		GrossAmount:       sql.NullString{String: detail.GrossAmount, Valid: true},
+		SignatureKey:      detail.SignatureKey,
+		Payload:           string(payload),
+		CreatedAt:         time.Now(),
+	}
+	notificationID, err := c.paymentModel.StoreNotification(ctx, notification)
 	if err != nil {
+		log.Error().Err(fmt.Errorf("error when StoreNotification: %w", err)).Send()
 		return nil, &handler.InternalServerError
 	}
 
-	err = json.Unmarshal([]byte(string(resData)), &transactionRes)
+	isProcessed, err := c.paymentModel.IsNotificationProcessed(ctx, notification)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when IsNotificationProcessed: %w", err)).Send()
+		return nil, &handler.InternalServerError
+	}
+
+	if !isProcessed {
+		err = c.updateLatestPayment(ctx, detail)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &VerifiedNotification{
+		ID:          notificationID,
+		IsDuplicate: isProcessed,
+	}, nil
+}
+
+func (c *paymentCtx) updateLatestPayment(ctx context.Context, detail *PaymentDetail) error {
+	vaNumbers, err := json.Marshal(detail.VANumbers)
+	if err != nil {
+		return &handler.InternalServerError
+	}
+
+	err = c.paymentModel.UpdateLatestPayment(ctx, &model.PaymentTransaction{
+		OrderID:       detail.OrderID,
+		TransactionID: sql.NullString{String: detail.TransactionID, Valid: detail.TransactionID != ""},
+		PaymentType:   sql.NullString{String: detail.PaymentType, Valid: detail.PaymentType != ""},
+		VANumbers:     sql.NullString{String: string(vaNumbers), Valid: len(detail.VANumbers) > 0},
+		Status:        detail.TransactionStatus,
+		UpdatedAt:     time.Now(),
+	})
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when UpdateLatestPayment: %w", err)).Send()
+		return &handler.InternalServerError
+	}
+	return nil
+}
+
+func (c *paymentCtx) PaymentStatus(ctx context.Context, userID, orderID string) (*PaymentStatusResponse, error) {
+	_, err := authorizeOrder(ctx, c.orderModel, userID, orderID)
 	if err != nil {
 		return nil, err
 	}
 
-	transactionRes.Data.FinishURL = os.Getenv("PAYMENT_REDIRECT_BASE_URL")
-	return &transactionRes, nil
+	payments, err := c.paymentModel.ListOfPayments(ctx, orderID)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when ListOfPayments: %w", err)).Send()
+		return nil, &handler.InternalServerError
+	}
+
+	history := make([]PaymentAttempt, 0)
+	for _, v := range payments {
+		vaNumbers := make([]VANumber, 0)
+		if v.VANumbers.Valid {
+			err = json.Unmarshal([]byte(v.VANumbers.String), &vaNumbers)
+			if err != nil {
+				log.Error().Err(fmt.Errorf("error when parsing va_numbers: %w", err)).Send()
+			}
+		}
+
+		history = append(history, PaymentAttempt{
+			ID:            v.ID,
+			TransactionID: v.TransactionID.String,
+			Token:         v.GatewayToken.String,
+			RedirectURL:   v.RedirectURL.String,
+			Amount:        v.Amount,
+			Currency:      v.Currency,
+			PaymentType:   v.PaymentType.String,
+			VANumbers:     vaNumbers,
+			Status:        v.Status,
+			CreatedAt:     v.CreatedAt.Format(time.RFC3339),
+			UpdatedAt:     v.UpdatedAt.Format(time.RFC3339),
+		})
+	}
+
+	res := &PaymentStatusResponse{
+		Data: PaymentStatusData{
+			OrderID: orderID,
+			History: history,
+		},
+	}
+	if len(history) > 0 {
+		res.Data.Latest = &history[0]
+	}
+	return res, nil
+}
+
+func (c *paymentCtx) MarkNotificationProcessed(ctx context.Context, notificationID int) error {
+	err := c.paymentModel.MarkNotificationProcessed(ctx, notificationID)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when MarkNotificationProcessed: %w", err)).Send()
+		return &handler.InternalServerError
+	}
+	return nil
 }
@@ -0,0 +1,34 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockPaymentController struct {
+	mock.Mock
+}
+
+func (m *MockPaymentController) Pay(ctx context.Context, userID, orderID string) (*TransactionResponse, error) {
+	args := m.Called(ctx, userID, orderID)
+	res, _ := args.Get(0).(*TransactionResponse)
+	return res, args.Error(1)
+}
+
+func (m *MockPaymentController) VerifyNotification(ctx context.Context, detail *PaymentDetail, payload []byte) (*VerifiedNotification, error) {
+	args := m.Called(ctx, detail, payload)
+	res, _ := args.Get(0).(*VerifiedNotification)
+	return res, args.Error(1)
+}
+
+func (m *MockPaymentController) MarkNotificationProcessed(ctx context.Context, notificationID int) error {
+	args := m.Called(ctx, notificationID)
+	return args.Error(0)
+}
+
+func (m *MockPaymentController) PaymentStatus(ctx context.Context, userID, orderID string) (*PaymentStatusResponse, error) {
+	args := m.Called(ctx, userID, orderID)
+	res, _ := args.Get(0).(*PaymentStatusResponse)
+	return res, args.Error(1)
+}
@@ -0,0 +1,199 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"e-montir/api/handler"
+	"e-montir/model"
+	"e-montir/pkg/payment"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type stubPaymentModel struct {
+	notifications []model.PaymentNotification
+	payments      []model.PaymentTransaction
+}
+
+func (s *stubPaymentModel) StoreNotification(ctx context.Context, param *model.PaymentNotification) (int, error) {
+	param.ID = len(s.notifications) + 1
+	s.notifications = append(s.notifications, *param)
+	return param.ID, nil
+}
+
+func (s *stubPaymentModel) IsNotificationProcessed(ctx context.Context, param *model.PaymentNotification) (bool, error) {
+	for _, v := range s.notifications {
+		if v.OrderID == param.OrderID && v.TransactionID == param.TransactionID && v.RefundKey == param.RefundKey &&
+			v.TransactionStatus == param.TransactionStatus && v.ProcessedAt.Valid {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *stubPaymentModel) MarkNotificationProcessed(ctx context.Context, notificationID int) error {
+	s.notifications[notificationID-1].ProcessedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	return nil
+}
+
+func (s *stubPaymentModel) SetPayment(ctx context.Context, param *model.PaymentTransaction) error {
+	param.ID = len(s.payments) + 1
+	s.payments = append(s.payments, *param)
+	return nil
+}
+
+func (s *stubPaymentModel) UpdateLatestPayment(ctx context.Context, param *model.PaymentTransaction) error {
+	for i := len(s.payments) - 1; i >= 0; i-- {
+		if s.payments[i].OrderID == param.OrderID {
+			s.payments[i].Status = param.Status
+			s.payments[i].TransactionID = param.TransactionID
+			return nil
+		}
+	}
+	return nil
+}
+
+// ListOfPayments lists the attempts of the order latest first, like model.Payment
+func (s *stubPaymentModel) ListOfPayments(ctx context.Context, orderID string) ([]model.PaymentTransaction, error) {
+	var result []model.PaymentTransaction
+	for i := len(s.payments) - 1; i >= 0; i-- {
+		if s.payments[i].OrderID == orderID {
+			result = append(result, s.payments[i])
+		}
+	}
+	return result, nil
+}
+
+func newSignedPaymentDetail(orderID, status, statusCode, grossAmount string) *PaymentDetail {
+	return &PaymentDetail{
+		OrderID:           orderID,
+		TransactionStatus: status,
+		StatusCode:        statusCode,
+		GrossAmount:       grossAmount,
+		SignatureKey:      payment.Signature(orderID, statusCode, grossAmount, "secret"),
+	}
+}
+
+func TestPay(t *testing.T) {
+	gateway := newFakeGateway(t)
+	unpaid := newOrderBaseModel("order-1", model.OrderStatus[1], 265000)
+	unpaid.ExpiresAt = sql.NullTime{Time: time.Now().Add(time.Hour), Valid: true}
+	expired := newOrderBaseModel("order-2", model.OrderStatus[1], 265000)
+	expired.ExpiresAt = sql.NullTime{Time: time.Now().Add(-time.Minute), Valid: true}
+	orders := newStubOrderModel(unpaid, expired)
+	payments := &stubPaymentModel{}
+	c := NewPayment(orders, nil, payments, gateway)
+
+	first, err := c.Pay(context.Background(), "user-1", "order-1")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, first.Data.Token)
+	assert.Len(t, payments.payments, 1)
+	assert.Equal(t, float64(265000), payments.payments[0].Amount)
+
+	t.Run("Open attempt is reused", func(t *testing.T) {
+		again, err := c.Pay(context.Background(), "user-1", "order-1")
+		assert.NoError(t, err)
+		assert.Equal(t, first.Data.Token, again.Data.Token)
+		assert.Equal(t, first.Data.RedirectURL, again.Data.RedirectURL)
+		assert.Len(t, payments.payments, 1)
+	})
+
+	t.Run("Failed attempt is not reused", func(t *testing.T) {
+		payments.payments[0].Status = payment.StatusFailed
+		retry, err := c.Pay(context.Background(), "user-1", "order-1")
+		assert.NoError(t, err)
+		assert.NotEqual(t, first.Data.Token, retry.Data.Token)
+		assert.Len(t, payments.payments, 2)
+	})
+
+	t.Run("Payment deadline passed", func(t *testing.T) {
+		_, err := c.Pay(context.Background(), "user-1", "order-2")
+		assert.Equal(t, &handler.OrderPaymentExpired, err)
+	})
+
+	t.Run("Order of another user", func(t *testing.T) {
+		_, err := c.Pay(context.Background(), "user-2", "order-1")
+		assert.Equal(t, &handler.OrderNotFound, err)
+	})
+}
+
+func TestVerifyNotification(t *testing.T) {
+	tt := []struct {
+		Name   string
+		Detail *PaymentDetail
+		Err    error
+	}{
+		{
+			Name:   "Signed by the gateway",
+			Detail: newSignedPaymentDetail("order-1", payment.StatusPaid, "200", "265000.00"),
+		},
+		{
+			Name: "Forged signature",
+			Detail: &PaymentDetail{
+				OrderID: "order-1", TransactionStatus: payment.StatusPaid, StatusCode: "200", GrossAmount: "265000.00",
+				SignatureKey: payment.Signature("order-1", "200", "265000.00", "guessed"),
+			},
+			Err: &handler.InvalidPaymentSignature,
+		},
+		{
+			Name:   "Gross amount less than the order",
+			Detail: newSignedPaymentDetail("order-1", payment.StatusPaid, "200", "1000.00"),
+			Err:    &handler.PaymentAmountMismatch,
+		},
+		{
+			Name:   "Gross amount not a number",
+			Detail: newSignedPaymentDetail("order-1", payment.StatusPaid, "200", "free"),
+			Err:    &handler.PaymentAmountMismatch,
+		},
+		{
+			Name:   "Order not exists",
+			Detail: newSignedPaymentDetail("order-2", payment.StatusPaid, "200", "265000.00"),
+			Err:    &handler.OrderNotFound,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			payments := &stubPaymentModel{}
+			c := NewPayment(newStubOrderModel(newOrderBaseModel("order-1", model.OrderStatus[1], 265000)), nil, payments, newFakeGateway(t))
+
+			notification, err := c.VerifyNotification(context.Background(), tc.Detail, []byte(`{}`))
+			assert.Equal(t, tc.Err, err)
+			if tc.Err != nil {
+				assert.Empty(t, payments.notifications)
+				return
+			}
+			assert.False(t, notification.IsDuplicate)
+			assert.Len(t, payments.notifications, 1)
+		})
+	}
+}
+
+func TestVerifyNotificationDuplicate(t *testing.T) {
+	payments := &stubPaymentModel{}
+	payments.payments = []model.PaymentTransaction{{ID: 1, OrderID: "order-1", Status: model.PaymentStatusPending}}
+	c := NewPayment(newStubOrderModel(newOrderBaseModel("order-1", model.OrderStatus[1], 265000)), nil, payments, newFakeGateway(t))
+	paid := newSignedPaymentDetail("order-1", payment.StatusPaid, "200", "265000.00")
+
+	first, err := c.VerifyNotification(context.Background(), paid, []byte(`{}`))
+	assert.NoError(t, err)
+	assert.False(t, first.IsDuplicate)
+	assert.Equal(t, payment.StatusPaid, payments.payments[0].Status)
+	assert.NoError(t, c.MarkNotificationProcessed(context.Background(), first.ID))
+
+	// the gateway sends the notification again, it is stored but reported as already processed
+	payments.payments[0].Status = model.PaymentStatusPending
+	again, err := c.VerifyNotification(context.Background(), paid, []byte(`{}`))
+	assert.NoError(t, err)
+	assert.True(t, again.IsDuplicate)
+	assert.NotEqual(t, first.ID, again.ID)
+	assert.Len(t, payments.notifications, 2)
+	assert.Equal(t, model.PaymentStatusPending, payments.payments[0].Status)
+
+	// another status of the same order is not a duplicate
+	expired, err := c.VerifyNotification(context.Background(), newSignedPaymentDetail("order-1", payment.StatusExpired, "407", "265000.00"), []byte(`{}`))
+	assert.NoError(t, err)
+	assert.False(t, expired.IsDuplicate)
+}
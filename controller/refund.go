@@ -0,0 +1,230 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"e-montir/api/handler"
+	"e-montir/model"
+	"e-montir/pkg/payment"
+	"e-montir/pkg/validator"
+	"errors"
+	"fmt"
+	"time"
+
+	"e-montir/pkg/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+type refundCtx struct {
+	orderModel     model.Order
+	refundModel    model.Refund
+	paymentGateway payment.PaymentGateway
+}
+
+type Refund interface {
+	RequestRefund(ctx context.Context, actor string, form *RefundRequest) (*RefundResponse, error)
+	RefundReceived(ctx context.Context, orderID, transactionStatus string) error
+}
+
+func NewRefund(orderModel model.Order, refundModel model.Refund, paymentGateway payment.PaymentGateway) Refund {
+	return &refundCtx{
+		orderModel:     orderModel,
+		refundModel:    refundModel,
+		paymentGateway: paymentGateway,
+	}
+}
+
+type (
+	RefundRequest struct {
+		OrderID string `json:"-"`
+		Reason  string `json:"reason"`
+	}
+
+	OrderRefund struct {
+		Status        string  `json:"status"`
+		Amount        float64 `json:"amount"`
+		Reason        string  `json:"reason,omitempty"`
+		FailureReason string  `json:"failure_reason,omitempty"`
+		RequestedAt   string  `json:"requested_at"`
+		UpdatedAt     string  `json:"updated_at"`
+	}
+
+	RefundResponse struct {
+		OrderID string      `json:"order_id"`
+		Refund  OrderRefund `json:"refund"`
+	}
+)
+
+func (req *RefundRequest) ValidateRefundRequest() ([]handler.Fields, error) {
+	var count int
+	var fields []handler.Fields
+	err := validator.ValidateOrderID(req.OrderID)
+	if err != nil {
+		count++
+		fields = append(fields, handler.Fields{
+			Name:    "order_id",
+			Message: err.Error(),
+		})
+	}
+
+	err = validator.ValidateRefundReason(req.Reason)
+	if err != nil {
+		count++
+		fields = append(fields, handler.Fields{
+			Name:    "reason",
+			Message: err.Error(),
+		})
+	}
+
+	if count == 0 {
+		return nil, nil
+	}
+	return fields, errors.New(handler.ValidationFailed)
+}
+
+// RequestRefund returns the money of a paid order which has been cancelled or disputed after it was done.
+// A failed refund can be requested again, any other refund of the order blocks a new one.
+func (c *refundCtx) RequestRefund(ctx context.Context, actor string, form *RefundRequest) (*RefundResponse, error) {
+	order, err := c.orderModel.GetOrderByOrderID(ctx, form.OrderID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, &handler.OrderNotFound
+		}
+		log.Error().Err(fmt.Errorf("error when getOrderByOrderID : %w", err)).Send()
+		return nil, &handler.InternalServerError
+	}
+
+	status := order.OrderStatus.String
+	if status != model.OrderStatus[5] && status != model.OrderStatus[6] {
+		return nil, &handler.OrderCannotBeRefunded
+	}
+
+	latest, err := c.refundModel.GetLatestRefund(ctx, form.OrderID)
+	if err != nil && err != sql.ErrNoRows {
+		log.Error().Err(fmt.Errorf("error when GetLatestRefund : %w", err)).Send()
+		return nil, err
+	}
+	if latest != nil && latest.Status != model.RefundStatusFailed {
+		return nil, &handler.RefundAlreadyRequested
+	}
+
+	history, err := c.orderModel.ListOfOrderStatusHistory(ctx, form.OrderID)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when ListOfOrderStatusHistory : %w", err)).Send()
+		return nil, err
+	}
+	// a payment which arrived after the order was cancelled never moved the order, its failed refund tells it was paid
+	if !isOrderPaid(history) && latest == nil {
+		return nil, &handler.OrderCannotBeRefunded
+	}
+
+	refund, err := requestRefund(ctx, c.refundModel, c.paymentGateway, form.OrderID, actor, form.Reason, order.TotalPrice)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RefundResponse{
+		OrderID: form.OrderID,
+		Refund:  orderRefund(refund),
+	}, nil
+}
+
+// RefundReceived settles the latest refund of the order once the gateway reports its outcome.
+func (c *refundCtx) RefundReceived(ctx context.Context, orderID, transactionStatus string) error {
+	refund, err := c.refundModel.GetLatestRefund(ctx, orderID)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when GetLatestRefund : %w", err)).Send()
+		return err
+	}
+
+	if transactionStatus != payment.StatusRefunded {
+		err = c.refundModel.UpdateRefundStatus(ctx, refund.ID, model.RefundStatusFailed, "refund rejected by payment gateway")
+		if err != nil {
+			log.Error().Err(fmt.Errorf("error when UpdateRefundStatus : %w", err)).Send()
+			return err
+		}
+		return nil
+	}
+
+	err = c.refundModel.UpdateRefundStatus(ctx, refund.ID, model.RefundStatusSucceeded, "")
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when UpdateRefundStatus : %w", err)).Send()
+		return err
+	}
+
+	err = c.orderModel.UpdateOrderStatus(ctx, orderID, model.OrderStatus[7], "", model.OrderActorPayment)
+	if err != nil && !errors.Is(err, &handler.InvalidOrderStatusTransition) {
+		log.Error().Err(fmt.Errorf("error when UpdateOrderStatus : %w", err)).Send()
+		return err
+	}
+	return nil
+}
+
+// requestRefund records the refund before calling the gateway so every attempt can be traced,
+// a refund rejected by the gateway is kept as failed instead of returning an error.
+func requestRefund(ctx context.Context, refundModel model.Refund, gateway payment.PaymentGateway, orderID, actor, reason string, amount float64) (*model.RefundBaseModel, error) {
+	refundKey, err := uuid.GenerateUUID()
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when generating refund key : %w", err)).Send()
+		return nil, &handler.InternalServerError
+	}
+
+	now := time.Now()
+	refund := &model.RefundBaseModel{
+		OrderID:     orderID,
+		RefundKey:   refundKey,
+		Amount:      amount,
+		Reason:      sql.NullString{String: reason, Valid: reason != ""},
+		Status:      model.RefundStatusRequested,
+		RequestedBy: actor,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	refund.ID, err = refundModel.SetRefund(ctx, refund)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when SetRefund : %w", err)).Send()
+		return nil, err
+	}
+
+	refund.Status = model.RefundStatusProcessing
+	_, err = gateway.Refund(ctx, &payment.RefundRequest{
+		OrderID:   orderID,
+		RefundKey: refundKey,
+		Amount:    int(amount),
+		Reason:    reason,
+	})
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when requestRefund : %w", err)).Send()
+		refund.Status = model.RefundStatusFailed
+		refund.FailureReason = sql.NullString{String: err.Error(), Valid: true}
+	}
+
+	err = refundModel.UpdateRefundStatus(ctx, refund.ID, refund.Status, refund.FailureReason.String)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when UpdateRefundStatus : %w", err)).Send()
+		return nil, err
+	}
+	refund.UpdatedAt = time.Now()
+	return refund, nil
+}
+
+// isOrderPaid reports whether the order ever left "Waiting for payment" through a payment
+func isOrderPaid(history []model.OrderStatusHistory) bool {
+	for _, v := range history {
+		if v.ToStatus == model.OrderStatus[2] {
+			return true
+		}
+	}
+	return false
+}
+
+func orderRefund(refund *model.RefundBaseModel) OrderRefund {
+	return OrderRefund{
+		Status:        refund.Status,
+		Amount:        refund.Amount,
+		Reason:        refund.Reason.String,
+		FailureReason: refund.FailureReason.String,
+		RequestedAt:   refund.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:     refund.UpdatedAt.Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,151 @@
+package controller
+
+import (
+	"context"
+	"e-montir/api/handler"
+	"e-montir/model"
+	"e-montir/pkg/payment"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newPaidOrderModel gives the order in status after it went through the payment, as the history tells
+func newPaidOrderModel(t *testing.T, orderID, status string) *stubOrderModel {
+	orders := newStubOrderModel(newOrderBaseModel(orderID, model.OrderStatus[1], 265000))
+	path := map[string][]string{
+		model.OrderStatus[2]: {model.OrderStatus[2]},
+		model.OrderStatus[5]: {model.OrderStatus[2], model.OrderStatus[3], model.OrderStatus[4], model.OrderStatus[5]},
+		model.OrderStatus[6]: {model.OrderStatus[2], model.OrderStatus[6]},
+	}
+	for _, next := range path[status] {
+		_, err := orders.transit(orderID, next, model.OrderActorSystem)
+		assert.NoError(t, err)
+	}
+	return orders
+}
+
+func TestRequestRefund(t *testing.T) {
+	tt := []struct {
+		Name          string
+		Orders        func(t *testing.T) *stubOrderModel
+		Refunds       []model.RefundBaseModel
+		Paid          int
+		Status        string
+		FailureReason string
+		Err           error
+	}{
+		{
+			Name:   "Cancelled after payment",
+			Orders: func(t *testing.T) *stubOrderModel { return newPaidOrderModel(t, "order-1", model.OrderStatus[6]) },
+			Paid:   265000,
+			Status: model.RefundStatusProcessing,
+		},
+		{
+			Name:   "Disputed after done",
+			Orders: func(t *testing.T) *stubOrderModel { return newPaidOrderModel(t, "order-1", model.OrderStatus[5]) },
+			Paid:   265000,
+			Status: model.RefundStatusProcessing,
+		},
+		{
+			Name:          "More than the paid amount",
+			Orders:        func(t *testing.T) *stubOrderModel { return newPaidOrderModel(t, "order-1", model.OrderStatus[6]) },
+			Paid:          100000,
+			Status:        model.RefundStatusFailed,
+			FailureReason: "refund amount exceeds paid amount of order order-1",
+		},
+		{
+			Name:    "Failed refund requested again",
+			Orders:  func(t *testing.T) *stubOrderModel { return newPaidOrderModel(t, "order-1", model.OrderStatus[6]) },
+			Refunds: []model.RefundBaseModel{{OrderID: "order-1", Amount: 265000, Status: model.RefundStatusFailed}},
+			Paid:    265000,
+			Status:  model.RefundStatusProcessing,
+		},
+		{
+			Name:    "Refund in process",
+			Orders:  func(t *testing.T) *stubOrderModel { return newPaidOrderModel(t, "order-1", model.OrderStatus[6]) },
+			Refunds: []model.RefundBaseModel{{OrderID: "order-1", Amount: 265000, Status: model.RefundStatusProcessing}},
+			Paid:    265000,
+			Err:     &handler.RefundAlreadyRequested,
+		},
+		{
+			Name: "Cancelled before payment",
+			Orders: func(t *testing.T) *stubOrderModel {
+				return newStubOrderModel(newOrderBaseModel("order-1", model.OrderStatus[6], 265000))
+			},
+			Err: &handler.OrderCannotBeRefunded,
+		},
+		{
+			Name: "Waiting for payment",
+			Orders: func(t *testing.T) *stubOrderModel {
+				return newStubOrderModel(newOrderBaseModel("order-1", model.OrderStatus[1], 265000))
+			},
+			Err: &handler.OrderCannotBeRefunded,
+		},
+		{
+			Name:   "Still on process",
+			Orders: func(t *testing.T) *stubOrderModel { return newPaidOrderModel(t, "order-1", model.OrderStatus[2]) },
+			Paid:   265000,
+			Err:    &handler.OrderCannotBeRefunded,
+		},
+		{
+			Name:   "Order not exists",
+			Orders: func(t *testing.T) *stubOrderModel { return newStubOrderModel() },
+			Err:    &handler.OrderNotFound,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			gateway := newFakeGateway(t)
+			if tc.Paid > 0 {
+				payThroughGateway(t, gateway, "order-1", tc.Paid, payment.StatusPaid)
+			}
+			refunds := &stubRefundModel{refunds: tc.Refunds}
+			c := NewRefund(tc.Orders(t), refunds, gateway)
+
+			res, err := c.RequestRefund(context.Background(), "admin-1", &RefundRequest{OrderID: "order-1", Reason: "mechanic never came"})
+			assert.Equal(t, tc.Err, err)
+			if tc.Err != nil {
+				assert.Len(t, refunds.refunds, len(tc.Refunds))
+				return
+			}
+			assert.Equal(t, tc.Status, res.Refund.Status)
+			assert.Equal(t, tc.FailureReason, res.Refund.FailureReason)
+			assert.Equal(t, float64(265000), res.Refund.Amount)
+			assert.Len(t, refunds.refunds, len(tc.Refunds)+1)
+			assert.Equal(t, tc.Status, refunds.refunds[len(refunds.refunds)-1].Status)
+		})
+	}
+}
+
+func TestRefundReceived(t *testing.T) {
+	gateway := newFakeGateway(t)
+	payThroughGateway(t, gateway, "order-1", 265000, payment.StatusPaid)
+	orders := newPaidOrderModel(t, "order-1", model.OrderStatus[6])
+	refunds := &stubRefundModel{}
+	c := NewRefund(orders, refunds, gateway)
+	request := &RefundRequest{OrderID: "order-1", Reason: "mechanic never came"}
+
+	_, err := c.RequestRefund(context.Background(), "admin-1", request)
+	assert.NoError(t, err)
+
+	// the gateway gives up on the refund, the order keeps its status and the refund can be requested again
+	assert.NoError(t, gateway.Simulate(context.Background(), "order-1", payment.StatusRefundFailed))
+	assert.NoError(t, c.RefundReceived(context.Background(), "order-1", payment.StatusRefundFailed))
+	assert.Equal(t, model.RefundStatusFailed, refunds.refunds[0].Status)
+	assert.Equal(t, "refund rejected by payment gateway", refunds.refunds[0].FailureReason.String)
+	assert.Equal(t, model.OrderStatus[6], orders.status("order-1"))
+
+	res, err := c.RequestRefund(context.Background(), "admin-1", request)
+	assert.NoError(t, err)
+	assert.Equal(t, model.RefundStatusProcessing, res.Refund.Status)
+
+	assert.NoError(t, gateway.Simulate(context.Background(), "order-1", payment.StatusRefunded))
+	assert.NoError(t, c.RefundReceived(context.Background(), "order-1", payment.StatusRefunded))
+	assert.Equal(t, model.RefundStatusSucceeded, refunds.refunds[1].Status)
+	assert.Equal(t, model.OrderStatus[7], orders.status("order-1"))
+
+	_, err = c.RequestRefund(context.Background(), "admin-1", request)
+	assert.Equal(t, &handler.OrderCannotBeRefunded, err)
+}
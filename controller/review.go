@@ -2,33 +2,62 @@ package controller
 
 import (
 	"context"
+	"database/sql"
 	"e-montir/api/handler"
 	"e-montir/model"
+	"e-montir/pkg/media"
+	"e-montir/pkg/sort"
 	"e-montir/pkg/validator"
 	"errors"
 	"fmt"
+	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/rs/zerolog/log"
 )
 
 type reviewCtx struct {
-	reviewModel model.Review
-	cartModel   model.Cart
+	reviewModel     model.Review
+	cartModel       model.Cart
+	orderModel      model.Order
+	attachmentModel model.Attachment
+	mediaStore      media.Storage
+	editWindow      time.Duration
 }
 
 type Review interface {
 	AddServiceReview(ctx context.Context, userID string, form *ReviewBaseModel) error
+	AddMechanicReview(ctx context.Context, userID string, form *MechanicReviewRequest) error
+	ListOfServiceReviews(ctx context.Context, form *ServiceReviewListRequest) (*ServiceReviewListResponse, error)
+	UpdateServiceReview(ctx context.Context, userID string, form *UpdateServiceReviewRequest) error
+	DeleteServiceReview(ctx context.Context, userID string, form *ServiceReviewIDRequest) error
+	ListOfModerationQueue(ctx context.Context, form *ModerationQueueRequest) (*ModerationQueueResponse, error)
+	ModerateServiceReview(ctx context.Context, moderatorID string, form *ReviewModerationRequest) error
+	ReplyServiceReview(ctx context.Context, form *ReviewReplyRequest) error
 }
 
-func NewReview(reviewModel model.Review, cartModel model.Cart) Review {
+func NewReview(reviewModel model.Review, cartModel model.Cart, orderModel model.Order, attachmentModel model.Attachment, mediaStore media.Storage) Review {
 	return &reviewCtx{
-		reviewModel: reviewModel,
-		cartModel:   cartModel,
+		reviewModel:     reviewModel,
+		cartModel:       cartModel,
+		orderModel:      orderModel,
+		attachmentModel: attachmentModel,
+		mediaStore:      mediaStore,
+		editWindow:      reviewEditWindow(),
 	}
 }
 
+// reviewEditWindow is how long after posting a customer may still edit or delete the review
+func reviewEditWindow() time.Duration {
+	window, err := time.ParseDuration(os.Getenv("REVIEW_EDIT_WINDOW"))
+	if err != nil || window < 0 {
+		window = 72 * time.Hour
+	}
+	return window
+}
+
 type (
 	ReviewBaseModel struct {
 		ID              int       `json:"id"`
@@ -40,6 +69,98 @@ type (
 		ServiceIDString string
 		OrderID         string
 	}
+
+	MechanicReviewRequest struct {
+		Feedback     string `json:"feedback"`
+		RatingString string `json:"rating"`
+		Rating       float64
+		OrderID      string
+	}
+
+	ServiceReviewListRequest struct {
+		ServiceIDString string
+		ServiceID       int
+		PageString      string
+		Page            int
+		LimitString     string
+		Limit           int
+		Sort            string
+	}
+
+	ReviewReply struct {
+		Body      string `json:"body"`
+		CreatedAt string `json:"created_at"`
+	}
+
+	ServiceReview struct {
+		ID          int              `json:"id"`
+		Reviewer    string           `json:"reviewer"`
+		Rating      float64          `json:"rating"`
+		Feedback    string           `json:"feedback"`
+		Reply       *ReviewReply     `json:"reply,omitempty"`
+		Attachments []AttachmentData `json:"attachments"`
+		CreatedAt   string           `json:"created_at"`
+		UpdatedAt   string           `json:"updated_at,omitempty"`
+	}
+
+	ServiceRatingSummary struct {
+		Rating      float64        `json:"rating"`
+		ReviewCount int            `json:"review_count"`
+		Histogram   map[string]int `json:"histogram"`
+	}
+
+	ServiceReviewListResponse struct {
+		Data       []ServiceReview      `json:"data"`
+		Summary    ServiceRatingSummary `json:"summary"`
+		Pagination Pagination           `json:"pagination"`
+	}
+
+	UpdateServiceReviewRequest struct {
+		ReviewIDString string  `json:"-"`
+		ReviewID       int     `json:"-"`
+		RatingString   string  `json:"rating"`
+		Rating         float64 `json:"-"`
+		Feedback       string  `json:"feedback"`
+	}
+
+	ServiceReviewIDRequest struct {
+		ReviewIDString string
+		ReviewID       int
+	}
+
+	ModerationQueueRequest struct {
+		Status      string
+		PageString  string
+		Page        int
+		LimitString string
+		Limit       int
+	}
+
+	ModeratedReview struct {
+		ServiceReview
+		ServiceID    int    `json:"service_id"`
+		OrderID      string `json:"order_id"`
+		HiddenAt     string `json:"hidden_at,omitempty"`
+		HiddenReason string `json:"hidden_reason,omitempty"`
+	}
+
+	ModerationQueueResponse struct {
+		Data       []ModeratedReview `json:"data"`
+		Pagination Pagination        `json:"pagination"`
+	}
+
+	ReviewModerationRequest struct {
+		ReviewIDString string `json:"-"`
+		ReviewID       int    `json:"-"`
+		Action         string `json:"-"`
+		Reason         string `json:"reason"`
+	}
+
+	ReviewReplyRequest struct {
+		ReviewIDString string `json:"-"`
+		ReviewID       int    `json:"-"`
+		Reply          string `json:"reply"`
+	}
 )
 
 func (req *ReviewBaseModel) ValidateReviewRequest() ([]handler.Fields, error) {
@@ -132,6 +253,11 @@ func (req *ReviewBaseModel) ValidateReviewRequest() ([]handler.Fields, error) {
 }
 
 func (c *reviewCtx) AddServiceReview(ctx context.Context, userID string, form *ReviewBaseModel) error {
+	_, err := authorizeOrder(ctx, c.orderModel, userID, form.OrderID)
+	if err != nil {
+		return err
+	}
+
 	isServiceAvailable, err := c.cartModel.IsServiceAvailable(ctx, form.ServiceID)
 	if err != nil {
 		log.Error().Err(fmt.Errorf("error when checking isServiceAvailable : %w", err)).Send()
@@ -168,3 +294,571 @@ func (c *reviewCtx) AddServiceReview(ctx context.Context, userID string, form *R
 
 	return nil
 }
+
+func (req *MechanicReviewRequest) ValidateMechanicReviewRequest() ([]handler.Fields, error) {
+	var count int
+	var fields []handler.Fields
+	ratingValid := true
+	err := validator.ValidateRating(req.RatingString)
+	if err != nil {
+		ratingValid = false
+		count++
+		fields = append(fields, handler.Fields{
+			Name:    "rating",
+			Message: err.Error(),
+		})
+	}
+
+	if ratingValid {
+		rating, ratingErr := strconv.ParseFloat(req.RatingString, 64)
+		if ratingErr != nil {
+			count++
+			ratingValid = false
+			fields = append(fields, handler.Fields{
+				Name:    "rating",
+				Message: ratingErr.Error(),
+			})
+		}
+		if rating < 1 && ratingValid || rating > 5 && ratingValid {
+			count++
+			fields = append(fields, handler.Fields{
+				Name:    "rating",
+				Message: "rating must be between 1 to 5",
+			})
+		}
+		req.Rating = rating
+	}
+
+	err = validator.ValidateFeedback(req.Feedback)
+	if err != nil {
+		count++
+		fields = append(fields, handler.Fields{
+			Name:    "feedback",
+			Message: err.Error(),
+		})
+	}
+
+	err = validator.ValidateOrderID(req.OrderID)
+	if err != nil {
+		count++
+		fields = append(fields, handler.Fields{
+			Name:    "order_id",
+			Message: err.Error(),
+		})
+	}
+
+	if count == 0 {
+		return nil, nil
+	}
+	return fields, errors.New(handler.ValidationFailed)
+}
+
+// AddMechanicReview rates the mechanic who handled a finished order, once per order
+func (c *reviewCtx) AddMechanicReview(ctx context.Context, userID string, form *MechanicReviewRequest) error {
+	order, err := authorizeOrder(ctx, c.orderModel, userID, form.OrderID)
+	if err != nil {
+		return err
+	}
+
+	if order.OrderStatus.String != model.OrderStatus[5] || !order.MechanicID.Valid {
+		return &handler.OrderNotCompleted
+	}
+
+	added, err := c.reviewModel.AddMechanicReview(ctx, &model.MechanicReviewBaseModel{
+		OrderID:    form.OrderID,
+		MechanicID: int(order.MechanicID.Int64),
+		UserID:     userID,
+		Rating:     form.Rating,
+		Feedback:   sql.NullString{String: form.Feedback, Valid: form.Feedback != ""},
+		CreatedAt:  time.Now(),
+	})
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when AddMechanicReview : %w", err)).Send()
+		return &handler.InternalServerError
+	}
+
+	if !added {
+		return &handler.MechanicIsReviewed
+	}
+	return nil
+}
+
+func (req *ServiceReviewListRequest) ValidateServiceReviewListRequest() ([]handler.Fields, error) {
+	var count int
+	var fields []handler.Fields
+	serviceID, err := strconv.Atoi(req.ServiceIDString)
+	if err != nil || serviceID < 1 {
+		count++
+		fields = append(fields, handler.Fields{
+			Name:    "service_id",
+			Message: "service_id must be more than 0",
+		})
+	}
+	req.ServiceID = serviceID
+
+	req.Page = 1
+	if strings.TrimSpace(req.PageString) != "" {
+		page, err := strconv.Atoi(req.PageString)
+		if err != nil || page < 1 {
+			count++
+			fields = append(fields, handler.Fields{
+				Name:    "page",
+				Message: "page must be more than 0",
+			})
+		}
+		req.Page = page
+	}
+
+	req.Limit = 10
+	if strings.TrimSpace(req.LimitString) != "" {
+		limit, err := strconv.Atoi(req.LimitString)
+		if err != nil || limit < 1 || limit > 50 {
+			count++
+			fields = append(fields, handler.Fields{
+				Name:    "limit",
+				Message: "limit must be between 1 and 50",
+			})
+		}
+		req.Limit = limit
+	}
+
+	err = validator.ValidateReviewSort(req.Sort)
+	if err != nil {
+		count++
+		fields = append(fields, handler.Fields{
+			Name:    "sort",
+			Message: err.Error(),
+		})
+	}
+	if req.Sort == "" {
+		req.Sort = sort.Newest
+	}
+
+	if count == 0 {
+		return nil, nil
+	}
+	return fields, errors.New(handler.ValidationFailed)
+}
+
+// ListOfServiceReviews pages through the reviews of a service along with its rating summary
+func (c *reviewCtx) ListOfServiceReviews(ctx context.Context, form *ServiceReviewListRequest) (*ServiceReviewListResponse, error) {
+	summary, err := c.reviewModel.GetServiceRatingSummary(ctx, form.ServiceID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, &handler.ServiceNotExists
+		}
+		log.Error().Err(fmt.Errorf("error when GetServiceRatingSummary : %w", err)).Send()
+		return nil, &handler.InternalServerError
+	}
+
+	ratingCounts, err := c.reviewModel.ListOfServiceRatingCounts(ctx, form.ServiceID)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when ListOfServiceRatingCounts : %w", err)).Send()
+		return nil, &handler.InternalServerError
+	}
+
+	// one more row than asked tells whether there is a next page
+	offset := (form.Page - 1) * form.Limit
+	res, err := c.reviewModel.ListOfServiceReviews(ctx, form.ServiceID, form.Sort, form.Limit+1, offset)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when ListOfServiceReviews : %w", err)).Send()
+		return nil, &handler.InternalServerError
+	}
+
+	response := &ServiceReviewListResponse{
+		Data: make([]ServiceReview, 0),
+		Summary: ServiceRatingSummary{
+			Rating:      summary.Rating,
+			ReviewCount: summary.ReviewCount,
+			Histogram:   ratingHistogram(ratingCounts),
+		},
+	}
+	if len(res) > form.Limit {
+		res = res[:form.Limit]
+		response.Pagination.NextPage = form.Page + 1
+	}
+
+	attachments, err := c.reviewAttachments(ctx, res)
+	if err != nil {
+		return nil, err
+	}
+	for i := range res {
+		review := serviceReview(&res[i])
+		review.Attachments = attachments[res[i].ID]
+		response.Data = append(response.Data, review)
+	}
+	return response, nil
+}
+
+// reviewAttachments loads the photos of every review in one go, keyed by review id
+func (c *reviewCtx) reviewAttachments(ctx context.Context, reviews []model.ServiceReviewBaseModel) (map[int][]AttachmentData, error) {
+	reviewIDs := make([]int, 0, len(reviews))
+	for _, v := range reviews {
+		reviewIDs = append(reviewIDs, v.ID)
+	}
+
+	res, err := c.attachmentModel.ListOfReviewAttachments(ctx, reviewIDs)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when ListOfReviewAttachments : %w", err)).Send()
+		return nil, &handler.InternalServerError
+	}
+
+	attachments := make(map[int][]AttachmentData, len(reviews))
+	for _, v := range reviews {
+		attachments[v.ID] = make([]AttachmentData, 0)
+	}
+	for i := range res {
+		reviewID := int(res[i].FeedbackID.Int64)
+		attachments[reviewID] = append(attachments[reviewID], attachmentData(c.mediaStore, &res[i]))
+	}
+	return attachments, nil
+}
+
+func serviceReview(v *model.ServiceReviewBaseModel) ServiceReview {
+	review := ServiceReview{
+		ID:        v.ID,
+		Reviewer:  v.UserName,
+		Rating:    v.Rating,
+		Feedback:  v.Feedback.String,
+		CreatedAt: v.CreatedAt.Format(time.RFC3339),
+	}
+	if v.UpdatedAt.Valid {
+		review.UpdatedAt = v.UpdatedAt.Time.Format(time.RFC3339)
+	}
+	if v.Reply.Valid {
+		review.Reply = &ReviewReply{
+			Body:      v.Reply.String,
+			CreatedAt: v.RepliedAt.Time.Format(time.RFC3339),
+		}
+	}
+	return review
+}
+
+// ratingHistogram lists every star from 1 to 5 so stars nobody gave show up as zero
+func ratingHistogram(counts []model.RatingCount) map[string]int {
+	histogram := make(map[string]int, 5)
+	for star := 1; star <= 5; star++ {
+		histogram[strconv.Itoa(star)] = 0
+	}
+	for _, v := range counts {
+		key := strconv.Itoa(v.Star)
+		if _, ok := histogram[key]; ok {
+			histogram[key] += v.Count
+		}
+	}
+	return histogram
+}
+
+func parseReviewID(reviewIDString string) (int, *handler.Fields) {
+	reviewID, err := strconv.Atoi(reviewIDString)
+	if err != nil || reviewID < 1 {
+		return reviewID, &handler.Fields{
+			Name:    "review_id",
+			Message: "review_id must be more than 0",
+		}
+	}
+	return reviewID, nil
+}
+
+func (req *UpdateServiceReviewRequest) ValidateUpdateServiceReviewRequest() ([]handler.Fields, error) {
+	var count int
+	var fields []handler.Fields
+	reviewID, fieldErr := parseReviewID(req.ReviewIDString)
+	if fieldErr != nil {
+		count++
+		fields = append(fields, *fieldErr)
+	}
+	req.ReviewID = reviewID
+
+	err := validator.ValidateRating(req.RatingString)
+	if err == nil {
+		req.Rating, err = strconv.ParseFloat(req.RatingString, 64)
+		if err == nil && (req.Rating < 0.1 || req.Rating > 5) {
+			err = fmt.Errorf("rating must be between 0.1 to 5")
+		}
+	}
+	if err != nil {
+		count++
+		fields = append(fields, handler.Fields{
+			Name:    "rating",
+			Message: err.Error(),
+		})
+	}
+
+	err = validator.ValidateFeedback(req.Feedback)
+	if err != nil {
+		count++
+		fields = append(fields, handler.Fields{
+			Name:    "feedback",
+			Message: err.Error(),
+		})
+	}
+
+	if count == 0 {
+		return nil, nil
+	}
+	return fields, errors.New(handler.ValidationFailed)
+}
+
+func (req *ServiceReviewIDRequest) ValidateServiceReviewIDRequest() ([]handler.Fields, error) {
+	reviewID, fieldErr := parseReviewID(req.ReviewIDString)
+	req.ReviewID = reviewID
+	if fieldErr != nil {
+		return []handler.Fields{*fieldErr}, errors.New(handler.ValidationFailed)
+	}
+	return nil, nil
+}
+
+func (req *ModerationQueueRequest) ValidateModerationQueueRequest() ([]handler.Fields, error) {
+	var count int
+	var fields []handler.Fields
+	if req.Status == "" {
+		req.Status = model.ModerationStatusPending
+	}
+	if req.Status != model.ModerationStatusPending && req.Status != model.ModerationStatusHidden {
+		count++
+		fields = append(fields, handler.Fields{
+			Name:    "status",
+			Message: "status must be pending or hidden",
+		})
+	}
+
+	req.Page = 1
+	if strings.TrimSpace(req.PageString) != "" {
+		page, err := strconv.Atoi(req.PageString)
+		if err != nil || page < 1 {
+			count++
+			fields = append(fields, handler.Fields{
+				Name:    "page",
+				Message: "page must be more than 0",
+			})
+		}
+		req.Page = page
+	}
+
+	req.Limit = 20
+	if strings.TrimSpace(req.LimitString) != "" {
+		limit, err := strconv.Atoi(req.LimitString)
+		if err != nil || limit < 1 || limit > 100 {
+			count++
+			fields = append(fields, handler.Fields{
+				Name:    "limit",
+				Message: "limit must be between 1 and 100",
+			})
+		}
+		req.Limit = limit
+	}
+
+	if count == 0 {
+		return nil, nil
+	}
+	return fields, errors.New(handler.ValidationFailed)
+}
+
+func (req *ReviewModerationRequest) ValidateReviewModerationRequest() ([]handler.Fields, error) {
+	var count int
+	var fields []handler.Fields
+	reviewID, fieldErr := parseReviewID(req.ReviewIDString)
+	if fieldErr != nil {
+		count++
+		fields = append(fields, *fieldErr)
+	}
+	req.ReviewID = reviewID
+
+	// approving only clears the review from the queue, hiding and restoring have to be explained
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Action != model.ModerationActionApprove {
+		err := validator.ValidateModerationReason(req.Reason)
+		if err != nil {
+			count++
+			fields = append(fields, handler.Fields{
+				Name:    "reason",
+				Message: err.Error(),
+			})
+		}
+	}
+
+	if count == 0 {
+		return nil, nil
+	}
+	return fields, errors.New(handler.ValidationFailed)
+}
+
+func (req *ReviewReplyRequest) ValidateReviewReplyRequest() ([]handler.Fields, error) {
+	var count int
+	var fields []handler.Fields
+	reviewID, fieldErr := parseReviewID(req.ReviewIDString)
+	if fieldErr != nil {
+		count++
+		fields = append(fields, *fieldErr)
+	}
+	req.ReviewID = reviewID
+
+	req.Reply = strings.TrimSpace(req.Reply)
+	err := validator.ValidateReviewReply(req.Reply)
+	if err != nil {
+		count++
+		fields = append(fields, handler.Fields{
+			Name:    "reply",
+			Message: err.Error(),
+		})
+	}
+
+	if count == 0 {
+		return nil, nil
+	}
+	return fields, errors.New(handler.ValidationFailed)
+}
+
+// authorizeReviewChange lets only the author change the review and only within the edit window,
+// reviews of other users are reported as missing so their ids cannot be probed
+func authorizeReviewChange(review *model.ServiceReviewBaseModel, userID string, now time.Time, window time.Duration) error {
+	if review.UserID != userID {
+		return &handler.ReviewNotFound
+	}
+	if now.Sub(review.CreatedAt) > window {
+		return &handler.ReviewEditWindowClosed
+	}
+	return nil
+}
+
+func (c *reviewCtx) ownServiceReview(ctx context.Context, userID string, reviewID int) error {
+	review, err := c.reviewModel.GetServiceReviewByID(ctx, reviewID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return &handler.ReviewNotFound
+		}
+		log.Error().Err(fmt.Errorf("error when GetServiceReviewByID : %w", err)).Send()
+		return &handler.InternalServerError
+	}
+	return authorizeReviewChange(review, userID, time.Now(), c.editWindow)
+}
+
+func (c *reviewCtx) UpdateServiceReview(ctx context.Context, userID string, form *UpdateServiceReviewRequest) error {
+	err := c.ownServiceReview(ctx, userID, form.ReviewID)
+	if err != nil {
+		return err
+	}
+
+	updated, err := c.reviewModel.UpdateServiceReview(ctx, &model.ServiceReviewBaseModel{
+		ID:        form.ReviewID,
+		Rating:    form.Rating,
+		Feedback:  sql.NullString{String: form.Feedback, Valid: form.Feedback != ""},
+		UpdatedAt: sql.NullTime{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when UpdateServiceReview : %w", err)).Send()
+		return &handler.InternalServerError
+	}
+
+	if !updated {
+		return &handler.ReviewNotFound
+	}
+	return nil
+}
+
+func (c *reviewCtx) DeleteServiceReview(ctx context.Context, userID string, form *ServiceReviewIDRequest) error {
+	err := c.ownServiceReview(ctx, userID, form.ReviewID)
+	if err != nil {
+		return err
+	}
+
+	// the attachment rows go along with the review, their files have to be removed here
+	attachments, err := c.attachmentModel.ListOfReviewAttachments(ctx, []int{form.ReviewID})
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when ListOfReviewAttachments : %w", err)).Send()
+		return &handler.InternalServerError
+	}
+
+	deleted, err := c.reviewModel.DeleteServiceReview(ctx, form.ReviewID)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when DeleteServiceReview : %w", err)).Send()
+		return &handler.InternalServerError
+	}
+
+	if !deleted {
+		return &handler.ReviewNotFound
+	}
+	deleteAttachmentFiles(ctx, c.mediaStore, attachments)
+	return nil
+}
+
+func (c *reviewCtx) ListOfModerationQueue(ctx context.Context, form *ModerationQueueRequest) (*ModerationQueueResponse, error) {
+	// one more row than asked tells whether there is a next page
+	offset := (form.Page - 1) * form.Limit
+	res, err := c.reviewModel.ListOfModerationQueue(ctx, form.Status, form.Limit+1, offset)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when ListOfModerationQueue : %w", err)).Send()
+		return nil, &handler.InternalServerError
+	}
+
+	response := &ModerationQueueResponse{
+		Data: make([]ModeratedReview, 0),
+	}
+	if len(res) > form.Limit {
+		res = res[:form.Limit]
+		response.Pagination.NextPage = form.Page + 1
+	}
+
+	attachments, err := c.reviewAttachments(ctx, res)
+	if err != nil {
+		return nil, err
+	}
+	for i := range res {
+		review := ModeratedReview{
+			ServiceReview: serviceReview(&res[i]),
+			ServiceID:     res[i].ServiceID,
+			OrderID:       res[i].OrderID,
+			HiddenReason:  res[i].HiddenReason.String,
+		}
+		review.Attachments = attachments[res[i].ID]
+		if res[i].HiddenAt.Valid {
+			review.HiddenAt = res[i].HiddenAt.Time.Format(time.RFC3339)
+		}
+		response.Data = append(response.Data, review)
+	}
+	return response, nil
+}
+
+// ModerateServiceReview hides, restores or approves the review, hidden reviews stop counting toward the service rating
+func (c *reviewCtx) ModerateServiceReview(ctx context.Context, moderatorID string, form *ReviewModerationRequest) error {
+	_, err := c.reviewModel.GetServiceReviewByID(ctx, form.ReviewID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return &handler.ReviewNotFound
+		}
+		log.Error().Err(fmt.Errorf("error when GetServiceReviewByID : %w", err)).Send()
+		return &handler.InternalServerError
+	}
+
+	moderated, err := c.reviewModel.ModerateServiceReview(ctx, &model.ReviewModerationBaseModel{
+		FeedbackID:  form.ReviewID,
+		ModeratorID: moderatorID,
+		Action:      form.Action,
+		Reason:      form.Reason,
+		CreatedAt:   time.Now(),
+	})
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when ModerateServiceReview : %w", err)).Send()
+		return &handler.InternalServerError
+	}
+
+	if !moderated {
+		return &handler.ReviewModerationConflict
+	}
+	return nil
+}
+
+// ReplyServiceReview attaches the official workshop reply to the review
+func (c *reviewCtx) ReplyServiceReview(ctx context.Context, form *ReviewReplyRequest) error {
+	replied, err := c.reviewModel.SetServiceReviewReply(ctx, form.ReviewID, form.Reply, time.Now())
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when SetServiceReviewReply : %w", err)).Send()
+		return &handler.InternalServerError
+	}
+
+	if !replied {
+		return &handler.ReviewNotFound
+	}
+	return nil
+}
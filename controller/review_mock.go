@@ -0,0 +1,53 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockReviewController struct {
+	mock.Mock
+}
+
+func (m *MockReviewController) AddServiceReview(ctx context.Context, userID string, form *ReviewBaseModel) error {
+	args := m.Called(ctx, userID, form)
+	return args.Error(0)
+}
+
+func (m *MockReviewController) AddMechanicReview(ctx context.Context, userID string, form *MechanicReviewRequest) error {
+	args := m.Called(ctx, userID, form)
+	return args.Error(0)
+}
+
+func (m *MockReviewController) ListOfServiceReviews(ctx context.Context, form *ServiceReviewListRequest) (*ServiceReviewListResponse, error) {
+	args := m.Called(ctx, form)
+	res, _ := args.Get(0).(*ServiceReviewListResponse)
+	return res, args.Error(1)
+}
+
+func (m *MockReviewController) UpdateServiceReview(ctx context.Context, userID string, form *UpdateServiceReviewRequest) error {
+	args := m.Called(ctx, userID, form)
+	return args.Error(0)
+}
+
+func (m *MockReviewController) DeleteServiceReview(ctx context.Context, userID string, form *ServiceReviewIDRequest) error {
+	args := m.Called(ctx, userID, form)
+	return args.Error(0)
+}
+
+func (m *MockReviewController) ListOfModerationQueue(ctx context.Context, form *ModerationQueueRequest) (*ModerationQueueResponse, error) {
+	args := m.Called(ctx, form)
+	res, _ := args.Get(0).(*ModerationQueueResponse)
+	return res, args.Error(1)
+}
+
+func (m *MockReviewController) ModerateServiceReview(ctx context.Context, moderatorID string, form *ReviewModerationRequest) error {
+	args := m.Called(ctx, moderatorID, form)
+	return args.Error(0)
+}
+
+func (m *MockReviewController) ReplyServiceReview(ctx context.Context, form *ReviewReplyRequest) error {
+	args := m.Called(ctx, form)
+	return args.Error(0)
+}
@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"e-montir/api/handler"
+	"e-montir/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRatingHistogram(t *testing.T) {
+	t.Run("stars without reviews are zero", func(t *testing.T) {
+		histogram := ratingHistogram(nil)
+		assert.Equal(t, map[string]int{"1": 0, "2": 0, "3": 0, "4": 0, "5": 0}, histogram)
+	})
+
+	t.Run("counts are placed under their star", func(t *testing.T) {
+		histogram := ratingHistogram([]model.RatingCount{
+			{Star: 5, Count: 7},
+			{Star: 3, Count: 2},
+			{Star: 9, Count: 1},
+		})
+		assert.Equal(t, map[string]int{"1": 0, "2": 0, "3": 2, "4": 0, "5": 7}, histogram)
+	})
+}
+
+func TestValidateServiceReviewListRequest(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		req := &ServiceReviewListRequest{ServiceIDString: "3"}
+		fields, err := req.ValidateServiceReviewListRequest()
+		assert.NoError(t, err)
+		assert.Nil(t, fields)
+		assert.Equal(t, 3, req.ServiceID)
+		assert.Equal(t, 1, req.Page)
+		assert.Equal(t, 10, req.Limit)
+		assert.Equal(t, "newest", req.Sort)
+	})
+
+	t.Run("invalid values", func(t *testing.T) {
+		req := &ServiceReviewListRequest{ServiceIDString: "x", PageString: "0", LimitString: "51", Sort: "oldest"}
+		fields, err := req.ValidateServiceReviewListRequest()
+		assert.Error(t, err)
+		assert.Len(t, fields, 4)
+	})
+}
+
+func TestAuthorizeReviewChange(t *testing.T) {
+	now := time.Date(2022, 1, 10, 12, 0, 0, 0, time.UTC)
+	review := &model.ServiceReviewBaseModel{UserID: "user-1", CreatedAt: now.Add(-time.Hour)}
+
+	t.Run("author within the window", func(t *testing.T) {
+		assert.NoError(t, authorizeReviewChange(review, "user-1", now, 72*time.Hour))
+	})
+
+	t.Run("author after the window", func(t *testing.T) {
+		err := authorizeReviewChange(review, "user-1", now, 30*time.Minute)
+		assert.Equal(t, &handler.ReviewEditWindowClosed, err)
+	})
+
+	t.Run("other user", func(t *testing.T) {
+		err := authorizeReviewChange(review, "user-2", now, 72*time.Hour)
+		assert.Equal(t, &handler.ReviewNotFound, err)
+	})
+}
+
+func TestValidateReviewModerationRequest(t *testing.T) {
+	t.Run("hiding needs a reason", func(t *testing.T) {
+		req := &ReviewModerationRequest{ReviewIDString: "1", Action: model.ModerationActionHide, Reason: "  "}
+		fields, err := req.ValidateReviewModerationRequest()
+		assert.Error(t, err)
+		assert.Len(t, fields, 1)
+	})
+
+	t.Run("approving does not", func(t *testing.T) {
+		req := &ReviewModerationRequest{ReviewIDString: "1", Action: model.ModerationActionApprove}
+		_, err := req.ValidateReviewModerationRequest()
+		assert.NoError(t, err)
+		assert.Equal(t, 1, req.ReviewID)
+	})
+}
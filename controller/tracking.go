@@ -0,0 +1,236 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"e-montir/api/handler"
+	"e-montir/model"
+	"e-montir/pkg/assignment"
+	"e-montir/pkg/tracking"
+	"e-montir/pkg/validator"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+type trackingCtx struct {
+	trackingModel model.Tracking
+	mechanicModel model.Mechanic
+	orderModel    model.Order
+	broker        *tracking.Broker
+}
+
+type Tracking interface {
+	UpdateLocation(ctx context.Context, userID string, form *LocationRequest) error
+	TrackOrder(ctx context.Context, userID, orderID string) (*TrackingSubscription, error)
+}
+
+func NewTracking(trackingModel model.Tracking, mechanicModel model.Mechanic, orderModel model.Order, broker *tracking.Broker) Tracking {
+	return &trackingCtx{
+		trackingModel: trackingModel,
+		mechanicModel: mechanicModel,
+		orderModel:    orderModel,
+		broker:        broker,
+	}
+}
+
+type (
+	LocationRequest struct {
+		Latitude  float64 `json:"latitude"`
+		Longitude float64 `json:"longitude"`
+	}
+
+	TrackingPosition struct {
+		Latitude   float64 `json:"latitude"`
+		Longitude  float64 `json:"longitude"`
+		RecordedAt string  `json:"recorded_at"`
+	}
+
+	TrackingEvent struct {
+		OrderID          string            `json:"order_id"`
+		Status           string            `json:"status_order"`
+		Position         *TrackingPosition `json:"position,omitempty"`
+		DistanceKm       *float64          `json:"distance_km,omitempty"`
+		ETASeconds       *int              `json:"eta_seconds,omitempty"`
+		EstimatedArrival string            `json:"estimated_arrival,omitempty"`
+	}
+
+	// TrackingSubscription streams the updates of an order until Close is called
+	TrackingSubscription struct {
+		Initial TrackingEvent
+		Updates <-chan tracking.Update
+		Close   func()
+
+		orderID        string
+		destination    *TrackingPosition
+		lastStatus     string
+		averageSpeedKm float64
+	}
+)
+
+func (req *LocationRequest) ValidateLocationRequest() ([]handler.Fields, error) {
+	var count int
+	var fields []handler.Fields
+	err := validator.ValidateLatitude(req.Latitude)
+	if err != nil {
+		count++
+		fields = append(fields, handler.Fields{
+			Name:    "latitude",
+			Message: err.Error(),
+		})
+	}
+
+	err = validator.ValidateLongitude(req.Longitude)
+	if err != nil {
+		count++
+		fields = append(fields, handler.Fields{
+			Name:    "longitude",
+			Message: err.Error(),
+		})
+	}
+
+	if count == 0 {
+		return nil, nil
+	}
+	return fields, errors.New(handler.ValidationFailed)
+}
+
+// UpdateLocation stores the position pushed by the mechanic app and forwards it to the
+// customers tracking the orders the mechanic is on the way to.
+func (c *trackingCtx) UpdateLocation(ctx context.Context, userID string, form *LocationRequest) error {
+	mechanic, err := mechanicOfUser(ctx, c.mechanicModel, userID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	orderIDs, err := c.trackingModel.SetMechanicLocation(ctx, mechanic.ID, form.Latitude, form.Longitude, now)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when SetMechanicLocation : %w", err)).Send()
+		return &handler.InternalServerError
+	}
+
+	for _, orderID := range orderIDs {
+		c.broker.Publish(orderID, tracking.Update{
+			Status:      model.OrderStatus[3],
+			Latitude:    form.Latitude,
+			Longitude:   form.Longitude,
+			HasPosition: true,
+			RecordedAt:  now,
+		})
+	}
+	return nil
+}
+
+// TrackOrder subscribes the owner of the order to the mechanic position, the initial
+// event carries the last known position so a reconnecting client is up to date.
+func (c *trackingCtx) TrackOrder(ctx context.Context, userID, orderID string) (*TrackingSubscription, error) {
+	order, err := authorizeOrder(ctx, c.orderModel, userID, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := order.OrderStatus.String
+	if status != model.OrderStatus[2] && status != model.OrderStatus[3] {
+		return nil, &handler.OrderNotTrackable
+	}
+
+	location, err := c.orderModel.OrderLocation(ctx, order.UserAddressID)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when getOrderLocation : %w", err)).Send()
+		return nil, &handler.InternalServerError
+	}
+
+	sub := &TrackingSubscription{
+		orderID:        orderID,
+		lastStatus:     status,
+		averageSpeedKm: averageTravelSpeed(),
+	}
+	latitude, latErr := strconv.ParseFloat(location.Latitude, 64)
+	longitude, longErr := strconv.ParseFloat(location.Longitude, 64)
+	if latErr == nil && longErr == nil {
+		sub.destination = &TrackingPosition{
+			Latitude:  latitude,
+			Longitude: longitude,
+		}
+	}
+
+	// subscribe before reading the last position so no update falls in between
+	sub.Updates, sub.Close = c.broker.Subscribe(orderID)
+
+	initial := tracking.Update{Status: status}
+	if status == model.OrderStatus[3] {
+		position, err := c.trackingModel.GetOrderTracking(ctx, orderID)
+		if err != nil && err != sql.ErrNoRows {
+			sub.Close()
+			log.Error().Err(fmt.Errorf("error when GetOrderTracking : %w", err)).Send()
+			return nil, &handler.InternalServerError
+		}
+		if err == nil {
+			initial.Latitude = position.Latitude
+			initial.Longitude = position.Longitude
+			initial.HasPosition = true
+			initial.RecordedAt = position.RecordedAt
+		}
+	}
+	sub.Initial = sub.Event(initial)
+	return sub, nil
+}
+
+// Event turns an update into what the customer sees, including the distance left and the ETA
+func (s *TrackingSubscription) Event(update tracking.Update) TrackingEvent {
+	if update.Status != "" {
+		s.lastStatus = update.Status
+	}
+	event := TrackingEvent{
+		OrderID: s.orderID,
+		Status:  s.lastStatus,
+	}
+	if !update.HasPosition {
+		return event
+	}
+
+	event.Position = &TrackingPosition{
+		Latitude:   update.Latitude,
+		Longitude:  update.Longitude,
+		RecordedAt: update.RecordedAt.UTC().Format(time.RFC3339),
+	}
+	if s.destination == nil {
+		return event
+	}
+
+	distance := assignment.Distance(update.Latitude, update.Longitude, s.destination.Latitude, s.destination.Longitude)
+	distance = math.Round(distance*100) / 100
+	eta := estimateArrival(distance, s.averageSpeedKm)
+	etaSeconds := int(eta.Seconds())
+	event.DistanceKm = &distance
+	event.ETASeconds = &etaSeconds
+	event.EstimatedArrival = update.RecordedAt.Add(eta).UTC().Format(time.RFC3339)
+	return event
+}
+
+// Finished reports whether the order is past the part of the journey worth tracking
+func (e TrackingEvent) Finished() bool {
+	return e.Status != model.OrderStatus[2] && e.Status != model.OrderStatus[3]
+}
+
+func estimateArrival(distanceKm, averageSpeedKm float64) time.Duration {
+	if averageSpeedKm <= 0 {
+		return 0
+	}
+	return time.Duration(distanceKm / averageSpeedKm * float64(time.Hour)).Round(time.Second)
+}
+
+// averageTravelSpeed is the speed in km/h used to estimate the arrival of the mechanic
+func averageTravelSpeed() float64 {
+	speed, err := strconv.ParseFloat(os.Getenv("TRACKING_AVERAGE_SPEED"), 64)
+	if err != nil || speed <= 0 {
+		return 30
+	}
+	return speed
+}
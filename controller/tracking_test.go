@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"e-montir/model"
+	"e-montir/pkg/tracking"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrackingSubscriptionEvent(t *testing.T) {
+	recordedAt := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+	sub := &TrackingSubscription{
+		orderID:        "order-1",
+		destination:    &TrackingPosition{Latitude: -6.1950, Longitude: 106.8230},
+		lastStatus:     model.OrderStatus[2],
+		averageSpeedKm: 30,
+	}
+
+	event := sub.Event(tracking.Update{Status: model.OrderStatus[3]})
+	assert.Equal(t, model.OrderStatus[3], event.Status)
+	assert.Nil(t, event.Position)
+	assert.Nil(t, event.ETASeconds)
+	assert.False(t, event.Finished())
+
+	// about 2.2 km away at 30 km/h
+	event = sub.Event(tracking.Update{Latitude: -6.1754, Longitude: 106.8272, HasPosition: true, RecordedAt: recordedAt})
+	assert.Equal(t, model.OrderStatus[3], event.Status)
+	assert.Equal(t, "2022-06-01T10:00:00Z", event.Position.RecordedAt)
+	assert.InDelta(t, 2.2, *event.DistanceKm, 0.1)
+	assert.InDelta(t, 264, *event.ETASeconds, 15)
+	assert.Equal(t, recordedAt.Add(time.Duration(*event.ETASeconds)*time.Second).Format(time.RFC3339), event.EstimatedArrival)
+
+	event = sub.Event(tracking.Update{Status: model.OrderStatus[4]})
+	assert.True(t, event.Finished())
+}
+
+func TestTrackingSubscriptionEventWithoutDestination(t *testing.T) {
+	sub := &TrackingSubscription{
+		orderID:        "order-1",
+		lastStatus:     model.OrderStatus[3],
+		averageSpeedKm: 30,
+	}
+
+	event := sub.Event(tracking.Update{Latitude: -6.1754, Longitude: 106.8272, HasPosition: true, RecordedAt: time.Now()})
+	assert.NotNil(t, event.Position)
+	assert.Nil(t, event.DistanceKm)
+	assert.Empty(t, event.EstimatedArrival)
+}
+
+func TestEstimateArrival(t *testing.T) {
+	assert.Equal(t, 30*time.Minute, estimateArrival(15, 30))
+	assert.Equal(t, time.Duration(0), estimateArrival(15, 0))
+}
@@ -8,22 +8,27 @@ import (
 	"e-montir/pkg/validator"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/rs/zerolog/log"
 )
 
 type userCtx struct {
-	userModel model.User
+	userModel   model.User
+	deviceModel model.Device
 }
 
 type User interface {
 	AddUserLocation(ctx context.Context, userID string, form *AddUserAddressRequest) error
 	ListOfUserLocation(ctx context.Context, userID string) (*ListOfUserAddresses, error)
+	RegisterDevice(ctx context.Context, userID string, form *RegisterDeviceRequest) error
+	RemoveDevice(ctx context.Context, userID string, form *RemoveDeviceRequest) error
 }
 
-func NewUser(userModel model.User) User {
+func NewUser(userModel model.User, deviceModel model.Device) User {
 	return &userCtx{
-		userModel: userModel,
+		userModel:   userModel,
+		deviceModel: deviceModel,
 	}
 }
 
@@ -60,6 +65,16 @@ type (
 	ListOfUserAddresses struct {
 		Address []UserAddressResponse `json:"addresses"`
 	}
+
+	RegisterDeviceRequest struct {
+		Token      string `json:"token"`
+		Platform   string `json:"platform"`
+		AppVersion string `json:"app_version"`
+	}
+
+	RemoveDeviceRequest struct {
+		Token string `json:"token"`
+	}
 )
 
 func (req *AddUserAddressRequest) ValidateAddUserLocation() ([]handler.Fields, error) {
@@ -108,6 +123,51 @@ func (req *AddUserAddressRequest) ValidateAddUserLocation() ([]handler.Fields, e
 	return fields, errors.New(handler.ValidationFailed)
 }
 
+func (req *RegisterDeviceRequest) ValidateRegisterDevice() ([]handler.Fields, error) {
+	var fields []handler.Fields
+	var count int
+
+	err := validator.ValidateDeviceToken(req.Token)
+	if err != nil {
+		count++
+		fields = append(fields, handler.Fields{
+			Name:    "token",
+			Message: err.Error(),
+		})
+	}
+
+	err = validator.ValidateDevicePlatform(req.Platform)
+	if err != nil {
+		count++
+		fields = append(fields, handler.Fields{
+			Name:    "platform",
+			Message: err.Error(),
+		})
+	}
+
+	err = validator.ValidateAppVersion(req.AppVersion)
+	if err != nil {
+		count++
+		fields = append(fields, handler.Fields{
+			Name:    "app_version",
+			Message: err.Error(),
+		})
+	}
+
+	if count == 0 {
+		return nil, nil
+	}
+	return fields, errors.New(handler.ValidationFailed)
+}
+
+func (req *RemoveDeviceRequest) ValidateRemoveDevice() ([]handler.Fields, error) {
+	err := validator.ValidateDeviceToken(req.Token)
+	if err != nil {
+		return []handler.Fields{{Name: "token", Message: err.Error()}}, errors.New(handler.ValidationFailed)
+	}
+	return nil, nil
+}
+
 func (c *userCtx) AddUserLocation(ctx context.Context, userID string, form *AddUserAddressRequest) error {
 	locationID, err := uuid.GenerateUUID()
 	if err != nil {
@@ -157,3 +217,33 @@ func (c *userCtx) ListOfUserLocation(ctx context.Context, userID string) (*ListO
 		Address: listOfAddress,
 	}, nil
 }
+
+// RegisterDevice adds the device to the ones the user is pushed to, registering a known device
+// again only refreshes it and keeps it active.
+func (c *userCtx) RegisterDevice(ctx context.Context, userID string, form *RegisterDeviceRequest) error {
+	err := c.deviceModel.SetDevice(ctx, &model.DeviceBaseModel{
+		UserID:     userID,
+		Token:      form.Token,
+		Platform:   form.Platform,
+		AppVersion: form.AppVersion,
+		LastSeenAt: time.Now(),
+	})
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when SetDevice: %w", err)).Send()
+		return &handler.InternalServerError
+	}
+	return nil
+}
+
+// RemoveDevice stops pushing to the device, e.g. when the user logs out of the app there
+func (c *userCtx) RemoveDevice(ctx context.Context, userID string, form *RemoveDeviceRequest) error {
+	removed, err := c.deviceModel.RemoveDevice(ctx, userID, form.Token)
+	if err != nil {
+		log.Error().Err(fmt.Errorf("error when RemoveDevice: %w", err)).Send()
+		return &handler.InternalServerError
+	}
+	if !removed {
+		return &handler.DeviceNotFound
+	}
+	return nil
+}
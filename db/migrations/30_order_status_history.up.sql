@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS "order_status_history"(
+    "id" SERIAL NOT NULL,
+    "order_id" UUID NOT NULL,
+    "from_status" VARCHAR(64),
+    "to_status" VARCHAR(64) NOT NULL,
+    "actor" VARCHAR(64) NOT NULL,
+    "created_at" TIMESTAMP NOT NULL,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_order_id" FOREIGN KEY ("order_id") REFERENCES "orders" ("id") ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS "order_status_history_order_id" ON "order_status_history" ("order_id");
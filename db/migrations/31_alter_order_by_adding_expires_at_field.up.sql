@@ -0,0 +1,7 @@
+ALTER TABLE "orders" 
+    ADD COLUMN "expires_at" TIMESTAMP;
+
+ALTER TABLE "order_status_history" 
+    ADD COLUMN "reason" VARCHAR(256);
+
+CREATE INDEX IF NOT EXISTS "order_status_order_expires_at" ON "orders" ("status_order", "expires_at");
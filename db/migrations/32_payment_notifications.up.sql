@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS "payment_notifications"(
+    "id" SERIAL NOT NULL,
+    "order_id" UUID NOT NULL,
+    "transaction_id" VARCHAR(128),
+    "transaction_status" VARCHAR(64) NOT NULL,
+    "status_code" VARCHAR(16),
+    "gross_amount" VARCHAR(32),
+    "signature_key" VARCHAR(256) NOT NULL,
+    "payload" TEXT NOT NULL,
+    "processed_at" TIMESTAMP,
+    "created_at" TIMESTAMP NOT NULL,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_order_id" FOREIGN KEY ("order_id") REFERENCES "orders" ("id") ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS "payment_notifications_order_id_status" ON "payment_notifications" ("order_id", "transaction_status");
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS "payments"(
+    "id" SERIAL NOT NULL,
+    "order_id" UUID NOT NULL,
+    "transaction_id" VARCHAR(128),
+    "gateway_token" VARCHAR(256),
+    "redirect_url" VARCHAR(512),
+    "amount" FLOAT NOT NULL,
+    "currency" VARCHAR(8) NOT NULL,
+    "payment_type" VARCHAR(64),
+    "va_numbers" TEXT,
+    "status" VARCHAR(64) NOT NULL,
+    "created_at" TIMESTAMP NOT NULL,
+    "updated_at" TIMESTAMP NOT NULL,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_order_id" FOREIGN KEY ("order_id") REFERENCES "orders" ("id") ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS "payments_order_id" ON "payments" ("order_id");
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS "refunds"(
+    "id" SERIAL NOT NULL,
+    "order_id" UUID NOT NULL,
+    "refund_key" VARCHAR(128) UNIQUE NOT NULL,
+    "amount" FLOAT NOT NULL,
+    "reason" VARCHAR(256),
+    "status" VARCHAR(32) NOT NULL,
+    "requested_by" VARCHAR(64) NOT NULL,
+    "failure_reason" VARCHAR(512),
+    "created_at" TIMESTAMP NOT NULL,
+    "updated_at" TIMESTAMP NOT NULL,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_order_id" FOREIGN KEY ("order_id") REFERENCES "orders" ("id") ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS "refunds_order_id" ON "refunds" ("order_id");
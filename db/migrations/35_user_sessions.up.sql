@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS "user_sessions"(
+    "id" UUID NOT NULL,
+    "user_id" UUID NOT NULL,
+    "refresh_token_hash" VARCHAR(64) UNIQUE NOT NULL,
+    "previous_token_hash" VARCHAR(64),
+    "access_token_id" UUID UNIQUE NOT NULL,
+    "expires_at" TIMESTAMP NOT NULL,
+    "revoked_at" TIMESTAMP,
+    "created_at" TIMESTAMP NOT NULL,
+    "updated_at" TIMESTAMP NOT NULL,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_user_id" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS "user_sessions_user_id" ON "user_sessions" ("user_id");
+CREATE INDEX IF NOT EXISTS "user_sessions_previous_token_hash" ON "user_sessions" ("previous_token_hash");
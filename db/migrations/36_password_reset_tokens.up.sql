@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS "password_reset_tokens"(
+    "id" SERIAL NOT NULL,
+    "user_id" UUID NOT NULL,
+    "token_hash" VARCHAR(64) UNIQUE NOT NULL,
+    "expires_at" TIMESTAMP NOT NULL,
+    "used_at" TIMESTAMP,
+    "created_at" TIMESTAMP NOT NULL,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_user_id" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS "password_reset_tokens_user_id" ON "password_reset_tokens" ("user_id");
@@ -0,0 +1,6 @@
+ALTER TABLE "mechanics" 
+    ADD COLUMN IF NOT EXISTS "user_id" UUID UNIQUE,
+    ADD CONSTRAINT "fk_user_id" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE SET NULL;
+
+ALTER TABLE "orders" 
+    ADD COLUMN IF NOT EXISTS "accepted_at" TIMESTAMP;
@@ -0,0 +1,6 @@
+ALTER TABLE "mechanics" 
+    ADD COLUMN IF NOT EXISTS "home_latitude" DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS "home_longitude" DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS "latitude" DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS "longitude" DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS "location_updated_at" TIMESTAMP;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS "mechanic_skills"(
+    "mechanic_id" INT NOT NULL,
+    "category" VARCHAR(128) NOT NULL,
+    PRIMARY KEY ("mechanic_id", "category"),
+    CONSTRAINT "fk_mechanic_id" FOREIGN KEY ("mechanic_id") REFERENCES "mechanics" ("id") ON DELETE CASCADE,
+    CONSTRAINT "fk_category" FOREIGN KEY ("category") REFERENCES "categories" ("category") ON DELETE CASCADE
+);
+
+-- the existing mechanics keep handling every category
+INSERT INTO "mechanic_skills" ("mechanic_id", "category")
+SELECT "mechanics"."id", "categories"."category" FROM "mechanics" CROSS JOIN "categories"
+ON CONFLICT DO NOTHING;
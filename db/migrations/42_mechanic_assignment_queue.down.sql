@@ -0,0 +1 @@
+DROP TABLE IF EXISTS "mechanic_assignment_queue";
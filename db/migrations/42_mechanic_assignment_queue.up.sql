@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS "mechanic_assignment_queue"(
+    "order_id" UUID NOT NULL,
+    "reason" VARCHAR(256) NOT NULL,
+    "attempts" INT NOT NULL DEFAULT 1,
+    "created_at" TIMESTAMP NOT NULL,
+    "updated_at" TIMESTAMP NOT NULL,
+    PRIMARY KEY ("order_id"),
+    CONSTRAINT "fk_order_id" FOREIGN KEY ("order_id") REFERENCES "orders" ("id") ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS "mechanic_assignment_queue_created_at" ON "mechanic_assignment_queue" ("created_at");
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS "order_trackings"(
+    "order_id" UUID NOT NULL,
+    "mechanic_id" INT NOT NULL,
+    "latitude" DOUBLE PRECISION NOT NULL,
+    "longitude" DOUBLE PRECISION NOT NULL,
+    "recorded_at" TIMESTAMP NOT NULL,
+    PRIMARY KEY ("order_id"),
+    CONSTRAINT "fk_order_id" FOREIGN KEY ("order_id") REFERENCES "orders" ("id") ON DELETE CASCADE,
+    CONSTRAINT "fk_mechanic_id" FOREIGN KEY ("mechanic_id") REFERENCES "mechanics" ("id") ON DELETE CASCADE
+);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS "order_messages"(
+    "id" UUID NOT NULL,
+    "order_id" UUID NOT NULL,
+    "sender_id" UUID NOT NULL,
+    "sender_role" VARCHAR(16) NOT NULL,
+    "body" VARCHAR(1000),
+    "attachment" VARCHAR(256),
+    "read_at" TIMESTAMP,
+    "created_at" TIMESTAMP NOT NULL,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_order_id" FOREIGN KEY ("order_id") REFERENCES "orders" ("id") ON DELETE CASCADE,
+    CONSTRAINT "fk_sender_id" FOREIGN KEY ("sender_id") REFERENCES "users" ("id") ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS "order_messages_order_id_created_at" ON "order_messages" ("order_id", "created_at");
@@ -0,0 +1,3 @@
+ALTER TABLE "mechanics" 
+    ADD COLUMN IF NOT EXISTS "rating" FLOAT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS "review_count" INT NOT NULL DEFAULT 0;
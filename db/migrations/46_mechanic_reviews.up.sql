@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS "mechanic_reviews"(
+    "id" SERIAL NOT NULL,
+    "order_id" UUID NOT NULL,
+    "mechanic_id" INT NOT NULL,
+    "user_id" UUID NOT NULL,
+    "rating" FLOAT NOT NULL,
+    "feedback" VARCHAR(300),
+    "created_at" TIMESTAMP NOT NULL,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uniq_mechanic_review_order_id" UNIQUE ("order_id"),
+    CONSTRAINT "fk_order_id" FOREIGN KEY ("order_id") REFERENCES "orders" ("id") ON DELETE CASCADE,
+    CONSTRAINT "fk_mechanic_id" FOREIGN KEY ("mechanic_id") REFERENCES "mechanics" ("id") ON DELETE CASCADE,
+    CONSTRAINT "fk_user_id" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS "mechanic_reviews_mechanic_id_created_at" ON "mechanic_reviews" ("mechanic_id", "created_at");
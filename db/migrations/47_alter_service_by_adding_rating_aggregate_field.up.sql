@@ -0,0 +1,13 @@
+ALTER TABLE "services" 
+    ADD COLUMN IF NOT EXISTS "rating_sum" FLOAT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS "review_count" INT NOT NULL DEFAULT 0;
+
+-- the rating of a reviewed service is the average of all its reviews, services without reviews keep the seeded rating
+WITH "aggregate" AS (
+    SELECT "service_id", SUM("rating") AS "rating_sum", COUNT(*) AS "review_count" FROM "feedbacks" GROUP BY "service_id"
+)
+UPDATE "services" SET
+    "rating_sum" = "aggregate"."rating_sum",
+    "review_count" = "aggregate"."review_count",
+    "rating" = round(("aggregate"."rating_sum" / "aggregate"."review_count")::numeric, 2)
+FROM "aggregate" WHERE "services"."id" = "aggregate"."service_id";
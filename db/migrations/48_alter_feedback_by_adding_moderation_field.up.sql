@@ -0,0 +1,9 @@
+ALTER TABLE "feedbacks" 
+    ADD COLUMN IF NOT EXISTS "updated_at" TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS "hidden_at" TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS "hidden_reason" VARCHAR(256),
+    ADD COLUMN IF NOT EXISTS "moderated_at" TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS "reply" VARCHAR(500),
+    ADD COLUMN IF NOT EXISTS "replied_at" TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS "feedbacks_service_id_created_at" ON "feedbacks" ("service_id", "created_at");
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS "review_moderations"(
+    "id" SERIAL NOT NULL,
+    "feedback_id" INT NOT NULL,
+    "moderator_id" UUID NOT NULL,
+    "action" VARCHAR(16) NOT NULL,
+    "reason" VARCHAR(256) NOT NULL,
+    "created_at" TIMESTAMP NOT NULL,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_feedback_id" FOREIGN KEY ("feedback_id") REFERENCES "feedbacks" ("id") ON DELETE CASCADE,
+    CONSTRAINT "fk_moderator_id" FOREIGN KEY ("moderator_id") REFERENCES "users" ("id") ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS "review_moderations_feedback_id" ON "review_moderations" ("feedback_id");
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS "attachments"(
+    "id" UUID NOT NULL,
+    "order_id" UUID,
+    "feedback_id" INT,
+    "uploader_id" UUID NOT NULL,
+    "kind" VARCHAR(16) NOT NULL,
+    "storage_key" VARCHAR(256) NOT NULL,
+    "thumbnail_key" VARCHAR(256),
+    "content_type" VARCHAR(32) NOT NULL,
+    "size" INT NOT NULL,
+    "created_at" TIMESTAMP NOT NULL,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "chk_attachment_owner" CHECK (("order_id" IS NULL) <> ("feedback_id" IS NULL)),
+    CONSTRAINT "fk_order_id" FOREIGN KEY ("order_id") REFERENCES "orders" ("id") ON DELETE CASCADE,
+    CONSTRAINT "fk_feedback_id" FOREIGN KEY ("feedback_id") REFERENCES "feedbacks" ("id") ON DELETE CASCADE,
+    CONSTRAINT "fk_uploader_id" FOREIGN KEY ("uploader_id") REFERENCES "users" ("id") ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS "attachments_order_id" ON "attachments" ("order_id");
+CREATE INDEX IF NOT EXISTS "attachments_feedback_id" ON "attachments" ("feedback_id");
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS "notification_outbox"(
+    "id" SERIAL NOT NULL,
+    "user_id" UUID NOT NULL,
+    "category" VARCHAR(32) NOT NULL,
+    "title" VARCHAR(128) NOT NULL,
+    "body" VARCHAR(512) NOT NULL,
+    "data" JSONB NOT NULL DEFAULT '{}',
+    "attempts" INT NOT NULL DEFAULT 0,
+    "next_attempt_at" TIMESTAMP NOT NULL,
+    "sent_at" TIMESTAMP,
+    "failed_at" TIMESTAMP,
+    "last_error" VARCHAR(512),
+    "created_at" TIMESTAMP NOT NULL,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_user_id" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS "notification_outbox_pending" ON "notification_outbox" ("next_attempt_at") WHERE "sent_at" IS NULL AND "failed_at" IS NULL;
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS "user_devices"(
+    "id" SERIAL NOT NULL,
+    "user_id" UUID NOT NULL,
+    "token" VARCHAR(360) NOT NULL,
+    "platform" VARCHAR(16) NOT NULL,
+    "app_version" VARCHAR(32) NOT NULL DEFAULT '',
+    "created_at" TIMESTAMP NOT NULL,
+    "last_seen_at" TIMESTAMP NOT NULL,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uq_user_devices_token" UNIQUE ("token"),
+    CONSTRAINT "fk_user_id" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS "user_devices_user_id_last_seen_at" ON "user_devices" ("user_id", "last_seen_at");
+
+-- the key stored on the user is kept as the first device of the user
+INSERT INTO "user_devices" ("user_id", "token", "platform", "created_at", "last_seen_at")
+SELECT "id", "fcm_key", 'unknown', NOW(), NOW() FROM "users" WHERE "fcm_key" IS NOT NULL AND "fcm_key" <> ''
+ON CONFLICT DO NOTHING;
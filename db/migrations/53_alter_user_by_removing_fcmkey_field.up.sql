@@ -0,0 +1,2 @@
+ALTER TABLE "users" 
+    DROP COLUMN IF EXISTS "fcm_key";
\ No newline at end of file
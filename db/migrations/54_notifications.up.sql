@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS "notifications"(
+    "id" SERIAL NOT NULL,
+    "user_id" UUID NOT NULL,
+    "category" VARCHAR(32) NOT NULL,
+    "title" VARCHAR(128) NOT NULL,
+    "body" VARCHAR(512) NOT NULL,
+    "data" JSONB NOT NULL DEFAULT '{}',
+    "read_at" TIMESTAMP,
+    "created_at" TIMESTAMP NOT NULL,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_user_id" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS "notifications_user_id_created_at" ON "notifications" ("user_id", "created_at" DESC, "id" DESC);
+CREATE INDEX IF NOT EXISTS "notifications_unread" ON "notifications" ("user_id") WHERE "read_at" IS NULL;
@@ -0,0 +1,2 @@
+ALTER TABLE "notification_outbox" 
+    ADD COLUMN IF NOT EXISTS "notification_id" INT REFERENCES "notifications" ("id") ON DELETE CASCADE;
\ No newline at end of file
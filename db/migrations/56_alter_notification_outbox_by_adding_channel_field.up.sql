@@ -0,0 +1,3 @@
+ALTER TABLE "notification_outbox" 
+    ADD COLUMN IF NOT EXISTS "channel" VARCHAR(16) NOT NULL DEFAULT 'push',
+    ADD COLUMN IF NOT EXISTS "email_template" VARCHAR(32);
\ No newline at end of file
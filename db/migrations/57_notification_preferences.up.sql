@@ -0,0 +1,16 @@
+-- a user without a row here gets the defaults, see model.DefaultNotificationPreference
+CREATE TABLE IF NOT EXISTS "notification_preferences"(
+    "user_id" UUID NOT NULL,
+    "push_enabled" BOOLEAN NOT NULL DEFAULT TRUE,
+    "email_enabled" BOOLEAN NOT NULL DEFAULT TRUE,
+    "sms_enabled" BOOLEAN NOT NULL DEFAULT FALSE,
+    "order_updates_enabled" BOOLEAN NOT NULL DEFAULT TRUE,
+    "reminders_enabled" BOOLEAN NOT NULL DEFAULT TRUE,
+    "promotions_enabled" BOOLEAN NOT NULL DEFAULT TRUE,
+    "quiet_hours_start" VARCHAR(5),
+    "quiet_hours_end" VARCHAR(5),
+    "timezone" VARCHAR(64) NOT NULL DEFAULT 'Asia/Jakarta',
+    "updated_at" TIMESTAMP NOT NULL,
+    PRIMARY KEY ("user_id"),
+    CONSTRAINT "fk_user_id" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE
+);
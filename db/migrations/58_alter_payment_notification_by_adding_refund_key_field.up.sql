@@ -0,0 +1,2 @@
+ALTER TABLE "payment_notifications" 
+    ADD COLUMN "refund_key" VARCHAR(128);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS "mechanic_assignment_queue_created_at";
+
+CREATE INDEX IF NOT EXISTS "mechanic_assignment_queue_updated_at" ON "mechanic_assignment_queue" ("updated_at");
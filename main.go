@@ -6,14 +6,23 @@ import (
 	v1 "e-montir/api/v1"
 	"e-montir/controller"
 	"e-montir/model"
+	"e-montir/pkg/fcm"
 	"e-montir/pkg/mailer"
+	"e-montir/pkg/media"
+	"e-montir/pkg/notification"
+	"e-montir/pkg/payment"
+	"e-montir/pkg/scheduler"
+	"e-montir/pkg/validator"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
+	// quiet hours are read in the timezone of the user, the host may not ship the zone database
+	_ "time/tzdata"
 
 	"github.com/go-chi/chi"
 	"github.com/rs/zerolog/log"
@@ -34,20 +43,45 @@ func main() {
 		os.Exit(0)
 	}()
 
-	mailerPort, err := strconv.Atoi(os.Getenv("MAILER_PORT"))
+	validator.SetBannedWords(strings.Split(os.Getenv("BANNED_WORDS"), ","))
+
+	paymentGateway := newPaymentGateway()
+	mediaStore := newMediaStore()
+	notifier := newNotifier()
+	mailTransport := newMailTransport()
+
+	passwordResetURL := os.Getenv("PASSWORD_RESET_URL")
+	if passwordResetURL == "" {
+		passwordResetURL = "emontir://password/reset"
+	}
+	mailQueue := mailer.NewQueue(mailTransport, 1000)
+	accountMailer := mailer.NewMailer(mailQueue, os.Getenv("BASE_URL"), passwordResetURL)
+
+	m := model.NewManager()
+	c := controller.NewManager(m, paymentGateway, mediaStore, notifier, mailTransport)
+	r := createHandler(c, accountMailer, paymentGateway)
+
+	orderExpiryInterval, err := time.ParseDuration(os.Getenv("ORDER_EXPIRY_INTERVAL"))
 	if err != nil {
-		mailerPort = 587
+		orderExpiryInterval = time.Minute
 	}
 
-	mailerCfg := &mailer.Config{
-		Sender:   os.Getenv("MAILER_SENDER"),
-		Username: os.Getenv("MAILER_USERNAME"),
-		Password: os.Getenv("MAILER_PASSWORD"),
-		Host:     os.Getenv("MAILER_HOST"),
-		Port:     mailerPort,
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	go mailQueue.Run(workerCtx)
+	go scheduler.Every(workerCtx, "order expiry sweeper", orderExpiryInterval, c.Order().ExpireUnpaidOrders)
+
+	assignmentRetryInterval, err := time.ParseDuration(os.Getenv("ASSIGNMENT_RETRY_INTERVAL"))
+	if err != nil {
+		assignmentRetryInterval = 5 * time.Minute
 	}
+	go scheduler.Every(workerCtx, "mechanic assignment queue", assignmentRetryInterval, c.Assignment().AssignQueuedOrders)
 
-	r := createHandler(mailerCfg)
+	notificationDispatchInterval, err := time.ParseDuration(os.Getenv("NOTIFICATION_DISPATCH_INTERVAL"))
+	if err != nil {
+		notificationDispatchInterval = 10 * time.Second
+	}
+	go scheduler.Every(workerCtx, "notification dispatcher", notificationDispatchInterval, c.Notification().DispatchNotifications)
 
 	readTimeout, err := time.ParseDuration(os.Getenv("READ_TIMEOUT"))
 	if err != nil {
@@ -92,42 +126,193 @@ func main() {
 	}(httpServer)
 }
 
-func createHandler(mailerCfg *mailer.Config) http.Handler {
-	m := model.NewManager()
-	c := controller.NewManager(m)
-	h := v1.GetHandler(c, mailerCfg)
+// newPaymentGateway returns the in-process fake gateway when PAYMENT_GATEWAY is "fake"
+// so the checkout can be exercised without reaching the payment provider.
+func newPaymentGateway() payment.PaymentGateway {
+	if os.Getenv("PAYMENT_GATEWAY") == "fake" {
+		autoDelay, err := time.ParseDuration(os.Getenv("FAKE_PAYMENT_AUTO_DELAY"))
+		if err != nil {
+			autoDelay = 5 * time.Second
+		}
+		return payment.NewFakeGateway(&payment.FakeConfig{
+			ServerKey:  os.Getenv("PAYMENT_SERVER_KEY"),
+			AutoStatus: os.Getenv("FAKE_PAYMENT_AUTO_STATUS"),
+			AutoDelay:  autoDelay,
+		})
+	}
+
+	paymentTimeout, err := time.ParseDuration(os.Getenv("PAYMENT_TIMEOUT"))
+	if err != nil {
+		paymentTimeout = 30 * time.Second
+	}
+	return payment.NewHTTPGateway(&payment.Config{
+		BaseURL:   os.Getenv("PAYMENT_BASE_URL"),
+		Token:     os.Getenv("PAYMENT_TOKEN"),
+		ServerKey: os.Getenv("PAYMENT_SERVER_KEY"),
+		Timeout:   paymentTimeout,
+	})
+}
+
+// newMediaStore keeps uploads on the local filesystem unless MEDIA_STORAGE is "s3", any S3 compatible
+// server works there, e.g. the minio service of docker-compose. Either way the files are served from
+// /media, which checks who may see them, so the bucket does not need to be public.
+func newMediaStore() media.Storage {
+	mediaBaseURL := os.Getenv("MEDIA_BASE_URL")
+	if mediaBaseURL == "" {
+		mediaBaseURL = "/media"
+	}
+	if os.Getenv("MEDIA_STORAGE") == "s3" {
+		timeout, err := time.ParseDuration(os.Getenv("MEDIA_S3_TIMEOUT"))
+		if err != nil {
+			timeout = 30 * time.Second
+		}
+		return media.NewS3(&media.S3Config{
+			Endpoint:  os.Getenv("MEDIA_S3_ENDPOINT"),
+			Region:    os.Getenv("MEDIA_S3_REGION"),
+			Bucket:    os.Getenv("MEDIA_S3_BUCKET"),
+			AccessKey: os.Getenv("MEDIA_S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("MEDIA_S3_SECRET_KEY"),
+			BaseURL:   mediaBaseURL,
+			Timeout:   timeout,
+		})
+	}
+
+	mediaDir := os.Getenv("MEDIA_DIR")
+	if mediaDir == "" {
+		mediaDir = "uploads"
+	}
+	return media.NewLocal(mediaDir, mediaBaseURL)
+}
+
+// newNotifier pushes through firebase cloud messaging unless PUSH_NOTIFIER is "log", the pushes
+// are only logged then. The server does not start when firebase cannot be set up.
+func newNotifier() notification.Notifier {
+	if os.Getenv("PUSH_NOTIFIER") == "log" {
+		return notification.NewLog()
+	}
+
+	client, err := fcm.NewClient(context.Background())
+	if err != nil {
+		log.Fatal().Err(fmt.Errorf("error when setting up fcm, set PUSH_NOTIFIER=log to only log the pushes : %w", err)).Send()
+	}
+	return client
+}
+
+// newMailTransport sends emails through the SMTP server of the MAILER_ variables unless MAILER_TRANSPORT
+// is "file", every email is written to MAILER_DIR then. The mailhog service of docker-compose captures
+// SMTP during development, point MAILER_HOST to it and leave MAILER_USERNAME empty.
+func newMailTransport() mailer.Transport {
+	mailerSender := os.Getenv("MAILER_SENDER")
+	if os.Getenv("MAILER_TRANSPORT") == "file" {
+		mailerDir := os.Getenv("MAILER_DIR")
+		if mailerDir == "" {
+			mailerDir = "mails"
+		}
+		return mailer.NewFile(mailerDir, mailerSender)
+	}
+
+	mailerPort, err := strconv.Atoi(os.Getenv("MAILER_PORT"))
+	if err != nil {
+		mailerPort = 587
+	}
+	return mailer.NewSMTP(&mailer.Config{
+		Sender:   mailerSender,
+		Username: os.Getenv("MAILER_USERNAME"),
+		Password: os.Getenv("MAILER_PASSWORD"),
+		Host:     os.Getenv("MAILER_HOST"),
+		Port:     mailerPort,
+	})
+}
+
+func createHandler(c controller.Manager, accountMailer *mailer.Mailer, paymentGateway payment.PaymentGateway) http.Handler {
+	h := v1.GetHandler(c, accountMailer)
 	r := chi.NewRouter()
+	validateToken := middleware.ValidateToken(c.Auth())
+	adminOnly := middleware.RequireRole(model.UserRoleAdmin)
+	mechanicOnly := middleware.RequireRole(model.UserRoleMechanic)
+
+	// uploaded files are served to the users allowed to see them, wherever they are stored
+	r.With(validateToken).Get("/media/*", h.Attachment.ServeMedia)
 
 	r.Route("/api/v1", func(apiRoute chi.Router) {
 		apiRoute.Post("/auth/register", h.Auth.Register)
 		apiRoute.Post("/auth/login", h.Auth.Login)
 		apiRoute.Get("/auth/verify", h.Auth.ActivateEmail)
+		apiRoute.Post("/auth/verify/resend", h.Auth.ResendActivationLink)
+		apiRoute.Post("/auth/refresh", h.Auth.RefreshToken)
+		apiRoute.Post("/auth/password/forgot", h.Auth.ForgotPassword)
+		apiRoute.Post("/auth/password/reset", h.Auth.ResetPassword)
+		apiRoute.With(validateToken).Post("/auth/logout", h.Auth.Logout)
+		apiRoute.With(validateToken).Post("/auth/logout/all", h.Auth.LogoutAllDevices)
 
-		apiRoute.With(middleware.ValidateToken()).Get("/services", h.Service.ListOfServices)
-		apiRoute.With(middleware.ValidateToken()).Get("/services/search", h.Service.SearchService)
-		apiRoute.With(middleware.ValidateToken()).Post("/services/{order_id}/{service_id}/review", h.Review.AddServiceReview)
-		apiRoute.With(middleware.ValidateToken()).Post("/services/{service_id}/favorite", h.Service.AddFavService)
-		apiRoute.With(middleware.ValidateToken()).Delete("/services/{service_id}/favorite", h.Service.RemoveFavService)
-		apiRoute.With(middleware.ValidateToken()).Get("/services/favorites", h.Service.ListOfFavServices)
+		apiRoute.With(validateToken).Get("/services", h.Service.ListOfServices)
+		apiRoute.With(validateToken).Get("/services/search", h.Service.SearchService)
+		apiRoute.With(validateToken).Post("/services/{order_id}/{service_id}/review", h.Review.AddServiceReview)
+		apiRoute.With(validateToken).Get("/services/{service_id}/reviews", h.Review.ListOfServiceReviews)
+		apiRoute.With(validateToken).Put("/reviews/{review_id}", h.Review.UpdateServiceReview)
+		apiRoute.With(validateToken).Delete("/reviews/{review_id}", h.Review.DeleteServiceReview)
+		apiRoute.With(validateToken, adminOnly).Get("/reviews/moderation", h.Review.ListOfModerationQueue)
+		apiRoute.With(validateToken, adminOnly).Post("/reviews/{review_id}/hide", h.Review.HideServiceReview)
+		apiRoute.With(validateToken, adminOnly).Post("/reviews/{review_id}/restore", h.Review.RestoreServiceReview)
+		apiRoute.With(validateToken, adminOnly).Post("/reviews/{review_id}/approve", h.Review.ApproveServiceReview)
+		apiRoute.With(validateToken, adminOnly).Post("/reviews/{review_id}/reply", h.Review.ReplyServiceReview)
+		apiRoute.With(validateToken).Post("/reviews/{review_id}/attachments", h.Attachment.UploadReviewAttachment)
+		apiRoute.With(validateToken).Post("/services/{service_id}/favorite", h.Service.AddFavService)
+		apiRoute.With(validateToken).Delete("/services/{service_id}/favorite", h.Service.RemoveFavService)
+		apiRoute.With(validateToken).Get("/services/favorites", h.Service.ListOfFavServices)
 
-		apiRoute.With(middleware.ValidateToken()).Get("/timeslot", h.Timeslot.ListOfTimeslot)
+		apiRoute.With(validateToken).Get("/timeslot", h.Timeslot.ListOfTimeslot)
 
-		apiRoute.With(middleware.ValidateToken()).Get("/me/address", h.User.ListOfUserLocation)
-		apiRoute.With(middleware.ValidateToken()).Post("/me/address", h.User.AddUserLocation)
+		apiRoute.With(validateToken).Get("/me/address", h.User.ListOfUserLocation)
+		apiRoute.With(validateToken).Post("/me/address", h.User.AddUserLocation)
+		apiRoute.With(validateToken).Post("/me/devices", h.User.RegisterDevice)
+		apiRoute.With(validateToken).Delete("/me/devices", h.User.RemoveDevice)
+		apiRoute.With(validateToken).Get("/me/notifications", h.Notification.ListOfNotifications)
+		apiRoute.With(validateToken).Post("/me/notifications/read", h.Notification.MarkAllNotificationsAsRead)
+		apiRoute.With(validateToken).Post("/me/notifications/{notification_id}/read", h.Notification.MarkNotificationAsRead)
+		apiRoute.With(validateToken).Get("/me/notification-preferences", h.Notification.GetNotificationPreferences)
+		apiRoute.With(validateToken).Put("/me/notification-preferences", h.Notification.UpdateNotificationPreferences)
+		apiRoute.With(validateToken, adminOnly).Post("/notifications/promotions", h.Notification.SendPromotion)
 
-		apiRoute.With(middleware.ValidateToken()).Get("/cart", h.Cart.GetCheckoutDetail)
-		apiRoute.With(middleware.ValidateToken()).Post("/cart/item", h.Cart.AddServiceToCart)
-		apiRoute.With(middleware.ValidateToken()).Delete("/cart/item/{service_id}", h.Cart.RemoveServiceFromCart)
-		apiRoute.With(middleware.ValidateToken()).Post("/cart/appointment", h.Cart.SetCartAppointment)
-		apiRoute.With(middleware.ValidateToken()).Delete("/cart/appointment", h.Cart.RemoveCartAppointment)
+		apiRoute.With(validateToken).Get("/cart", h.Cart.GetCheckoutDetail)
+		apiRoute.With(validateToken).Post("/cart/item", h.Cart.AddServiceToCart)
+		apiRoute.With(validateToken).Delete("/cart/item/{service_id}", h.Cart.RemoveServiceFromCart)
+		apiRoute.With(validateToken).Post("/cart/appointment", h.Cart.SetCartAppointment)
+		apiRoute.With(validateToken).Delete("/cart/appointment", h.Cart.RemoveCartAppointment)
 
-		apiRoute.With(middleware.ValidateToken()).Post("/pay/{order_id}", h.Payment.MakePayment)
+		apiRoute.With(validateToken).Post("/pay/{order_id}", h.Payment.MakePayment)
 		apiRoute.Post("/payment/notification", h.Payment.PaymentNotification)
 
-		apiRoute.With(middleware.ValidateToken()).Post("/order", h.Order.PlaceOrder)
-		apiRoute.Post("/order/status", h.Order.UpdateOrderStatus)
-		apiRoute.With(middleware.ValidateToken()).Get("/orders", h.Order.OrderLists)
-		apiRoute.With(middleware.ValidateToken()).Get("/orders/{order_id}", h.Order.OrderDetail)
+		apiRoute.With(validateToken).Post("/order", h.Order.PlaceOrder)
+		apiRoute.With(validateToken, adminOnly).Post("/order/status", h.Order.UpdateOrderStatus)
+		apiRoute.With(validateToken).Get("/orders", h.Order.OrderLists)
+		apiRoute.With(validateToken).Get("/orders/{order_id}", h.Order.OrderDetail)
+		apiRoute.With(validateToken).Post("/orders/{order_id}/cancel", h.Order.CancelOrder)
+		apiRoute.With(validateToken).Get("/orders/{order_id}/payment", h.Payment.PaymentStatus)
+		apiRoute.With(validateToken).Get("/orders/{order_id}/track", h.Tracking.TrackOrder)
+		apiRoute.With(validateToken).Post("/orders/{order_id}/mechanic/review", h.Review.AddMechanicReview)
+		apiRoute.With(validateToken).Get("/orders/{order_id}/messages", h.Chat.ListOfMessages)
+		apiRoute.With(validateToken).Post("/orders/{order_id}/messages", h.Chat.SendMessage)
+		apiRoute.With(validateToken).Post("/orders/{order_id}/messages/read", h.Chat.MarkMessagesAsRead)
+		apiRoute.With(validateToken).Get("/orders/{order_id}/messages/stream", h.Chat.Subscribe)
+		apiRoute.With(validateToken).Get("/orders/{order_id}/attachments", h.Attachment.ListOfOrderAttachments)
+		apiRoute.With(validateToken, mechanicOnly).Post("/orders/{order_id}/attachments", h.Attachment.UploadOrderAttachment)
+		apiRoute.With(validateToken, adminOnly).Post("/orders/{order_id}/refund", h.Refund.RequestRefund)
+
+		apiRoute.Post("/mechanic/login", h.Auth.MechanicLogin)
+		apiRoute.With(validateToken, mechanicOnly).Post("/mechanic/location", h.Tracking.UpdateLocation)
+		apiRoute.With(validateToken, mechanicOnly).Get("/mechanic/jobs", h.Mechanic.ListOfJobs)
+		apiRoute.With(validateToken, mechanicOnly).Post("/mechanic/jobs/{order_id}/accept", h.Mechanic.AcceptJob)
+		apiRoute.With(validateToken, mechanicOnly).Post("/mechanic/jobs/{order_id}/start", h.Mechanic.StartTravel)
+		apiRoute.With(validateToken, mechanicOnly).Post("/mechanic/jobs/{order_id}/arrived", h.Mechanic.MarkArrived)
+		apiRoute.With(validateToken, mechanicOnly).Post("/mechanic/jobs/{order_id}/complete", h.Mechanic.CompleteJob)
+		apiRoute.With(validateToken).Get("/mechanics/{mechanic_id}", h.Mechanic.MechanicProfile)
+		apiRoute.With(validateToken, adminOnly).Post("/mechanics/{mechanic_id}/account", h.Mechanic.CreateMechanicAccount)
+
+		if fakeGateway, ok := paymentGateway.(*payment.FakeGateway); ok {
+			fakePayment := v1.NewFakePaymentHandler(fakeGateway)
+			apiRoute.Post("/dev/payment/{order_id}/{status}", fakePayment.Simulate)
+		}
 	})
 
 	return r
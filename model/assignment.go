@@ -0,0 +1,175 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+)
+
+type (
+	AssignmentCandidate struct {
+		MechanicID  int             `db:"id"`
+		Latitude    sql.NullFloat64 `db:"latitude"`
+		Longitude   sql.NullFloat64 `db:"longitude"`
+		JobsOnDate  int             `db:"jobs_on_date"`
+		JobsOnSlot  int             `db:"jobs_on_slot"`
+		Skills      sql.NullString  `db:"skills"` // comma separated categories
+		Rating      float64         `db:"rating"`
+		ReviewCount int             `db:"review_count"`
+	}
+
+	QueuedAssignment struct {
+		OrderID   string    `db:"order_id"`
+		Reason    string    `db:"reason"`
+		Attempts  int       `db:"attempts"`
+		CreatedAt time.Time `db:"created_at"`
+		UpdatedAt time.Time `db:"updated_at"`
+	}
+)
+
+type Assignment interface {
+	ListOfAssignmentCandidates(ctx context.Context, date, timeSlot string) ([]AssignmentCandidate, error)
+	ListOfOrderCategories(ctx context.Context, orderID string) ([]string, error)
+	AssignMechanic(ctx context.Context, orderID string, mechanicID int) (bool, error)
+	QueueAssignment(ctx context.Context, orderID, reason string) error
+	ListOfQueuedAssignments(ctx context.Context, limit int) ([]QueuedAssignment, error)
+	RemoveQueuedAssignment(ctx context.Context, orderID string) error
+}
+
+type assignment struct {
+	db      *sqlx.DB
+	queries map[string]*sqlx.Stmt
+}
+
+func NewAssignment(db *sqlx.DB) Assignment {
+	assignment := new(assignment)
+	assignment.db = db
+	assignment.queries = make(map[string]*sqlx.Stmt, len(assignmentQueries))
+	for k, v := range assignmentQueries {
+		stmt, err := db.Preparex(v)
+		if err != nil {
+			log.Fatal().Msg("error : " + err.Error() + "\nassignment : " + v)
+		}
+		assignment.queries[k] = stmt
+	}
+	return assignment
+}
+
+var (
+	// candidates are the mechanics on duty, located at the last reported position or at home,
+	// with the number of jobs they already hold on the date and in the time slot
+	listOfAssignmentCandidates    = "listOfAssignmentCandidates"
+	assignmentCandidateJobs       = `SELECT COUNT(*) FROM "orders" WHERE "orders"."mechanic_id" = "mechanics"."id" AND "orders"."date" = $1 AND "orders"."status_order" IN ($3, $4, $5, $6)`
+	assignmentCandidateFields1    = `"id", "rating", "review_count", COALESCE("latitude", "home_latitude") AS "latitude", COALESCE("longitude", "home_longitude") AS "longitude", `
+	assignmentCandidateFields2    = `(` + assignmentCandidateJobs + `) AS "jobs_on_date", (` + assignmentCandidateJobs + ` AND "orders"."time_slot" = $2) AS "jobs_on_slot", `
+	assignmentCandidateFields3    = `(SELECT STRING_AGG("category", ',') FROM "mechanic_skills" WHERE "mechanic_id" = "mechanics"."id") AS "skills"`
+	assignmentCandidateFields     = assignmentCandidateFields1 + assignmentCandidateFields2 + assignmentCandidateFields3
+	listOfAssignmentCandidatesSQL = `SELECT ` + assignmentCandidateFields + ` FROM "mechanics" WHERE "is_available" = TRUE`
+
+	listOfOrderCategories     = "listOfOrderCategories"
+	listOfOrderCategoriesJoin = `JOIN "service_categories" ON order_items.service_id = service_categories.service_id`
+	listOfOrderCategoriesSQL  = `SELECT DISTINCT "category" FROM "order_items" ` + listOfOrderCategoriesJoin + ` WHERE "order_id" = $1`
+
+	assignMechanicToOrderSQL = `UPDATE "orders" SET "mechanic_id" = $2 WHERE "id" = $1 AND "mechanic_id" IS NULL AND "status_order" = $3`
+
+	queueAssignment         = "queueAssignment"
+	queueAssignmentConflict = `ON CONFLICT ("order_id") DO UPDATE SET "reason" = $2, "attempts" = "mechanic_assignment_queue"."attempts" + 1, "updated_at" = $3`
+	queueAssignmentSQL      = `INSERT INTO "mechanic_assignment_queue" ("order_id", "reason", "created_at", "updated_at") VALUES ($1,$2,$3,$3) ` + queueAssignmentConflict
+
+	listOfQueuedAssignments       = "listOfQueuedAssignments"
+	listOfQueuedAssignmentsFields = `"order_id", "reason", "attempts", "created_at", "updated_at"`
+	listOfQueuedAssignmentsSQL    = `SELECT ` + listOfQueuedAssignmentsFields + ` FROM "mechanic_assignment_queue" ORDER BY "updated_at" ASC LIMIT $1`
+
+	removeQueuedAssignment    = "removeQueuedAssignment"
+	removeQueuedAssignmentSQL = `DELETE FROM "mechanic_assignment_queue" WHERE "order_id" = $1`
+
+	assignmentQueries = map[string]string{
+		listOfAssignmentCandidates: listOfAssignmentCandidatesSQL,
+		listOfOrderCategories:      listOfOrderCategoriesSQL,
+		queueAssignment:            queueAssignmentSQL,
+		listOfQueuedAssignments:    listOfQueuedAssignmentsSQL,
+		removeQueuedAssignment:     removeQueuedAssignmentSQL,
+	}
+)
+
+func (c *assignment) ListOfAssignmentCandidates(ctx context.Context, date, timeSlot string) ([]AssignmentCandidate, error) {
+	var result []AssignmentCandidate
+	err := c.queries[listOfAssignmentCandidates].SelectContext(ctx, &result, date, timeSlot, OrderStatus[2], OrderStatus[3], OrderStatus[4], OrderStatus[5])
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *assignment) ListOfOrderCategories(ctx context.Context, orderID string) ([]string, error) {
+	var result []string
+	err := c.queries[listOfOrderCategories].SelectContext(ctx, &result, orderID)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// AssignMechanic sets the mechanic of a paid order and takes it off the queue, it reports false
+// when the order already has a mechanic or is no longer waiting for one.
+func (c *assignment) AssignMechanic(ctx context.Context, orderID string, mechanicID int) (bool, error) {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return false, err
+	}
+
+	defer func() {
+		if rollback := tx.Rollback(); rollback == nil {
+			log.Info().Msg("rolling back changes")
+		}
+	}()
+
+	res, err := tx.ExecContext(ctx, assignMechanicToOrderSQL, orderID, mechanicID, OrderStatus[2])
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected != 1 {
+		return false, nil
+	}
+
+	_, err = tx.ExecContext(ctx, removeQueuedAssignmentSQL, orderID)
+	if err != nil {
+		return false, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// QueueAssignment keeps the order for a later attempt, queueing it again counts another attempt
+func (c *assignment) QueueAssignment(ctx context.Context, orderID, reason string) error {
+	_, err := c.queries[queueAssignment].ExecContext(ctx, orderID, reason, time.Now())
+	return err
+}
+
+// ListOfQueuedAssignments lists the orders tried least recently first, an order queued again goes to the back
+// so the ones nobody qualifies for do not hold up the rest
+func (c *assignment) ListOfQueuedAssignments(ctx context.Context, limit int) ([]QueuedAssignment, error) {
+	var result []QueuedAssignment
+	err := c.queries[listOfQueuedAssignments].SelectContext(ctx, &result, limit)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *assignment) RemoveQueuedAssignment(ctx context.Context, orderID string) error {
+	_, err := c.queries[removeQueuedAssignment].ExecContext(ctx, orderID)
+	return err
+}
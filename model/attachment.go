@@ -0,0 +1,133 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	AttachmentKindBefore = "before"
+	AttachmentKindAfter  = "after"
+	AttachmentKindReview = "review"
+
+	MaxOrderAttachments  = 10
+	MaxReviewAttachments = 5
+)
+
+type (
+	AttachmentBaseModel struct {
+		ID           string         `db:"id"`
+		OrderID      sql.NullString `db:"order_id"`
+		FeedbackID   sql.NullInt64  `db:"feedback_id"`
+		UploaderID   string         `db:"uploader_id"`
+		Kind         string         `db:"kind"`
+		StorageKey   string         `db:"storage_key"`
+		ThumbnailKey sql.NullString `db:"thumbnail_key"`
+		ContentType  string         `db:"content_type"`
+		Size         int            `db:"size"`
+		CreatedAt    time.Time      `db:"created_at"`
+	}
+)
+
+type Attachment interface {
+	SetAttachment(ctx context.Context, param *AttachmentBaseModel) (bool, error)
+	ListOfOrderAttachments(ctx context.Context, orderID string) ([]AttachmentBaseModel, error)
+	ListOfReviewAttachments(ctx context.Context, feedbackIDs []int) ([]AttachmentBaseModel, error)
+}
+
+type attachment struct {
+	db      *sqlx.DB
+	queries map[string]*sqlx.Stmt
+}
+
+func NewAttachment(db *sqlx.DB) Attachment {
+	attachment := new(attachment)
+	attachment.db = db
+	attachment.queries = make(map[string]*sqlx.Stmt, len(attachmentQueries))
+	for k, v := range attachmentQueries {
+		stmt, err := db.Preparex(v)
+		if err != nil {
+			log.Fatal().Msg("error : " + err.Error() + "\nattachment : " + v)
+		}
+		attachment.queries[k] = stmt
+	}
+	return attachment
+}
+
+var (
+	attachmentFields = `"id", "order_id", "feedback_id", "uploader_id", "kind", "storage_key", "thumbnail_key", "content_type", "size", "created_at"`
+
+	// the attachment is only stored while its order or review is below the limit
+	setAttachmentValues = `SELECT $1::UUID, $2::UUID, $3::INT, $4::UUID, $5::VARCHAR, $6::VARCHAR, $7::VARCHAR, $8::VARCHAR, $9::INT, $10::TIMESTAMP`
+
+	setOrderAttachment     = "setOrderAttachment"
+	setOrderAttachmentCond = `WHERE (SELECT COUNT(*) FROM "attachments" WHERE "order_id" = $2) < $11`
+	setOrderAttachmentSQL  = `INSERT INTO "attachments" (` + attachmentFields + `) ` + setAttachmentValues + ` ` + setOrderAttachmentCond
+
+	setReviewAttachment     = "setReviewAttachment"
+	setReviewAttachmentCond = `WHERE (SELECT COUNT(*) FROM "attachments" WHERE "feedback_id" = $3) < $11`
+	setReviewAttachmentSQL  = `INSERT INTO "attachments" (` + attachmentFields + `) ` + setAttachmentValues + ` ` + setReviewAttachmentCond
+
+	listOfOrderAttachments    = "listOfOrderAttachments"
+	listOfOrderAttachmentsSQL = `SELECT ` + attachmentFields + ` FROM "attachments" WHERE "order_id" = $1 ORDER BY "created_at" ASC`
+
+	listOfReviewAttachmentsSQL = `SELECT ` + attachmentFields + ` FROM "attachments" WHERE "feedback_id" IN (?) ORDER BY "created_at" ASC`
+
+	attachmentQueries = map[string]string{
+		setOrderAttachment:     setOrderAttachmentSQL,
+		setReviewAttachment:    setReviewAttachmentSQL,
+		listOfOrderAttachments: listOfOrderAttachmentsSQL,
+	}
+)
+
+// SetAttachment stores the attachment of an order or a review, it reports false when
+// the order or review already holds as many attachments as allowed.
+func (c *attachment) SetAttachment(ctx context.Context, param *AttachmentBaseModel) (bool, error) {
+	query, limit := setOrderAttachment, MaxOrderAttachments
+	if param.FeedbackID.Valid {
+		query, limit = setReviewAttachment, MaxReviewAttachments
+	}
+
+	res, err := c.queries[query].ExecContext(ctx, param.ID, param.OrderID, param.FeedbackID, param.UploaderID,
+		param.Kind, param.StorageKey, param.ThumbnailKey, param.ContentType, param.Size, param.CreatedAt, limit)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+func (c *attachment) ListOfOrderAttachments(ctx context.Context, orderID string) ([]AttachmentBaseModel, error) {
+	var result []AttachmentBaseModel
+	err := c.queries[listOfOrderAttachments].SelectContext(ctx, &result, orderID)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *attachment) ListOfReviewAttachments(ctx context.Context, feedbackIDs []int) ([]AttachmentBaseModel, error) {
+	if len(feedbackIDs) == 0 {
+		return nil, nil
+	}
+
+	query, args, err := sqlx.In(listOfReviewAttachmentsSQL, feedbackIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []AttachmentBaseModel
+	err = c.db.SelectContext(ctx, &result, c.db.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,115 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	DevicePlatformAndroid = "android"
+	DevicePlatformIOS     = "ios"
+	DevicePlatformWeb     = "web"
+	DevicePlatformUnknown = "unknown"
+
+	// a device which has not been seen for this long is most likely gone, it is not pushed to anymore
+	DeviceActivePeriod = 60 * 24 * time.Hour
+)
+
+type (
+	DeviceBaseModel struct {
+		ID         int       `db:"id"`
+		UserID     string    `db:"user_id"`
+		Token      string    `db:"token"`
+		Platform   string    `db:"platform"`
+		AppVersion string    `db:"app_version"`
+		CreatedAt  time.Time `db:"created_at"`
+		LastSeenAt time.Time `db:"last_seen_at"`
+	}
+)
+
+type Device interface {
+	SetDevice(ctx context.Context, param *DeviceBaseModel) error
+	RemoveDevice(ctx context.Context, userID, token string) (bool, error)
+	RemoveDeviceByToken(ctx context.Context, token string) error
+	ListOfActiveDevices(ctx context.Context, userID string, since time.Time) ([]DeviceBaseModel, error)
+}
+
+type device struct {
+	db      *sqlx.DB
+	queries map[string]*sqlx.Stmt
+}
+
+func NewDevice(db *sqlx.DB) Device {
+	device := new(device)
+	device.db = db
+	device.queries = make(map[string]*sqlx.Stmt, len(deviceQueries))
+	for k, v := range deviceQueries {
+		stmt, err := db.Preparex(v)
+		if err != nil {
+			log.Fatal().Msg("error : " + err.Error() + "\ndevice : " + v)
+		}
+		device.queries[k] = stmt
+	}
+	return device
+}
+
+var (
+	deviceFields = `"id", "user_id", "token", "platform", "app_version", "created_at", "last_seen_at"`
+
+	// a token identifies one app install, registering it again moves it to whoever is logged in there now
+	setDevice         = "setDevice"
+	setDeviceFields   = `("user_id", "token", "platform", "app_version", "created_at", "last_seen_at")`
+	setDeviceConflict = `ON CONFLICT ("token") DO UPDATE SET "user_id" = EXCLUDED."user_id", "platform" = EXCLUDED."platform", ` +
+		`"app_version" = EXCLUDED."app_version", "last_seen_at" = EXCLUDED."last_seen_at"`
+	setDeviceSQL = `INSERT INTO "user_devices" ` + setDeviceFields + ` VALUES ($1,$2,$3,$4,$5,$5) ` + setDeviceConflict
+
+	removeDevice    = "removeDevice"
+	removeDeviceSQL = `DELETE FROM "user_devices" WHERE "user_id" = $1 AND "token" = $2`
+
+	removeDeviceByToken    = "removeDeviceByToken"
+	removeDeviceByTokenSQL = `DELETE FROM "user_devices" WHERE "token" = $1`
+
+	listOfActiveDevices    = "listOfActiveDevices"
+	listOfActiveDevicesSQL = `SELECT ` + deviceFields + ` FROM "user_devices" WHERE "user_id" = $1 AND "last_seen_at" >= $2 ORDER BY "last_seen_at" DESC`
+
+	deviceQueries = map[string]string{
+		setDevice:           setDeviceSQL,
+		removeDevice:        removeDeviceSQL,
+		removeDeviceByToken: removeDeviceByTokenSQL,
+		listOfActiveDevices: listOfActiveDevicesSQL,
+	}
+)
+
+func (c *device) SetDevice(ctx context.Context, param *DeviceBaseModel) error {
+	_, err := c.queries[setDevice].ExecContext(ctx, param.UserID, param.Token, param.Platform, param.AppVersion, param.LastSeenAt)
+	return err
+}
+
+func (c *device) RemoveDevice(ctx context.Context, userID, token string) (bool, error) {
+	res, err := c.queries[removeDevice].ExecContext(ctx, userID, token)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (c *device) RemoveDeviceByToken(ctx context.Context, token string) error {
+	_, err := c.queries[removeDeviceByToken].ExecContext(ctx, token)
+	return err
+}
+
+func (c *device) ListOfActiveDevices(ctx context.Context, userID string, since time.Time) ([]DeviceBaseModel, error) {
+	var result []DeviceBaseModel
+	err := c.queries[listOfActiveDevices].SelectContext(ctx, &result, userID, since)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -79,6 +79,17 @@ type Manager interface {
 	Cart() Cart
 	Order() Order
 	Review() Review
+	Payment() Payment
+	Refund() Refund
+	Session() Session
+	Mechanic() Mechanic
+	Assignment() Assignment
+	Tracking() Tracking
+	Message() Message
+	Attachment() Attachment
+	Notification() Notification
+	Device() Device
+	NotificationPreference() NotificationPreference
 }
 
 type manager struct {
@@ -165,3 +176,135 @@ func (c *manager) Review() Review {
 	})
 	return reviewModel
 }
+
+var (
+	paymentModelOnce sync.Once
+	paymentModel     Payment
+)
+
+func (c *manager) Payment() Payment {
+	paymentModelOnce.Do(func() {
+		paymentModel = NewPayment(c.SQLDB)
+	})
+	return paymentModel
+}
+
+var (
+	refundModelOnce sync.Once
+	refundModel     Refund
+)
+
+func (c *manager) Refund() Refund {
+	refundModelOnce.Do(func() {
+		refundModel = NewRefund(c.SQLDB)
+	})
+	return refundModel
+}
+
+var (
+	sessionModelOnce sync.Once
+	sessionModel     Session
+)
+
+func (c *manager) Session() Session {
+	sessionModelOnce.Do(func() {
+		sessionModel = NewSession(c.SQLDB)
+	})
+	return sessionModel
+}
+
+var (
+	mechanicModelOnce sync.Once
+	mechanicModel     Mechanic
+)
+
+func (c *manager) Mechanic() Mechanic {
+	mechanicModelOnce.Do(func() {
+		mechanicModel = NewMechanic(c.SQLDB)
+	})
+	return mechanicModel
+}
+
+var (
+	assignmentModelOnce sync.Once
+	assignmentModel     Assignment
+)
+
+func (c *manager) Assignment() Assignment {
+	assignmentModelOnce.Do(func() {
+		assignmentModel = NewAssignment(c.SQLDB)
+	})
+	return assignmentModel
+}
+
+var (
+	trackingModelOnce sync.Once
+	trackingModel     Tracking
+)
+
+func (c *manager) Tracking() Tracking {
+	trackingModelOnce.Do(func() {
+		trackingModel = NewTracking(c.SQLDB)
+	})
+	return trackingModel
+}
+
+var (
+	messageModelOnce sync.Once
+	messageModel     Message
+)
+
+func (c *manager) Message() Message {
+	messageModelOnce.Do(func() {
+		messageModel = NewMessage(c.SQLDB)
+	})
+	return messageModel
+}
+
+var (
+	attachmentModelOnce sync.Once
+	attachmentModel     Attachment
+)
+
+func (c *manager) Attachment() Attachment {
+	attachmentModelOnce.Do(func() {
+		attachmentModel = NewAttachment(c.SQLDB)
+	})
+	return attachmentModel
+}
+
+var (
+	notificationModelOnce sync.Once
+	notificationModel     Notification
+)
+
+func (c *manager) Notification() Notification {
+	notificationModelOnce.Do(func() {
+		notificationModel = NewNotification(c.SQLDB)
+	})
+	return notificationModel
+}
+
+var (
+	deviceModelOnce sync.Once
+	deviceModel     Device
+)
+
+func (c *manager) Device() Device {
+	deviceModelOnce.Do(func() {
+		deviceModel = NewDevice(c.SQLDB)
+	})
+	return deviceModel
+}
+
+var (
+	notificationPreferenceModelOnce sync.Once
+	notificationPreferenceModel     NotificationPreference
+)
+
+func (c *manager) NotificationPreference() NotificationPreference {
+	notificationPreferenceModelOnce.Do(func() {
+		notificationPreferenceModel = NewNotificationPreference(c.SQLDB)
+	})
+	return notificationPreferenceModel
+}
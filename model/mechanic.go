@@ -0,0 +1,160 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+)
+
+type (
+	MechanicBaseModel struct {
+		ID               int            `db:"id"`
+		UserID           sql.NullString `db:"user_id"`
+		Name             string         `db:"name"`
+		PhoneNumber      string         `db:"phone_number"`
+		IsAvailable      bool           `db:"is_available"`
+		CompletedService int            `db:"completed_service"`
+		Picture          sql.NullString `db:"picture"`
+		Rating           float64        `db:"rating"`
+		ReviewCount      int            `db:"review_count"`
+	}
+)
+
+type Mechanic interface {
+	GetMechanicByUserID(ctx context.Context, userID string) (*MechanicBaseModel, error)
+	GetMechanicByID(ctx context.Context, mechanicID int) (*MechanicBaseModel, error)
+	SetMechanicAccount(ctx context.Context, mechanicID int, param *RegisterUser) (bool, error)
+	ListOfMechanicJobs(ctx context.Context, mechanicID int) ([]OrderBaseModel, error)
+	AcceptJob(ctx context.Context, mechanicID int, orderID string) (bool, error)
+}
+
+type mechanic struct {
+	db      *sqlx.DB
+	queries map[string]*sqlx.Stmt
+}
+
+func NewMechanic(db *sqlx.DB) Mechanic {
+	mechanic := new(mechanic)
+	mechanic.db = db
+	mechanic.queries = make(map[string]*sqlx.Stmt, len(mechanicQueries))
+	for k, v := range mechanicQueries {
+		stmt, err := db.Preparex(v)
+		if err != nil {
+			log.Fatal().Msg("error : " + err.Error() + "\nmechanic : " + v)
+		}
+		mechanic.queries[k] = stmt
+	}
+	return mechanic
+}
+
+var (
+	getMechanicByUserID       = "getMechanicByUserID"
+	getMechanicByUserIDFields = `"id", "user_id", "name", "phone_number", "is_available", "completed_service", "picture", "rating", "review_count"`
+	getMechanicByUserIDSQL    = `SELECT ` + getMechanicByUserIDFields + ` FROM "mechanics" WHERE "user_id" = $1`
+
+	getMechanicByID    = "getMechanicByID"
+	getMechanicByIDSQL = `SELECT ` + getMechanicByUserIDFields + ` FROM "mechanics" WHERE "id" = $1`
+
+	setMechanicUserSQL    = `INSERT INTO "users" (id, name, email, password, is_active, role) VALUES ($1,$2,$3,$4,$5,$6)`
+	linkMechanicToUserSQL = `UPDATE "mechanics" SET "user_id" = $2 WHERE "id" = $1 AND "user_id" IS NULL`
+
+	// jobs are the orders assigned to the mechanic which are not finished yet
+	listOfMechanicJobs     = "listOfMechanicJobs"
+	listOfMechanicJobsCond = `WHERE "mechanic_id" = $1 AND "status_order" IN ($2, $3, $4) ORDER BY "date" ASC, "time_slot" ASC`
+	listOfMechanicJobsSQL  = `SELECT ` + getOrderListField + ` FROM "orders" ` + listOfMechanicJobsCond
+
+	acceptJob     = "acceptJob"
+	acceptJobCond = `WHERE "id" = $1 AND "mechanic_id" = $2 AND "status_order" = $4 AND "accepted_at" IS NULL`
+	acceptJobSQL  = `UPDATE "orders" SET "accepted_at" = $3 ` + acceptJobCond
+
+	mechanicQueries = map[string]string{
+		getMechanicByUserID: getMechanicByUserIDSQL,
+		getMechanicByID:     getMechanicByIDSQL,
+		listOfMechanicJobs:  listOfMechanicJobsSQL,
+		acceptJob:           acceptJobSQL,
+	}
+)
+
+func (c *mechanic) GetMechanicByUserID(ctx context.Context, userID string) (*MechanicBaseModel, error) {
+	var result MechanicBaseModel
+	err := c.queries[getMechanicByUserID].GetContext(ctx, &result, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *mechanic) GetMechanicByID(ctx context.Context, mechanicID int) (*MechanicBaseModel, error) {
+	var result MechanicBaseModel
+	err := c.queries[getMechanicByID].GetContext(ctx, &result, mechanicID)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// SetMechanicAccount creates an activated user with the mechanic role and links it to the mechanic,
+// it reports false when the mechanic does not exist or already has an account.
+func (c *mechanic) SetMechanicAccount(ctx context.Context, mechanicID int, param *RegisterUser) (bool, error) {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return false, err
+	}
+
+	defer func() {
+		if rollback := tx.Rollback(); rollback == nil {
+			log.Info().Msg("rolling back changes")
+		}
+	}()
+
+	_, err = tx.ExecContext(ctx, setMechanicUserSQL, param.ID, param.Name, param.Email, param.Password, true, UserRoleMechanic)
+	if err != nil {
+		return false, err
+	}
+
+	res, err := tx.ExecContext(ctx, linkMechanicToUserSQL, mechanicID, param.ID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected != 1 {
+		return false, nil
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (c *mechanic) ListOfMechanicJobs(ctx context.Context, mechanicID int) ([]OrderBaseModel, error) {
+	var result []OrderBaseModel
+	err := c.queries[listOfMechanicJobs].SelectContext(ctx, &result, mechanicID, OrderStatus[2], OrderStatus[3], OrderStatus[4])
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// AcceptJob records that the mechanic took the job, it reports false when the job has
+// already been accepted or is no longer waiting for the mechanic.
+func (c *mechanic) AcceptJob(ctx context.Context, mechanicID int, orderID string) (bool, error) {
+	res, err := c.queries[acceptJob].ExecContext(ctx, orderID, mechanicID, time.Now(), OrderStatus[2])
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
@@ -0,0 +1,112 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	MessageSenderCustomer = "customer"
+	MessageSenderMechanic = "mechanic"
+)
+
+type (
+	MessageBaseModel struct {
+		ID         string         `db:"id"`
+		OrderID    string         `db:"order_id"`
+		SenderID   string         `db:"sender_id"`
+		SenderRole string         `db:"sender_role"`
+		Body       sql.NullString `db:"body"`
+		Attachment sql.NullString `db:"attachment"` // storage key of the image
+		ReadAt     sql.NullTime   `db:"read_at"`
+		CreatedAt  time.Time      `db:"created_at"`
+	}
+)
+
+type Message interface {
+	SetMessage(ctx context.Context, param *MessageBaseModel) error
+	ListOfMessages(ctx context.Context, orderID string, limit, offset int) ([]MessageBaseModel, error)
+	CountUnreadMessages(ctx context.Context, orderID, readerID string) (int, error)
+	MarkMessagesAsRead(ctx context.Context, orderID, readerID string, readAt time.Time) (int64, error)
+}
+
+type message struct {
+	db      *sqlx.DB
+	queries map[string]*sqlx.Stmt
+}
+
+func NewMessage(db *sqlx.DB) Message {
+	message := new(message)
+	message.db = db
+	message.queries = make(map[string]*sqlx.Stmt, len(messageQueries))
+	for k, v := range messageQueries {
+		stmt, err := db.Preparex(v)
+		if err != nil {
+			log.Fatal().Msg("error : " + err.Error() + "\nmessage : " + v)
+		}
+		message.queries[k] = stmt
+	}
+	return message
+}
+
+var (
+	setMessage       = "setMessage"
+	setMessageFields = `("id", "order_id", "sender_id", "sender_role", "body", "attachment", "created_at")`
+	setMessageSQL    = `INSERT INTO "order_messages" ` + setMessageFields + ` VALUES ($1,$2,$3,$4,$5,$6,$7)`
+
+	listOfMessages       = "listOfMessages"
+	listOfMessagesFields = `"id", "order_id", "sender_id", "sender_role", "body", "attachment", "read_at", "created_at"`
+	listOfMessagesCond   = `WHERE "order_id" = $1 ORDER BY "created_at" DESC LIMIT $2 OFFSET $3`
+	listOfMessagesSQL    = `SELECT ` + listOfMessagesFields + ` FROM "order_messages" ` + listOfMessagesCond
+
+	// messages of the other participant are the ones the reader has to read
+	countUnreadMessages    = "countUnreadMessages"
+	countUnreadMessagesSQL = `SELECT COUNT(*) FROM "order_messages" WHERE "order_id" = $1 AND "sender_id" <> $2 AND "read_at" IS NULL`
+
+	markMessagesAsRead    = "markMessagesAsRead"
+	markMessagesAsReadSQL = `UPDATE "order_messages" SET "read_at" = $3 WHERE "order_id" = $1 AND "sender_id" <> $2 AND "read_at" IS NULL`
+
+	messageQueries = map[string]string{
+		setMessage:          setMessageSQL,
+		listOfMessages:      listOfMessagesSQL,
+		countUnreadMessages: countUnreadMessagesSQL,
+		markMessagesAsRead:  markMessagesAsReadSQL,
+	}
+)
+
+func (c *message) SetMessage(ctx context.Context, param *MessageBaseModel) error {
+	_, err := c.queries[setMessage].ExecContext(ctx,
+		param.ID, param.OrderID, param.SenderID, param.SenderRole, param.Body, param.Attachment, param.CreatedAt)
+	return err
+}
+
+// ListOfMessages returns the conversation newest first
+func (c *message) ListOfMessages(ctx context.Context, orderID string, limit, offset int) ([]MessageBaseModel, error) {
+	var result []MessageBaseModel
+	err := c.queries[listOfMessages].SelectContext(ctx, &result, orderID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *message) CountUnreadMessages(ctx context.Context, orderID, readerID string) (int, error) {
+	var result int
+	err := c.queries[countUnreadMessages].GetContext(ctx, &result, orderID, readerID)
+	if err != nil {
+		return 0, err
+	}
+	return result, nil
+}
+
+func (c *message) MarkMessagesAsRead(ctx context.Context, orderID, readerID string, readAt time.Time) (int64, error) {
+	res, err := c.queries[markMessagesAsRead].ExecContext(ctx, orderID, readerID, readAt)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
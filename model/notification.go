@@ -0,0 +1,322 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	NotificationChannelPush  = "push"
+	NotificationChannelEmail = "email"
+	NotificationChannelSMS   = "sms"
+
+	NotificationCategoryPayment        = "payment"
+	NotificationCategoryOrderStatus    = "order_status"
+	NotificationCategoryReviewReminder = "review_reminder"
+	NotificationCategoryPromotion      = "promotion"
+)
+
+type (
+	// NotificationData is the key value payload delivered along with the push, it is stored as json
+	NotificationData map[string]string
+
+	NotificationBaseModel struct {
+		ID            int              `db:"id"`
+		UserID        string           `db:"user_id"`
+		Category      string           `db:"category"`
+		Title         string           `db:"title"`
+		Body          string           `db:"body"`
+		Data          NotificationData `db:"data"`
+		Channel       string           `db:"channel"`
+		EmailTemplate sql.NullString   `db:"email_template"` // the notification is mailed as well when set
+		Attempts      int              `db:"attempts"`
+		NextAttemptAt time.Time        `db:"next_attempt_at"`
+		SentAt        sql.NullTime     `db:"sent_at"`
+		FailedAt      sql.NullTime     `db:"failed_at"`
+		LastError     sql.NullString   `db:"last_error"`
+		CreatedAt     time.Time        `db:"created_at"`
+	}
+
+	// InboxNotificationBaseModel is the copy of a notification the user keeps in the app, whether or not the push made it
+	InboxNotificationBaseModel struct {
+		ID        int              `db:"id"`
+		UserID    string           `db:"user_id"`
+		Category  string           `db:"category"`
+		Title     string           `db:"title"`
+		Body      string           `db:"body"`
+		Data      NotificationData `db:"data"`
+		ReadAt    sql.NullTime     `db:"read_at"`
+		CreatedAt time.Time        `db:"created_at"`
+	}
+)
+
+func (d NotificationData) Value() (driver.Value, error) {
+	if d == nil {
+		return "{}", nil
+	}
+	content, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	return string(content), nil
+}
+
+func (d *NotificationData) Scan(src interface{}) error {
+	var content []byte
+	switch v := src.(type) {
+	case nil:
+		*d = nil
+		return nil
+	case []byte:
+		content = v
+	case string:
+		content = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into NotificationData", src)
+	}
+	return json.Unmarshal(content, d)
+}
+
+type Notification interface {
+	EnqueueNotification(ctx context.Context, param *NotificationBaseModel) error
+	ClaimNotifications(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]NotificationBaseModel, error)
+	MarkNotificationSent(ctx context.Context, notificationID int, sentAt time.Time) error
+	RetryNotification(ctx context.Context, notificationID int, nextAttemptAt time.Time, lastError string) error
+	FailNotification(ctx context.Context, notificationID int, failedAt time.Time, lastError string) error
+	DeferNotification(ctx context.Context, notificationID int, nextAttemptAt time.Time) error
+	EnqueuePromotion(ctx context.Context, role string, param *NotificationBaseModel) (int64, error)
+	ListOfInboxNotifications(ctx context.Context, userID string, cursor, limit int, now time.Time) ([]InboxNotificationBaseModel, error)
+	CountUnreadNotifications(ctx context.Context, userID string, now time.Time) (int, error)
+	MarkNotificationAsRead(ctx context.Context, userID string, notificationID int, readAt time.Time) (bool, error)
+	MarkAllNotificationsAsRead(ctx context.Context, userID string, readAt time.Time) (int64, error)
+}
+
+type notification struct {
+	db      *sqlx.DB
+	queries map[string]*sqlx.Stmt
+}
+
+func NewNotification(db *sqlx.DB) Notification {
+	notification := new(notification)
+	notification.db = db
+	notification.queries = make(map[string]*sqlx.Stmt, len(notificationQueries))
+	for k, v := range notificationQueries {
+		stmt, err := db.Preparex(v)
+		if err != nil {
+			log.Fatal().Msg("error : " + err.Error() + "\nnotification : " + v)
+		}
+		notification.queries[k] = stmt
+	}
+	return notification
+}
+
+var (
+	notificationFields = `"id", "user_id", "category", "title", "body", "data", "channel", "email_template", "attempts", "next_attempt_at", "sent_at", "failed_at", "last_error", "created_at"`
+
+	enqueueNotificationFields = `("notification_id", "user_id", "category", "title", "body", "data", "next_attempt_at", "created_at", "channel", "email_template")`
+	enqueueNotificationSQL    = `INSERT INTO "notification_outbox" ` + enqueueNotificationFields + ` VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10)`
+
+	setInboxNotificationFields = `("user_id", "category", "title", "body", "data", "created_at")`
+	setInboxNotificationSQL    = `INSERT INTO "notifications" ` + setInboxNotificationFields + ` VALUES ($1,$2,$3,$4,$5,$6) RETURNING "id"`
+
+	// a promotion goes at once to every active user of the role who did not opt out of promotions, the push carries the id of the inbox copy of each user
+	enqueuePromotion        = "enqueuePromotion"
+	enqueuePromotionOptedIn = `NOT EXISTS (SELECT 1 FROM "notification_preferences" WHERE "user_id" = "users"."id" AND "promotions_enabled" = FALSE)`
+	enqueuePromotionInbox   = `INSERT INTO "notifications" ` + setInboxNotificationFields + ` SELECT "id", $2, $3, $4, $5, $6 FROM "users" ` +
+		`WHERE "role" = $1 AND "is_active" = TRUE AND ` + enqueuePromotionOptedIn + ` RETURNING "id", "user_id", "category", "title", "body", "data", "created_at"`
+	enqueuePromotionSQL = `WITH "inbox" AS (` + enqueuePromotionInbox + `) INSERT INTO "notification_outbox" ` + enqueueNotificationFields +
+		` SELECT "id", "user_id", "category", "title", "body", "data" || jsonb_build_object('notification_id', "id"::TEXT), "created_at", "created_at", '` + NotificationChannelPush + `', NULL FROM "inbox"`
+
+	inboxNotificationFields = `"id", "user_id", "category", "title", "body", "data", "read_at", "created_at"`
+
+	// notifications scheduled for later only show up once they are due, the cursor is the id of the last notification of the previous page
+	listOfInboxNotifications       = "listOfInboxNotifications"
+	listOfInboxNotificationsCursor = `($2 = 0 OR ("created_at", "id") < (SELECT "created_at", "id" FROM "notifications" WHERE "id" = $2 AND "user_id" = $1))`
+	listOfInboxNotificationsCond   = `WHERE "user_id" = $1 AND "created_at" <= $3 AND ` + listOfInboxNotificationsCursor + ` ORDER BY "created_at" DESC, "id" DESC LIMIT $4`
+	listOfInboxNotificationsSQL    = `SELECT ` + inboxNotificationFields + ` FROM "notifications" ` + listOfInboxNotificationsCond
+
+	countUnreadNotifications    = "countUnreadNotifications"
+	countUnreadNotificationsSQL = `SELECT COUNT(*) FROM "notifications" WHERE "user_id" = $1 AND "read_at" IS NULL AND "created_at" <= $2`
+
+	markNotificationAsRead    = "markNotificationAsRead"
+	markNotificationAsReadSQL = `UPDATE "notifications" SET "read_at" = COALESCE("read_at", $3) WHERE "id" = $2 AND "user_id" = $1 AND "created_at" <= $3`
+
+	markAllNotificationsAsRead    = "markAllNotificationsAsRead"
+	markAllNotificationsAsReadSQL = `UPDATE "notifications" SET "read_at" = $2 WHERE "user_id" = $1 AND "read_at" IS NULL AND "created_at" <= $2`
+
+	// claiming leases the due notifications by pushing their next attempt past the lease, a dispatcher which
+	// dies while sending leaves them to be picked up again once the lease is over
+	claimNotifications        = "claimNotifications"
+	claimNotificationsPending = `SELECT "id" FROM "notification_outbox" WHERE "sent_at" IS NULL AND "failed_at" IS NULL AND "next_attempt_at" <= $1 ` +
+		`ORDER BY "next_attempt_at" ASC LIMIT $3 FOR UPDATE SKIP LOCKED`
+	claimNotificationsSet = `"attempts" = "attempts" + 1, "next_attempt_at" = $2`
+	claimNotificationsSQL = `UPDATE "notification_outbox" SET ` + claimNotificationsSet + ` WHERE "id" IN (` + claimNotificationsPending + `) RETURNING ` + notificationFields
+
+	markNotificationSent    = "markNotificationSent"
+	markNotificationSentSQL = `UPDATE "notification_outbox" SET "sent_at" = $2, "last_error" = NULL WHERE "id" = $1`
+
+	retryNotification    = "retryNotification"
+	retryNotificationSQL = `UPDATE "notification_outbox" SET "next_attempt_at" = $2, "last_error" = $3 WHERE "id" = $1`
+
+	failNotification    = "failNotification"
+	failNotificationSQL = `UPDATE "notification_outbox" SET "failed_at" = $2, "last_error" = $3 WHERE "id" = $1`
+
+	// holding a notification back is not a failed attempt, the attempt the claim counted is given back
+	deferNotification    = "deferNotification"
+	deferNotificationSQL = `UPDATE "notification_outbox" SET "next_attempt_at" = $2, "attempts" = GREATEST("attempts" - 1, 0) WHERE "id" = $1`
+
+	notificationQueries = map[string]string{
+		claimNotifications:         claimNotificationsSQL,
+		markNotificationSent:       markNotificationSentSQL,
+		retryNotification:          retryNotificationSQL,
+		failNotification:           failNotificationSQL,
+		deferNotification:          deferNotificationSQL,
+		enqueuePromotion:           enqueuePromotionSQL,
+		listOfInboxNotifications:   listOfInboxNotificationsSQL,
+		countUnreadNotifications:   countUnreadNotificationsSQL,
+		markNotificationAsRead:     markNotificationAsReadSQL,
+		markAllNotificationsAsRead: markAllNotificationsAsReadSQL,
+	}
+)
+
+// enqueueNotificationTx writes the notification within tx so it is only sent when the change it tells about is committed.
+// The user keeps a copy in the inbox, it shows up there when the notification is due. It is pushed and,
+// with an email template, mailed as well.
+func enqueueNotificationTx(ctx context.Context, tx *sql.Tx, param *NotificationBaseModel) error {
+	dueAt := param.NextAttemptAt
+	if dueAt.IsZero() {
+		dueAt = param.CreatedAt
+	}
+
+	var inboxID int
+	err := tx.QueryRowContext(ctx, setInboxNotificationSQL, param.UserID, param.Category, param.Title, param.Body, param.Data, dueAt).Scan(&inboxID)
+	if err != nil {
+		return err
+	}
+
+	// tapping the push opens the inbox entry, the app marks it as read with the id
+	data := NotificationData{"notification_id": strconv.Itoa(inboxID)}
+	for k, v := range param.Data {
+		data[k] = v
+	}
+	_, err = tx.ExecContext(ctx, enqueueNotificationSQL, inboxID, param.UserID, param.Category, param.Title, param.Body, data, dueAt, param.CreatedAt, NotificationChannelPush, nil)
+	if err != nil {
+		return err
+	}
+
+	if param.EmailTemplate.Valid {
+		_, err = tx.ExecContext(ctx, enqueueNotificationSQL, inboxID, param.UserID, param.Category, param.Title, param.Body, data, dueAt, param.CreatedAt, NotificationChannelEmail, param.EmailTemplate)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *notification) EnqueueNotification(ctx context.Context, param *NotificationBaseModel) error {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if rollback := tx.Rollback(); rollback == nil {
+			log.Info().Msg("rolling back changes")
+		}
+	}()
+
+	err = enqueueNotificationTx(ctx, tx, param)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (c *notification) ClaimNotifications(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]NotificationBaseModel, error) {
+	var result []NotificationBaseModel
+	err := c.queries[claimNotifications].SelectContext(ctx, &result, now, now.Add(lease), limit)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *notification) MarkNotificationSent(ctx context.Context, notificationID int, sentAt time.Time) error {
+	_, err := c.queries[markNotificationSent].ExecContext(ctx, notificationID, sentAt)
+	return err
+}
+
+func (c *notification) RetryNotification(ctx context.Context, notificationID int, nextAttemptAt time.Time, lastError string) error {
+	_, err := c.queries[retryNotification].ExecContext(ctx, notificationID, nextAttemptAt, truncate(lastError, 512))
+	return err
+}
+
+func (c *notification) FailNotification(ctx context.Context, notificationID int, failedAt time.Time, lastError string) error {
+	_, err := c.queries[failNotification].ExecContext(ctx, notificationID, failedAt, truncate(lastError, 512))
+	return err
+}
+
+func (c *notification) DeferNotification(ctx context.Context, notificationID int, nextAttemptAt time.Time) error {
+	_, err := c.queries[deferNotification].ExecContext(ctx, notificationID, nextAttemptAt)
+	return err
+}
+
+func (c *notification) EnqueuePromotion(ctx context.Context, role string, param *NotificationBaseModel) (int64, error) {
+	res, err := c.queries[enqueuePromotion].ExecContext(ctx, role, param.Category, param.Title, param.Body, param.Data, param.CreatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (c *notification) ListOfInboxNotifications(ctx context.Context, userID string, cursor, limit int, now time.Time) ([]InboxNotificationBaseModel, error) {
+	var result []InboxNotificationBaseModel
+	err := c.queries[listOfInboxNotifications].SelectContext(ctx, &result, userID, cursor, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *notification) CountUnreadNotifications(ctx context.Context, userID string, now time.Time) (int, error) {
+	var result int
+	err := c.queries[countUnreadNotifications].GetContext(ctx, &result, userID, now)
+	if err != nil {
+		return 0, err
+	}
+	return result, nil
+}
+
+func (c *notification) MarkNotificationAsRead(ctx context.Context, userID string, notificationID int, readAt time.Time) (bool, error) {
+	res, err := c.queries[markNotificationAsRead].ExecContext(ctx, userID, notificationID, readAt)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (c *notification) MarkAllNotificationsAsRead(ctx context.Context, userID string, readAt time.Time) (int64, error) {
+	res, err := c.queries[markAllNotificationsAsRead].ExecContext(ctx, userID, readAt)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func truncate(s string, length int) string {
+	if len(s) > length {
+		return s[:length]
+	}
+	return s
+}
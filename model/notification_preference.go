@@ -0,0 +1,106 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// quiet hours are read in this timezone until the user picks another one
+	DefaultNotificationTimezone = "Asia/Jakarta"
+)
+
+type (
+	NotificationPreferenceBaseModel struct {
+		UserID              string         `db:"user_id"`
+		PushEnabled         bool           `db:"push_enabled"`
+		EmailEnabled        bool           `db:"email_enabled"`
+		SMSEnabled          bool           `db:"sms_enabled"`
+		OrderUpdatesEnabled bool           `db:"order_updates_enabled"`
+		RemindersEnabled    bool           `db:"reminders_enabled"`
+		PromotionsEnabled   bool           `db:"promotions_enabled"`
+		QuietHoursStart     sql.NullString `db:"quiet_hours_start"` // HH:MM, no quiet hours when not set
+		QuietHoursEnd       sql.NullString `db:"quiet_hours_end"`
+		Timezone            string         `db:"timezone"`
+		UpdatedAt           time.Time      `db:"updated_at"`
+	}
+)
+
+// DefaultNotificationPreference is what a user who never changed the preferences gets
+func DefaultNotificationPreference(userID string) *NotificationPreferenceBaseModel {
+	return &NotificationPreferenceBaseModel{
+		UserID:              userID,
+		PushEnabled:         true,
+		EmailEnabled:        true,
+		OrderUpdatesEnabled: true,
+		RemindersEnabled:    true,
+		PromotionsEnabled:   true,
+		Timezone:            DefaultNotificationTimezone,
+	}
+}
+
+type NotificationPreference interface {
+	GetNotificationPreference(ctx context.Context, userID string) (*NotificationPreferenceBaseModel, error)
+	SetNotificationPreference(ctx context.Context, param *NotificationPreferenceBaseModel) error
+}
+
+type notificationPreference struct {
+	db      *sqlx.DB
+	queries map[string]*sqlx.Stmt
+}
+
+func NewNotificationPreference(db *sqlx.DB) NotificationPreference {
+	notificationPreference := new(notificationPreference)
+	notificationPreference.db = db
+	notificationPreference.queries = make(map[string]*sqlx.Stmt, len(notificationPreferenceQueries))
+	for k, v := range notificationPreferenceQueries {
+		stmt, err := db.Preparex(v)
+		if err != nil {
+			log.Fatal().Msg("error : " + err.Error() + "\nnotification preference : " + v)
+		}
+		notificationPreference.queries[k] = stmt
+	}
+	return notificationPreference
+}
+
+var (
+	notificationPreferenceFields = `"user_id", "push_enabled", "email_enabled", "sms_enabled", "order_updates_enabled", "reminders_enabled", ` +
+		`"promotions_enabled", "quiet_hours_start", "quiet_hours_end", "timezone", "updated_at"`
+
+	getNotificationPreference    = "getNotificationPreference"
+	getNotificationPreferenceSQL = `SELECT ` + notificationPreferenceFields + ` FROM "notification_preferences" WHERE "user_id" = $1`
+
+	setNotificationPreference         = "setNotificationPreference"
+	setNotificationPreferenceConflict = `ON CONFLICT ("user_id") DO UPDATE SET "push_enabled" = EXCLUDED."push_enabled", ` +
+		`"email_enabled" = EXCLUDED."email_enabled", "sms_enabled" = EXCLUDED."sms_enabled", ` +
+		`"order_updates_enabled" = EXCLUDED."order_updates_enabled", "reminders_enabled" = EXCLUDED."reminders_enabled", ` +
+		`"promotions_enabled" = EXCLUDED."promotions_enabled", "quiet_hours_start" = EXCLUDED."quiet_hours_start", ` +
+		`"quiet_hours_end" = EXCLUDED."quiet_hours_end", "timezone" = EXCLUDED."timezone", "updated_at" = EXCLUDED."updated_at"`
+	setNotificationPreferenceSQL = `INSERT INTO "notification_preferences" (` + notificationPreferenceFields + `) ` +
+		`VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11) ` + setNotificationPreferenceConflict
+
+	notificationPreferenceQueries = map[string]string{
+		getNotificationPreference: getNotificationPreferenceSQL,
+		setNotificationPreference: setNotificationPreferenceSQL,
+	}
+)
+
+func (c *notificationPreference) GetNotificationPreference(ctx context.Context, userID string) (*NotificationPreferenceBaseModel, error) {
+	result := new(NotificationPreferenceBaseModel)
+	err := c.queries[getNotificationPreference].GetContext(ctx, result, userID)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *notificationPreference) SetNotificationPreference(ctx context.Context, param *NotificationPreferenceBaseModel) error {
+	_, err := c.queries[setNotificationPreference].ExecContext(ctx, param.UserID, param.PushEnabled, param.EmailEnabled, param.SMSEnabled,
+		param.OrderUpdatesEnabled, param.RemindersEnabled, param.PromotionsEnabled, param.QuietHoursStart, param.QuietHoursEnd,
+		param.Timezone, param.UpdatedAt)
+	return err
+}
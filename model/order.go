@@ -4,6 +4,8 @@ import (
 	"context"
 	"database/sql"
 	"e-montir/api/handler"
+	"e-montir/pkg/mailer"
+	"strconv"
 	"strings"
 	"time"
 
@@ -26,6 +28,8 @@ type (
 		Date            string         `db:"date"`
 		MechanicID      sql.NullInt64  `db:"mechanic_id"`
 		InvoiceID       string         `db:"invoice_id"`
+		ExpiresAt       sql.NullTime   `db:"expires_at"`
+		AcceptedAt      sql.NullTime   `db:"accepted_at"`
 	}
 
 	OrderItem struct {
@@ -48,26 +52,47 @@ type (
 	}
 
 	OrderMechanic struct {
-		ID               string         `db:"id"`
+		ID               int            `db:"id"`
 		Name             string         `db:"name"`
 		PhoneNumber      string         `db:"phone_number"`
 		CompletedService int            `db:"completed_service"`
 		Picture          sql.NullString `db:"picture"`
 		Status           bool           `db:"status"`
+		Rating           float64        `db:"rating"`
+		ReviewCount      int            `db:"review_count"`
+	}
+
+	CancelledOrder struct {
+		ID             string
+		PreviousStatus string
+		TotalPrice     float64
+	}
+
+	OrderStatusHistory struct {
+		ID         int            `db:"id"`
+		OrderID    string         `db:"order_id"`
+		FromStatus sql.NullString `db:"from_status"`
+		ToStatus   string         `db:"to_status"`
+		Actor      string         `db:"actor"`
+		Reason     sql.NullString `db:"reason"`
+		CreatedAt  time.Time      `db:"created_at"`
 	}
 )
 
 type Order interface {
 	SetOrder(ctx context.Context, userID string, param *OrderBaseModel) error
-	AssignMechanic(ctx context.Context, orderID string) error
 	CheckOrder(ctx context.Context, orderID string) (*OrderBaseModel, error)
 	ListOfOrders(ctx context.Context, userID string) ([]OrderBaseModel, error)
 	ListOfOrderItems(ctx context.Context, orderID string) ([]OrderItem, error)
 	OrderLocation(ctx context.Context, userAddressID string) (*OrderLocation, error)
 	GetOrderMechanic(ctx context.Context, mechanicID int) (*OrderMechanic, error)
-	UpdateOrderStatus(ctx context.Context, orderID, status, invoiceID string) error
+	UpdateOrderStatus(ctx context.Context, orderID, status, invoiceID, actor string) error
 	OrderCompleted(ctx context.Context, invoiceID string) error
 	GetOrderByOrderID(ctx context.Context, orderID string) (*OrderBaseModel, error)
+	GetOrderByInvoiceID(ctx context.Context, invoiceID string) (*OrderBaseModel, error)
+	ListOfOrderStatusHistory(ctx context.Context, orderID string) ([]OrderStatusHistory, error)
+	CancelOrder(ctx context.Context, orderID, actor, reason string) (*CancelledOrder, error)
+	ListOfExpiredOrders(ctx context.Context, now time.Time) ([]string, error)
 }
 
 type order struct {
@@ -92,9 +117,9 @@ func NewOrder(db *sqlx.DB) Order {
 var (
 	setOrder        = "setOrder"
 	setOrderField1  = `("id", "user_id", "user_address_id", "date", "time_slot", "created_at", `
-	setOrderFields2 = `"total_price", "motor_cycle_brand_name", "status_order", "invoice_id")`
+	setOrderFields2 = `"total_price", "motor_cycle_brand_name", "status_order", "invoice_id", "expires_at")`
 	setOrderFields  = setOrderField1 + setOrderFields2
-	setOrderSQL     = `INSERT INTO "orders" ` + setOrderFields + ` VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10)`
+	setOrderSQL     = `INSERT INTO "orders" ` + setOrderFields + ` VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)`
 
 	getServiceIDSQL = `SELECT "service_id" FROM "cart_items" WHERE "cart_id" = $1`
 
@@ -104,8 +129,9 @@ var (
 	reduceEmployeeNumCondition = `WHERE "date" = $1 AND "time" = $2 AND "employee_num" > 0`
 	reduceEmployeeNumSQL       = `UPDATE "time_slots" SET "employee_num"="employee_num" - 1 ` + reduceEmployeeNumCondition
 
+	// gives the slot of a cancelled or completed order back, without a cap so a fully booked slot recovers
 	increaseEmployeeNum          = "increaseEmployeeNum"
-	increaseEmployeeNumCondition = `WHERE "date" = $1 AND "time" = $2 AND "employee_num" > 0`
+	increaseEmployeeNumCondition = `WHERE "date" = $1 AND "time" = $2`
 	increaseEmployeeNumSQL       = `UPDATE "time_slots" SET "employee_num"="employee_num" + 1 ` + increaseEmployeeNumCondition
 
 	checkEmployeeAvailability    = "employeeAvailability"
@@ -114,21 +140,12 @@ var (
 	removeOrder    = "removeOrder"
 	removeOrderSQL = `DELETE FROM "orders" WHERE id = $1`
 
-	assignMechanic    = "assignMontirToOrder"
-	assignMechanicSQL = `UPDATE "orders" SET "mechanic_id" = $2 WHERE "id" = $1`
-
 	updateOrderStatusByOrderID    = "updateOrderStatusByOrderID"
 	updateOrderStatusByOrderIDSQL = `UPDATE "orders" SET "status_order" = $2, "status_detail" = $3 WHERE "id" = $1`
 
 	updateOrderStatusByInvoiceID    = "updateOrderStatusByInvoiceID"
 	updateOrderStatusByInvoiceIDSQL = `UPDATE "orders" SET "status_order" = $2, "status_detail" = $3 WHERE "invoice_id" = $1`
 
-	getMechanicIDs    = "getMechanic"
-	getMechanicIDsSQL = `SELECT "id" FROM "mechanics" ORDER BY "is_available" DESC`
-
-	updateMechanicAvailability    = "updateMechanicAvailability"
-	updateMechanicAvailabilitySQL = `UPDATE "mechanics" SET "is_available" = $2 WHERE "id" = $1`
-
 	updateMechanicCompletedService    = "updateMechanicCompletedService"
 	updateMechanicCompletedServiceSQL = `UPDATE "mechanics" SET "completed_service" = "completed_service" + 1 WHERE "id" = $1`
 
@@ -143,15 +160,19 @@ var (
 
 	getOrderListByID    = "getOrder"
 	getOrderListField1  = `"id", "description", "total_price", "user_address_id", "created_at", "status_detail", `
-	getOrderListField2  = `"status_order", "user_id", "motor_cycle_brand_name", "time_slot", "date", "mechanic_id", "invoice_id"`
-	getOrderListField   = getOrderListField1 + getOrderListField2
+	getOrderListField2  = `"status_order", "user_id", "motor_cycle_brand_name", "time_slot", "date", "mechanic_id", "invoice_id", `
+	getOrderListField3  = `"expires_at", "accepted_at"`
+	getOrderListField   = getOrderListField1 + getOrderListField2 + getOrderListField3
 	getOrderListByIDSQL = `SELECT ` + getOrderListField + `FROM "orders" WHERE "id" = $1`
 
+	getOrderListByInvoiceID    = "getOrderByInvoiceID"
+	getOrderListByInvoiceIDSQL = `SELECT ` + getOrderListField + `FROM "orders" WHERE "invoice_id" = $1`
+
 	getOrderListByUserID    = "getOrderByUserID"
 	getOrderListByUserIDSQL = `SELECT ` + getOrderListField + `FROM "orders" WHERE "user_id" = $1 ORDER BY "created_at" DESC`
 
 	getMechanic       = "getMechanic"
-	getMechanicFields = `"id", "name", "phone_number", "completed_service", "picture"`
+	getMechanicFields = `"id", "name", "phone_number", "completed_service", "picture", "rating", "review_count"`
 	getMechanicSQL    = `SELECT ` + getMechanicFields + ` FROM "mechanics" WHERE "id" = $1`
 
 	getOrderByInvoiceID    = "getOrderIDByInvoiceID"
@@ -163,15 +184,30 @@ var (
 	getServiceIDByOrderID    = "getServiceIDByOrderID"
 	getServiceIDByOrderIDSQL = `SELECT "service_id" from "order_items" WHERE "order_id" = $1`
 
+	getOrderStatusForUpdateSQL            = `SELECT "id", "user_id", "invoice_id", "total_price", "status_order" FROM "orders" WHERE "id" = $1 FOR UPDATE`
+	getOrderStatusByInvoiceIDForUpdateSQL = `SELECT "id", "status_order" FROM "orders" WHERE "invoice_id" = $1 FOR UPDATE`
+
+	getOrderSlotNPriceSQL = `SELECT "time_slot", "date", "total_price" FROM "orders" WHERE "id" = $1`
+
+	setOrderStatusHistoryFields = `("order_id", "from_status", "to_status", "actor", "created_at", "reason")`
+	setOrderStatusHistorySQL    = `INSERT INTO "order_status_history" ` + setOrderStatusHistoryFields + ` VALUES ($1,$2,$3,$4,$5,$6)`
+
+	getOrderStatusHistory       = "getOrderStatusHistory"
+	getOrderStatusHistoryFields = `"id", "order_id", "from_status", "to_status", "actor", "reason", "created_at"`
+	getOrderStatusHistoryCond   = `WHERE "order_id" = $1 ORDER BY "created_at" ASC, "id" ASC`
+	getOrderStatusHistorySQL    = `SELECT ` + getOrderStatusHistoryFields + ` FROM "order_status_history" ` + getOrderStatusHistoryCond
+
+	getExpiredOrders     = "getExpiredOrders"
+	getExpiredOrdersCond = `WHERE "status_order" = $1 AND "expires_at" IS NOT NULL AND "expires_at" <= $2 ORDER BY "expires_at" LIMIT 100`
+	getExpiredOrdersSQL  = `SELECT "id" FROM "orders" ` + getExpiredOrdersCond
+
 	orderQueries = map[string]string{
 		setOrder:                       setOrderSQL,
 		reduceEmployeeNum:              reduceEmployeeNumSQL,
 		checkEmployeeAvailability:      checkEmployeeAvailabilitySQL,
 		removeOrder:                    removeOrderSQL,
-		assignMechanic:                 assignMechanicSQL,
-		getMechanicIDs:                 getMechanicIDsSQL,
-		updateMechanicAvailability:     updateMechanicAvailabilitySQL,
 		getOrderListByID:               getOrderListByIDSQL,
+		getOrderListByInvoiceID:        getOrderListByInvoiceIDSQL,
 		getOrderItems:                  getOrderItemsSQL,
 		getOrderLocation:               getOrderLocationSQL,
 		getMechanic:                    getMechanicSQL,
@@ -182,6 +218,8 @@ var (
 		updateMechanicCompletedService: updateMechanicCompletedServiceSQL,
 		updateNumberOfServiceOrder:     updateNumberOfServiceOrderSQL,
 		getServiceIDByOrderID:          getServiceIDByOrderIDSQL,
+		getOrderStatusHistory:          getOrderStatusHistorySQL,
+		getExpiredOrders:               getExpiredOrdersSQL,
 	}
 
 	OrderDetail = map[int]string{
@@ -189,6 +227,8 @@ var (
 		2: "Montir is on the way to you",
 		3: "Montir is arrived at your place",
 		4: "Service done",
+		5: "Order has been cancelled",
+		6: "Payment has been refunded",
 	}
 
 	OrderStatus = map[int]string{
@@ -197,9 +237,146 @@ var (
 		3: "On the way",
 		4: "Arrived",
 		5: "Done",
+		6: "Cancelled",
+		7: "Refunded",
+	}
+
+	orderStatusDetail = map[string]string{
+		OrderStatus[2]: OrderDetail[1],
+		OrderStatus[3]: OrderDetail[2],
+		OrderStatus[4]: OrderDetail[3],
+		OrderStatus[5]: OrderDetail[4],
+		OrderStatus[6]: OrderDetail[5],
+		OrderStatus[7]: OrderDetail[6],
+	}
+
+	// the customer is pushed about these statuses, along with the change itself, and mailed with the email template
+	orderStatusNotifications = map[string]struct{ Category, Title, Body, EmailTemplate string }{
+		OrderStatus[2]: {Category: NotificationCategoryPayment, Title: "payment success", Body: "preparing your order", EmailTemplate: mailer.TemplatePaymentReceipt},
+		OrderStatus[3]: {Category: NotificationCategoryOrderStatus, Title: "mechanic is on the way", Body: "mechanic is on the way to your place. please wait", EmailTemplate: mailer.TemplateOrderStatus},
+		OrderStatus[5]: {Category: NotificationCategoryOrderStatus, Title: "service done", Body: "service done, looking forward to your next order", EmailTemplate: mailer.TemplateOrderStatus},
+		OrderStatus[6]: {Category: NotificationCategoryOrderStatus, Title: "order cancelled", Body: "your order has been cancelled", EmailTemplate: mailer.TemplateOrderStatus},
+		OrderStatus[7]: {Category: NotificationCategoryPayment, Title: "payment refunded", Body: "the payment of your order has been refunded", EmailTemplate: mailer.TemplatePaymentReceipt},
+	}
+
+	// a while after the service is done the customer is asked to review it
+	reviewReminderDelay = 2 * time.Hour
+
+	// orderTransitions lists, for every order status, the statuses the order
+	// is allowed to move to next. Statuses without an entry are final.
+	orderTransitions = map[string][]string{
+		OrderStatus[1]: {OrderStatus[2], OrderStatus[6]},
+		OrderStatus[2]: {OrderStatus[3], OrderStatus[6]},
+		OrderStatus[3]: {OrderStatus[4]},
+		OrderStatus[4]: {OrderStatus[5]},
+		OrderStatus[5]: {OrderStatus[7]},
+		OrderStatus[6]: {OrderStatus[7]},
 	}
 )
 
+// changes made by a user record the id of the user as actor
+const (
+	OrderActorSystem  = "system"
+	OrderActorPayment = "payment"
+)
+
+// CanTransitOrderStatus reports whether an order in status from may be moved to status to.
+func CanTransitOrderStatus(from, to string) bool {
+	for _, next := range orderTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// transitOrderStatus locks the order row, validates the transition against the
+// order state machine, updates the order and records the change in the history table.
+// It returns the status the order had before the transition.
+func transitOrderStatus(ctx context.Context, tx *sql.Tx, orderID, status, actor, reason string) (string, error) {
+	var id, userID, invoiceID string
+	var totalPrice float64
+	var currentStatus sql.NullString
+	err := tx.QueryRowContext(ctx, getOrderStatusForUpdateSQL, orderID).Scan(&id, &userID, &invoiceID, &totalPrice, &currentStatus)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", &handler.OrderNotFound
+		}
+		return "", err
+	}
+
+	if !CanTransitOrderStatus(currentStatus.String, status) {
+		return currentStatus.String, &handler.InvalidOrderStatusTransition
+	}
+
+	_, err = tx.ExecContext(ctx, updateOrderStatusByOrderIDSQL, orderID, status, orderStatusDetail[status])
+	if err != nil {
+		return currentStatus.String, err
+	}
+
+	statusReason := sql.NullString{String: reason, Valid: reason != ""}
+	_, err = tx.ExecContext(ctx, setOrderStatusHistorySQL, orderID, currentStatus, status, actor, time.Now(), statusReason)
+	if err != nil {
+		return currentStatus.String, err
+	}
+
+	now := time.Now()
+	if notif, ok := orderStatusNotifications[status]; ok {
+		data := NotificationData{
+			"order_id":    orderID,
+			"status":      status,
+			"redirect":    "/orders/" + orderID,
+			"invoice_id":  invoiceID,
+			"total_price": formatPrice(totalPrice),
+		}
+		if notif.Category == NotificationCategoryPayment {
+			data["transaction_at"] = now.Format("2 Jan 2006 15:04")
+		}
+		err = enqueueNotificationTx(ctx, tx, &NotificationBaseModel{
+			UserID:        userID,
+			Category:      notif.Category,
+			Title:         notif.Title,
+			Body:          notif.Body,
+			Data:          data,
+			EmailTemplate: sql.NullString{String: notif.EmailTemplate, Valid: notif.EmailTemplate != ""},
+			CreatedAt:     now,
+		})
+		if err != nil {
+			return currentStatus.String, err
+		}
+	}
+
+	if status == OrderStatus[5] {
+		err = enqueueNotificationTx(ctx, tx, &NotificationBaseModel{
+			UserID:        userID,
+			Category:      NotificationCategoryReviewReminder,
+			Title:         "how was the service?",
+			Body:          "rate the service and the mechanic of your order",
+			Data:          NotificationData{"order_id": orderID, "redirect": "/orders/" + orderID + "/review"},
+			NextAttemptAt: now.Add(reviewReminderDelay),
+			CreatedAt:     now,
+		})
+		if err != nil {
+			return currentStatus.String, err
+		}
+	}
+
+	return currentStatus.String, nil
+}
+
+// formatPrice writes the rupiah amount with dots between the thousands, e.g. 150.000
+func formatPrice(price float64) string {
+	digits := strconv.FormatFloat(price, 'f', 0, 64)
+	var result strings.Builder
+	for i, v := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 && digits[i-1] != '-' {
+			result.WriteByte('.')
+		}
+		result.WriteRune(v)
+	}
+	return result.String()
+}
+
 func (c *order) SetOrder(ctx context.Context, userID string, param *OrderBaseModel) error {
 	var serviceIDs []string
 	tx, err := c.db.Begin()
@@ -238,7 +415,7 @@ func (c *order) SetOrder(ctx context.Context, userID string, param *OrderBaseMod
 	}
 
 	// nolint(gosec) // false positive
-	_, err = tx.ExecContext(ctx, setOrderSQL, param.ID, userID, param.UserAddressID, param.Date, param.TimeSlot, param.CreatedAt, param.TotalPrice, param.MotorCycleBrand, OrderStatus[1], param.InvoiceID)
+	_, err = tx.ExecContext(ctx, setOrderSQL, param.ID, userID, param.UserAddressID, param.Date, param.TimeSlot, param.CreatedAt, param.TotalPrice, param.MotorCycleBrand, OrderStatus[1], param.InvoiceID, param.ExpiresAt)
 	if err != nil {
 		return err
 	}
@@ -250,47 +427,41 @@ func (c *order) SetOrder(ctx context.Context, userID string, param *OrderBaseMod
 		}
 	}
 
-	_, err = tx.ExecContext(ctx, reduceEmployeeNumSQL, param.Date, param.TimeSlot)
+	_, err = tx.ExecContext(ctx, setOrderStatusHistorySQL, param.ID, nil, OrderStatus[1], userID, param.CreatedAt, nil)
 	if err != nil {
 		return err
 	}
 
-	_, err = tx.ExecContext(ctx, removeCartAppointmentSQL, param.UserID)
-	if err != nil {
-		return err
-	}
-
-	err = tx.Commit()
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
-func (c *order) AssignMechanic(ctx context.Context, orderID string) error {
-	var mechanicID int
-	tx, err := c.db.Begin()
-	if err != nil {
-		return err
-	}
-
-	defer func() {
-		if rollback := tx.Rollback(); rollback == nil {
-			log.Info().Msg("rolling back changes")
-		}
-	}()
-
-	err = tx.QueryRowContext(ctx, getMechanicIDsSQL).Scan(&mechanicID)
+	confirmation := NotificationData{
+		"order_id":    param.ID,
+		"redirect":    "/orders/" + param.ID,
+		"invoice_id":  param.InvoiceID,
+		"date":        param.Date,
+		"time_slot":   param.TimeSlot,
+		"total_price": formatPrice(param.TotalPrice),
+	}
+	if param.ExpiresAt.Valid {
+		confirmation["payment_deadline"] = param.ExpiresAt.Time.Format("2 Jan 2006 15:04")
+	}
+	err = enqueueNotificationTx(ctx, tx, &NotificationBaseModel{
+		UserID:        userID,
+		Category:      NotificationCategoryOrderStatus,
+		Title:         "order placed",
+		Body:          "complete the payment to confirm your appointment",
+		Data:          confirmation,
+		EmailTemplate: sql.NullString{String: mailer.TemplateOrderConfirmation, Valid: true},
+		CreatedAt:     param.CreatedAt,
+	})
 	if err != nil {
 		return err
 	}
 
-	_, err = tx.ExecContext(ctx, assignMechanicSQL, orderID, mechanicID)
+	_, err = tx.ExecContext(ctx, reduceEmployeeNumSQL, param.Date, param.TimeSlot)
 	if err != nil {
 		return err
 	}
 
-	_, err = tx.ExecContext(ctx, updateMechanicAvailabilitySQL, mechanicID, false)
+	_, err = tx.ExecContext(ctx, removeCartAppointmentSQL, param.UserID)
 	if err != nil {
 		return err
 	}
@@ -355,31 +526,42 @@ func (c *order) GetOrderMechanic(ctx context.Context, mechanicID int) (*OrderMec
 	return &result, nil
 }
 
-func (c *order) UpdateOrderStatus(ctx context.Context, orderID, status, invoiceID string) error {
-	statusDetail := map[string]string{
-		OrderStatus[2]: OrderDetail[1],
-		OrderStatus[3]: OrderDetail[2],
-		OrderStatus[4]: OrderDetail[3],
-		OrderStatus[5]: OrderDetail[4],
-	}
-
+func (c *order) UpdateOrderStatus(ctx context.Context, orderID, status, invoiceID, actor string) error {
 	first := status[0]
 	rest := status[1:]
 	orderStatus := strings.ToUpper(string(first)) + rest
-	if orderID != "" {
-		_, err := c.queries[updateOrderStatusByOrderID].ExecContext(ctx, orderID, orderStatus, statusDetail[orderStatus])
-		if err != nil {
-			return err
-		}
+
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
 	}
 
-	if invoiceID != "" {
-		_, err := c.queries[updateOrderStatusByInvoiceID].ExecContext(ctx, invoiceID, orderStatus, statusDetail[orderStatus])
+	defer func() {
+		if rollback := tx.Rollback(); rollback == nil {
+			log.Info().Msg("rolling back changes")
+		}
+	}()
+
+	if orderID == "" {
+		var currentStatus sql.NullString
+		err = tx.QueryRowContext(ctx, getOrderStatusByInvoiceIDForUpdateSQL, invoiceID).Scan(&orderID, &currentStatus)
 		if err != nil {
+			if err == sql.ErrNoRows {
+				return &handler.OrderNotFound
+			}
 			return err
 		}
 	}
 
+	_, err = transitOrderStatus(ctx, tx, orderID, orderStatus, actor, "")
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -402,11 +584,6 @@ func (c *order) OrderCompleted(ctx context.Context, invoiceID string) error {
 		return err
 	}
 
-	_, err = c.db.ExecContext(ctx, updateMechanicAvailabilitySQL, mechanicID, true)
-	if err != nil {
-		return err
-	}
-
 	_, err = c.db.ExecContext(ctx, updateMechanicCompletedServiceSQL, mechanicID)
 	if err != nil {
 		return err
@@ -453,3 +630,74 @@ func (c *order) GetOrderByOrderID(ctx context.Context, orderID string) (*OrderBa
 	}
 	return &result, nil
 }
+
+func (c *order) GetOrderByInvoiceID(ctx context.Context, invoiceID string) (*OrderBaseModel, error) {
+	var result OrderBaseModel
+	err := c.queries[getOrderListByInvoiceID].GetContext(ctx, &result, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *order) ListOfOrderStatusHistory(ctx context.Context, orderID string) ([]OrderStatusHistory, error) {
+	var result []OrderStatusHistory
+	err := c.queries[getOrderStatusHistory].SelectContext(ctx, &result, orderID)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// CancelOrder marks the order as cancelled and gives the reserved time slot back in a single transaction.
+// The assigned mechanic is freed by the status alone, the assignment counts only the jobs in progress.
+func (c *order) CancelOrder(ctx context.Context, orderID, actor, reason string) (*CancelledOrder, error) {
+	var timeSlot, date string
+	var totalPrice float64
+	tx, err := c.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if rollback := tx.Rollback(); rollback == nil {
+			log.Info().Msg("rolling back changes")
+		}
+	}()
+
+	previousStatus, err := transitOrderStatus(ctx, tx, orderID, OrderStatus[6], actor, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.QueryRowContext(ctx, getOrderSlotNPriceSQL, orderID).Scan(&timeSlot, &date, &totalPrice)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, increaseEmployeeNumSQL, date, timeSlot)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return &CancelledOrder{
+		ID:             orderID,
+		PreviousStatus: previousStatus,
+		TotalPrice:     totalPrice,
+	}, nil
+}
+
+// ListOfExpiredOrders returns the orders that are still waiting for payment after their payment deadline.
+func (c *order) ListOfExpiredOrders(ctx context.Context, now time.Time) ([]string, error) {
+	var result []string
+	err := c.queries[getExpiredOrders].SelectContext(ctx, &result, OrderStatus[1], now)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,53 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanTransitOrderStatus(t *testing.T) {
+	tt := []struct {
+		Name    string
+		From    string
+		To      string
+		Allowed bool
+	}{
+		{Name: "Paid", From: OrderStatus[1], To: OrderStatus[2], Allowed: true},
+		{Name: "Cancelled before payment", From: OrderStatus[1], To: OrderStatus[6], Allowed: true},
+		{Name: "Mechanic on the way", From: OrderStatus[2], To: OrderStatus[3], Allowed: true},
+		{Name: "Cancelled after payment", From: OrderStatus[2], To: OrderStatus[6], Allowed: true},
+		{Name: "Mechanic arrived", From: OrderStatus[3], To: OrderStatus[4], Allowed: true},
+		{Name: "Service done", From: OrderStatus[4], To: OrderStatus[5], Allowed: true},
+		{Name: "Done order refunded", From: OrderStatus[5], To: OrderStatus[7], Allowed: true},
+		{Name: "Cancelled order refunded", From: OrderStatus[6], To: OrderStatus[7], Allowed: true},
+
+		{Name: "Skip payment", From: OrderStatus[1], To: OrderStatus[3]},
+		{Name: "Skip the way", From: OrderStatus[2], To: OrderStatus[4]},
+		{Name: "Skip arrival", From: OrderStatus[3], To: OrderStatus[5]},
+		{Name: "Refund before payment", From: OrderStatus[1], To: OrderStatus[7]},
+		{Name: "Back to waiting for payment", From: OrderStatus[2], To: OrderStatus[1]},
+		{Name: "Back to on process", From: OrderStatus[3], To: OrderStatus[2]},
+		{Name: "Back to on the way", From: OrderStatus[4], To: OrderStatus[3]},
+		{Name: "Cancel on the way", From: OrderStatus[3], To: OrderStatus[6]},
+		{Name: "Cancel done order", From: OrderStatus[5], To: OrderStatus[6]},
+		{Name: "Same status", From: OrderStatus[2], To: OrderStatus[2]},
+		{Name: "Leave refunded", From: OrderStatus[7], To: OrderStatus[2]},
+		{Name: "Refund twice", From: OrderStatus[7], To: OrderStatus[7]},
+		{Name: "Reopen cancelled order", From: OrderStatus[6], To: OrderStatus[2]},
+		{Name: "Unknown status", From: "Lost", To: OrderStatus[2]},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			assert.Equal(t, tc.Allowed, CanTransitOrderStatus(tc.From, tc.To))
+		})
+	}
+}
+
+// a refunded order is final, nothing moves it anywhere
+func TestRefundedOrderIsFinal(t *testing.T) {
+	for _, next := range OrderStatus {
+		assert.False(t, CanTransitOrderStatus(OrderStatus[7], next), "Refunded -> %s", next)
+	}
+}
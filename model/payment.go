@@ -0,0 +1,179 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+)
+
+type (
+	PaymentNotification struct {
+		ID                int            `db:"id"`
+		OrderID           string         `db:"order_id"`
+		TransactionID     sql.NullString `db:"transaction_id"`
+		RefundKey         sql.NullString `db:"refund_key"`
+		TransactionStatus string         `db:"transaction_status"`
+		StatusCode        sql.NullString `db:"status_code"`
+		GrossAmount       sql.NullString `db:"gross_amount"`
+		SignatureKey      string         `db:"signature_key"`
+		Payload           string         `db:"payload"`
+		ProcessedAt       sql.NullTime   `db:"processed_at"`
+		CreatedAt         time.Time      `db:"created_at"`
+	}
+
+	PaymentTransaction struct {
+		ID            int            `db:"id"`
+		OrderID       string         `db:"order_id"`
+		TransactionID sql.NullString `db:"transaction_id"`
+		GatewayToken  sql.NullString `db:"gateway_token"`
+		RedirectURL   sql.NullString `db:"redirect_url"`
+		Amount        float64        `db:"amount"`
+		Currency      string         `db:"currency"`
+		PaymentType   sql.NullString `db:"payment_type"`
+		VANumbers     sql.NullString `db:"va_numbers"` // json encoded list of virtual account numbers
+		Status        string         `db:"status"`
+		CreatedAt     time.Time      `db:"created_at"`
+		UpdatedAt     time.Time      `db:"updated_at"`
+	}
+)
+
+const (
+	PaymentStatusPending = "PENDING"
+	PaymentCurrency      = "IDR"
+)
+
+type Payment interface {
+	StoreNotification(ctx context.Context, param *PaymentNotification) (int, error)
+	IsNotificationProcessed(ctx context.Context, param *PaymentNotification) (bool, error)
+	MarkNotificationProcessed(ctx context.Context, notificationID int) error
+	SetPayment(ctx context.Context, param *PaymentTransaction) error
+	UpdateLatestPayment(ctx context.Context, param *PaymentTransaction) error
+	ListOfPayments(ctx context.Context, orderID string) ([]PaymentTransaction, error)
+}
+
+type payment struct {
+	db      *sqlx.DB
+	queries map[string]*sqlx.Stmt
+}
+
+func NewPayment(db *sqlx.DB) Payment {
+	payment := new(payment)
+	payment.db = db
+	payment.queries = make(map[string]*sqlx.Stmt, len(paymentQueries))
+	for k, v := range paymentQueries {
+		stmt, err := db.Preparex(v)
+		if err != nil {
+			log.Fatal().Msg("error : " + err.Error() + "\npayment : " + v)
+		}
+		payment.queries[k] = stmt
+	}
+	return payment
+}
+
+var (
+	setPaymentNotification       = "setPaymentNotification"
+	setPaymentNotificationField1 = `("order_id", "transaction_id", "refund_key", "transaction_status", "status_code", `
+	setPaymentNotificationField2 = `"gross_amount", "signature_key", "payload", "created_at")`
+	setPaymentNotificationFields = setPaymentNotificationField1 + setPaymentNotificationField2
+	setPaymentNotificationValue  = ` VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9) RETURNING "id"`
+	setPaymentNotificationSQL    = `INSERT INTO "payment_notifications" ` + setPaymentNotificationFields + setPaymentNotificationValue
+
+	getProcessedNotification      = "getProcessedNotification"
+	getProcessedNotificationCond1 = `WHERE "order_id" = $1 AND "transaction_id" IS NOT DISTINCT FROM $2 AND "refund_key" IS NOT DISTINCT FROM $3 `
+	getProcessedNotificationCond2 = `AND "transaction_status" = $4 AND "processed_at" IS NOT NULL LIMIT 1`
+	getProcessedNotificationSQL   = `SELECT "id" FROM "payment_notifications" ` + getProcessedNotificationCond1 + getProcessedNotificationCond2
+
+	setNotificationProcessed    = "setNotificationProcessed"
+	setNotificationProcessedSQL = `UPDATE "payment_notifications" SET "processed_at" = $2 WHERE "id" = $1`
+
+	setPayment       = "setPayment"
+	setPaymentField1 = `("order_id", "gateway_token", "redirect_url", "amount", "currency", `
+	setPaymentField2 = `"status", "created_at", "updated_at")`
+	setPaymentFields = setPaymentField1 + setPaymentField2
+	setPaymentSQL    = `INSERT INTO "payments" ` + setPaymentFields + ` VALUES ($1,$2,$3,$4,$5,$6,$7,$8)`
+
+	updateLatestPayment       = "updateLatestPayment"
+	updateLatestPaymentField1 = `"transaction_id" = $2, "payment_type" = $3, "va_numbers" = $4, `
+	updateLatestPaymentField2 = `"status" = $5, "updated_at" = $6`
+	updateLatestPaymentCond   = `(SELECT "id" FROM "payments" WHERE "order_id" = $1 ORDER BY "created_at" DESC LIMIT 1)`
+	updateLatestPaymentSQL    = `UPDATE "payments" SET ` + updateLatestPaymentField1 + updateLatestPaymentField2 +
+		` WHERE "id" = ` + updateLatestPaymentCond
+
+	getPayments       = "getPayments"
+	getPaymentsField1 = `"id", "order_id", "transaction_id", "gateway_token", "redirect_url", "amount", `
+	getPaymentsField2 = `"currency", "payment_type", "va_numbers", "status", "created_at", "updated_at"`
+	getPaymentsFields = getPaymentsField1 + getPaymentsField2
+	getPaymentsSQL    = `SELECT ` + getPaymentsFields + ` FROM "payments" WHERE "order_id" = $1 ORDER BY "created_at" DESC`
+
+	paymentQueries = map[string]string{
+		setPaymentNotification:   setPaymentNotificationSQL,
+		getProcessedNotification: getProcessedNotificationSQL,
+		setNotificationProcessed: setNotificationProcessedSQL,
+		setPayment:               setPaymentSQL,
+		updateLatestPayment:      updateLatestPaymentSQL,
+		getPayments:              getPaymentsSQL,
+	}
+)
+
+func (c *payment) StoreNotification(ctx context.Context, param *PaymentNotification) (int, error) {
+	var id int
+	// nolint(gosec) // false positive
+	err := c.queries[setPaymentNotification].QueryRowContext(ctx, param.OrderID, param.TransactionID, param.RefundKey, param.TransactionStatus, param.StatusCode, param.GrossAmount, param.SignatureKey, param.Payload, param.CreatedAt).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// IsNotificationProcessed tells whether a notification of the same transaction, refund and status was processed
+// before. A refund requested again gets a new refund key, so its outcome is not taken for the earlier one.
+func (c *payment) IsNotificationProcessed(ctx context.Context, param *PaymentNotification) (bool, error) {
+	var id int
+	err := c.queries[getProcessedNotification].QueryRowContext(ctx, param.OrderID, param.TransactionID, param.RefundKey, param.TransactionStatus).Scan(&id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (c *payment) MarkNotificationProcessed(ctx context.Context, notificationID int) error {
+	_, err := c.queries[setNotificationProcessed].ExecContext(ctx, notificationID, time.Now())
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c *payment) SetPayment(ctx context.Context, param *PaymentTransaction) error {
+	// nolint(gosec) // false positive
+	_, err := c.queries[setPayment].ExecContext(ctx, param.OrderID, param.GatewayToken, param.RedirectURL, param.Amount, param.Currency, param.Status, param.CreatedAt, param.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// UpdateLatestPayment updates the most recent payment attempt of the order with the data reported by the gateway.
+func (c *payment) UpdateLatestPayment(ctx context.Context, param *PaymentTransaction) error {
+	// nolint(gosec) // false positive
+	_, err := c.queries[updateLatestPayment].ExecContext(ctx, param.OrderID, param.TransactionID, param.PaymentType, param.VANumbers, param.Status, param.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c *payment) ListOfPayments(ctx context.Context, orderID string) ([]PaymentTransaction, error) {
+	var result []PaymentTransaction
+	err := c.queries[getPayments].SelectContext(ctx, &result, orderID)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
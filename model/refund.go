@@ -0,0 +1,108 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+)
+
+type (
+	RefundBaseModel struct {
+		ID            int            `db:"id"`
+		OrderID       string         `db:"order_id"`
+		RefundKey     string         `db:"refund_key"`
+		Amount        float64        `db:"amount"`
+		Reason        sql.NullString `db:"reason"`
+		Status        string         `db:"status"`
+		RequestedBy   string         `db:"requested_by"`
+		FailureReason sql.NullString `db:"failure_reason"`
+		CreatedAt     time.Time      `db:"created_at"`
+		UpdatedAt     time.Time      `db:"updated_at"`
+	}
+)
+
+const (
+	RefundStatusRequested  = "requested"
+	RefundStatusProcessing = "processing"
+	RefundStatusSucceeded  = "succeeded"
+	RefundStatusFailed     = "failed"
+)
+
+type Refund interface {
+	SetRefund(ctx context.Context, param *RefundBaseModel) (int, error)
+	UpdateRefundStatus(ctx context.Context, refundID int, status, failureReason string) error
+	GetLatestRefund(ctx context.Context, orderID string) (*RefundBaseModel, error)
+}
+
+type refund struct {
+	db      *sqlx.DB
+	queries map[string]*sqlx.Stmt
+}
+
+func NewRefund(db *sqlx.DB) Refund {
+	refund := new(refund)
+	refund.db = db
+	refund.queries = make(map[string]*sqlx.Stmt, len(refundQueries))
+	for k, v := range refundQueries {
+		stmt, err := db.Preparex(v)
+		if err != nil {
+			log.Fatal().Msg("error : " + err.Error() + "\nrefund : " + v)
+		}
+		refund.queries[k] = stmt
+	}
+	return refund
+}
+
+var (
+	setRefund       = "setRefund"
+	setRefundField1 = `("order_id", "refund_key", "amount", "reason", "status", "requested_by", `
+	setRefundField2 = `"created_at", "updated_at")`
+	setRefundFields = setRefundField1 + setRefundField2
+	setRefundSQL    = `INSERT INTO "refunds" ` + setRefundFields + ` VALUES ($1,$2,$3,$4,$5,$6,$7,$8) RETURNING "id"`
+
+	updateRefundStatus    = "updateRefundStatus"
+	updateRefundStatusSQL = `UPDATE "refunds" SET "status" = $2, "failure_reason" = $3, "updated_at" = $4 WHERE "id" = $1`
+
+	getLatestRefund       = "getLatestRefund"
+	getLatestRefundField1 = `"id", "order_id", "refund_key", "amount", "reason", "status", "requested_by", `
+	getLatestRefundField2 = `"failure_reason", "created_at", "updated_at"`
+	getLatestRefundCond   = `WHERE "order_id" = $1 ORDER BY "created_at" DESC, "id" DESC LIMIT 1`
+	getLatestRefundSQL    = `SELECT ` + getLatestRefundField1 + getLatestRefundField2 + ` FROM "refunds" ` + getLatestRefundCond
+
+	refundQueries = map[string]string{
+		setRefund:          setRefundSQL,
+		updateRefundStatus: updateRefundStatusSQL,
+		getLatestRefund:    getLatestRefundSQL,
+	}
+)
+
+func (c *refund) SetRefund(ctx context.Context, param *RefundBaseModel) (int, error) {
+	var id int
+	// nolint(gosec) // false positive
+	err := c.queries[setRefund].QueryRowContext(ctx, param.OrderID, param.RefundKey, param.Amount, param.Reason, param.Status, param.RequestedBy, param.CreatedAt, param.UpdatedAt).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (c *refund) UpdateRefundStatus(ctx context.Context, refundID int, status, failureReason string) error {
+	reason := sql.NullString{String: failureReason, Valid: failureReason != ""}
+	_, err := c.queries[updateRefundStatus].ExecContext(ctx, refundID, status, reason, time.Now())
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c *refund) GetLatestRefund(ctx context.Context, orderID string) (*RefundBaseModel, error) {
+	var result RefundBaseModel
+	err := c.queries[getLatestRefund].GetContext(ctx, &result, orderID)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"e-montir/api/handler"
+	"e-montir/pkg/sort"
 	"time"
 
 	"github.com/jmoiron/sqlx"
@@ -20,14 +21,82 @@ type (
 		OrderID   string    `db:"order_id"`
 		CreatedAt time.Time `db:"created_at"`
 	}
+
+	MechanicReviewBaseModel struct {
+		ID         int            `db:"id"`
+		OrderID    string         `db:"order_id"`
+		MechanicID int            `db:"mechanic_id"`
+		UserID     string         `db:"user_id"`
+		UserName   string         `db:"name"`
+		Rating     float64        `db:"rating"`
+		Feedback   sql.NullString `db:"feedback"`
+		CreatedAt  time.Time      `db:"created_at"`
+	}
+
+	ServiceReviewBaseModel struct {
+		ID           int            `db:"id"`
+		UserID       string         `db:"user_id"`
+		UserName     string         `db:"name"`
+		ServiceID    int            `db:"service_id"`
+		OrderID      string         `db:"order_id"`
+		Rating       float64        `db:"rating"`
+		Feedback     sql.NullString `db:"feedback"`
+		CreatedAt    time.Time      `db:"created_at"`
+		UpdatedAt    sql.NullTime   `db:"updated_at"`
+		HiddenAt     sql.NullTime   `db:"hidden_at"`
+		HiddenReason sql.NullString `db:"hidden_reason"`
+		Reply        sql.NullString `db:"reply"`
+		RepliedAt    sql.NullTime   `db:"replied_at"`
+	}
+
+	ReviewModerationBaseModel struct {
+		FeedbackID  int       `db:"feedback_id"`
+		ModeratorID string    `db:"moderator_id"`
+		Action      string    `db:"action"`
+		Reason      string    `db:"reason"`
+		CreatedAt   time.Time `db:"created_at"`
+	}
+
+	ServiceRatingSummary struct {
+		Rating      float64 `db:"rating"`
+		ReviewCount int     `db:"review_count"`
+	}
+
+	RatingCount struct {
+		Star  int `db:"star"`
+		Count int `db:"count"`
+	}
 )
 
 type Review interface {
 	AddServiceReview(ctx context.Context, param *ReviewBaseModel) error
 	IsServiceReviewed(ctx context.Context, orderID string, serviceID int) (bool, error)
 	GetReviewByOrderID(ctx context.Context, orderID string) (*ReviewBaseModel, error)
+	AddMechanicReview(ctx context.Context, param *MechanicReviewBaseModel) (bool, error)
+	IsMechanicReviewed(ctx context.Context, orderID string) (bool, error)
+	ListOfMechanicReviews(ctx context.Context, mechanicID, limit int) ([]MechanicReviewBaseModel, error)
+	GetServiceRatingSummary(ctx context.Context, serviceID int) (*ServiceRatingSummary, error)
+	ListOfServiceRatingCounts(ctx context.Context, serviceID int) ([]RatingCount, error)
+	ListOfServiceReviews(ctx context.Context, serviceID int, sortBy string, limit, offset int) ([]ServiceReviewBaseModel, error)
+	RecomputeRatings(ctx context.Context) error
+	GetServiceReviewByID(ctx context.Context, reviewID int) (*ServiceReviewBaseModel, error)
+	UpdateServiceReview(ctx context.Context, param *ServiceReviewBaseModel) (bool, error)
+	DeleteServiceReview(ctx context.Context, reviewID int) (bool, error)
+	ModerateServiceReview(ctx context.Context, param *ReviewModerationBaseModel) (bool, error)
+	ListOfModerationQueue(ctx context.Context, status string, limit, offset int) ([]ServiceReviewBaseModel, error)
+	SetServiceReviewReply(ctx context.Context, reviewID int, reply string, repliedAt time.Time) (bool, error)
 }
 
+const (
+	ModerationActionHide    = "hide"
+	ModerationActionRestore = "restore"
+	ModerationActionApprove = "approve"
+
+	// reviews nobody has looked at yet, or which changed since they were looked at
+	ModerationStatusPending = "pending"
+	ModerationStatusHidden  = "hidden"
+)
+
 type review struct {
 	db      *sqlx.DB
 	queries map[string]*sqlx.Stmt
@@ -52,8 +121,97 @@ var (
 	setServiceReviewField = `("user_id", "feedback", "rating", "service_id", "order_id", "created_at", "is_reviewed")`
 	setServiceReviewSQL   = `INSERT INTO "feedbacks" ` + setServiceReviewField + ` VALUES ($1,$2,$3,$4,$5,$6,$7)`
 
-	updateServiceRating    = "updateServiceRating"
-	updateServiceRatingSQL = `UPDATE "services" SET "rating" = round((float8(rating + $2)/2)::numeric, 2) WHERE "id" = $1`
+	// the rating is the average of every visible review, kept as a running sum and count which are
+	// moved by $2 and $3, a service left without reviews keeps its last rating
+	adjustServiceRatingAverage = `CASE WHEN "review_count" + $3 > 0 THEN round((("rating_sum" + $2) / ("review_count" + $3))::numeric, 2) ELSE "rating" END`
+	adjustServiceRatingSet     = `"rating_sum" = "rating_sum" + $2, "review_count" = "review_count" + $3, "rating" = ` + adjustServiceRatingAverage
+	adjustServiceRatingSQL     = `UPDATE "services" SET ` + adjustServiceRatingSet + ` WHERE "id" = $1`
+
+	// recomputing from the reviews repairs the stored aggregates, services without reviews keep their seeded rating
+	serviceRatingAggregate     = `SELECT "service_id", SUM("rating") AS "rating_sum", COUNT(*) AS "review_count" FROM "feedbacks" WHERE "hidden_at" IS NULL GROUP BY "service_id"`
+	resetServiceRatingsSQL     = `UPDATE "services" SET "rating_sum" = 0, "review_count" = 0`
+	recomputeServiceRatingsSet = `"rating_sum" = a.rating_sum, "review_count" = a.review_count, "rating" = round((a.rating_sum / a.review_count)::numeric, 2)`
+	recomputeServiceRatingsSQL = `UPDATE "services" SET ` + recomputeServiceRatingsSet + ` FROM (` + serviceRatingAggregate + `) a WHERE services.id = a.service_id`
+
+	mechanicRatingAggregate     = `SELECT "mechanic_id", AVG("rating") AS "rating", COUNT(*) AS "review_count" FROM "mechanic_reviews" GROUP BY "mechanic_id"`
+	resetMechanicRatingsSQL     = `UPDATE "mechanics" SET "rating" = 0, "review_count" = 0`
+	recomputeMechanicRatingsSet = `"rating" = round(a.rating::numeric, 2), "review_count" = a.review_count`
+	recomputeMechanicRatingsSQL = `UPDATE "mechanics" SET ` + recomputeMechanicRatingsSet + ` FROM (` + mechanicRatingAggregate + `) a WHERE mechanics.id = a.mechanic_id`
+
+	getServiceRatingSummary    = "getServiceRatingSummary"
+	getServiceRatingSummarySQL = `SELECT "rating", "review_count" FROM "services" WHERE "id" = $1`
+
+	// ratings are stored as floats, each one is counted under its nearest star
+	listOfServiceRatingCounts     = "listOfServiceRatingCounts"
+	listOfServiceRatingCountsStar = `LEAST(GREATEST(ROUND("rating")::INT, 1), 5)`
+	listOfServiceRatingCountsSQL  = `SELECT ` + listOfServiceRatingCountsStar + ` AS "star", COUNT(*) AS "count" FROM "feedbacks" WHERE "service_id" = $1 AND "hidden_at" IS NULL GROUP BY "star"`
+
+	serviceReviewFields = `feedbacks.id, "user_id", users.name, "service_id", "order_id", "rating", "feedback", feedbacks.created_at, feedbacks.updated_at, ` +
+		`"hidden_at", "hidden_reason", "reply", "replied_at"`
+	serviceReviewJoin       = `JOIN "users" ON feedbacks.user_id = users.id`
+	listOfServiceReviewsSQL = `SELECT ` + serviceReviewFields + ` FROM "feedbacks" ` + serviceReviewJoin + ` WHERE "service_id" = $1 AND "hidden_at" IS NULL`
+
+	listOfServiceReviewsNewest    = "listOfServiceReviewsNewest"
+	listOfServiceReviewsNewestSQL = listOfServiceReviewsSQL + ` ORDER BY feedbacks.created_at DESC, feedbacks.id DESC LIMIT $2 OFFSET $3`
+
+	listOfServiceReviewsHighest    = "listOfServiceReviewsHighest"
+	listOfServiceReviewsHighestSQL = listOfServiceReviewsSQL + ` ORDER BY "rating" DESC, feedbacks.created_at DESC, feedbacks.id DESC LIMIT $2 OFFSET $3`
+
+	listOfServiceReviewsLowest    = "listOfServiceReviewsLowest"
+	listOfServiceReviewsLowestSQL = listOfServiceReviewsSQL + ` ORDER BY "rating" ASC, feedbacks.created_at DESC, feedbacks.id DESC LIMIT $2 OFFSET $3`
+
+	getServiceReviewByID    = "getServiceReviewByID"
+	getServiceReviewByIDSQL = `SELECT ` + serviceReviewFields + ` FROM "feedbacks" ` + serviceReviewJoin + ` WHERE feedbacks.id = $1`
+
+	// an edited review goes back to the moderation queue
+	lockServiceReviewSQL   = `SELECT "service_id", "rating", "hidden_at" IS NULL FROM "feedbacks" WHERE "id" = $1 FOR UPDATE`
+	updateServiceReviewSet = `"rating" = $2, "feedback" = $3, "updated_at" = $4, "moderated_at" = NULL`
+	updateServiceReviewSQL = `UPDATE "feedbacks" SET ` + updateServiceReviewSet + ` WHERE "id" = $1`
+
+	deleteServiceReviewSQL = `DELETE FROM "feedbacks" WHERE "id" = $1 RETURNING "service_id", "rating", "hidden_at" IS NULL`
+
+	hideServiceReviewSet    = `"hidden_at" = $2, "hidden_reason" = $3, "moderated_at" = $2`
+	hideServiceReviewSQL    = `UPDATE "feedbacks" SET ` + hideServiceReviewSet + ` WHERE "id" = $1 AND "hidden_at" IS NULL RETURNING "service_id", "rating"`
+	restoreServiceReviewSet = `"hidden_at" = NULL, "hidden_reason" = NULL, "moderated_at" = $2`
+	restoreServiceReviewSQL = `UPDATE "feedbacks" SET ` + restoreServiceReviewSet + ` WHERE "id" = $1 AND "hidden_at" IS NOT NULL RETURNING "service_id", "rating"`
+	approveServiceReviewSQL = `UPDATE "feedbacks" SET "moderated_at" = $2 WHERE "id" = $1 AND "hidden_at" IS NULL AND "moderated_at" IS NULL RETURNING "service_id", "rating"`
+
+	setReviewModerationFields = `("feedback_id", "moderator_id", "action", "reason", "created_at")`
+	setReviewModerationSQL    = `INSERT INTO "review_moderations" ` + setReviewModerationFields + ` VALUES ($1,$2,$3,$4,$5)`
+
+	listOfPendingReviews     = "listOfPendingReviews"
+	listOfPendingReviewsCond = `WHERE "hidden_at" IS NULL AND "moderated_at" IS NULL ORDER BY COALESCE(feedbacks.updated_at, feedbacks.created_at) ASC LIMIT $1 OFFSET $2`
+	listOfPendingReviewsSQL  = `SELECT ` + serviceReviewFields + ` FROM "feedbacks" ` + serviceReviewJoin + ` ` + listOfPendingReviewsCond
+
+	listOfHiddenReviews     = "listOfHiddenReviews"
+	listOfHiddenReviewsCond = `WHERE "hidden_at" IS NOT NULL ORDER BY "hidden_at" DESC LIMIT $1 OFFSET $2`
+	listOfHiddenReviewsSQL  = `SELECT ` + serviceReviewFields + ` FROM "feedbacks" ` + serviceReviewJoin + ` ` + listOfHiddenReviewsCond
+
+	setServiceReviewReply    = "setServiceReviewReply"
+	setServiceReviewReplySQL = `UPDATE "feedbacks" SET "reply" = $2, "replied_at" = $3 WHERE "id" = $1`
+
+	// a moderation action only applies to reviews in the matching state, the rating moves along with visibility
+	moderateServiceReviewSQL = map[string]string{
+		ModerationActionHide:    hideServiceReviewSQL,
+		ModerationActionRestore: restoreServiceReviewSQL,
+		ModerationActionApprove: approveServiceReviewSQL,
+	}
+	moderateServiceReviewCount = map[string]int{
+		ModerationActionHide:    -1,
+		ModerationActionRestore: 1,
+		ModerationActionApprove: 0,
+	}
+
+	listOfModerationQueue = map[string]string{
+		ModerationStatusPending: listOfPendingReviews,
+		ModerationStatusHidden:  listOfHiddenReviews,
+	}
+
+	listOfServiceReviewsSort = map[string]string{
+		sort.Newest:  listOfServiceReviewsNewest,
+		sort.Highest: listOfServiceReviewsHighest,
+		sort.Lowest:  listOfServiceReviewsLowest,
+	}
 
 	getReviewByOrderIDNServiceID    = "getReviewByOrderIDNServiceID"
 	getReviewByOrderIDNServiceIDSQL = `SELECT "user_id", "rating", "feedback" FROM "feedbacks" WHERE "service_id" = $1 AND "order_id" = $2`
@@ -61,9 +219,35 @@ var (
 	getReviewByOrderID    = "getReviewByOrderID"
 	getReviewByOrderIDSQL = `SELECT "user_id", "rating", "feedback" FROM "feedbacks" WHERE "order_id" = $1`
 
+	setMechanicReviewFields = `("order_id", "mechanic_id", "user_id", "rating", "feedback", "created_at")`
+	setMechanicReviewSQL    = `INSERT INTO "mechanic_reviews" ` + setMechanicReviewFields + ` VALUES ($1,$2,$3,$4,$5,$6) ON CONFLICT DO NOTHING`
+
+	// the average is kept exact by weighting it with the number of reviews it is made of
+	updateMechanicRatingAverage = `round(((("rating" * "review_count") + $2) / ("review_count" + 1))::numeric, 2)`
+	updateMechanicRatingSQL     = `UPDATE "mechanics" SET "rating" = ` + updateMechanicRatingAverage + `, "review_count" = "review_count" + 1 WHERE "id" = $1`
+
+	isMechanicReviewed    = "isMechanicReviewed"
+	isMechanicReviewedSQL = `SELECT EXISTS (SELECT 1 FROM "mechanic_reviews" WHERE "order_id" = $1)`
+
+	listOfMechanicReviews       = "listOfMechanicReviews"
+	listOfMechanicReviewsFields = `mechanic_reviews.id, "order_id", "mechanic_id", "user_id", users.name, "rating", "feedback", mechanic_reviews.created_at`
+	listOfMechanicReviewsJoin   = `JOIN "users" ON mechanic_reviews.user_id = users.id`
+	listOfMechanicReviewsCond   = `WHERE "mechanic_id" = $1 ORDER BY mechanic_reviews.created_at DESC LIMIT $2`
+	listOfMechanicReviewsSQL    = `SELECT ` + listOfMechanicReviewsFields + ` FROM "mechanic_reviews" ` + listOfMechanicReviewsJoin + ` ` + listOfMechanicReviewsCond
+
 	reviewQueries = map[string]string{
+		isMechanicReviewed:           isMechanicReviewedSQL,
+		listOfMechanicReviews:        listOfMechanicReviewsSQL,
 		setServiceReview:             setServiceReviewSQL,
-		updateServiceRating:          updateServiceRatingSQL,
+		getServiceRatingSummary:      getServiceRatingSummarySQL,
+		listOfServiceRatingCounts:    listOfServiceRatingCountsSQL,
+		listOfServiceReviewsNewest:   listOfServiceReviewsNewestSQL,
+		listOfServiceReviewsHighest:  listOfServiceReviewsHighestSQL,
+		listOfServiceReviewsLowest:   listOfServiceReviewsLowestSQL,
+		getServiceReviewByID:         getServiceReviewByIDSQL,
+		listOfPendingReviews:         listOfPendingReviewsSQL,
+		listOfHiddenReviews:          listOfHiddenReviewsSQL,
+		setServiceReviewReply:        setServiceReviewReplySQL,
 		getReviewByOrderIDNServiceID: getReviewByOrderIDNServiceIDSQL,
 		getReviewByOrderID:           getReviewByOrderIDSQL,
 	}
@@ -93,7 +277,7 @@ func (c *review) AddServiceReview(ctx context.Context, param *ReviewBaseModel) e
 		}
 	}
 
-	row, err := tx.ExecContext(ctx, updateServiceRatingSQL, param.ServiceID, param.Rating)
+	row, err := tx.ExecContext(ctx, adjustServiceRatingSQL, param.ServiceID, param.Rating, 1)
 	if err != nil {
 		return err
 	}
@@ -134,3 +318,288 @@ func (c *review) GetReviewByOrderID(ctx context.Context, orderID string) (*Revie
 	result.Feedback = feedback.String
 	return &result, nil
 }
+
+// AddMechanicReview stores the review and folds it into the mechanic rating, it reports
+// false when the order has already been reviewed.
+func (c *review) AddMechanicReview(ctx context.Context, param *MechanicReviewBaseModel) (bool, error) {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return false, err
+	}
+
+	defer func() {
+		if rollback := tx.Rollback(); rollback == nil {
+			log.Info().Msg("rolling back changes")
+		}
+	}()
+
+	res, err := tx.ExecContext(ctx, setMechanicReviewSQL, param.OrderID, param.MechanicID, param.UserID, param.Rating, param.Feedback, param.CreatedAt)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected != 1 {
+		return false, nil
+	}
+
+	_, err = tx.ExecContext(ctx, updateMechanicRatingSQL, param.MechanicID, param.Rating)
+	if err != nil {
+		return false, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (c *review) IsMechanicReviewed(ctx context.Context, orderID string) (bool, error) {
+	var result bool
+	err := c.queries[isMechanicReviewed].GetContext(ctx, &result, orderID)
+	if err != nil {
+		return false, err
+	}
+	return result, nil
+}
+
+func (c *review) ListOfMechanicReviews(ctx context.Context, mechanicID, limit int) ([]MechanicReviewBaseModel, error) {
+	var result []MechanicReviewBaseModel
+	err := c.queries[listOfMechanicReviews].SelectContext(ctx, &result, mechanicID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *review) GetServiceRatingSummary(ctx context.Context, serviceID int) (*ServiceRatingSummary, error) {
+	var result ServiceRatingSummary
+	err := c.queries[getServiceRatingSummary].GetContext(ctx, &result, serviceID)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *review) ListOfServiceRatingCounts(ctx context.Context, serviceID int) ([]RatingCount, error) {
+	var result []RatingCount
+	err := c.queries[listOfServiceRatingCounts].SelectContext(ctx, &result, serviceID)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *review) ListOfServiceReviews(ctx context.Context, serviceID int, sortBy string, limit, offset int) ([]ServiceReviewBaseModel, error) {
+	query, ok := listOfServiceReviewsSort[sortBy]
+	if !ok {
+		query = listOfServiceReviewsNewest
+	}
+
+	var result []ServiceReviewBaseModel
+	err := c.queries[query].SelectContext(ctx, &result, serviceID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// RecomputeRatings rebuilds the service and mechanic ratings from every stored review
+func (c *review) RecomputeRatings(ctx context.Context) error {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if rollback := tx.Rollback(); rollback == nil {
+			log.Info().Msg("rolling back changes")
+		}
+	}()
+
+	for _, query := range []string{resetServiceRatingsSQL, recomputeServiceRatingsSQL, resetMechanicRatingsSQL, recomputeMechanicRatingsSQL} {
+		_, err = tx.ExecContext(ctx, query)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (c *review) GetServiceReviewByID(ctx context.Context, reviewID int) (*ServiceReviewBaseModel, error) {
+	var result ServiceReviewBaseModel
+	err := c.queries[getServiceReviewByID].GetContext(ctx, &result, reviewID)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// UpdateServiceReview changes the rating and feedback of the review and moves the service rating
+// along when the review is visible, it reports false when the review does not exist.
+func (c *review) UpdateServiceReview(ctx context.Context, param *ServiceReviewBaseModel) (bool, error) {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return false, err
+	}
+
+	defer func() {
+		if rollback := tx.Rollback(); rollback == nil {
+			log.Info().Msg("rolling back changes")
+		}
+	}()
+
+	var serviceID int
+	var rating float64
+	var visible bool
+	err = tx.QueryRowContext(ctx, lockServiceReviewSQL, param.ID).Scan(&serviceID, &rating, &visible)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+
+	_, err = tx.ExecContext(ctx, updateServiceReviewSQL, param.ID, param.Rating, param.Feedback, param.UpdatedAt)
+	if err != nil {
+		return false, err
+	}
+
+	if visible {
+		_, err = tx.ExecContext(ctx, adjustServiceRatingSQL, serviceID, param.Rating-rating, 0)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// DeleteServiceReview removes the review and its share of the service rating, it reports false
+// when the review does not exist.
+func (c *review) DeleteServiceReview(ctx context.Context, reviewID int) (bool, error) {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return false, err
+	}
+
+	defer func() {
+		if rollback := tx.Rollback(); rollback == nil {
+			log.Info().Msg("rolling back changes")
+		}
+	}()
+
+	var serviceID int
+	var rating float64
+	var visible bool
+	err = tx.QueryRowContext(ctx, deleteServiceReviewSQL, reviewID).Scan(&serviceID, &rating, &visible)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if visible {
+		_, err = tx.ExecContext(ctx, adjustServiceRatingSQL, serviceID, -rating, -1)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ModerateServiceReview applies the moderation action and records who took it, it reports false
+// when the review is not in a state the action applies to.
+func (c *review) ModerateServiceReview(ctx context.Context, param *ReviewModerationBaseModel) (bool, error) {
+	query, ok := moderateServiceReviewSQL[param.Action]
+	if !ok {
+		return false, nil
+	}
+
+	tx, err := c.db.Begin()
+	if err != nil {
+		return false, err
+	}
+
+	defer func() {
+		if rollback := tx.Rollback(); rollback == nil {
+			log.Info().Msg("rolling back changes")
+		}
+	}()
+
+	var serviceID int
+	var rating float64
+	if param.Action == ModerationActionApprove {
+		err = tx.QueryRowContext(ctx, query, param.FeedbackID, param.CreatedAt).Scan(&serviceID, &rating)
+	} else {
+		err = tx.QueryRowContext(ctx, query, param.FeedbackID, param.CreatedAt, param.Reason).Scan(&serviceID, &rating)
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if count := moderateServiceReviewCount[param.Action]; count != 0 {
+		_, err = tx.ExecContext(ctx, adjustServiceRatingSQL, serviceID, float64(count)*rating, count)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, setReviewModerationSQL, param.FeedbackID, param.ModeratorID, param.Action, param.Reason, param.CreatedAt)
+	if err != nil {
+		return false, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (c *review) ListOfModerationQueue(ctx context.Context, status string, limit, offset int) ([]ServiceReviewBaseModel, error) {
+	query, ok := listOfModerationQueue[status]
+	if !ok {
+		query = listOfPendingReviews
+	}
+
+	var result []ServiceReviewBaseModel
+	err := c.queries[query].SelectContext(ctx, &result, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// SetServiceReviewReply stores the workshop reply of the review, replacing the previous one,
+// it reports false when the review does not exist.
+func (c *review) SetServiceReviewReply(ctx context.Context, reviewID int, reply string, repliedAt time.Time) (bool, error) {
+	res, err := c.queries[setServiceReviewReply].ExecContext(ctx, reviewID, reply, repliedAt)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
@@ -0,0 +1,167 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+)
+
+type (
+	SessionBaseModel struct {
+		ID                string         `db:"id"`
+		UserID            string         `db:"user_id"`
+		RefreshTokenHash  string         `db:"refresh_token_hash"`
+		PreviousTokenHash sql.NullString `db:"previous_token_hash"`
+		AccessTokenID     string         `db:"access_token_id"`
+		ExpiresAt         time.Time      `db:"expires_at"`
+		RevokedAt         sql.NullTime   `db:"revoked_at"`
+		CreatedAt         time.Time      `db:"created_at"`
+		UpdatedAt         time.Time      `db:"updated_at"`
+	}
+)
+
+type Session interface {
+	SetSession(ctx context.Context, param *SessionBaseModel) error
+	GetSessionByRefreshToken(ctx context.Context, tokenHash string) (*SessionBaseModel, error)
+	RotateSession(ctx context.Context, param *SessionBaseModel, oldTokenHash string) (bool, error)
+	RevokeSession(ctx context.Context, sessionID string) error
+	RevokeSessionByTokenID(ctx context.Context, tokenID string) error
+	RevokeUserSessions(ctx context.Context, userID string) error
+	IsTokenRevoked(ctx context.Context, tokenID string) (bool, error)
+}
+
+type session struct {
+	db      *sqlx.DB
+	queries map[string]*sqlx.Stmt
+}
+
+func NewSession(db *sqlx.DB) Session {
+	session := new(session)
+	session.db = db
+	session.queries = make(map[string]*sqlx.Stmt, len(sessionQueries))
+	for k, v := range sessionQueries {
+		stmt, err := db.Preparex(v)
+		if err != nil {
+			log.Fatal().Msg("error : " + err.Error() + "\nsession : " + v)
+		}
+		session.queries[k] = stmt
+	}
+	return session
+}
+
+var (
+	setSession       = "setSession"
+	setSessionField1 = `("id", "user_id", "refresh_token_hash", "access_token_id", "expires_at", `
+	setSessionField2 = `"created_at", "updated_at")`
+	setSessionFields = setSessionField1 + setSessionField2
+	setSessionSQL    = `INSERT INTO "user_sessions" ` + setSessionFields + ` VALUES ($1,$2,$3,$4,$5,$6,$7)`
+
+	// the previous hash is matched as well so a refresh token which has been rotated can be detected
+	getSessionByRefreshToken       = "getSessionByRefreshToken"
+	getSessionByRefreshTokenField1 = `"id", "user_id", "refresh_token_hash", "previous_token_hash", "access_token_id", `
+	getSessionByRefreshTokenField2 = `"expires_at", "revoked_at", "created_at", "updated_at"`
+	getSessionByRefreshTokenCond   = `WHERE "refresh_token_hash" = $1 OR "previous_token_hash" = $1 LIMIT 1`
+	getSessionByRefreshTokenSQL    = `SELECT ` + getSessionByRefreshTokenField1 + getSessionByRefreshTokenField2 + ` FROM "user_sessions" ` + getSessionByRefreshTokenCond
+
+	rotateSession       = "rotateSession"
+	rotateSessionField1 = `"refresh_token_hash" = $2, "previous_token_hash" = $3, "access_token_id" = $4, `
+	rotateSessionField2 = `"expires_at" = $5, "updated_at" = $6`
+	rotateSessionCond   = `WHERE "id" = $1 AND "refresh_token_hash" = $3 AND "revoked_at" IS NULL`
+	rotateSessionSQL    = `UPDATE "user_sessions" SET ` + rotateSessionField1 + rotateSessionField2 + ` ` + rotateSessionCond
+
+	revokeSession    = "revokeSession"
+	revokeSessionSQL = `UPDATE "user_sessions" SET "revoked_at" = $2, "updated_at" = $2 WHERE "id" = $1 AND "revoked_at" IS NULL`
+
+	revokeSessionByTokenID    = "revokeSessionByTokenID"
+	revokeSessionByTokenIDSQL = `UPDATE "user_sessions" SET "revoked_at" = $2, "updated_at" = $2 WHERE "access_token_id" = $1 AND "revoked_at" IS NULL`
+
+	revokeUserSessions    = "revokeUserSessions"
+	revokeUserSessionsSQL = `UPDATE "user_sessions" SET "revoked_at" = $2, "updated_at" = $2 WHERE "user_id" = $1 AND "revoked_at" IS NULL`
+
+	isTokenRevoked    = "isTokenRevoked"
+	isTokenRevokedSQL = `SELECT "revoked_at" IS NOT NULL FROM "user_sessions" WHERE "access_token_id" = $1`
+
+	sessionQueries = map[string]string{
+		setSession:               setSessionSQL,
+		getSessionByRefreshToken: getSessionByRefreshTokenSQL,
+		rotateSession:            rotateSessionSQL,
+		revokeSession:            revokeSessionSQL,
+		revokeSessionByTokenID:   revokeSessionByTokenIDSQL,
+		revokeUserSessions:       revokeUserSessionsSQL,
+		isTokenRevoked:           isTokenRevokedSQL,
+	}
+)
+
+func (c *session) SetSession(ctx context.Context, param *SessionBaseModel) error {
+	// nolint(gosec) // false positive
+	_, err := c.queries[setSession].ExecContext(ctx, param.ID, param.UserID, param.RefreshTokenHash, param.AccessTokenID, param.ExpiresAt, param.CreatedAt, param.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c *session) GetSessionByRefreshToken(ctx context.Context, tokenHash string) (*SessionBaseModel, error) {
+	var result SessionBaseModel
+	err := c.queries[getSessionByRefreshToken].GetContext(ctx, &result, tokenHash)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// RotateSession swaps the refresh token of the session, it reports false when the old token
+// has been rotated by another request in the meantime.
+func (c *session) RotateSession(ctx context.Context, param *SessionBaseModel, oldTokenHash string) (bool, error) {
+	res, err := c.queries[rotateSession].ExecContext(ctx, param.ID, param.RefreshTokenHash, oldTokenHash, param.AccessTokenID, param.ExpiresAt, param.UpdatedAt)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+func (c *session) RevokeSession(ctx context.Context, sessionID string) error {
+	_, err := c.queries[revokeSession].ExecContext(ctx, sessionID, time.Now())
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c *session) RevokeSessionByTokenID(ctx context.Context, tokenID string) error {
+	_, err := c.queries[revokeSessionByTokenID].ExecContext(ctx, tokenID, time.Now())
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c *session) RevokeUserSessions(ctx context.Context, userID string) error {
+	_, err := c.queries[revokeUserSessions].ExecContext(ctx, userID, time.Now())
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// IsTokenRevoked treats an access token which no longer belongs to any session as revoked,
+// that is the case once its refresh token has been rotated.
+func (c *session) IsTokenRevoked(ctx context.Context, tokenID string) (bool, error) {
+	var revoked bool
+	err := c.queries[isTokenRevoked].QueryRowxContext(ctx, tokenID).Scan(&revoked)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return true, nil
+		}
+		return false, err
+	}
+	return revoked, nil
+}
@@ -0,0 +1,114 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+)
+
+type (
+	OrderTracking struct {
+		OrderID    string    `db:"order_id"`
+		MechanicID int       `db:"mechanic_id"`
+		Latitude   float64   `db:"latitude"`
+		Longitude  float64   `db:"longitude"`
+		RecordedAt time.Time `db:"recorded_at"`
+	}
+)
+
+type Tracking interface {
+	SetMechanicLocation(ctx context.Context, mechanicID int, latitude, longitude float64, recordedAt time.Time) ([]string, error)
+	GetOrderTracking(ctx context.Context, orderID string) (*OrderTracking, error)
+}
+
+type tracking struct {
+	db      *sqlx.DB
+	queries map[string]*sqlx.Stmt
+}
+
+func NewTracking(db *sqlx.DB) Tracking {
+	tracking := new(tracking)
+	tracking.db = db
+	tracking.queries = make(map[string]*sqlx.Stmt, len(trackingQueries))
+	for k, v := range trackingQueries {
+		stmt, err := db.Preparex(v)
+		if err != nil {
+			log.Fatal().Msg("error : " + err.Error() + "\ntracking : " + v)
+		}
+		tracking.queries[k] = stmt
+	}
+	return tracking
+}
+
+var (
+	updateMechanicLocationSQL = `UPDATE "mechanics" SET "latitude" = $2, "longitude" = $3, "location_updated_at" = $4 WHERE "id" = $1`
+
+	// the position is kept for every order the mechanic is on the way to
+	setOrderTrackingFields   = `("order_id", "mechanic_id", "latitude", "longitude", "recorded_at")`
+	setOrderTrackingSelect   = `SELECT "id", "mechanic_id", $2::DOUBLE PRECISION, $3::DOUBLE PRECISION, $4::TIMESTAMP FROM "orders" WHERE "mechanic_id" = $1 AND "status_order" = $5`
+	setOrderTrackingConflict = `ON CONFLICT ("order_id") DO UPDATE SET "latitude" = $2, "longitude" = $3, "recorded_at" = $4`
+	setOrderTrackingSQL      = `INSERT INTO "order_trackings" ` + setOrderTrackingFields + ` ` + setOrderTrackingSelect + ` ` + setOrderTrackingConflict + ` RETURNING "order_id"`
+
+	getOrderTracking       = "getOrderTracking"
+	getOrderTrackingFields = `"order_id", "mechanic_id", "latitude", "longitude", "recorded_at"`
+	getOrderTrackingSQL    = `SELECT ` + getOrderTrackingFields + ` FROM "order_trackings" WHERE "order_id" = $1`
+
+	trackingQueries = map[string]string{
+		getOrderTracking: getOrderTrackingSQL,
+	}
+)
+
+// SetMechanicLocation stores the latest position of the mechanic and returns the orders it is tracked for
+func (c *tracking) SetMechanicLocation(ctx context.Context, mechanicID int, latitude, longitude float64, recordedAt time.Time) ([]string, error) {
+	orderIDs := make([]string, 0)
+	tx, err := c.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if rollback := tx.Rollback(); rollback == nil {
+			log.Info().Msg("rolling back changes")
+		}
+	}()
+
+	_, err = tx.ExecContext(ctx, updateMechanicLocationSQL, mechanicID, latitude, longitude, recordedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx, setOrderTrackingSQL, mechanicID, latitude, longitude, recordedAt, OrderStatus[3])
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var orderID string
+		err = rows.Scan(&orderID)
+		if err != nil {
+			return nil, err
+		}
+		orderIDs = append(orderIDs, orderID)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return orderIDs, nil
+}
+
+func (c *tracking) GetOrderTracking(ctx context.Context, orderID string) (*OrderTracking, error) {
+	var result OrderTracking
+	err := c.queries[getOrderTracking].GetContext(ctx, &result, orderID)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
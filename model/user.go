@@ -18,6 +18,7 @@ type (
 		Address     sql.NullString `db:"address"`
 		PhoneNumber sql.NullString `db:"phone_num"`
 		IsActive    bool           `db:"is_active"`
+		Role        string         `db:"role"`
 	}
 
 	RegisterUser struct {
@@ -32,6 +33,24 @@ type (
 		Password string `db:"password"`
 	}
 
+	ActivationToken struct {
+		ID        int          `db:"id"`
+		UserID    string       `db:"user_id"`
+		TokenHash string       `db:"token_hash"`
+		ExpiresAt time.Time    `db:"expires_at"`
+		UsedAt    sql.NullTime `db:"used_at"`
+		CreatedAt time.Time    `db:"created_at"`
+	}
+
+	PasswordResetToken struct {
+		ID        int          `db:"id"`
+		UserID    string       `db:"user_id"`
+		TokenHash string       `db:"token_hash"`
+		ExpiresAt time.Time    `db:"expires_at"`
+		UsedAt    sql.NullTime `db:"used_at"`
+		CreatedAt time.Time    `db:"created_at"`
+	}
+
 	UserLocation struct {
 		ID            string    `db:"id"`
 		Label         string    `db:"label"`
@@ -45,20 +64,29 @@ type (
 	}
 )
 
+const (
+	UserRoleCustomer = "customer"
+	UserRoleMechanic = "mechanic"
+	UserRoleAdmin    = "admin"
+)
+
 type User interface {
 	RegisterUser(ctx context.Context, param *RegisterUser) error
 	IsEmailUsed(ctx context.Context, email string) (bool, error)
-	ActivateEmail(ctx context.Context, email string) error
+	SetActivationToken(ctx context.Context, param *ActivationToken) error
+	GetActivationToken(ctx context.Context, tokenHash string) (*ActivationToken, error)
+	ActivateEmail(ctx context.Context, tokenID int, userID string) (bool, error)
 	GetUserByEmail(ctx context.Context, email string) (*UserBaseModel, error)
+	GetUserRole(ctx context.Context, userID string) (string, error)
+	GetUserByID(ctx context.Context, userID string) (*UserBaseModel, error)
 	GetUserCurrentLocation(ctx context.Context, userID string) (*UserLocation, error)
 	GetListOfUserLocation(ctx context.Context, userID string) ([]UserLocation, error)
 	AddUserLocation(ctx context.Context, userID string, param *UserLocation) error
-	StoreFCMKey(ctx context.Context, userID, fmc string) error
-	GetFCMKey(ctx context.Context, userID string) (string, error)
 	GetUserIDNOrderIDByInvoiceID(ctx context.Context, invoiceID string) (string, string, error)
 	GetUserIDByOrderID(ctx context.Context, orderID string) (string, error)
-	// SetEmailTimeLimit(email, userID string) error
-	// IsEmailStillValid(email string) error
+	SetPasswordResetToken(ctx context.Context, param *PasswordResetToken) error
+	GetPasswordResetToken(ctx context.Context, tokenHash string) (*PasswordResetToken, error)
+	ResetPassword(ctx context.Context, tokenID int, userID, passwordHash string) (bool, error)
 }
 
 type user struct {
@@ -83,14 +111,19 @@ func NewUser(db *sqlx.DB) User {
 }
 
 var (
-	userActivateEmail    = "ActivateEmail"
-	userActivateEmailSQL = `UPDATE "users" SET is_active = $2 WHERE email = $1`
+	userActivateEmailSQL = `UPDATE "users" SET is_active = $2 WHERE id = $1`
 
 	userSetNewUser    = "AddNewUser"
 	userSetNewUserSQL = `INSERT INTO "users" (id, name, email, password, is_active) VALUES ($1,$2,$3,$4,$5)`
 
 	userGetUserByEmail    = "GetUserByEmail"
-	userGetUserByEmailSQL = `SELECT "id", "is_active", "password" from "users" WHERE email = $1`
+	userGetUserByEmailSQL = `SELECT "id", "is_active", "password", "role" from "users" WHERE email = $1`
+
+	getUserRole    = "getUserRole"
+	getUserRoleSQL = `SELECT "role" FROM "users" WHERE "id" = $1`
+
+	getUserByID    = "getUserByID"
+	getUserByIDSQL = `SELECT "id", "name", "email", "is_active", "role" FROM "users" WHERE "id" = $1`
 
 	getUserIDByInvoiceID    = "getUserByInvoiceID"
 	getUserIDByInvoiceIDSQL = `SELECT "user_id", "id" from "orders" WHERE "invoice_id" = $1`
@@ -101,12 +134,6 @@ var (
 	userIsEmailUsed    = "IsEmailUsed"
 	userIsEmailUsedSQL = `SELECT "email" FROM "users" WHERE email = $1`
 
-	setFCMKey    = "setFCMKey"
-	setFCMKeySQL = `UPDATE "users" SET "fcm_key" = $2 WHERE "id" = $1`
-
-	getFCMKey    = "getFCMKey"
-	getFCMKeySQL = `SELECT "fcm_key" FROM "users" WHERE "id" = $1`
-
 	getUserLocation          = "getUserLocation"
 	userLocField1            = `"id", "label", "address", "address_detail", `
 	userLocField2            = `"phone_num", "recipient_name", "latitude", "longitude"`
@@ -121,17 +148,38 @@ var (
 	setUserLocValue       = `VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10)`
 	setUserLocationSQL    = `INSERT INTO "user_addresses" ` + setUserLocationFields + setUserLocValue
 
+	setActivationTokenSQL      = `INSERT INTO "email_activation_tokens" ("user_id", "token_hash", "expires_at", "created_at") VALUES ($1,$2,$3,$4)`
+	discardActivationTokensSQL = `UPDATE "email_activation_tokens" SET "used_at" = $2 WHERE "user_id" = $1 AND "used_at" IS NULL`
+	useActivationTokenSQL      = `UPDATE "email_activation_tokens" SET "used_at" = $2 WHERE "id" = $1 AND "used_at" IS NULL`
+	getActivationToken         = "getActivationToken"
+	getActivationTokenFields   = `"id", "user_id", "token_hash", "expires_at", "used_at", "created_at"`
+	getActivationTokenSQL      = `SELECT ` + getActivationTokenFields + ` FROM "email_activation_tokens" WHERE "token_hash" = $1`
+
+	setPasswordResetToken    = "setPasswordResetToken"
+	setPasswordResetTokenSQL = `INSERT INTO "password_reset_tokens" ("user_id", "token_hash", "expires_at", "created_at") VALUES ($1,$2,$3,$4)`
+
+	getPasswordResetToken       = "getPasswordResetToken"
+	getPasswordResetTokenFields = `"id", "user_id", "token_hash", "expires_at", "used_at", "created_at"`
+	getPasswordResetTokenSQL    = `SELECT ` + getPasswordResetTokenFields + ` FROM "password_reset_tokens" WHERE "token_hash" = $1`
+
+	usePasswordResetTokenSQL      = `UPDATE "password_reset_tokens" SET "used_at" = $2 WHERE "id" = $1 AND "used_at" IS NULL`
+	discardPasswordResetTokensSQL = `UPDATE "password_reset_tokens" SET "used_at" = $2 WHERE "user_id" = $1 AND "used_at" IS NULL`
+	updatePasswordSQL             = `UPDATE "users" SET "password" = $2 WHERE "id" = $1`
+
 	userQueries = map[string]string{
 		userSetNewUser:       userSetNewUserSQL,
 		userIsEmailUsed:      userIsEmailUsedSQL,
-		userActivateEmail:    userActivateEmailSQL,
 		userGetUserByEmail:   userGetUserByEmailSQL,
 		getUserLocation:      getUserLocationSQL,
 		setUserLocation:      setUserLocationSQL,
-		setFCMKey:            setFCMKeySQL,
-		getFCMKey:            getFCMKeySQL,
 		getUserIDByInvoiceID: getUserIDByInvoiceIDSQL,
 		getReviewByOrderID:   getUserIDByOrderIDSQL,
+
+		getUserRole:           getUserRoleSQL,
+		getUserByID:           getUserByIDSQL,
+		getActivationToken:    getActivationTokenSQL,
+		setPasswordResetToken: setPasswordResetTokenSQL,
+		getPasswordResetToken: getPasswordResetTokenSQL,
 	}
 )
 
@@ -154,14 +202,79 @@ func (c *user) IsEmailUsed(ctx context.Context, email string) (bool, error) {
 	return true, nil
 }
 
-func (c *user) ActivateEmail(ctx context.Context, email string) error {
-	_, err := c.queries[userActivateEmail].ExecContext(ctx, email, true)
+// SetActivationToken stores a new activation token and discards the outstanding ones,
+// only the link which has been sent last can activate the email.
+func (c *user) SetActivationToken(ctx context.Context, param *ActivationToken) error {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if rollback := tx.Rollback(); rollback == nil {
+			log.Info().Msg("rolling back changes")
+		}
+	}()
 
+	_, err = tx.ExecContext(ctx, discardActivationTokensSQL, param.UserID, param.CreatedAt)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	_, err = tx.ExecContext(ctx, setActivationTokenSQL, param.UserID, param.TokenHash, param.ExpiresAt, param.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (c *user) GetActivationToken(ctx context.Context, tokenHash string) (*ActivationToken, error) {
+	var result ActivationToken
+	err := c.queries[getActivationToken].GetContext(ctx, &result, tokenHash)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ActivateEmail consumes the activation token and activates the user in one transaction,
+// it reports false when the token has been used by another request in the meantime.
+func (c *user) ActivateEmail(ctx context.Context, tokenID int, userID string) (bool, error) {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return false, err
+	}
+
+	defer func() {
+		if rollback := tx.Rollback(); rollback == nil {
+			log.Info().Msg("rolling back changes")
+		}
+	}()
+
+	res, err := tx.ExecContext(ctx, useActivationTokenSQL, tokenID, time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected != 1 {
+		return false, nil
+	}
+
+	_, err = tx.ExecContext(ctx, userActivateEmailSQL, userID, true)
+	if err != nil {
+		return false, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+	return true, nil
 }
 
 func (c *user) GetUserByEmail(ctx context.Context, email string) (*UserBaseModel, error) {
@@ -172,6 +285,23 @@ func (c *user) GetUserByEmail(ctx context.Context, email string) (*UserBaseModel
 	return &result, nil
 }
 
+func (c *user) GetUserRole(ctx context.Context, userID string) (string, error) {
+	var role string
+	err := c.queries[getUserRole].QueryRowContext(ctx, userID).Scan(&role)
+	if err != nil {
+		return "", err
+	}
+	return role, nil
+}
+
+func (c *user) GetUserByID(ctx context.Context, userID string) (*UserBaseModel, error) {
+	var result UserBaseModel
+	if err := c.queries[getUserByID].GetContext(ctx, &result, userID); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
 func (c *user) GetUserCurrentLocation(ctx context.Context, userID string) (*UserLocation, error) {
 	var userLoc UserLocation
 	if err := c.queries[getUserLocation].GetContext(ctx, &userLoc, userID); err != nil {
@@ -198,23 +328,6 @@ func (c *user) GetListOfUserLocation(ctx context.Context, userID string) ([]User
 	return result, nil
 }
 
-func (c *user) StoreFCMKey(ctx context.Context, userID, fcm string) error {
-	_, err := c.queries[setFCMKey].ExecContext(ctx, userID, fcm)
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
-func (c *user) GetFCMKey(ctx context.Context, userID string) (string, error) {
-	var fcmKey sql.NullString
-	err := c.queries[getFCMKey].QueryRowContext(ctx, userID).Scan(&fcmKey)
-	if err != nil {
-		return "", err
-	}
-	return fcmKey.String, nil
-}
-
 func (c *user) GetUserIDNOrderIDByInvoiceID(ctx context.Context, invoiceID string) (string, string, error) {
 	var userID, orderID sql.NullString
 	err := c.queries[getUserIDByInvoiceID].QueryRowContext(ctx, invoiceID).Scan(&userID, &orderID)
@@ -233,18 +346,65 @@ func (c *user) GetUserIDByOrderID(ctx context.Context, orderID string) (string,
 	return userID.String, nil
 }
 
-// func (c *user) SetEmailTimeLimit(email, userID string) error {
-// 	err := c.redis.Set(email, userID, time.Minute*1).Err()
-// 	if err != nil {
-// 		return err
-// 	}
-// 	return nil
-// }
-
-// func (c *user) IsEmailStillValid(email string) error {
-// 	_, err := c.redis.Get(email).Result()
-// 	if err != nil {
-// 		return err
-// 	}
-// 	return nil
-// }
+func (c *user) SetPasswordResetToken(ctx context.Context, param *PasswordResetToken) error {
+	_, err := c.queries[setPasswordResetToken].ExecContext(ctx, param.UserID, param.TokenHash, param.ExpiresAt, param.CreatedAt)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c *user) GetPasswordResetToken(ctx context.Context, tokenHash string) (*PasswordResetToken, error) {
+	var result PasswordResetToken
+	err := c.queries[getPasswordResetToken].GetContext(ctx, &result, tokenHash)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ResetPassword consumes the reset token and stores the new password in one transaction,
+// it reports false when the token has been used by another request in the meantime.
+// Every other outstanding reset token of the user is discarded as well.
+func (c *user) ResetPassword(ctx context.Context, tokenID int, userID, passwordHash string) (bool, error) {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return false, err
+	}
+
+	defer func() {
+		if rollback := tx.Rollback(); rollback == nil {
+			log.Info().Msg("rolling back changes")
+		}
+	}()
+
+	now := time.Now()
+	res, err := tx.ExecContext(ctx, usePasswordResetTokenSQL, tokenID, now)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected != 1 {
+		return false, nil
+	}
+
+	_, err = tx.ExecContext(ctx, discardPasswordResetTokensSQL, userID, now)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.ExecContext(ctx, updatePasswordSQL, userID, passwordHash)
+	if err != nil {
+		return false, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
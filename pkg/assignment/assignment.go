@@ -0,0 +1,145 @@
+package assignment
+
+import (
+	"math"
+	"sort"
+)
+
+const earthRadius = 6371.0 // km
+
+type (
+	// Job is the paid order waiting for a mechanic
+	Job struct {
+		OrderID     string
+		Latitude    float64
+		Longitude   float64
+		HasLocation bool
+		Categories  []string
+	}
+
+	// Candidate is a mechanic on duty together with the jobs already assigned on the order date
+	Candidate struct {
+		MechanicID  int
+		Latitude    float64
+		Longitude   float64
+		HasLocation bool
+		JobsOnDate  int
+		JobsOnSlot  int
+		Skills      []string
+		Rating      float64
+		ReviewCount int
+	}
+)
+
+// Strategy decides which mechanic takes the job, ok is false when nobody qualifies
+type Strategy interface {
+	Pick(job Job, candidates []Candidate) (mechanicID int, ok bool)
+}
+
+// Scorer is the default strategy. A candidate qualifies when the time slot is free, the
+// daily workload is below MaxJobsPerDay, every category of the job is one of the skills
+// and the distance is within MaxDistance. The qualified candidate with the lowest score wins,
+// the score grows with the distance, the workload and the stars missing from the rating.
+type Scorer struct {
+	MaxDistance    float64 // km, zero disables the limit
+	MaxJobsPerDay  int     // zero disables the limit
+	DistanceWeight float64 // score per km
+	WorkloadWeight float64 // score per job on the same date
+	RatingWeight   float64 // score per star below the best rating
+}
+
+// mechanics without reviews are neither favoured nor penalized against the reviewed ones
+const neutralRating = 4.0
+
+func NewScorer(maxDistance float64, maxJobsPerDay int) *Scorer {
+	return &Scorer{
+		MaxDistance:    maxDistance,
+		MaxJobsPerDay:  maxJobsPerDay,
+		DistanceWeight: 1,
+		WorkloadWeight: 5,
+		RatingWeight:   2,
+	}
+}
+
+type scored struct {
+	mechanicID int
+	jobsOnDate int
+	score      float64
+}
+
+func (s *Scorer) Pick(job Job, candidates []Candidate) (int, bool) {
+	qualified := make([]scored, 0, len(candidates))
+	for _, c := range candidates {
+		if c.JobsOnSlot > 0 {
+			continue
+		}
+		if s.MaxJobsPerDay > 0 && c.JobsOnDate >= s.MaxJobsPerDay {
+			continue
+		}
+		if !hasSkills(c.Skills, job.Categories) {
+			continue
+		}
+
+		// a mechanic without a known location is scored as if at the edge of the area
+		distance := s.MaxDistance
+		if job.HasLocation && c.HasLocation {
+			distance = Distance(job.Latitude, job.Longitude, c.Latitude, c.Longitude)
+			if s.MaxDistance > 0 && distance > s.MaxDistance {
+				continue
+			}
+		}
+
+		rating := c.Rating
+		if c.ReviewCount == 0 {
+			rating = neutralRating
+		}
+
+		qualified = append(qualified, scored{
+			mechanicID: c.MechanicID,
+			jobsOnDate: c.JobsOnDate,
+			score:      s.DistanceWeight*distance + s.WorkloadWeight*float64(c.JobsOnDate) + s.RatingWeight*(5-rating),
+		})
+	}
+
+	if len(qualified) == 0 {
+		return 0, false
+	}
+
+	sort.SliceStable(qualified, func(i, j int) bool {
+		if qualified[i].score != qualified[j].score {
+			return qualified[i].score < qualified[j].score
+		}
+		if qualified[i].jobsOnDate != qualified[j].jobsOnDate {
+			return qualified[i].jobsOnDate < qualified[j].jobsOnDate
+		}
+		return qualified[i].mechanicID < qualified[j].mechanicID
+	})
+	return qualified[0].mechanicID, true
+}
+
+// Distance returns the great circle distance in km between two coordinates using the haversine formula
+func Distance(lat1, lon1, lat2, lon2 float64) float64 {
+	dLat := toRadians(lat2 - lat1)
+	dLon := toRadians(lon2 - lon1)
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+func toRadians(degree float64) float64 {
+	return degree * math.Pi / 180
+}
+
+func hasSkills(skills, categories []string) bool {
+	owned := make(map[string]bool, len(skills))
+	for _, skill := range skills {
+		owned[skill] = true
+	}
+	for _, category := range categories {
+		if !owned[category] {
+			return false
+		}
+	}
+	return true
+}
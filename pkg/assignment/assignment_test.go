@@ -0,0 +1,112 @@
+package assignment
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDistance(t *testing.T) {
+	// Monas to Bundaran HI, Jakarta
+	distance := Distance(-6.1754, 106.8272, -6.1950, 106.8230)
+	assert.InDelta(t, 2.2, distance, 0.1)
+
+	assert.Equal(t, 0.0, Distance(-6.2, 106.8, -6.2, 106.8))
+}
+
+func TestScorerPick(t *testing.T) {
+	job := Job{
+		OrderID:     "order-1",
+		Latitude:    -6.1754,
+		Longitude:   106.8272,
+		HasLocation: true,
+		Categories:  []string{"Engine"},
+	}
+
+	tt := []struct {
+		Name       string
+		Candidates []Candidate
+		MechanicID int
+		Ok         bool
+	}{
+		{
+			Name: "Nearest mechanic",
+			Candidates: []Candidate{
+				{MechanicID: 1, Latitude: -6.2950, Longitude: 106.8230, HasLocation: true, Skills: []string{"Engine"}},
+				{MechanicID: 2, Latitude: -6.1950, Longitude: 106.8230, HasLocation: true, Skills: []string{"Engine"}},
+			},
+			MechanicID: 2,
+			Ok:         true,
+		},
+		{
+			Name: "Busy mechanic loses to a free one nearby",
+			Candidates: []Candidate{
+				{MechanicID: 1, Latitude: -6.1754, Longitude: 106.8272, HasLocation: true, JobsOnDate: 3, Skills: []string{"Engine"}},
+				{MechanicID: 2, Latitude: -6.1950, Longitude: 106.8230, HasLocation: true, Skills: []string{"Engine"}},
+			},
+			MechanicID: 2,
+			Ok:         true,
+		},
+		{
+			Name: "Time slot taken",
+			Candidates: []Candidate{
+				{MechanicID: 1, Latitude: -6.1754, Longitude: 106.8272, HasLocation: true, JobsOnSlot: 1, JobsOnDate: 1, Skills: []string{"Engine"}},
+				{MechanicID: 2, Latitude: -6.2950, Longitude: 106.8230, HasLocation: true, JobsOnDate: 1, Skills: []string{"Engine"}},
+			},
+			MechanicID: 2,
+			Ok:         true,
+		},
+		{
+			Name: "Missing skill",
+			Candidates: []Candidate{
+				{MechanicID: 1, Latitude: -6.1754, Longitude: 106.8272, HasLocation: true, Skills: []string{"Tire"}},
+			},
+			Ok: false,
+		},
+		{
+			Name: "Too far",
+			Candidates: []Candidate{
+				{MechanicID: 1, Latitude: -7.2575, Longitude: 112.7521, HasLocation: true, Skills: []string{"Engine"}},
+			},
+			Ok: false,
+		},
+		{
+			Name: "Daily workload reached",
+			Candidates: []Candidate{
+				{MechanicID: 1, Latitude: -6.1754, Longitude: 106.8272, HasLocation: true, JobsOnDate: 4, Skills: []string{"Engine"}},
+			},
+			Ok: false,
+		},
+		{
+			Name: "Unknown location is ranked last",
+			Candidates: []Candidate{
+				{MechanicID: 1, Skills: []string{"Engine"}},
+				{MechanicID: 2, Latitude: -6.2950, Longitude: 106.8230, HasLocation: true, Skills: []string{"Engine"}},
+			},
+			MechanicID: 2,
+			Ok:         true,
+		},
+		{
+			Name: "Better rated mechanic slightly further away",
+			Candidates: []Candidate{
+				{MechanicID: 1, Latitude: -6.1754, Longitude: 106.8272, HasLocation: true, Skills: []string{"Engine"}, Rating: 2, ReviewCount: 10},
+				{MechanicID: 2, Latitude: -6.1950, Longitude: 106.8230, HasLocation: true, Skills: []string{"Engine"}, Rating: 4.9, ReviewCount: 25},
+			},
+			MechanicID: 2,
+			Ok:         true,
+		},
+		{
+			Name: "Nobody on duty",
+			Ok:   false,
+		},
+	}
+
+	scorer := NewScorer(20, 4)
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			mechanicID, ok := scorer.Pick(job, tc.Candidates)
+			assert.Equal(t, tc.Ok, ok)
+			assert.Equal(t, tc.MechanicID, mechanicID)
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package chat
+
+import "sync"
+
+const subscriberBuffer = 16
+
+const (
+	EventMessage = "message"
+	EventRead    = "read"
+)
+
+// Event is pushed to the participants of an order conversation, Data is sent as JSON
+type Event struct {
+	Name string
+	Data interface{}
+}
+
+// Hub fans out the events of a conversation to the participants connected to this instance
+type Hub struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan Event]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		subscribers: make(map[string]map[chan Event]struct{}),
+	}
+}
+
+// Subscribe returns the events of the conversation and a function to leave it,
+// the channel is closed once unsubscribed.
+func (h *Hub) Subscribe(orderID string) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	h.mu.Lock()
+	if h.subscribers[orderID] == nil {
+		h.subscribers[orderID] = make(map[chan Event]struct{})
+	}
+	h.subscribers[orderID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subscribers[orderID], ch)
+			if len(h.subscribers[orderID]) == 0 {
+				delete(h.subscribers, orderID)
+			}
+			h.mu.Unlock()
+			close(ch)
+		})
+	}
+	return ch, unsubscribe
+}
+
+// Publish never blocks, a participant which does not keep up misses the event
+// and catches up by listing the messages again.
+func (h *Hub) Publish(orderID string, event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subscribers[orderID] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package chat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHub(t *testing.T) {
+	hub := NewHub()
+
+	customer, leaveCustomer := hub.Subscribe("order-1")
+	mechanic, leaveMechanic := hub.Subscribe("order-1")
+	defer leaveMechanic()
+
+	hub.Publish("order-1", Event{Name: EventMessage, Data: "hello"})
+	assert.Equal(t, "hello", (<-customer).Data)
+	assert.Equal(t, "hello", (<-mechanic).Data)
+
+	leaveCustomer()
+	_, ok := <-customer
+	assert.False(t, ok)
+
+	hub.Publish("order-2", Event{Name: EventRead})
+	assert.Len(t, mechanic, 0)
+}
@@ -2,35 +2,39 @@ package fcm
 
 import (
 	"context"
+	"e-montir/pkg/notification"
 	"fmt"
+	"strings"
 
 	firebase "firebase.google.com/go"
 	"firebase.google.com/go/messaging"
-	"github.com/rs/zerolog/log"
 )
 
-type NotifFcm struct {
-	To       string
-	Title    string
-	Body     string
-	Redirect string
+// invalidTokenMessage is how firebase tells an invalid registration token apart from the other invalid arguments
+const invalidTokenMessage = "not a valid FCM registration token"
+
+// Client sends pushes through firebase cloud messaging, the firebase app is set up once
+// from the default credentials (GOOGLE_APPLICATION_CREDENTIALS).
+type Client struct {
+	client *messaging.Client
 }
 
-func SendNotification(ctx context.Context, notif NotifFcm) {
+func NewClient(ctx context.Context) (*Client, error) {
 	app, err := firebase.NewApp(ctx, nil)
 	if err != nil {
-		log.Info().Msg(fmt.Sprintf("failed to establish connection: %s", err.Error()))
+		return nil, fmt.Errorf("failed to establish connection: %w", err)
 	}
 
 	client, err := app.Messaging(ctx)
 	if err != nil {
-		log.Info().Msg(fmt.Sprintf("error getting Messaging client: %s", err.Error()))
+		return nil, fmt.Errorf("error getting Messaging client: %w", err)
 	}
+	return &Client{client: client}, nil
+}
 
+func (c *Client) Send(ctx context.Context, notif *notification.Message) error {
 	msg := &messaging.Message{
-		Data: map[string]string{
-			"redirect": notif.Redirect,
-		},
+		Data:  notif.Data,
 		Token: notif.To,
 		Notification: &messaging.Notification{
 			Title: notif.Title,
@@ -51,10 +55,18 @@ func SendNotification(ctx context.Context, notif NotifFcm) {
 		},
 	}
 
-	res, err := client.Send(ctx, msg)
+	_, err := c.client.Send(ctx, msg)
 	if err != nil {
-		log.Info().Msg(fmt.Sprintf("error when sending message to client: %s", err.Error()))
+		if messaging.IsRegistrationTokenNotRegistered(err) || isInvalidToken(err) {
+			return fmt.Errorf("%w: %s", notification.ErrInvalidToken, err.Error())
+		}
+		return fmt.Errorf("error when sending message to client: %w", err)
 	}
+	return nil
+}
 
-	fmt.Println(res)
+// isInvalidToken tells whether firebase rejected the token itself, any other invalid argument is a problem
+// of the message which says nothing about the device.
+func isInvalidToken(err error) bool {
+	return messaging.IsInvalidArgument(err) && strings.Contains(err.Error(), invalidTokenMessage)
 }
@@ -10,13 +10,18 @@ import (
 
 type Claim struct {
 	jwt.StandardClaims
-	ID string `json:"id"`
+	ID   string `json:"id"`
+	Role string `json:"role"`
 }
 
-func GenerateToken(id, key string, duration int) (token, expiredAt string, err error) {
+// GenerateToken signs an access token of the user, tokenID is carried as the jti claim
+// so the token can be revoked before it expires.
+func GenerateToken(id, role, tokenID, key string, duration int) (token, expiredAt string, err error) {
 	claim := Claim{
-		ID: id,
+		ID:   id,
+		Role: role,
 	}
+	claim.Id = tokenID
 	now := time.Now().UTC()
 	claim.IssuedAt = now.Unix()
 	claim.ExpiresAt = now.Add(time.Minute * time.Duration(duration)).Unix()
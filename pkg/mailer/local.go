@@ -0,0 +1,69 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// File writes every email as an .eml file into Dir instead of sending it, the files
+// open in any mail client which makes checking the templates during development easy.
+type File struct {
+	Dir    string
+	sender string
+}
+
+func NewFile(dir, sender string) *File {
+	return &File{
+		Dir:    dir,
+		sender: sender,
+	}
+}
+
+func (f *File) Send(ctx context.Context, msg *Message) error {
+	now := time.Now()
+	content, err := msg.Bytes(f.sender, now)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(f.Dir, 0o755)
+	if err != nil {
+		return err
+	}
+
+	recipient := strings.NewReplacer("@", "_at_", "/", "_", "\\", "_").Replace(msg.To)
+	name := fmt.Sprintf("%s-%s.eml", now.Format("20060102T150405.000000000"), recipient)
+	return os.WriteFile(filepath.Join(f.Dir, name), content, 0o600)
+}
+
+// Memory keeps the emails it is asked to send so tests can look at them, Err is returned instead when set
+type Memory struct {
+	mu       sync.Mutex
+	messages []Message
+	Err      error
+}
+
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+func (m *Memory) Send(ctx context.Context, msg *Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Err != nil {
+		return m.Err
+	}
+	m.messages = append(m.messages, *msg)
+	return nil
+}
+
+func (m *Memory) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message(nil), m.messages...)
+}
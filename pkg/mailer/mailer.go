@@ -4,21 +4,11 @@ import (
 	"context"
 	"fmt"
 	"net/smtp"
-	"os"
-
-	"github.com/rs/zerolog/log"
+	"net/url"
+	"strconv"
+	"time"
 )
 
-type EmailSender interface {
-	SendActivationLink(ctx context.Context, recipient string)
-}
-
-type Mailer struct {
-	auth   smtp.Auth
-	sender string
-	server string
-}
-
 type Config struct {
 	Username string
 	Password string
@@ -27,24 +17,81 @@ type Config struct {
 	Port     int
 }
 
-func NewMailtrap(m *Config) *Mailer {
-	return &Mailer{
-		auth:   smtp.PlainAuth("", m.Username, m.Password, m.Host),
+// Message is a rendered email, Text is the fallback of clients which do not show HTML
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Transport delivers a single email
+type Transport interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// SMTP delivers through the SMTP server of the config. Without a username the server is
+// reached without authentication, as SMTP capture tools like mailhog expect.
+type SMTP struct {
+	auth   smtp.Auth
+	sender string
+	server string
+}
+
+func NewSMTP(m *Config) *SMTP {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	return &SMTP{
+		auth:   auth,
 		sender: m.Sender,
 		server: fmt.Sprintf("%s:%d", m.Host, m.Port),
 	}
 }
 
-func (s *Mailer) SendActivationLink(ctx context.Context, recipient, content string) {
-	from := "e-montir"
-	to := []string{recipient}
-	link := fmt.Sprintf("%s/auth/verify?email=%s&id=%s", os.Getenv("BASE_URL"), recipient, content)
-	msg := []byte(fmt.Sprintf("From: %s\r\n", from) +
-		fmt.Sprintf("To: %s\r\n", recipient) +
-		"Subject: Email verification\r\n\r\n" + ActivationEmailLinkTemplate(link))
+func (s *SMTP) Send(ctx context.Context, msg *Message) error {
+	content, err := msg.Bytes(s.sender, time.Now())
+	if err != nil {
+		return err
+	}
+	return smtp.SendMail(s.server, s.auth, s.sender, []string{msg.To}, content)
+}
+
+// Mailer renders the account emails and leaves sending them to the queue
+type Mailer struct {
+	queue            *Queue
+	baseURL          string
+	passwordResetURL string
+}
+
+// NewMailer links the activation email to baseURL, the API verifying the token, and the
+// password reset email to passwordResetURL, the screen of the app which asks for the new password.
+func NewMailer(queue *Queue, baseURL, passwordResetURL string) *Mailer {
+	return &Mailer{
+		queue:            queue,
+		baseURL:          baseURL,
+		passwordResetURL: passwordResetURL,
+	}
+}
+
+func (s *Mailer) SendActivationLink(recipient, token string) error {
+	msg, err := Render(TemplateActivation, recipient, &TemplateData{
+		Link: fmt.Sprintf("%s/auth/verify?token=%s", s.baseURL, url.QueryEscape(token)),
+	})
+	if err != nil {
+		return err
+	}
+	return s.queue.Enqueue(msg)
+}
 
-	err := smtp.SendMail(s.server, s.auth, s.sender, to, msg)
+func (s *Mailer) SendPasswordResetLink(recipient, token string, duration int) error {
+	msg, err := Render(TemplatePasswordReset, recipient, &TemplateData{
+		Link: fmt.Sprintf("%s?token=%s", s.passwordResetURL, url.QueryEscape(token)),
+		Data: map[string]string{"duration": strconv.Itoa(duration)},
+	})
 	if err != nil {
-		log.Error().Err(err).Msg(err.Error())
+		return err
 	}
+	return s.queue.Enqueue(msg)
 }
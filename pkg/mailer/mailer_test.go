@@ -0,0 +1,144 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRender(t *testing.T) {
+	msg, err := Render(TemplateOrderStatus, "hello@gmail.com", &TemplateData{
+		Name:  "Budi <b>",
+		Title: "service done",
+		Body:  "service done, looking forward to your next order",
+		Link:  "emontir://app/orders/order-1",
+		Data:  map[string]string{"invoice_id": "INV-1", "status": "Done"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "hello@gmail.com", msg.To)
+	assert.Equal(t, "service done", msg.Subject)
+	assert.True(t, strings.HasPrefix(msg.Text, "Hi Budi <b>,"))
+	assert.Contains(t, msg.Text, "Invoice: INV-1")
+	assert.Contains(t, msg.HTML, "Hi Budi &lt;b&gt;,")
+	assert.Contains(t, msg.HTML, `href="emontir://app/orders/order-1"`)
+
+	_, err = Render("unknown", "hello@gmail.com", &TemplateData{})
+	assert.Error(t, err)
+}
+
+func TestRenderEveryTemplate(t *testing.T) {
+	for name := range textTemplates {
+		msg, err := Render(name, "hello@gmail.com", &TemplateData{Title: "service done", Link: "https://e-montir.id"})
+		assert.NoError(t, err, name)
+		assert.NotEmpty(t, msg.Subject, name)
+		assert.NotContains(t, msg.Text, "<no value>", name)
+	}
+}
+
+func TestMessageBytes(t *testing.T) {
+	msg := &Message{
+		To:      "hello@gmail.com",
+		Subject: "Pembayaran berhasil ✓",
+		Text:    "payment success",
+		HTML:    "<p>payment success</p>",
+	}
+	content, err := msg.Bytes("noreply@e-montir.id", time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+
+	parsed, err := mail.ReadMessage(bytes.NewReader(content))
+	assert.NoError(t, err)
+	assert.Equal(t, `"e-Montir" <noreply@e-montir.id>`, parsed.Header.Get("From"))
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	assert.NoError(t, err)
+	assert.Equal(t, msg.Subject, subject)
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	assert.NoError(t, err)
+	assert.Equal(t, "multipart/alternative", mediaType)
+
+	reader := multipart.NewReader(parsed.Body, params["boundary"])
+	var parts []string
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		body, err := io.ReadAll(part)
+		assert.NoError(t, err)
+		parts = append(parts, part.Header.Get("Content-Type")+": "+string(body))
+	}
+	assert.Equal(t, []string{
+		"text/plain; charset=utf-8: payment success",
+		"text/html; charset=utf-8: <p>payment success</p>",
+	}, parts)
+}
+
+func TestFile(t *testing.T) {
+	dir := t.TempDir()
+	transport := NewFile(filepath.Join(dir, "mails"), "noreply@e-montir.id")
+	assert.NoError(t, transport.Send(context.Background(), &Message{To: "hello@gmail.com", Subject: "Email verification", Text: "hi"}))
+
+	files, err := os.ReadDir(filepath.Join(dir, "mails"))
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+	assert.True(t, strings.HasSuffix(files[0].Name(), "hello_at_gmail.com.eml"))
+}
+
+// flakyTransport fails the first failures sends
+type flakyTransport struct {
+	Memory
+	failures int
+	attempts chan struct{}
+}
+
+func (f *flakyTransport) Send(ctx context.Context, msg *Message) error {
+	defer func() { f.attempts <- struct{}{} }()
+	if f.failures > 0 {
+		f.failures--
+		return errors.New("connection refused")
+	}
+	return f.Memory.Send(ctx, msg)
+}
+
+func TestQueueRetries(t *testing.T) {
+	transport := &flakyTransport{failures: 2, attempts: make(chan struct{}, 10)}
+	queue := NewQueue(transport, 10)
+	queue.backoff = func(attempt int) time.Duration { return time.Millisecond }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go queue.Run(ctx)
+
+	assert.NoError(t, queue.Enqueue(&Message{To: "hello@gmail.com", Subject: "Email verification"}))
+	for i := 0; i < 3; i++ {
+		select {
+		case <-transport.attempts:
+		case <-time.After(time.Second):
+			t.Fatal("email was not tried again")
+		}
+	}
+	assert.Len(t, transport.Messages(), 1)
+}
+
+func TestQueueFull(t *testing.T) {
+	queue := NewQueue(NewMemory(), 1)
+	assert.NoError(t, queue.Enqueue(&Message{To: "hello@gmail.com"}))
+	assert.Equal(t, ErrQueueFull, queue.Enqueue(&Message{To: "hello@gmail.com"}))
+}
+
+func TestQueueBackoff(t *testing.T) {
+	assert.Equal(t, 10*time.Second, queueBackoff(1))
+	assert.Equal(t, 80*time.Second, queueBackoff(4))
+}
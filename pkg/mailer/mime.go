@@ -0,0 +1,68 @@
+package mailer
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"time"
+)
+
+const senderName = "e-Montir"
+
+// Bytes builds the message as MIME multipart/alternative with the text part first,
+// clients show the last part they understand.
+func (m *Message) Bytes(sender string, date time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	body := multipart.NewWriter(&buf)
+
+	from := mail.Address{Name: senderName, Address: sender}
+	to := mail.Address{Address: m.To}
+	headers := fmt.Sprintf("From: %s\r\n", from.String()) +
+		fmt.Sprintf("To: %s\r\n", to.String()) +
+		fmt.Sprintf("Subject: %s\r\n", mime.QEncoding.Encode("utf-8", m.Subject)) +
+		fmt.Sprintf("Date: %s\r\n", date.Format(time.RFC1123Z)) +
+		"MIME-Version: 1.0\r\n" +
+		fmt.Sprintf("Content-Type: multipart/alternative; boundary=%q\r\n\r\n", body.Boundary())
+
+	var message bytes.Buffer
+	message.WriteString(headers)
+
+	err := writePart(body, "text/plain; charset=utf-8", m.Text)
+	if err != nil {
+		return nil, err
+	}
+	if m.HTML != "" {
+		err = writePart(body, "text/html; charset=utf-8", m.HTML)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = body.Close()
+	if err != nil {
+		return nil, err
+	}
+	message.Write(buf.Bytes())
+	return message.Bytes(), nil
+}
+
+func writePart(body *multipart.Writer, contentType, content string) error {
+	part, err := body.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return err
+	}
+
+	writer := quotedprintable.NewWriter(part)
+	_, err = writer.Write([]byte(content))
+	if err != nil {
+		return err
+	}
+	return writer.Close()
+}
@@ -0,0 +1,95 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ErrQueueFull is returned when the email cannot be queued without waiting
+var ErrQueueFull = errors.New("email queue is full")
+
+const (
+	queueMaxAttempts = 5
+	queueBackoffBase = 10 * time.Second
+	queueSendTimeout = 30 * time.Second
+)
+
+type queuedMessage struct {
+	msg      *Message
+	attempts int
+}
+
+// Queue sends the emails in the background so the request which asked for one does not wait
+// for the mail server, a failed email is tried again with a growing delay.
+type Queue struct {
+	transport Transport
+	messages  chan *queuedMessage
+	backoff   func(attempt int) time.Duration
+}
+
+func NewQueue(transport Transport, size int) *Queue {
+	return &Queue{
+		transport: transport,
+		messages:  make(chan *queuedMessage, size),
+		backoff:   queueBackoff,
+	}
+}
+
+// queueBackoff doubles from 10 seconds, 10s, 20s, 40s, 80s between the five attempts
+func queueBackoff(attempt int) time.Duration {
+	return queueBackoffBase << (attempt - 1)
+}
+
+func (q *Queue) Enqueue(msg *Message) error {
+	return q.enqueue(&queuedMessage{msg: msg})
+}
+
+func (q *Queue) enqueue(item *queuedMessage) error {
+	select {
+	case q.messages <- item:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Run sends the queued emails until ctx is cancelled, emails still waiting then are dropped
+func (q *Queue) Run(ctx context.Context) {
+	log.Info().Msg("starting email queue")
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info().Msg(fmt.Sprintf("stopping email queue, %d emails left", len(q.messages)))
+			return
+		case item := <-q.messages:
+			q.send(ctx, item)
+		}
+	}
+}
+
+func (q *Queue) send(ctx context.Context, item *queuedMessage) {
+	sendCtx, cancel := context.WithTimeout(ctx, queueSendTimeout)
+	defer cancel()
+
+	item.attempts++
+	err := q.transport.Send(sendCtx, item.msg)
+	if err == nil {
+		return
+	}
+
+	if item.attempts >= queueMaxAttempts {
+		log.Error().Err(fmt.Errorf("giving up sending %q to %s : %w", item.msg.Subject, item.msg.To, err)).Send()
+		return
+	}
+
+	log.Error().Err(fmt.Errorf("error when sending %q to %s, trying again : %w", item.msg.Subject, item.msg.To, err)).Send()
+	time.AfterFunc(q.backoff(item.attempts), func() {
+		if err := q.enqueue(item); err != nil {
+			log.Error().Err(fmt.Errorf("error when queueing %q to %s again : %w", item.msg.Subject, item.msg.To, err)).Send()
+		}
+	})
+}
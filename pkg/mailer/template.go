@@ -0,0 +1,78 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+)
+
+const (
+	TemplateActivation        = "activation"
+	TemplatePasswordReset     = "password_reset"
+	TemplateOrderConfirmation = "order_confirmation"
+	TemplatePaymentReceipt    = "payment_receipt"
+	TemplateOrderStatus       = "order_status"
+)
+
+//go:embed templates
+var templateFiles embed.FS
+
+// every template has a text version, <name>.txt which also defines the subject,
+// and an html version, <name>.html which fills the content of the layout.
+var (
+	textTemplates = map[string]*texttemplate.Template{}
+	htmlTemplates = map[string]*htmltemplate.Template{}
+)
+
+func init() {
+	for _, name := range []string{TemplateActivation, TemplatePasswordReset, TemplateOrderConfirmation, TemplatePaymentReceipt, TemplateOrderStatus} {
+		textTemplates[name] = texttemplate.Must(texttemplate.New(name+".txt").Option("missingkey=zero").ParseFS(templateFiles, "templates/"+name+".txt"))
+		htmlTemplates[name] = htmltemplate.Must(htmltemplate.New("layout.html").Option("missingkey=zero").ParseFS(templateFiles, "templates/layout.html", "templates/"+name+".html"))
+	}
+}
+
+// TemplateData is what the templates are rendered with, Data carries the values
+// only some templates use, e.g. the invoice of the order.
+type TemplateData struct {
+	Name  string
+	Title string
+	Body  string
+	Link  string
+	Data  map[string]string
+}
+
+// Render builds the email of the template for recipient
+func Render(name, recipient string, data *TemplateData) (*Message, error) {
+	textTemplate, ok := textTemplates[name]
+	if !ok {
+		return nil, fmt.Errorf("email template %s not exists", name)
+	}
+
+	var subject, text, html bytes.Buffer
+	err := textTemplate.ExecuteTemplate(&subject, "subject", data)
+	if err != nil {
+		return nil, err
+	}
+	err = textTemplate.Execute(&text, data)
+	if err != nil {
+		return nil, err
+	}
+	// the links are built by the server, custom schemes like the deep links of the app are let through
+	err = htmlTemplates[name].Execute(&html, struct {
+		*TemplateData
+		Link htmltemplate.URL
+	}{data, htmltemplate.URL(data.Link)})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Message{
+		To:      recipient,
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+		HTML:    html.String(),
+	}, nil
+}
@@ -0,0 +1,114 @@
+package media
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"net/http"
+
+	// registers the png decoder for image.Decode
+	_ "image/png"
+)
+
+const (
+	MaxImageSize = 5 << 20 // 5 MB
+
+	// ThumbnailSize is the longest side of a generated thumbnail in pixels
+	ThumbnailSize = 320
+)
+
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+}
+
+// ImageType sniffs the content and returns the content type and file extension of a supported image
+func ImageType(content []byte) (contentType, ext string, err error) {
+	contentType = http.DetectContentType(content)
+	ext, ok := imageExtensions[contentType]
+	if !ok {
+		return "", "", fmt.Errorf("image must be a jpeg, png or webp")
+	}
+	return contentType, ext, nil
+}
+
+// ImageExtension sniffs the content and returns the file extension of a supported image
+func ImageExtension(content []byte) (string, error) {
+	_, ext, err := ImageType(content)
+	return ext, err
+}
+
+// Thumbnail scales the image down so its longest side is at most maxSide and encodes it as jpeg,
+// it reports false for formats which cannot be decoded here (webp) so the original is used instead.
+func Thumbnail(content []byte, maxSide int) ([]byte, bool, error) {
+	src, format, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		if err == image.ErrFormat {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	if format != "jpeg" && format != "png" {
+		return nil, false, nil
+	}
+
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 {
+		return nil, false, fmt.Errorf("image is empty")
+	}
+	if width > maxSide || height > maxSide {
+		if width >= height {
+			width, height = maxSide, max(1, height*maxSide/width)
+		} else {
+			width, height = max(1, width*maxSide/height), maxSide
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	scale(dst, src)
+
+	var buf bytes.Buffer
+	err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80})
+	if err != nil {
+		return nil, false, err
+	}
+	return buf.Bytes(), true, nil
+}
+
+// scale fills dst by averaging the block of source pixels each destination pixel covers
+func scale(dst *image.RGBA, src image.Image) {
+	sb := src.Bounds()
+	db := dst.Bounds()
+	for y := 0; y < db.Dy(); y++ {
+		y0 := sb.Min.Y + y*sb.Dy()/db.Dy()
+		y1 := max(y0+1, sb.Min.Y+(y+1)*sb.Dy()/db.Dy())
+		for x := 0; x < db.Dx(); x++ {
+			x0 := sb.Min.X + x*sb.Dx()/db.Dx()
+			x1 := max(x0+1, sb.Min.X+(x+1)*sb.Dx()/db.Dx())
+
+			var r, g, b, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, b, a = r+pr, g+pg, b+pb, a+pa
+					n++
+				}
+			}
+			i := dst.PixOffset(x, y)
+			dst.Pix[i] = uint8(r / n >> 8)
+			dst.Pix[i+1] = uint8(g / n >> 8)
+			dst.Pix[i+2] = uint8(b / n >> 8)
+			dst.Pix[i+3] = uint8(a / n >> 8)
+		}
+	}
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
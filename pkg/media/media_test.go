@@ -0,0 +1,175 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func pngImage(t *testing.T, width, height int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: 200, G: 10, B: 10, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func TestImageType(t *testing.T) {
+	contentType, ext, err := ImageType(pngImage(t, 2, 2))
+	assert.NoError(t, err)
+	assert.Equal(t, "image/png", contentType)
+	assert.Equal(t, ".png", ext)
+
+	_, _, err = ImageType([]byte("%PDF-1.4 not an image"))
+	assert.Error(t, err)
+}
+
+func TestThumbnail(t *testing.T) {
+	t.Run("large image is scaled down keeping the ratio", func(t *testing.T) {
+		thumb, ok, err := Thumbnail(pngImage(t, 1000, 500), 320)
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		img, err := jpeg.Decode(bytes.NewReader(thumb))
+		require.NoError(t, err)
+		assert.Equal(t, 320, img.Bounds().Dx())
+		assert.Equal(t, 160, img.Bounds().Dy())
+
+		r, _, _, _ := img.At(10, 10).RGBA()
+		assert.InDelta(t, 200, r>>8, 10)
+	})
+
+	t.Run("small image keeps its size", func(t *testing.T) {
+		thumb, ok, err := Thumbnail(pngImage(t, 40, 100), 320)
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		img, err := jpeg.Decode(bytes.NewReader(thumb))
+		require.NoError(t, err)
+		assert.Equal(t, image.Rect(0, 0, 40, 100), img.Bounds())
+	})
+
+	t.Run("unknown format has no thumbnail", func(t *testing.T) {
+		_, ok, err := Thumbnail([]byte("RIFF....WEBPVP8 "), 320)
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+}
+
+func TestLocal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "media")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	store := NewLocal(dir, "/media/")
+	require.NoError(t, store.Save(context.Background(), "orders/1/a.png", "image/png", []byte("png")))
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, "orders", "1", "a.png"))
+	require.NoError(t, err)
+	assert.Equal(t, "png", string(content))
+	assert.Equal(t, "/media/orders/1/a.png", store.URL("orders/1/a.png"))
+
+	content, err = store.Get(context.Background(), "orders/1/a.png")
+	require.NoError(t, err)
+	assert.Equal(t, "png", string(content))
+	_, err = store.Get(context.Background(), "orders/1/../1/a.png")
+	assert.Equal(t, ErrNotExist, err)
+
+	require.NoError(t, store.Delete(context.Background(), "orders/1/a.png"))
+	require.NoError(t, store.Delete(context.Background(), "orders/1/a.png"))
+	_, err = store.Get(context.Background(), "orders/1/a.png")
+	assert.Equal(t, ErrNotExist, err)
+}
+
+func TestValidKey(t *testing.T) {
+	for key, valid := range map[string]bool{
+		"orders/1/a.png":     true,
+		"reviews/1/a.png":    true,
+		"":                   false,
+		"/etc/passwd":        false,
+		"orders/../../a.png": false,
+		"orders/1/..":        false,
+		"orders//1/a.png":    false,
+		"orders/1/./a.png":   false,
+		"orders\\1\\a.png":   false,
+	} {
+		assert.Equal(t, valid, ValidKey(key), key)
+	}
+}
+
+func TestS3(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests = append(requests, r)
+		bodies = append(bodies, string(body))
+		if strings.HasSuffix(r.URL.Path, "missing.png") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, "deleted.png") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte("png"))
+		}
+	}))
+	defer server.Close()
+
+	store := NewS3(&S3Config{
+		Endpoint:  server.URL,
+		Bucket:    "emontir",
+		AccessKey: "access",
+		SecretKey: "secret",
+	})
+	store.now = func() time.Time { return time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+	require.NoError(t, store.Save(context.Background(), "reviews/1/a.png", "image/png", []byte("png")))
+	require.NoError(t, store.Delete(context.Background(), "reviews/1/a.png"))
+	assert.Error(t, store.Save(context.Background(), "reviews/1/missing.png", "image/png", []byte("png")))
+
+	require.Len(t, requests, 3)
+	put := requests[0]
+	assert.Equal(t, http.MethodPut, put.Method)
+	assert.Equal(t, "/emontir/reviews/1/a.png", put.URL.Path)
+	assert.Equal(t, "png", bodies[0])
+	assert.Equal(t, "image/png", put.Header.Get("Content-Type"))
+	assert.Equal(t, "20220102T030405Z", put.Header.Get("X-Amz-Date"))
+	assert.Equal(t, sha256Hex([]byte("png")), put.Header.Get("X-Amz-Content-Sha256"))
+	assert.True(t, strings.HasPrefix(put.Header.Get("Authorization"),
+		"AWS4-HMAC-SHA256 Credential=access/20220102/us-east-1/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature="))
+	assert.Equal(t, http.MethodDelete, requests[1].Method)
+
+	content, err := store.Get(context.Background(), "reviews/1/a.png")
+	require.NoError(t, err)
+	assert.Equal(t, "png", string(content))
+	assert.Equal(t, http.MethodGet, requests[3].Method)
+	assert.NotEmpty(t, requests[3].Header.Get("Authorization"))
+	_, err = store.Get(context.Background(), "reviews/1/deleted.png")
+	assert.Equal(t, ErrNotExist, err)
+	_, err = store.Get(context.Background(), "reviews/1/missing.png")
+	assert.Error(t, err)
+
+	assert.Equal(t, server.URL+"/emontir/reviews/1/a.png", store.URL("reviews/1/a.png"))
+}
@@ -0,0 +1,196 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	s3Algorithm = "AWS4-HMAC-SHA256"
+	s3Service   = "s3"
+	s3TimeFmt   = "20060102T150405Z"
+	s3DateFmt   = "20060102"
+)
+
+type S3Config struct {
+	// Endpoint is the base URL of the S3 compatible server, e.g. http://localhost:9000 for minio
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// BaseURL is where the objects are served from, it defaults to the bucket on the endpoint
+	BaseURL string
+	Timeout time.Duration
+}
+
+// S3 keeps uploaded files in a bucket of an S3 compatible object storage, objects are addressed
+// path style so any stand-in server works without DNS setup.
+type S3 struct {
+	client    *http.Client
+	endpoint  string
+	region    string
+	bucket    string
+	accessKey string
+	secretKey string
+	baseURL   string
+	now       func() time.Time
+}
+
+func NewS3(cfg *S3Config) *S3 {
+	endpoint := strings.TrimSuffix(cfg.Endpoint, "/")
+	baseURL := strings.TrimSuffix(cfg.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = endpoint + "/" + cfg.Bucket
+	}
+	region := cfg.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	return &S3{
+		client:    &http.Client{Timeout: cfg.Timeout},
+		endpoint:  endpoint,
+		region:    region,
+		bucket:    cfg.Bucket,
+		accessKey: cfg.AccessKey,
+		secretKey: cfg.SecretKey,
+		baseURL:   baseURL,
+		now:       time.Now,
+	}
+}
+
+func (s *S3) Save(ctx context.Context, key, contentType string, content []byte) error {
+	req, err := s.newRequest(ctx, http.MethodPut, key, content)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	return s.do(req)
+}
+
+func (s *S3) Get(ctx context.Context, key string) ([]byte, error) {
+	req, err := s.newRequest(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, ErrNotExist
+	}
+	if err = checkResponse(req, res); err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(res.Body)
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	req, err := s.newRequest(ctx, http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	return s.do(req)
+}
+
+func (s *S3) URL(key string) string {
+	return s.baseURL + "/" + key
+}
+
+func (s *S3) newRequest(ctx context.Context, method, key string, content []byte) (*http.Request, error) {
+	var body io.Reader
+	if content != nil {
+		body = bytes.NewReader(content)
+	}
+	path := "/" + s.bucket + "/" + key
+	req, err := http.NewRequestWithContext(ctx, method, s.endpoint+escapePath(path), body)
+	if err != nil {
+		return nil, err
+	}
+	s.sign(req, content)
+	return req, nil
+}
+
+func (s *S3) do(req *http.Request) error {
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	return checkResponse(req, res)
+}
+
+func checkResponse(req *http.Request, res *http.Response) error {
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("storage responded %d to %s %s: %s", res.StatusCode, req.Method, req.URL.Path, msg)
+	}
+	return nil
+}
+
+// sign adds the AWS signature version 4 headers, only the host and the amz headers are signed
+func (s *S3) sign(req *http.Request, content []byte) {
+	now := s.now().UTC()
+	amzDate := now.Format(s3TimeFmt)
+	scope := strings.Join([]string{now.Format(s3DateFmt), s.region, s3Service, "aws4_request"}, "/")
+	payloadHash := sha256Hex(content)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + payloadHash + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	stringToSign := strings.Join([]string{s3Algorithm, amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), now.Format(s3DateFmt))
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, s3Service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3Algorithm, s.accessKey, scope, signedHeaders, signature))
+}
+
+// escapePath encodes every segment of the object path the way the signature expects
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, v := range segments {
+		segments[i] = strings.ReplaceAll(url.PathEscape(v), "+", "%2B")
+	}
+	return strings.Join(segments, "/")
+}
+
+func sha256Hex(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,32 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"path"
+	"strings"
+)
+
+// ErrNotExist is returned by Get when nothing is stored under the key
+var ErrNotExist = errors.New("media not exists")
+
+// Storage keeps uploaded files under keys and tells the URL each one is served from
+type Storage interface {
+	Save(ctx context.Context, key, contentType string, content []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+	URL(key string) string
+}
+
+// ValidKey tells whether the key is a relative slash separated path which stays inside the storage
+func ValidKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return false
+	}
+	for _, v := range strings.Split(key, "/") {
+		if v == ".." {
+			return false
+		}
+	}
+	return path.Clean(key) == key
+}
@@ -0,0 +1,93 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ErrInvalidToken tells that the device token will never accept a push again, the token should be dropped
+var ErrInvalidToken = errors.New("device token is invalid or no longer registered")
+
+type Message struct {
+	To    string
+	Title string
+	Body  string
+	Data  map[string]string
+}
+
+// Notifier pushes a message to a single device
+type Notifier interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// Log only writes the messages to the log, it stands in for a push service during development
+type Log struct{}
+
+func NewLog() *Log {
+	return &Log{}
+}
+
+func (l *Log) Send(ctx context.Context, msg *Message) error {
+	log.Info().Msg(fmt.Sprintf("push to %s: %s - %s %v", msg.To, msg.Title, msg.Body, msg.Data))
+	return nil
+}
+
+// Memory keeps the messages it is asked to send so tests can look at them, Err is returned instead when set
+// and TokenErrs for the messages to one of its tokens.
+type Memory struct {
+	mu        sync.Mutex
+	messages  []Message
+	Err       error
+	TokenErrs map[string]error
+}
+
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+func (m *Memory) Send(ctx context.Context, msg *Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Err != nil {
+		return m.Err
+	}
+	if err, ok := m.TokenErrs[msg.To]; ok {
+		return err
+	}
+	m.messages = append(m.messages, *msg)
+	return nil
+}
+
+func (m *Memory) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message(nil), m.messages...)
+}
+
+const (
+	MaxAttempts = 8
+
+	backoffBase = 30 * time.Second
+	backoffMax  = time.Hour
+)
+
+// Backoff is how long to wait before retrying a message which failed attempt times,
+// it doubles from 30 seconds up to an hour.
+func Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	wait := backoffBase
+	for i := 1; i < attempt; i++ {
+		wait *= 2
+		if wait >= backoffMax {
+			return backoffMax
+		}
+	}
+	return wait
+}
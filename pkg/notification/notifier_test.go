@@ -0,0 +1,31 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, 30*time.Second, Backoff(0))
+	assert.Equal(t, 30*time.Second, Backoff(1))
+	assert.Equal(t, time.Minute, Backoff(2))
+	assert.Equal(t, 32*time.Minute, Backoff(7))
+	assert.Equal(t, time.Hour, Backoff(8))
+	assert.Equal(t, time.Hour, Backoff(50))
+}
+
+func TestMemory(t *testing.T) {
+	notifier := NewMemory()
+	assert.NoError(t, notifier.Send(context.Background(), &Message{To: "token-1", Title: "payment success"}))
+
+	notifier.Err = errors.New("unavailable")
+	assert.Error(t, notifier.Send(context.Background(), &Message{To: "token-2"}))
+
+	messages := notifier.Messages()
+	assert.Len(t, messages, 1)
+	assert.Equal(t, "token-1", messages[0].To)
+}
@@ -0,0 +1,229 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+type FakeConfig struct {
+	ServerKey string
+	// AutoStatus, when set, is reported for every transaction AutoDelay after it is created
+	AutoStatus string
+	AutoDelay  time.Duration
+}
+
+// FakeGateway is an in-process payment provider for local development and tests.
+// Transactions are kept in memory and their outcome is chosen by calling Simulate,
+// which sends a signed notification to the transaction notification url.
+type FakeGateway struct {
+	mu           sync.Mutex
+	client       *http.Client
+	serverKey    string
+	autoStatus   string
+	autoDelay    time.Duration
+	transactions map[string]*fakeTransaction
+}
+
+type fakeTransaction struct {
+	ID              string
+	OrderID         string
+	GrossAmount     int
+	Status          string
+	NotificationURL string
+	RefundedAmount  int
+	RefundKey       string
+}
+
+// fakeStatusCodes follows the status codes the real gateway sends along with each status
+var fakeStatusCodes = map[string]string{
+	StatusPaid:         "200",
+	StatusPending:      "201",
+	StatusFailed:       "202",
+	StatusExpired:      "407",
+	StatusRefunded:     "200",
+	StatusRefundFailed: "202",
+}
+
+func NewFakeGateway(cfg *FakeConfig) *FakeGateway {
+	return &FakeGateway{
+		client:       &http.Client{Timeout: 10 * time.Second},
+		serverKey:    cfg.ServerKey,
+		autoStatus:   cfg.AutoStatus,
+		autoDelay:    cfg.AutoDelay,
+		transactions: make(map[string]*fakeTransaction),
+	}
+}
+
+func (g *FakeGateway) CreateTransaction(ctx context.Context, req *TransactionRequest) (*Transaction, error) {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return nil, err
+	}
+
+	g.mu.Lock()
+	g.transactions[req.OrderID] = &fakeTransaction{
+		ID:              id.String(),
+		OrderID:         req.OrderID,
+		GrossAmount:     req.GrossAmount,
+		Status:          StatusPending,
+		NotificationURL: req.NotificationURL,
+	}
+	g.mu.Unlock()
+
+	if g.autoStatus != "" {
+		go func(orderID string) {
+			time.Sleep(g.autoDelay)
+			if simulateErr := g.Simulate(context.Background(), orderID, g.autoStatus); simulateErr != nil {
+				log.Error().Err(fmt.Errorf("error when simulating payment: %w", simulateErr)).Send()
+			}
+		}(req.OrderID)
+	}
+
+	return &Transaction{
+		Code:        "200",
+		Message:     "success",
+		Token:       id.String(),
+		RedirectURL: fmt.Sprintf("%s?order_id=%s", req.FinishURL, req.OrderID),
+	}, nil
+}
+
+func (g *FakeGateway) GetStatus(ctx context.Context, orderID string) (*TransactionStatus, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	trx, ok := g.transactions[orderID]
+	if !ok {
+		return nil, fmt.Errorf("transaction of order %s not found", orderID)
+	}
+
+	return &TransactionStatus{
+		OrderID:           trx.OrderID,
+		TransactionID:     trx.ID,
+		TransactionStatus: trx.Status,
+		PaymentType:       "fake",
+		GrossAmount:       grossAmount(trx.GrossAmount),
+	}, nil
+}
+
+func (g *FakeGateway) Refund(ctx context.Context, req *RefundRequest) (*Refund, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	trx, ok := g.transactions[req.OrderID]
+	if !ok {
+		return nil, fmt.Errorf("transaction of order %s not found", req.OrderID)
+	}
+
+	if trx.Status != StatusPaid {
+		return nil, fmt.Errorf("transaction of order %s is %s and cannot be refunded", req.OrderID, trx.Status)
+	}
+
+	if trx.RefundedAmount+req.Amount > trx.GrossAmount {
+		return nil, fmt.Errorf("refund amount exceeds paid amount of order %s", req.OrderID)
+	}
+
+	trx.RefundedAmount += req.Amount
+	trx.RefundKey = req.RefundKey
+	return &Refund{
+		RefundKey: req.RefundKey,
+		Status:    StatusPending,
+	}, nil
+}
+
+func (g *FakeGateway) VerifyNotification(notif *Notification) error {
+	return verifySignature(notif, g.serverKey)
+}
+
+// Simulate moves the transaction of the order to status and notifies the application
+// the same way the real gateway would.
+func (g *FakeGateway) Simulate(ctx context.Context, orderID, status string) error {
+	statusCode, ok := fakeStatusCodes[status]
+	if !ok {
+		return fmt.Errorf("unsupported status %s", status)
+	}
+
+	g.mu.Lock()
+	trx, ok := g.transactions[orderID]
+	if !ok {
+		g.mu.Unlock()
+		return fmt.Errorf("transaction of order %s not found", orderID)
+	}
+	if status == StatusRefundFailed {
+		// a rejected refund leaves the money with the merchant so it can be refunded again
+		trx.RefundedAmount = 0
+	} else {
+		trx.Status = status
+	}
+	notif := fakeNotification{
+		OrderID:           trx.OrderID,
+		TransactionID:     trx.ID,
+		TransactionStatus: status,
+		TransactionTime:   time.Now().Format("2006-01-02 15:04:05"),
+		StatusCode:        statusCode,
+		GrossAmount:       grossAmount(trx.GrossAmount),
+		PaymentType:       "fake",
+		Currency:          "IDR",
+	}
+	if status == StatusRefunded || status == StatusRefundFailed {
+		notif.RefundKey = trx.RefundKey
+	}
+	notificationURL := trx.NotificationURL
+	g.mu.Unlock()
+
+	notif.SignatureKey = Signature(notif.OrderID, notif.StatusCode, notif.GrossAmount, g.serverKey)
+	return g.notify(ctx, notificationURL, notif)
+}
+
+type fakeNotification struct {
+	OrderID           string `json:"order_id"`
+	TransactionID     string `json:"transaction_id"`
+	RefundKey         string `json:"refund_key,omitempty"`
+	TransactionStatus string `json:"transaction_status"`
+	TransactionTime   string `json:"transaction_time"`
+	StatusCode        string `json:"status_code"`
+	GrossAmount       string `json:"gross_amount"`
+	SignatureKey      string `json:"signature_key"`
+	PaymentType       string `json:"payment_type"`
+	Currency          string `json:"currency"`
+}
+
+func (g *FakeGateway) notify(ctx context.Context, url string, notif fakeNotification) error {
+	if url == "" {
+		return fmt.Errorf("notification url of order %s is empty", notif.OrderID)
+	}
+
+	payload, err := json.Marshal(notif)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Content-Type", "application/json")
+
+	res, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("notification of order %s responded %d", notif.OrderID, res.StatusCode)
+	}
+	return nil
+}
+
+func grossAmount(amount int) string {
+	return strconv.Itoa(amount) + ".00"
+}
@@ -0,0 +1,82 @@
+package payment
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeGatewaySimulate(t *testing.T) {
+	tt := []struct {
+		Name       string
+		Status     string
+		StatusCode string
+	}{
+		{
+			Name:       "Paid",
+			Status:     StatusPaid,
+			StatusCode: "200",
+		},
+		{
+			Name:       "Expired",
+			Status:     StatusExpired,
+			StatusCode: "407",
+		},
+		{
+			Name:       "Failed",
+			Status:     StatusFailed,
+			StatusCode: "202",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			var received fakeNotification
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_ = json.NewDecoder(r.Body).Decode(&received)
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer server.Close()
+
+			gateway := NewFakeGateway(&FakeConfig{ServerKey: "secret"})
+			_, err := gateway.CreateTransaction(context.Background(), &TransactionRequest{
+				OrderID:         "order-1",
+				GrossAmount:     265000,
+				NotificationURL: server.URL,
+			})
+			assert.Nil(t, err)
+
+			err = gateway.Simulate(context.Background(), "order-1", tc.Status)
+			assert.Nil(t, err)
+
+			assert.Equal(t, tc.Status, received.TransactionStatus)
+			assert.Equal(t, tc.StatusCode, received.StatusCode)
+			assert.Equal(t, "265000.00", received.GrossAmount)
+			assert.Nil(t, gateway.VerifyNotification(&Notification{
+				OrderID:      received.OrderID,
+				StatusCode:   received.StatusCode,
+				GrossAmount:  received.GrossAmount,
+				SignatureKey: received.SignatureKey,
+			}))
+
+			status, err := gateway.GetStatus(context.Background(), "order-1")
+			assert.Nil(t, err)
+			assert.Equal(t, tc.Status, status.TransactionStatus)
+		})
+	}
+}
+
+func TestFakeGatewayVerifyNotification(t *testing.T) {
+	gateway := NewFakeGateway(&FakeConfig{ServerKey: "secret"})
+	err := gateway.VerifyNotification(&Notification{
+		OrderID:      "order-1",
+		StatusCode:   "200",
+		GrossAmount:  "265000.00",
+		SignatureKey: Signature("order-1", "200", "265000.00", "another-secret"),
+	})
+	assert.Equal(t, ErrInvalidSignature, err)
+}
@@ -0,0 +1,88 @@
+package payment
+
+import (
+	"context"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+const (
+	StatusPending  = "PENDING"
+	StatusPaid     = "PAID"
+	StatusExpired  = "EXPIRED"
+	StatusFailed   = "FAILED"
+	StatusRefunded = "REFUNDED"
+	// StatusRefundFailed is only reported through notifications of a rejected refund
+	StatusRefundFailed = "REFUND_FAILED"
+)
+
+var ErrInvalidSignature = errors.New("invalid notification signature")
+
+// PaymentGateway is the payment provider used to charge customers for their orders.
+type PaymentGateway interface {
+	CreateTransaction(ctx context.Context, req *TransactionRequest) (*Transaction, error)
+	GetStatus(ctx context.Context, orderID string) (*TransactionStatus, error)
+	Refund(ctx context.Context, req *RefundRequest) (*Refund, error)
+	VerifyNotification(notif *Notification) error
+}
+
+type (
+	TransactionRequest struct {
+		OrderID         string
+		GrossAmount     int
+		FinishURL       string
+		NotificationURL string
+	}
+
+	Transaction struct {
+		Code        string
+		Message     string
+		Token       string
+		RedirectURL string
+	}
+
+	TransactionStatus struct {
+		OrderID           string
+		TransactionID     string
+		TransactionStatus string
+		PaymentType       string
+		GrossAmount       string
+	}
+
+	RefundRequest struct {
+		OrderID   string
+		RefundKey string
+		Amount    int
+		Reason    string
+	}
+
+	Refund struct {
+		RefundKey string
+		Status    string
+	}
+
+	Notification struct {
+		OrderID      string
+		StatusCode   string
+		GrossAmount  string
+		SignatureKey string
+	}
+)
+
+// Signature computes the signature key attached to every notification:
+// SHA-512 of order_id + status_code + gross_amount + server key.
+func Signature(orderID, statusCode, grossAmount, serverKey string) string {
+	hash := sha512.Sum512([]byte(orderID + statusCode + grossAmount + serverKey))
+	return hex.EncodeToString(hash[:])
+}
+
+func verifySignature(notif *Notification, serverKey string) error {
+	signature := Signature(notif.OrderID, notif.StatusCode, notif.GrossAmount, serverKey)
+	if subtle.ConstantTimeCompare([]byte(signature), []byte(strings.ToLower(notif.SignatureKey))) != 1 {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+type Config struct {
+	BaseURL   string
+	Token     string
+	ServerKey string
+	Timeout   time.Duration
+}
+
+type httpGateway struct {
+	client    *http.Client
+	baseURL   string
+	token     string
+	serverKey string
+}
+
+type (
+	transactionPayload struct {
+		TransactionDetail transactionDetail `json:"transaction_detail"`
+		Redirect          redirect          `json:"redirect"`
+	}
+
+	transactionDetail struct {
+		OrderID     string `json:"order_id"`
+		GrossAmount int    `json:"gross_amount"`
+	}
+
+	redirect struct {
+		FinishURL       string `json:"finish_url"`
+		NotificationURL string `json:"payment_notification_url"`
+	}
+
+	transactionResponse struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+		Data    struct {
+			Token       string `json:"token"`
+			RedirectURL string `json:"redirect_url"`
+		} `json:"data"`
+	}
+
+	statusResponse struct {
+		OrderID           string `json:"order_id"`
+		TransactionID     string `json:"transaction_id"`
+		TransactionStatus string `json:"transaction_status"`
+		PaymentType       string `json:"payment_type"`
+		GrossAmount       string `json:"gross_amount"`
+	}
+
+	refundPayload struct {
+		RefundKey string `json:"refund_key"`
+		Amount    int    `json:"amount"`
+		Reason    string `json:"reason"`
+	}
+
+	refundResponse struct {
+		RefundKey string `json:"refund_key"`
+		Status    string `json:"status"`
+	}
+)
+
+// NewHTTPGateway returns the gateway talking to the payment provider over http.
+func NewHTTPGateway(cfg *Config) PaymentGateway {
+	return &httpGateway{
+		client:    &http.Client{Timeout: cfg.Timeout},
+		baseURL:   cfg.BaseURL,
+		token:     cfg.Token,
+		serverKey: cfg.ServerKey,
+	}
+}
+
+func (g *httpGateway) CreateTransaction(ctx context.Context, req *TransactionRequest) (*Transaction, error) {
+	var res transactionResponse
+	err := g.do(ctx, http.MethodPost, "/transactions", transactionPayload{
+		TransactionDetail: transactionDetail{
+			OrderID:     req.OrderID,
+			GrossAmount: req.GrossAmount,
+		},
+		Redirect: redirect{
+			FinishURL:       req.FinishURL,
+			NotificationURL: req.NotificationURL,
+		},
+	}, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Transaction{
+		Code:        res.Code,
+		Message:     res.Message,
+		Token:       res.Data.Token,
+		RedirectURL: res.Data.RedirectURL,
+	}, nil
+}
+
+func (g *httpGateway) GetStatus(ctx context.Context, orderID string) (*TransactionStatus, error) {
+	var res statusResponse
+	err := g.do(ctx, http.MethodGet, fmt.Sprintf("/transactions/%s/status", orderID), nil, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TransactionStatus{
+		OrderID:           res.OrderID,
+		TransactionID:     res.TransactionID,
+		TransactionStatus: res.TransactionStatus,
+		PaymentType:       res.PaymentType,
+		GrossAmount:       res.GrossAmount,
+	}, nil
+}
+
+func (g *httpGateway) Refund(ctx context.Context, req *RefundRequest) (*Refund, error) {
+	var res refundResponse
+	err := g.do(ctx, http.MethodPost, fmt.Sprintf("/transactions/%s/refund", req.OrderID), refundPayload{
+		RefundKey: req.RefundKey,
+		Amount:    req.Amount,
+		Reason:    req.Reason,
+	}, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Refund{
+		RefundKey: res.RefundKey,
+		Status:    res.Status,
+	}, nil
+}
+
+func (g *httpGateway) VerifyNotification(notif *Notification) error {
+	return verifySignature(notif, g.serverKey)
+}
+
+func (g *httpGateway) do(ctx context.Context, method, path string, payload, result interface{}) error {
+	var body *bytes.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	} else {
+		body = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, g.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Add("Content-Type", "application/json; charset=UTF-8")
+	req.Header.Add("token", g.token)
+
+	res, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	resData, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("payment gateway responded %d: %s", res.StatusCode, string(resData))
+	}
+
+	return json.Unmarshal(resData, result)
+}
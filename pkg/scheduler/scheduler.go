@@ -0,0 +1,29 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Every runs job each interval until ctx is cancelled. Errors returned by job
+// are logged and do not stop the schedule.
+func Every(ctx context.Context, name string, interval time.Duration, job func(ctx context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.Info().Msg(fmt.Sprintf("starting %s every %s", name, interval))
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info().Msg(fmt.Sprintf("stopping %s", name))
+			return
+		case <-ticker.C:
+			if err := job(ctx); err != nil {
+				log.Error().Err(fmt.Errorf("error when running %s: %w", name, err)).Send()
+			}
+		}
+	}
+}
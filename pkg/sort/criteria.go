@@ -7,3 +7,10 @@ const (
 	NameAsc       = "name_a-z"
 	NameDesc      = "name_z-a"
 )
+
+// review sorts
+const (
+	Newest  = "newest"
+	Highest = "highest"
+	Lowest  = "lowest"
+)
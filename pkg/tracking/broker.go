@@ -0,0 +1,70 @@
+package tracking
+
+import (
+	"sync"
+	"time"
+)
+
+const subscriberBuffer = 8
+
+// Update is published to the subscribers of an order whenever the mechanic moves or the order status changes
+type Update struct {
+	Status      string
+	Latitude    float64
+	Longitude   float64
+	HasPosition bool
+	RecordedAt  time.Time
+}
+
+// Broker fans out updates of an order to the customers tracking it within this instance
+type Broker struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan Update]struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{
+		subscribers: make(map[string]map[chan Update]struct{}),
+	}
+}
+
+// Subscribe returns the updates of the order and a function to stop receiving them,
+// the channel is closed once unsubscribed.
+func (b *Broker) Subscribe(orderID string) (<-chan Update, func()) {
+	ch := make(chan Update, subscriberBuffer)
+
+	b.mu.Lock()
+	if b.subscribers[orderID] == nil {
+		b.subscribers[orderID] = make(map[chan Update]struct{})
+	}
+	b.subscribers[orderID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers[orderID], ch)
+			if len(b.subscribers[orderID]) == 0 {
+				delete(b.subscribers, orderID)
+			}
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+	return ch, unsubscribe
+}
+
+// Publish never blocks, a subscriber which does not keep up misses the update
+// since the next position supersedes it anyway.
+func (b *Broker) Publish(orderID string, update Update) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers[orderID] {
+		select {
+		case ch <- update:
+		default:
+		}
+	}
+}
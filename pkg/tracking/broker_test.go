@@ -0,0 +1,43 @@
+package tracking
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker(t *testing.T) {
+	broker := NewBroker()
+
+	first, unsubscribeFirst := broker.Subscribe("order-1")
+	second, unsubscribeSecond := broker.Subscribe("order-1")
+	other, unsubscribeOther := broker.Subscribe("order-2")
+	defer unsubscribeOther()
+
+	broker.Publish("order-1", Update{Latitude: -6.2, Longitude: 106.8, HasPosition: true})
+	assert.Equal(t, -6.2, (<-first).Latitude)
+	assert.Equal(t, -6.2, (<-second).Latitude)
+	assert.Len(t, other, 0)
+
+	unsubscribeFirst()
+	unsubscribeFirst()
+	_, ok := <-first
+	assert.False(t, ok)
+
+	broker.Publish("order-1", Update{Status: "Arrived"})
+	assert.Equal(t, "Arrived", (<-second).Status)
+
+	unsubscribeSecond()
+	assert.NotContains(t, broker.subscribers, "order-1")
+}
+
+func TestBrokerPublishDoesNotBlock(t *testing.T) {
+	broker := NewBroker()
+	updates, unsubscribe := broker.Subscribe("order-1")
+	defer unsubscribe()
+
+	for i := 0; i < subscriberBuffer+5; i++ {
+		broker.Publish("order-1", Update{})
+	}
+	assert.Len(t, updates, subscriberBuffer)
+}
@@ -6,10 +6,50 @@ import (
 	"fmt"
 	"net"
 	"strings"
+	"sync"
 	"time"
 	"unicode"
 )
 
+var (
+	bannedWordsMu sync.RWMutex
+	bannedWords   = map[string]bool{}
+)
+
+// SetBannedWords replaces the words which are not allowed in user written text, matching ignores case
+func SetBannedWords(words []string) {
+	banned := make(map[string]bool, len(words))
+	for _, v := range words {
+		word := strings.ToLower(strings.TrimSpace(v))
+		if word != "" {
+			banned[word] = true
+		}
+	}
+
+	bannedWordsMu.Lock()
+	bannedWords = banned
+	bannedWordsMu.Unlock()
+}
+
+// ContainsBannedWord reports whether any word of the text is banned
+func ContainsBannedWord(text string) bool {
+	bannedWordsMu.RLock()
+	defer bannedWordsMu.RUnlock()
+	if len(bannedWords) == 0 {
+		return false
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	for _, v := range words {
+		if bannedWords[v] {
+			return true
+		}
+	}
+	return false
+}
+
 func ValidateName(name string) error {
 	if strings.TrimSpace(name) == "" {
 		return fmt.Errorf("name cannot be empty")
@@ -229,6 +269,32 @@ func ValidateFeedback(feedback string) error {
 		if len(feedback) < 10 || len(feedback) > 300 {
 			return fmt.Errorf("feedback must be between 10 and 300 characters")
 		}
+		if ContainsBannedWord(feedback) {
+			return fmt.Errorf("feedback contains inappropriate words")
+		}
+	}
+	return nil
+}
+
+func ValidateReviewReply(reply string) error {
+	if strings.TrimSpace(reply) == "" {
+		return fmt.Errorf("reply cannot be empty")
+	}
+	if len(reply) > 500 {
+		return fmt.Errorf("reply cannot exceed 500 characters")
+	}
+	if ContainsBannedWord(reply) {
+		return fmt.Errorf("reply contains inappropriate words")
+	}
+	return nil
+}
+
+func ValidateModerationReason(reason string) error {
+	if strings.TrimSpace(reason) == "" {
+		return fmt.Errorf("reason cannot be empty")
+	}
+	if len(reason) > 256 {
+		return fmt.Errorf("reason cannot exceed 256 characters")
 	}
 	return nil
 }
@@ -287,3 +353,182 @@ func ValidateCategory(category string) error {
 	}
 	return nil
 }
+
+func ValidateInvoiceID(invoiceID string) error {
+	if strings.TrimSpace(invoiceID) == "" {
+		return fmt.Errorf("invoice_id cannot be empty")
+	}
+	return nil
+}
+
+// ValidateOrderStatus accepts the statuses an order is moved to by hand. An order is put on process by
+// its payment, cancelled through the cancellation and refunded through the refund flow, those go
+// along with work a bare status change would skip.
+func ValidateOrderStatus(status string) error {
+	statusVal := map[string]bool{
+		"on the way": true,
+		"arrived":    true,
+		"done":       true,
+	}
+
+	if strings.TrimSpace(status) == "" {
+		return fmt.Errorf("status cannot be empty")
+	}
+
+	if !statusVal[status] {
+		return fmt.Errorf("status must be on the way, arrived or done")
+	}
+	return nil
+}
+
+func ValidateRefundReason(reason string) error {
+	if len(reason) > 256 {
+		return fmt.Errorf("reason must be at most 256 characters")
+	}
+	return nil
+}
+
+func ValidateRefreshToken(token string) error {
+	if strings.TrimSpace(token) == "" {
+		return fmt.Errorf("refresh_token cannot be empty")
+	}
+	if len(token) > 128 {
+		return fmt.Errorf("refresh_token cannot exceed 128 characters")
+	}
+	return nil
+}
+
+func ValidatePasswordResetToken(token string) error {
+	if strings.TrimSpace(token) == "" {
+		return fmt.Errorf("token cannot be empty")
+	}
+	if len(token) > 128 {
+		return fmt.Errorf("token cannot exceed 128 characters")
+	}
+	return nil
+}
+
+func ValidateActivationToken(token string) error {
+	if strings.TrimSpace(token) == "" {
+		return fmt.Errorf("token cannot be empty")
+	}
+	if len(token) > 128 {
+		return fmt.Errorf("token cannot exceed 128 characters")
+	}
+	return nil
+}
+
+func ValidateDeviceToken(token string) error {
+	if strings.TrimSpace(token) == "" {
+		return fmt.Errorf("token cannot be empty")
+	}
+	if len(token) > 360 {
+		return fmt.Errorf("token cannot exceed 360 characters")
+	}
+	return nil
+}
+
+func ValidateDevicePlatform(platform string) error {
+	platformVal := map[string]bool{
+		"android": true,
+		"ios":     true,
+		"web":     true,
+	}
+
+	if !platformVal[platform] {
+		return fmt.Errorf("platform must be android, ios or web")
+	}
+	return nil
+}
+
+func ValidateAppVersion(version string) error {
+	if len(version) > 32 {
+		return fmt.Errorf("app_version cannot exceed 32 characters")
+	}
+	return nil
+}
+
+func ValidateNotificationTitle(title string) error {
+	if strings.TrimSpace(title) == "" {
+		return fmt.Errorf("title cannot be empty")
+	}
+	if len(title) > 128 {
+		return fmt.Errorf("title cannot exceed 128 characters")
+	}
+	return nil
+}
+
+func ValidateNotificationBody(body string) error {
+	if strings.TrimSpace(body) == "" {
+		return fmt.Errorf("body cannot be empty")
+	}
+	if len(body) > 512 {
+		return fmt.Errorf("body cannot exceed 512 characters")
+	}
+	return nil
+}
+
+// ValidateRedirect accepts an empty redirect or a path within the app
+func ValidateRedirect(redirect string) error {
+	if redirect == "" {
+		return nil
+	}
+	if !strings.HasPrefix(redirect, "/") || strings.HasPrefix(redirect, "//") {
+		return fmt.Errorf("redirect must be a path starting with /")
+	}
+	if len(redirect) > 256 {
+		return fmt.Errorf("redirect cannot exceed 256 characters")
+	}
+	return nil
+}
+
+func ValidateLatitude(latitude float64) error {
+	if latitude < -90 || latitude > 90 {
+		return fmt.Errorf("latitude must be between -90 and 90")
+	}
+	return nil
+}
+
+func ValidateLongitude(longitude float64) error {
+	if longitude < -180 || longitude > 180 {
+		return fmt.Errorf("longitude must be between -180 and 180")
+	}
+	return nil
+}
+
+func ValidateReviewSort(sort string) error {
+	sortVal := map[string]bool{
+		"newest":  true,
+		"highest": true,
+		"lowest":  true,
+	}
+
+	if len(sort) > 0 {
+		if !sortVal[sort] {
+			return fmt.Errorf("sort must be newest, highest or lowest")
+		}
+	}
+	return nil
+}
+
+// ValidateQuietHour checks a time of day written as HH:MM
+func ValidateQuietHour(clock string) error {
+	if _, err := time.Parse("15:04", clock); err != nil || len(clock) != 5 {
+		return fmt.Errorf("quiet hours must be a time of day in HH:MM format")
+	}
+	return nil
+}
+
+func ValidateTimezone(timezone string) error {
+	// Local and the empty name load the zone of the server, not one the user lives in
+	if timezone == "" || timezone == "Local" {
+		return fmt.Errorf("timezone cannot be empty")
+	}
+	if len(timezone) > 64 {
+		return fmt.Errorf("timezone cannot exceed 64 characters")
+	}
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return fmt.Errorf("timezone must be an IANA time zone like Asia/Jakarta")
+	}
+	return nil
+}
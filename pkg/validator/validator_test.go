@@ -0,0 +1,55 @@
+package validator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateFeedbackBannedWords(t *testing.T) {
+	SetBannedWords([]string{" Bodoh ", "scam", ""})
+	defer SetBannedWords(nil)
+
+	t.Run("banned word in any case", func(t *testing.T) {
+		assert.Error(t, ValidateFeedback("the mechanic was BODOH and slow"))
+	})
+
+	t.Run("banned word next to punctuation", func(t *testing.T) {
+		assert.Error(t, ValidateFeedback("this workshop is a scam!!"))
+	})
+
+	t.Run("banned word inside another word is allowed", func(t *testing.T) {
+		assert.NoError(t, ValidateFeedback("no scammers here, great service"))
+	})
+
+	t.Run("clean feedback", func(t *testing.T) {
+		assert.NoError(t, ValidateFeedback("fast and friendly mechanic"))
+	})
+}
+
+func TestValidateOrderStatus(t *testing.T) {
+	tt := []struct {
+		Name   string
+		Status string
+		Valid  bool
+	}{
+		{Name: "On the way", Status: "on the way", Valid: true},
+		{Name: "Arrived", Status: "arrived", Valid: true},
+		{Name: "Done", Status: "done", Valid: true},
+		{Name: "On process is set by the payment", Status: "on process"},
+		{Name: "Cancelled goes through the cancellation", Status: "cancelled"},
+		{Name: "Refunded goes through the refund", Status: "refunded"},
+		{Name: "Empty", Status: " "},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			err := ValidateOrderStatus(tc.Status)
+			if tc.Valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}